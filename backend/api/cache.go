@@ -0,0 +1,312 @@
+package api
+
+import (
+	"ariadne/openurl"
+	"ariadne/redis"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Set on a request to skip the response cache, for debugging.  The response is
+// still stored in the cache.
+const CacheBypassHeader = "X-Ariadne-Cache-Bypass"
+
+// Set on every response when the response cache is enabled: "hit", "miss", or
+// "bypass".
+const CacheStatusHeader = "X-Ariadne-Cache"
+
+const DefaultCacheSize = 1000
+const DefaultCacheTTL = time.Hour
+const DefaultCacheTimeout = 500 * time.Millisecond
+
+// Prepended to all keys stored in Redis, in case the server is shared with
+// other applications.
+const RedisCacheKeyPrefix = "ariadne:"
+
+const cacheStatusBypass = "bypass"
+const cacheStatusHit = "hit"
+const cacheStatusMiss = "miss"
+
+const backendDOI = "doi"
+const backendOpenAccess = "open_access"
+const backendPrimo = "primo"
+const backendSFX = "sfx"
+
+// Key namespaces
+const cacheKeyPrefixPrimo = "primo:"
+const cacheKeyPrefixResponse = "response:"
+const cacheKeyPrefixSFX = "sfx:"
+
+// These vary between otherwise identical requests, and don't affect resolution.
+var cacheKeyIgnoredParams = map[string]struct{}{
+	"ctx_tim": {},
+	"req.ip":  {},
+}
+
+// Cache stores serialized values.  Implementations must be safe for concurrent use.
+type Cache interface {
+	// Returns false if `key` is not in the cache or has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+type ResponseCacheOptions struct {
+	// Nil disables the response cache.
+	Cache Cache
+	// Also cache the raw SFX XML and Primo JSON response bodies, for replay by
+	// the debug commands.
+	CacheUpstreamResponses bool
+	// Deadline for each cache lookup and store, after which the cache is
+	// skipped, so that a slow cache can't hold up resolver requests.  Defaults
+	// to `DefaultCacheTimeout`.
+	Timeout time.Duration
+	TTL     time.Duration
+}
+
+// Cached upstream response bodies for a single OpenURL.  Empty if that
+// upstream was not queried or its response was not cached.
+type CachedUpstreamResponses struct {
+	// JSON array of the Primo API response bodies, in the same format as
+	// `ariadne debug primo-api-responses`.
+	Primo json.RawMessage `json:"primo,omitempty"`
+	SFX   string          `json:"sfx,omitempty"`
+}
+
+type cacheEntry struct {
+	// Which backend produced the response: "sfx", "primo", "doi", or
+	// "open_access"
+	Backend  string   `json:"backend"`
+	Response Response `json:"response"`
+}
+
+// MemoryCache is a least-recently-used cache whose entries also expire after
+// their TTL.  It is local to the process, so replicas do not share entries.
+type MemoryCache struct {
+	maxEntries int
+
+	entries map[string]*list.Element
+	// Most recently used at the front
+	recency *list.List
+	mutex   sync.Mutex
+
+	// Can be overridden in tests.
+	now func() time.Time
+}
+
+type memoryCacheItem struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// Stores values in a server that speaks the Redis protocol, so that it can be
+// shared by all replicas.
+type RedisCache struct {
+	client *redis.Client
+}
+
+// Returns the cached upstream response bodies for the OpenURL in `queryString`,
+// as resolved for the tenant named `tenantName`.
+func GetCachedUpstreamResponses(ctx context.Context, cache Cache, tenantName string, queryString string) (CachedUpstreamResponses, error) {
+	cachedUpstreamResponses := CachedUpstreamResponses{}
+
+	cacheKey := makeCacheKey(tenantName, queryString)
+
+	sfxXML, ok, err := cache.Get(ctx, cacheKeyPrefixSFX+cacheKey)
+	if err != nil {
+		return cachedUpstreamResponses, fmt.Errorf("Could not get cached SFX response: %v", err)
+	}
+	if ok {
+		cachedUpstreamResponses.SFX = string(sfxXML)
+	}
+
+	primoJSON, ok, err := cache.Get(ctx, cacheKeyPrefixPrimo+cacheKey)
+	if err != nil {
+		return cachedUpstreamResponses, fmt.Errorf("Could not get cached Primo responses: %v", err)
+	}
+	if ok {
+		cachedUpstreamResponses.Primo = primoJSON
+	}
+
+	return cachedUpstreamResponses, nil
+}
+
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		recency:    list.New(),
+		now:        time.Now,
+	}
+}
+
+// See `redis.NewClient` for the `address` format.
+func NewRedisCache(address string) (*RedisCache, error) {
+	client, err := redis.NewClient(address, redis.ClientOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RedisCache{client}, nil
+}
+
+func (cache *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	item := element.Value.(*memoryCacheItem)
+	if !cache.now().Before(item.expiresAt) {
+		cache.remove(element)
+		return nil, false, nil
+	}
+
+	cache.recency.MoveToFront(element)
+
+	return item.value, true, nil
+}
+
+func (cache *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	expiresAt := cache.now().Add(ttl)
+
+	element, ok := cache.entries[key]
+	if ok {
+		item := element.Value.(*memoryCacheItem)
+		item.value = value
+		item.expiresAt = expiresAt
+		cache.recency.MoveToFront(element)
+		return nil
+	}
+
+	cache.entries[key] = cache.recency.PushFront(&memoryCacheItem{key, value, expiresAt})
+
+	for cache.recency.Len() > cache.maxEntries {
+		cache.remove(cache.recency.Back())
+	}
+
+	return nil
+}
+
+// Caller must hold the mutex.
+func (cache *MemoryCache) remove(element *list.Element) {
+	cache.recency.Remove(element)
+	delete(cache.entries, element.Value.(*memoryCacheItem).key)
+}
+
+func (cache *RedisCache) Close() error {
+	return cache.client.Close()
+}
+
+func (cache *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return cache.client.Get(ctx, RedisCacheKeyPrefix+key)
+}
+
+func (cache *RedisCache) Ping(ctx context.Context) error {
+	return cache.client.Ping(ctx)
+}
+
+func (cache *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return cache.client.Set(ctx, RedisCacheKeyPrefix+key, value, ttl)
+}
+
+// Cache errors are logged and otherwise treated as misses, since the cache
+// should never block the user request.
+func (server *Server) getCachedResponse(ctx context.Context, queryString string, cacheKey string) (cacheEntry, bool) {
+	entry := cacheEntry{}
+	logEntryFields := server.getSharedLogEntryFields(getRequestID(ctx), queryString)
+
+	cacheCtx, cancel := context.WithTimeout(ctx, server.responseCache.Timeout)
+	defer cancel()
+
+	entryJSON, ok, err := server.responseCache.Cache.Get(cacheCtx, cacheKeyPrefixResponse+cacheKey)
+	if err != nil {
+		server.logger.Warn(MessageKey, fmt.Sprintf("Could not get cached response: %v", err),
+			AriadneKey, logEntryFields)
+		return entry, false
+	}
+	if !ok {
+		return entry, false
+	}
+
+	err = json.Unmarshal(entryJSON, &entry)
+	if err != nil {
+		server.logger.Warn(MessageKey, fmt.Sprintf("Could not unmarshal cached response: %v", err),
+			AriadneKey, logEntryFields)
+		return entry, false
+	}
+
+	return entry, true
+}
+
+// Returns the canonical OpenURL 1.0 KEV form of `queryString`, minus params
+// that don't affect resolution, so that equivalent OpenURLs share a cache entry
+// regardless of param order, OpenURL version, or param name case.
+// The key is prefixed with the tenant name, since the same OpenURL can resolve
+// differently for different tenants.
+func makeCacheKey(tenantName string, queryString string) string {
+	// Requests for which `openurl.Parse` returns an error are rejected before
+	// they can be cached.
+	contextObject, _ := openurl.Parse(queryString)
+
+	kev := contextObject.KEV()
+	for paramName := range kev {
+		if _, ok := cacheKeyIgnoredParams[strings.ToLower(paramName)]; ok {
+			kev.Del(paramName)
+		}
+	}
+
+	return tenantName + ":" + kev.Encode()
+}
+
+func (server *Server) setCachedResponse(ctx context.Context, queryString string, cacheKey string, resolution resolution) {
+	options := server.responseCache
+	logEntryFields := server.getSharedLogEntryFields(getRequestID(ctx), queryString)
+	setCacheValue := func(key string, value []byte) {
+		cacheCtx, cancel := context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+
+		err := options.Cache.Set(cacheCtx, key, value, options.TTL)
+		if err != nil {
+			server.logger.Warn(MessageKey, fmt.Sprintf("Could not set cached value: %v", err),
+				AriadneKey, logEntryFields)
+		}
+	}
+
+	entryJSON, err := json.Marshal(cacheEntry{resolution.backend, resolution.response})
+	if err != nil {
+		server.logger.Warn(MessageKey, fmt.Sprintf("Could not marshal response for cache: %v", err),
+			AriadneKey, logEntryFields)
+		return
+	}
+	setCacheValue(cacheKeyPrefixResponse+cacheKey, entryJSON)
+
+	if !options.CacheUpstreamResponses {
+		return
+	}
+
+	if resolution.sfxResponse != nil {
+		setCacheValue(cacheKeyPrefixSFX+cacheKey, []byte(resolution.sfxResponse.XML))
+	}
+
+	if resolution.primoResponse != nil {
+		primoJSON, err := json.Marshal(resolution.primoResponse.APIResponses)
+		if err != nil {
+			server.logger.Warn(MessageKey, fmt.Sprintf("Could not marshal Primo responses for cache: %v", err),
+				AriadneKey, logEntryFields)
+			return
+		}
+		setCacheValue(cacheKeyPrefixPrimo+cacheKey, primoJSON)
+	}
+}
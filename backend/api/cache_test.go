@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestMemoryCache(t *testing.T) {
+	now := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewMemoryCache(2)
+	cache.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	assertCached := func(key string, expected bool) {
+		t.Helper()
+		_, ok, _ := cache.Get(ctx, key)
+		if ok != expected {
+			t.Errorf("Get(\"%s\") returned ok=%t, expecting %t", key, ok, expected)
+		}
+	}
+
+	cache.Set(ctx, "a", []byte("a"), time.Minute)
+	cache.Set(ctx, "b", []byte("b"), time.Minute)
+
+	// Using "a" makes "b" the least recently used, so it is evicted by "c".
+	assertCached("a", true)
+	cache.Set(ctx, "c", []byte("c"), time.Minute)
+	assertCached("b", false)
+	assertCached("a", true)
+	assertCached("c", true)
+
+	value, _, _ := cache.Get(ctx, "a")
+	if string(value) != "a" {
+		t.Errorf("Get(\"a\") returned \"%s\", expecting \"a\"", value)
+	}
+
+	// Replacing an entry resets its TTL.
+	now = now.Add(30 * time.Second)
+	cache.Set(ctx, "c", []byte("c2"), time.Minute)
+	now = now.Add(30 * time.Second)
+	assertCached("a", false)
+	assertCached("c", true)
+
+	now = now.Add(30 * time.Second)
+	assertCached("c", false)
+
+	if len(cache.entries) != 0 || cache.recency.Len() != 0 {
+		t.Errorf("Expected expired entries to be removed, %d entries remain", len(cache.entries))
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cache, err := NewRedisCache(server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedisCache returned error: %s", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+
+	err = cache.Set(ctx, "key", []byte("value"), time.Minute)
+	if err != nil {
+		t.Fatalf("Set returned error: %s", err)
+	}
+
+	// Keys are namespaced on the server.
+	if !server.Exists(RedisCacheKeyPrefix + "key") {
+		t.Errorf("Expected key \"%s\" to exist on server", RedisCacheKeyPrefix+"key")
+	}
+
+	value, ok, err := cache.Get(ctx, "key")
+	if err != nil || !ok || string(value) != "value" {
+		t.Errorf("Get returned \"%s\", ok=%t, error '%v', expecting \"value\"", value, ok, err)
+	}
+
+	server.FastForward(time.Minute)
+	_, ok, _ = cache.Get(ctx, "key")
+	if ok {
+		t.Errorf("Get returned expired key")
+	}
+}
+
+func TestMakeCacheKey(t *testing.T) {
+	testCases := []struct {
+		name         string
+		queryString1 string
+		queryString2 string
+		expectedSame bool
+	}{
+		{
+			"Param order",
+			"issn=0028-792X&date=2002&volume=78",
+			"volume=78&date=2002&issn=0028-792X",
+			true,
+		},
+		{
+			"OpenURL 0.1 vs. 1.0",
+			"?sid=google&isbn=9780198129103&genre=book",
+			"url_ver=Z39.88-2004&rfr_id=google&rft.isbn=9780198129103&rft.genre=book",
+			true,
+		},
+		{
+			"`ctx_tim` and `req.ip` ignored",
+			"rft.jtitle=New+Yorker&ctx_tim=2021-10-22T12%3A29%3A27-04%3A00&req.ip=209.150.44.95",
+			"rft.jtitle=New+Yorker&REQ.IP=127.0.0.1",
+			true,
+		},
+		{
+			"Different citations",
+			"issn=0028-792X&date=2002",
+			"issn=0028-792X&date=2003",
+			false,
+		},
+		{
+			"Unrecognized params are significant",
+			"isbn=9780198129103&pid=1",
+			"isbn=9780198129103&pid=2",
+			false,
+		},
+		{
+			"Different second ISBNs",
+			"isbn=9780198129103&isbn=9780191732355",
+			"isbn=9780198129103&isbn=9780521532525",
+			false,
+		},
+		{
+			"Different ISBN URNs",
+			"isbn=9780198129103&rft_id=urn:ISBN:0191732354",
+			"isbn=9780198129103&rft_id=urn:ISBN:0521532523",
+			false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			key1 := makeCacheKey(DefaultTenantName, testCase.queryString1)
+			key2 := makeCacheKey(DefaultTenantName, testCase.queryString2)
+			if (key1 == key2) != testCase.expectedSame {
+				t.Errorf("Expected keys to be the same: %t\nkey 1: %s\nkey 2: %s",
+					testCase.expectedSame, key1, key2)
+			}
+		})
+	}
+
+	t.Run("Tenants are significant", func(t *testing.T) {
+		queryString := "isbn=9780198129103"
+		if makeCacheKey("nyu", queryString) == makeCacheKey("nyuad", queryString) {
+			t.Errorf("Expected keys for different tenants to differ")
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"ariadne/openurl"
+	"ariadne/sfx"
+)
+
+// Returns the normalized citation for the OpenURL in `queryString`, with
+// any gaps filled in from the context object attributes in the SFX response.
+// Values from the OpenURL take precedence over the SFX values, since they
+// reflect what the user actually requested.
+func (server *Server) makeCitationSupplemental(requestID string, queryString string, sfxResponse *sfx.SFXResponse) CitationSupplemental {
+	// See comment in `getSharedLogEntryFields` regarding `url.ParseQuery` errors,
+	// which apply here too.
+	contextObject, _ := openurl.Parse(queryString)
+
+	return server.makeCitationSupplementalForContextObject(requestID, queryString, contextObject, sfxResponse)
+}
+
+// Like `makeCitationSupplemental`, but for a context object which has been
+// enriched since it was parsed from `queryString`.  `queryString` only
+// identifies the request in log entries.
+func (server *Server) makeCitationSupplementalForContextObject(requestID string, queryString string, contextObject *openurl.ContextObject, sfxResponse *sfx.SFXResponse) CitationSupplemental {
+	citationSupplemental := newCitationSupplemental(contextObject)
+
+	contextObjectAttributes, err := sfxResponse.ContextObjectAttributes()
+	if err != nil {
+		// The SFX data is only used to enrich the citation, so this shouldn't
+		// block the user request.
+		server.logger.Warn(MessageKey, err.Error(), AriadneKey, server.getSharedLogEntryFields(requestID, queryString))
+	}
+
+	return citationSupplemental.merge(newCitationSupplemental(openurl.NewContextObject(contextObjectAttributes)))
+}
+
+func newCitationSupplemental(contextObject *openurl.ContextObject) CitationSupplemental {
+	referent := contextObject.Referent
+
+	title := referent.JTitle
+	if title == "" {
+		title = referent.BTitle
+	}
+	if title == "" {
+		title = referent.Title
+	}
+
+	return CitationSupplemental{
+		Genre:        referent.Genre,
+		Title:        title,
+		ArticleTitle: referent.ATitle,
+		Authors:      referent.Authors(),
+		ISSN:         referent.ISSN,
+		EISSN:        referent.EISSN,
+		ISBN:         referent.ISBN,
+		DOI:          referent.DOI,
+		PMID:         referent.PMID,
+		Volume:       referent.Volume,
+		Issue:        referent.Issue,
+		StartPage:    referent.SPage,
+		EndPage:      referent.EPage,
+		Pages:        referent.Pages,
+		Date:         referent.Date,
+	}
+}
+
+// Returns a copy of `citationSupplemental` with empty fields filled in with
+// the corresponding values from `other`.
+func (citationSupplemental CitationSupplemental) merge(other CitationSupplemental) CitationSupplemental {
+	merged := citationSupplemental
+
+	mergeField := func(field *string, otherValue string) {
+		if *field == "" {
+			*field = otherValue
+		}
+	}
+
+	mergeField(&merged.Genre, other.Genre)
+	mergeField(&merged.Title, other.Title)
+	mergeField(&merged.ArticleTitle, other.ArticleTitle)
+	mergeField(&merged.ISSN, other.ISSN)
+	mergeField(&merged.EISSN, other.EISSN)
+	mergeField(&merged.ISBN, other.ISBN)
+	mergeField(&merged.DOI, other.DOI)
+	mergeField(&merged.PMID, other.PMID)
+	mergeField(&merged.Volume, other.Volume)
+	mergeField(&merged.Issue, other.Issue)
+	mergeField(&merged.StartPage, other.StartPage)
+	mergeField(&merged.EndPage, other.EndPage)
+	mergeField(&merged.Pages, other.Pages)
+	mergeField(&merged.Date, other.Date)
+
+	if len(merged.Authors) == 0 {
+		merged.Authors = other.Authors
+	}
+
+	return merged
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"ariadne/openurl"
+	"ariadne/sfx"
+	"reflect"
+	"testing"
+)
+
+func TestNewCitationSupplemental(t *testing.T) {
+	testCases := []struct {
+		name        string
+		queryString string
+		expected    CitationSupplemental
+	}{
+		{
+			"Journal title preferred",
+			"rft.jtitle=New+Yorker&rft.btitle=Book&rft.title=Title&rft.atitle=Article",
+			CitationSupplemental{Title: "New Yorker", ArticleTitle: "Article", Authors: []string{}},
+		},
+		{
+			"Book title preferred to title",
+			"rft.btitle=Book&rft.title=Title",
+			CitationSupplemental{Title: "Book", Authors: []string{}},
+		},
+		{
+			"Title",
+			"title=Title",
+			CitationSupplemental{Title: "Title", Authors: []string{}},
+		},
+		{
+			"Identifiers and position",
+			"rft.genre=article&rft.issn=0028-792X&rft.eissn=2163-3827&rft.doi=10.1000%2F1&rft.pmid=123" +
+				"&rft.volume=78&rft.issue=3&rft.spage=1&rft.epage=5&rft.pages=1-5&rft.date=2002",
+			CitationSupplemental{
+				Genre:     "article",
+				Authors:   []string{},
+				ISSN:      "0028-792X",
+				EISSN:     "2163-3827",
+				DOI:       "10.1000/1",
+				PMID:      "123",
+				Volume:    "78",
+				Issue:     "3",
+				StartPage: "1",
+				EndPage:   "5",
+				Pages:     "1-5",
+				Date:      "2002",
+			},
+		},
+		{
+			"Authors",
+			"rft.au=Ross,&rft.au=Thurber",
+			CitationSupplemental{Authors: []string{"Ross", "Thurber"}},
+		},
+		{
+			"Empty",
+			"",
+			CitationSupplemental{Authors: []string{}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			contextObject, err := openurl.Parse(testCase.queryString)
+			if err != nil {
+				t.Fatalf("openurl.Parse returned error: %s", err)
+			}
+
+			got := newCitationSupplemental(contextObject)
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Errorf("newCitationSupplemental returned %+v, expecting %+v", got, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestCitationSupplementalMerge(t *testing.T) {
+	testCases := []struct {
+		name     string
+		citation CitationSupplemental
+		other    CitationSupplemental
+		expected CitationSupplemental
+	}{
+		{
+			"Conflicting values",
+			CitationSupplemental{Title: "New Yorker", ISSN: "0028-792X", Authors: []string{"Ross"}},
+			CitationSupplemental{Title: "The New Yorker", ISSN: "9999-9999", Authors: []string{"Thurber"}},
+			CitationSupplemental{Title: "New Yorker", ISSN: "0028-792X", Authors: []string{"Ross"}},
+		},
+		{
+			"Missing fields filled in",
+			CitationSupplemental{Title: "New Yorker", Authors: []string{}},
+			CitationSupplemental{Volume: "78", Date: "2002", Authors: []string{"Ross"}},
+			CitationSupplemental{Title: "New Yorker", Volume: "78", Date: "2002", Authors: []string{"Ross"}},
+		},
+		{
+			"Empty values don't replace values",
+			CitationSupplemental{Title: "New Yorker", Volume: "78", Authors: []string{"Ross"}},
+			CitationSupplemental{Title: "", Volume: "", Authors: []string{}},
+			CitationSupplemental{Title: "New Yorker", Volume: "78", Authors: []string{"Ross"}},
+		},
+		{
+			"Nothing to merge",
+			CitationSupplemental{Genre: "book", ISBN: "9780198129103"},
+			CitationSupplemental{},
+			CitationSupplemental{Genre: "book", ISBN: "9780198129103"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			original := testCase.citation
+			got := testCase.citation.merge(testCase.other)
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Errorf("merge returned %+v, expecting %+v", got, testCase.expected)
+			}
+			if !reflect.DeepEqual(testCase.citation, original) {
+				t.Errorf("merge changed the citation to %+v", testCase.citation)
+			}
+		})
+	}
+}
+
+// OpenURL values take precedence over the SFX context object attributes, which
+// only fill in gaps.  Primo responses aren't used for the citation, so Primo
+// fallbacks, which have no SFX response, get the OpenURL's citation as is.
+func TestMakeCitationSupplemental(t *testing.T) {
+	testCases := []struct {
+		name        string
+		queryString string
+		sfxResponse *sfx.SFXResponse
+		expected    CitationSupplemental
+	}{
+		{
+			"OpenURL values preferred to SFX values",
+			"rft.jtitle=New+Yorker&rft.issn=0028-792X&rft.date=2002",
+			makeSFXResponseWithAttributes(`<item key="rft.jtitle">The New Yorker</item>` +
+				`<item key="rft.issn">9999-9999</item><item key="rft.date">2003</item>`),
+			CitationSupplemental{Title: "New Yorker", Authors: []string{}, ISSN: "0028-792X", Date: "2002"},
+		},
+		{
+			"SFX values fill in missing OpenURL values",
+			"rft.jtitle=New+Yorker&rft.volume=",
+			makeSFXResponseWithAttributes(`<item key="rft.issn">0028-792X</item><item key="rft.volume">78</item>` +
+				`<item key="@rft.au"><array><item key="0">Ross,</item></array></item>`),
+			CitationSupplemental{Title: "New Yorker", Authors: []string{"Ross"}, ISSN: "0028-792X", Volume: "78"},
+		},
+		{
+			"SFX titles only used if the OpenURL has none",
+			"rft.atitle=Talk+of+the+Town",
+			makeSFXResponseWithAttributes(`<item key="rft.btitle">Book</item>`),
+			CitationSupplemental{Title: "Book", ArticleTitle: "Talk of the Town", Authors: []string{}},
+		},
+		{
+			"No SFX response",
+			"rft.jtitle=New+Yorker&rft.issn=0028-792X",
+			&sfx.SFXResponse{},
+			CitationSupplemental{Title: "New Yorker", Authors: []string{}, ISSN: "0028-792X"},
+		},
+		{
+			"Unparseable SFX attributes ignored",
+			"rft.jtitle=New+Yorker",
+			&sfx.SFXResponse{XMLResponseBody: sfx.XMLResponseBody{
+				ContextObject: &[]sfx.ContextObject{{SFXContextObjectAttrs: "<perldata"}},
+			}},
+			CitationSupplemental{Title: "New Yorker", Authors: []string{}},
+		},
+	}
+
+	server := newTestServer(t, "", "", Options{})
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := server.makeCitationSupplemental("test-request-id", testCase.queryString, testCase.sfxResponse)
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Errorf("makeCitationSupplemental returned %+v, expecting %+v", got, testCase.expected)
+			}
+		})
+	}
+}
+
+// Returns an SFX response whose context object attributes are the given
+// perldata hash items.
+func makeSFXResponseWithAttributes(items string) *sfx.SFXResponse {
+	return &sfx.SFXResponse{XMLResponseBody: sfx.XMLResponseBody{
+		ContextObject: &[]sfx.ContextObject{
+			{SFXContextObjectAttrs: "<perldata><hash>" + items + "</hash></perldata>"},
+		},
+	}}
+}
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"ariadne/sfx"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values for `Link.CoverageStatus`
+const (
+	CoverageStatusIn      = "in_coverage"
+	CoverageStatusOut     = "out_of_coverage"
+	CoverageStatusUnknown = "unknown"
+)
+
+// Query param which overrides the server default set by `Options.HideOutOfCoverage`.
+// Any value accepted by `strconv.ParseBool` is valid.
+const HideOutOfCoverageParam = "hide_out_of_coverage"
+
+var nonDigitsRegexp = regexp.MustCompile(`[^0-9]`)
+
+// The parts of the citation that can be compared against coverage limits.
+// Zero values mean that the citation did not specify that part.
+type citationPosition struct {
+	year   int
+	month  int
+	day    int
+	volume string
+	issue  string
+}
+
+// Returns `serverDefault` if `queryString` doesn't have a valid
+// `hide_out_of_coverage` param.
+func shouldHideOutOfCoverage(queryString string, serverDefault bool) bool {
+	// See comment in `getSharedLogEntryFields` regarding `url.ParseQuery` errors,
+	// which apply here too.
+	queryParams, _ := url.ParseQuery(queryString)
+	if !queryParams.Has(HideOutOfCoverageParam) {
+		return serverDefault
+	}
+
+	hide, err := strconv.ParseBool(queryParams.Get(HideOutOfCoverageParam))
+	if err != nil {
+		return serverDefault
+	}
+
+	return hide
+}
+
+// Sets the coverage status of each link, and sorts them so that in-coverage
+// links come first, followed by links with unknown coverage (e.g. Interlibrary
+// Loan), and then out-of-coverage links.  The order within each group is the
+// order SFX returned them in.  Embargoes are relative to `now`.
+func rankLinksByCoverage(links []Link, citationSupplemental CitationSupplemental, hideOutOfCoverage bool, now time.Time) []Link {
+	citation := newCitationPosition(citationSupplemental)
+
+	rankedLinks := []Link{}
+	for _, link := range links {
+		link.CoverageStatus = getCoverageStatus(link.Coverage, citation, now)
+		if hideOutOfCoverage && link.CoverageStatus == CoverageStatusOut {
+			continue
+		}
+		rankedLinks = append(rankedLinks, link)
+	}
+
+	rank := map[string]int{
+		CoverageStatusIn:      0,
+		CoverageStatusUnknown: 1,
+		CoverageStatusOut:     2,
+	}
+	sort.SliceStable(rankedLinks, func(i, j int) bool {
+		return rank[rankedLinks[i].CoverageStatus] < rank[rankedLinks[j].CoverageStatus]
+	})
+
+	return rankedLinks
+}
+
+// Dates have been seen as YYYY, YYYY-MM-DD, and YYYYMMDD.
+func newCitationPosition(citationSupplemental CitationSupplemental) citationPosition {
+	citation := citationPosition{
+		volume: strings.TrimSpace(citationSupplemental.Volume),
+		issue:  strings.TrimSpace(citationSupplemental.Issue),
+	}
+
+	digits := nonDigitsRegexp.ReplaceAllString(citationSupplemental.Date, "")
+	if len(digits) >= 4 {
+		citation.year = atoi(digits[0:4])
+	}
+	if len(digits) >= 6 {
+		citation.month = atoi(digits[4:6])
+	}
+	if len(digits) >= 8 {
+		citation.day = atoi(digits[6:8])
+	}
+
+	return citation
+}
+
+// A link is in coverage if any of its coverage ranges includes the citation,
+// and out of coverage only if all of them definitely exclude it.
+func getCoverageStatus(coverages []Coverage, citation citationPosition, now time.Time) string {
+	if len(coverages) == 0 {
+		return CoverageStatusUnknown
+	}
+
+	numOut := 0
+	for _, coverage := range coverages {
+		switch getCoverageRangeStatus(coverage, citation, now) {
+		case CoverageStatusIn:
+			return CoverageStatusIn
+		case CoverageStatusOut:
+			numOut++
+		}
+	}
+
+	if numOut == len(coverages) {
+		return CoverageStatusOut
+	}
+
+	return CoverageStatusUnknown
+}
+
+func getCoverageRangeStatus(coverage Coverage, citation citationPosition, now time.Time) string {
+	status := CoverageStatusIn
+
+	if coverage.From != nil {
+		comparison, ok := compareToCoverageLimit(citation, *coverage.From)
+		if !ok {
+			status = CoverageStatusUnknown
+		} else if comparison < 0 {
+			return CoverageStatusOut
+		}
+	}
+
+	if coverage.To != nil {
+		comparison, ok := compareToCoverageLimit(citation, *coverage.To)
+		if !ok {
+			status = CoverageStatusUnknown
+		} else if comparison > 0 {
+			return CoverageStatusOut
+		}
+	}
+
+	if coverage.Embargo != nil {
+		switch embargoStatus := getEmbargoStatus(*coverage.Embargo, citation, now); embargoStatus {
+		case CoverageStatusOut:
+			return CoverageStatusOut
+		case CoverageStatusUnknown:
+			status = CoverageStatusUnknown
+		}
+	}
+
+	return status
+}
+
+// Returns -1, 0, or 1 if the citation is before, at, or after the limit, as far
+// as can be told from the parts that both specify.  Dates are compared first,
+// then volume and issue if the dates are the same.  `ok` is false if the citation
+// and limit have nothing in common to compare.
+func compareToCoverageLimit(citation citationPosition, limit CoverageLimit) (comparison int, ok bool) {
+	if citation.year > 0 && limit.Year > 0 {
+		ok = true
+		if comparison = compareInts(citation.year, limit.Year); comparison != 0 {
+			return comparison, ok
+		}
+		if citation.month > 0 && limit.Month > 0 {
+			if comparison = compareInts(citation.month, limit.Month); comparison != 0 {
+				return comparison, ok
+			}
+			if citation.day > 0 && limit.Day > 0 {
+				if comparison = compareInts(citation.day, limit.Day); comparison != 0 {
+					return comparison, ok
+				}
+			}
+		}
+	}
+
+	citationVolume, citationVolumeErr := strconv.Atoi(citation.volume)
+	limitVolume, limitVolumeErr := strconv.Atoi(limit.Volume)
+	if citationVolumeErr != nil || limitVolumeErr != nil {
+		return 0, ok
+	}
+	ok = true
+	if comparison = compareInts(citationVolume, limitVolume); comparison != 0 {
+		return comparison, ok
+	}
+
+	citationIssue, citationIssueErr := strconv.Atoi(citation.issue)
+	limitIssue, limitIssueErr := strconv.Atoi(limit.Issue)
+	if citationIssueErr != nil || limitIssueErr != nil {
+		return 0, ok
+	}
+
+	return compareInts(citationIssue, limitIssue), ok
+}
+
+// Citations that only specify a year or month are given the benefit of the doubt:
+// they are in coverage if any part of that year or month is.
+func getEmbargoStatus(embargo Embargo, citation citationPosition, now time.Time) string {
+	days := embargo.Days
+	if days == 0 {
+		days = embargo.Years*365 + embargo.Months*30
+	}
+	if days == 0 {
+		return CoverageStatusIn
+	}
+
+	if citation.year == 0 {
+		return CoverageStatusUnknown
+	}
+
+	earliest := time.Date(citation.year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	latest := earliest.AddDate(1, 0, -1)
+	if citation.month > 0 {
+		earliest = time.Date(citation.year, time.Month(citation.month), 1, 0, 0, 0, 0, time.UTC)
+		latest = earliest.AddDate(0, 1, -1)
+		if citation.day > 0 {
+			earliest = time.Date(citation.year, time.Month(citation.month), citation.day, 0, 0, 0, 0, time.UTC)
+			latest = earliest
+		}
+	}
+
+	cutoff := now.UTC().AddDate(0, 0, -days)
+	switch embargo.Availability {
+	// The most recent `days` are not available
+	case "not_available":
+		if earliest.After(cutoff) {
+			return CoverageStatusOut
+		}
+	// Only the most recent `days` are available
+	case "available":
+		if latest.Before(cutoff) {
+			return CoverageStatusOut
+		}
+	default:
+		return CoverageStatusUnknown
+	}
+
+	return CoverageStatusIn
+}
+
+func makeCoverage(target sfx.Target) []Coverage {
+	coverages := []Coverage{}
+	if target.Coverage == nil {
+		return coverages
+	}
+
+	for _, sfxCoverage := range *target.Coverage {
+		coverage := Coverage{
+			From:              makeCoverageLimit(sfxCoverage.From),
+			To:                makeCoverageLimit(sfxCoverage.To),
+			Embargo:           makeEmbargo(sfxCoverage.Embargo),
+			Statements:        []string{},
+			EmbargoStatements: []string{},
+		}
+
+		if sfxCoverage.CoverageText != nil {
+			for _, coverageText := range *sfxCoverage.CoverageText {
+				if coverageText.ThresholdText != nil {
+					for _, thresholdText := range *coverageText.ThresholdText {
+						coverage.Statements = append(coverage.Statements, nonEmpty(thresholdText.CoverageStatement)...)
+					}
+				}
+				if coverageText.EmbargoText != nil {
+					for _, embargoText := range *coverageText.EmbargoText {
+						coverage.EmbargoStatements = append(coverage.EmbargoStatements,
+							nonEmpty([]string{embargoText.EmbargoStatement})...)
+					}
+				}
+			}
+		}
+
+		// Targets that have no coverage restrictions, like Interlibrary Loan, still
+		// get an empty <coverage> element.
+		if coverage.From == nil && coverage.To == nil && coverage.Embargo == nil &&
+			len(coverage.Statements) == 0 && len(coverage.EmbargoStatements) == 0 {
+			continue
+		}
+
+		coverages = append(coverages, coverage)
+	}
+
+	return coverages
+}
+
+// Only the first <from> or <to> is used.  SFX has never been seen to return more
+// than one per <coverage>.
+func makeCoverageLimit(fromTo *[]sfx.FromTo) *CoverageLimit {
+	if fromTo == nil || len(*fromTo) == 0 {
+		return nil
+	}
+
+	first := (*fromTo)[0]
+	coverageLimit := CoverageLimit{
+		Year:   atoi(first.Year),
+		Month:  atoi(first.Month),
+		Day:    atoi(first.Day),
+		Volume: strings.TrimSpace(first.Volume),
+		Issue:  strings.TrimSpace(first.Issue),
+	}
+	if coverageLimit == (CoverageLimit{}) {
+		return nil
+	}
+
+	return &coverageLimit
+}
+
+func makeEmbargo(sfxEmbargo *sfx.Embargo) *Embargo {
+	if sfxEmbargo == nil {
+		return nil
+	}
+
+	embargo := Embargo{
+		Availability: strings.TrimSpace(sfxEmbargo.Availability),
+		Years:        atoi(sfxEmbargo.Year),
+		Months:       atoi(sfxEmbargo.Month),
+		Days:         atoi(sfxEmbargo.Days),
+	}
+	if embargo == (Embargo{}) {
+		return nil
+	}
+
+	return &embargo
+}
+
+// Preserves the existing `coverage_text` behavior: the statements in the first
+// threshold text of the first coverage range.
+func makeCoverageText(target sfx.Target) string {
+	if target.Coverage == nil || len(*target.Coverage) == 0 {
+		return ""
+	}
+
+	firstCoverage := (*target.Coverage)[0]
+	if firstCoverage.CoverageText == nil || len(*firstCoverage.CoverageText) == 0 {
+		return ""
+	}
+
+	firstCoverageText := (*firstCoverage.CoverageText)[0]
+	if firstCoverageText.ThresholdText == nil || len(*firstCoverageText.ThresholdText) == 0 {
+		return ""
+	}
+
+	firstThresholdText := (*firstCoverageText.ThresholdText)[0]
+	return strings.Join(firstThresholdText.CoverageStatement, ". ")
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Unparseable or missing values are treated as unspecified.
+func atoi(value string) int {
+	number, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0
+	}
+
+	return number
+}
+
+func nonEmpty(values []string) []string {
+	nonEmptyValues := []string{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			nonEmptyValues = append(nonEmptyValues, value)
+		}
+	}
+
+	return nonEmptyValues
+}
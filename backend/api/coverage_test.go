@@ -0,0 +1,203 @@
+package api
+
+import (
+	"ariadne/sfx"
+	"reflect"
+	"testing"
+)
+
+func TestMakeCoverage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		coverage *[]sfx.Coverage
+		expected []Coverage
+	}{
+		{"No coverage", nil, []Coverage{}},
+		{
+			"Empty coverage",
+			&[]sfx.Coverage{
+				{
+					CoverageText: &[]sfx.CoverageText{{ThresholdText: &[]sfx.ThresholdText{{}}}},
+					Embargo:      &sfx.Embargo{},
+				},
+			},
+			[]Coverage{},
+		},
+		{
+			"Volume and issue range with embargo",
+			&[]sfx.Coverage{
+				{
+					CoverageText: &[]sfx.CoverageText{
+						{
+							ThresholdText: &[]sfx.ThresholdText{
+								{CoverageStatement: []string{"Available from 1990 volume: 1 issue: 1", "until 2000 volume: 11"}},
+							},
+							EmbargoText: &[]sfx.EmbargoStatement{
+								{EmbargoStatement: "Most recent 1 year(s) not available"},
+							},
+						},
+					},
+					From:    &[]sfx.FromTo{{Year: "1990", Volume: "1", Issue: "1"}},
+					To:      &[]sfx.FromTo{{Year: "2000", Volume: "11"}},
+					Embargo: &sfx.Embargo{Availability: "not_available", Year: "1", Days: "365"},
+				},
+			},
+			[]Coverage{
+				{
+					From:              &CoverageLimit{Year: 1990, Volume: "1", Issue: "1"},
+					To:                &CoverageLimit{Year: 2000, Volume: "11"},
+					Embargo:           &Embargo{Availability: "not_available", Years: 1, Days: 365},
+					Statements:        []string{"Available from 1990 volume: 1 issue: 1", "until 2000 volume: 11"},
+					EmbargoStatements: []string{"Most recent 1 year(s) not available"},
+				},
+			},
+		},
+		{
+			"Multiple coverage ranges",
+			&[]sfx.Coverage{
+				{
+					CoverageText: &[]sfx.CoverageText{
+						{ThresholdText: &[]sfx.ThresholdText{{CoverageStatement: []string{"Available from 1925 until 1990"}}}},
+					},
+					From: &[]sfx.FromTo{{Year: "1925"}},
+					To:   &[]sfx.FromTo{{Year: "1990"}},
+				},
+				{
+					CoverageText: &[]sfx.CoverageText{
+						{ThresholdText: &[]sfx.ThresholdText{{CoverageStatement: []string{"Available from 2000/03/15"}}}},
+					},
+					From: &[]sfx.FromTo{{Year: "2000", Month: "03", Day: "15"}},
+				},
+			},
+			[]Coverage{
+				{
+					From:              &CoverageLimit{Year: 1925},
+					To:                &CoverageLimit{Year: 1990},
+					Statements:        []string{"Available from 1925 until 1990"},
+					EmbargoStatements: []string{},
+				},
+				{
+					From:              &CoverageLimit{Year: 2000, Month: 3, Day: 15},
+					Statements:        []string{"Available from 2000/03/15"},
+					EmbargoStatements: []string{},
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := makeCoverage(sfx.Target{Coverage: testCase.coverage})
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Errorf("makeCoverage returned %+v, expecting %+v", got, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestGetCoverageStatus(t *testing.T) {
+	from1990To2000 := Coverage{From: &CoverageLimit{Year: 1990, Month: 3}, To: &CoverageLimit{Year: 2000}}
+	fromVolume10 := Coverage{From: &CoverageLimit{Volume: "10", Issue: "2"}}
+	mostRecentYearNotAvailable := Coverage{
+		From:    &CoverageLimit{Year: 1990},
+		Embargo: &Embargo{Availability: "not_available", Years: 1, Days: 365},
+	}
+	onlyMostRecent3MonthsAvailable := Coverage{Embargo: &Embargo{Availability: "available", Months: 3}}
+
+	testCases := []struct {
+		name      string
+		coverages []Coverage
+		citation  CitationSupplemental
+		expected  string
+	}{
+		{"No coverage", []Coverage{}, CitationSupplemental{Date: "1995"}, CoverageStatusUnknown},
+		{"No citation date", []Coverage{from1990To2000}, CitationSupplemental{}, CoverageStatusUnknown},
+		{"Year in range", []Coverage{from1990To2000}, CitationSupplemental{Date: "1995"}, CoverageStatusIn},
+		{"Year before range", []Coverage{from1990To2000}, CitationSupplemental{Date: "1989"}, CoverageStatusOut},
+		{"Year after range", []Coverage{from1990To2000}, CitationSupplemental{Date: "2001-01-01"}, CoverageStatusOut},
+		{"Month before start of range", []Coverage{from1990To2000}, CitationSupplemental{Date: "19900201"}, CoverageStatusOut},
+		{"Year-only citation in first year of range", []Coverage{from1990To2000}, CitationSupplemental{Date: "1990"}, CoverageStatusIn},
+		{"Volume in range", []Coverage{fromVolume10}, CitationSupplemental{Volume: "11"}, CoverageStatusIn},
+		{"Issue before range", []Coverage{fromVolume10}, CitationSupplemental{Volume: "10", Issue: "1"}, CoverageStatusOut},
+		{"Non-numeric volume", []Coverage{fromVolume10}, CitationSupplemental{Volume: "Spring"}, CoverageStatusUnknown},
+		{"Before embargo", []Coverage{mostRecentYearNotAvailable}, CitationSupplemental{Date: "2021"}, CoverageStatusIn},
+		{"During embargo", []Coverage{mostRecentYearNotAvailable}, CitationSupplemental{Date: "2022-12-01"}, CoverageStatusOut},
+		{"Within available window", []Coverage{onlyMostRecent3MonthsAvailable}, CitationSupplemental{Date: "2023-02"}, CoverageStatusIn},
+		{"Before available window", []Coverage{onlyMostRecent3MonthsAvailable}, CitationSupplemental{Date: "2022-11-15"}, CoverageStatusOut},
+		{
+			"In any range",
+			[]Coverage{from1990To2000, {From: &CoverageLimit{Year: 2010}}},
+			CitationSupplemental{Date: "2015"},
+			CoverageStatusIn,
+		},
+		{
+			"Out of one range, unknown for the other",
+			[]Coverage{from1990To2000, fromVolume10},
+			CitationSupplemental{Date: "2015"},
+			CoverageStatusUnknown,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := getCoverageStatus(testCase.coverages, newCitationPosition(testCase.citation), testNow)
+			if got != testCase.expected {
+				t.Errorf("getCoverageStatus returned '%s', expecting '%s'", got, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestRankLinksByCoverage(t *testing.T) {
+	links := []Link{
+		{DisplayName: "Out", Coverage: []Coverage{{From: &CoverageLimit{Year: 2010}}}},
+		{DisplayName: "Unknown"},
+		{DisplayName: "In 1", Coverage: []Coverage{{From: &CoverageLimit{Year: 1990}}}},
+		{DisplayName: "In 2", Coverage: []Coverage{{To: &CoverageLimit{Year: 2000}}}},
+	}
+	citationSupplemental := CitationSupplemental{Date: "1995"}
+
+	testCases := []struct {
+		name              string
+		hideOutOfCoverage bool
+		expected          []string
+	}{
+		{"Sorted", false, []string{"In 1", "In 2", "Unknown", "Out"}},
+		{"Out of coverage hidden", true, []string{"In 1", "In 2", "Unknown"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := []string{}
+			for _, link := range rankLinksByCoverage(links, citationSupplemental, testCase.hideOutOfCoverage, testNow) {
+				got = append(got, link.DisplayName)
+			}
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Errorf("rankLinksByCoverage returned links %v, expecting %v", got, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestShouldHideOutOfCoverage(t *testing.T) {
+	testCases := []struct {
+		name          string
+		serverDefault bool
+		queryString   string
+		expected      bool
+	}{
+		{"Server default", true, "issn=0028-792X", true},
+		{"Query param overrides server default", true, "issn=0028-792X&hide_out_of_coverage=false", false},
+		{"Query param enables hiding", false, "hide_out_of_coverage=1&issn=0028-792X", true},
+		{"Invalid query param value", false, "hide_out_of_coverage=maybe", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := shouldHideOutOfCoverage(testCase.queryString, testCase.serverDefault)
+			if got != testCase.expected {
+				t.Errorf("shouldHideOutOfCoverage returned %t, expecting %t", got, testCase.expected)
+			}
+		})
+	}
+}
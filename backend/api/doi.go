@@ -0,0 +1,133 @@
+package api
+
+import (
+	"ariadne/openurl"
+	"ariadne/sfx"
+	"ariadne/upstream"
+	"context"
+	"fmt"
+)
+
+// Display name of the last-resort link to the DOI resolver.
+const doiLinkDisplayName = "Publisher website (via DOI)"
+
+// Resolves an OpenURL with a DOI which neither SFX nor Primo found anything
+// for, which is common for DOI-only OpenURLs: SFX often can't match the DOI on
+// its own, and Primo can't be searched by DOI at all, only by an ISBN, ISSN,
+// OCLC number, LCCN, or title and author, none of which a DOI-only OpenURL has.
+// The DOI metadata is used to fill in the citation, and SFX is queried again
+// with the enriched citation.  If SFX still doesn't find anything, the response
+// has the DOI resolver link as a last resort.
+//
+// The lookup is only made once Primo has come up empty.  An OpenURL that Primo
+// can search already has the identifiers or title that the DOI metadata would
+// add, so a Primo match is used as is, without waiting on Crossref and a second
+// SFX request.
+//
+// Returns false if DOI lookups are disabled, the OpenURL has no DOI, or the DOI
+// lookup failed, in which case the caller falls back to the original SFX
+// response.  Lookup failures are logged but never fail the request.  If the DOI
+// lookup failed for any reason but the DOI not being found, the error is also
+// returned, so that the caller doesn't cache the fallback response.  A failed
+// SFX request with the DOI metadata instead makes the resolution partial.
+func (server *Server) resolveDOI(ctx context.Context, tenant *Tenant, requestID string, queryString string, sfxResponse *sfx.SFXResponse) (resolution, bool, error) {
+	if server.crossrefClient == nil {
+		return resolution{}, false, nil
+	}
+
+	// See comment in `getSharedLogEntryFields` regarding `url.ParseQuery` errors,
+	// which apply here too.
+	contextObject, _ := openurl.Parse(queryString)
+	doi := contextObject.Referent.DOI
+	if doi == "" {
+		return resolution{}, false, nil
+	}
+
+	work, err := server.crossrefClient.Lookup(ctx, doi)
+	if err != nil {
+		logEntryFields := server.makeDOILookupLogEntry(requestID, queryString, doi, "")
+		logMessage := server.redactor.redactText(fmt.Sprintf("DOI lookup failed: %v", err))
+		if upstream.IsNotFound(err) {
+			server.logger.Info(MessageKey, logMessage, AriadneKey, logEntryFields)
+			return resolution{}, false, nil
+		}
+		server.logger.Warn(MessageKey, logMessage, AriadneKey, logEntryFields)
+		return resolution{}, false, err
+	}
+
+	enrichedQueryString := ""
+	if work.Enrich(contextObject) {
+		enrichedQueryString = contextObject.KEV().Encode()
+	}
+	server.logger.Info(MessageKey, "DOI lookup",
+		AriadneKey, server.makeDOILookupLogEntry(requestID, queryString, doi, enrichedQueryString))
+
+	partial := false
+	if enrichedQueryString != "" {
+		enrichedSFXResponse, err := server.doEnrichedSFXRequest(ctx, tenant, requestID, queryString, enrichedQueryString)
+		if err != nil {
+			server.logger.Warn(MessageKey, server.redactor.redactText(fmt.Sprintf("SFX request for DOI metadata failed: %v", err)),
+				AriadneKey, server.getSharedLogEntryFields(requestID, queryString))
+			partial = true
+		} else {
+			sfxResponse = enrichedSFXResponse
+		}
+	}
+
+	citationSupplemental := server.makeCitationSupplementalForContextObject(requestID, queryString, contextObject, sfxResponse)
+	response := makeAriadneResponseFromSFXResponse(sfxResponse, citationSupplemental,
+		shouldHideOutOfCoverage(queryString, server.hideOutOfCoverage), server.now())
+	if sfxResponse.IsFound() {
+		return resolution{
+			backend:     backendSFX,
+			response:    response,
+			partial:     partial,
+			sfxResponse: sfxResponse,
+		}, true, nil
+	}
+
+	// The SFX "helper" links, like interlibrary loan, are still useful, so the
+	// DOI link is added after them.
+	record := &response.Records[0]
+	record.Links = append(record.Links, Link{
+		DisplayName:    doiLinkDisplayName,
+		Url:            work.URL(),
+		Coverage:       []Coverage{},
+		CoverageStatus: CoverageStatusUnknown,
+	})
+	response.Found = true
+
+	return resolution{
+		backend:     backendDOI,
+		response:    response,
+		partial:     partial,
+		sfxResponse: sfxResponse,
+	}, true, nil
+}
+
+// Queries SFX with the OpenURL in `enrichedQueryString`.  The request and
+// response are logged with the original `queryString`, like all the other
+// entries for the request.
+func (server *Server) doEnrichedSFXRequest(ctx context.Context, tenant *Tenant, requestID string, queryString string, enrichedQueryString string) (*sfx.SFXResponse, error) {
+	sfxRequest, err := tenant.SFXClient.NewRequest(removeAriadneParams(enrichedQueryString))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", invalidSFXRequestErrorMessage, err)
+	}
+
+	sfxAPIRequestLogEntry := server.makeNewSFXAPIRequestLogEntry(requestID, queryString, sfxRequest.DumpedHTTPRequest)
+	server.logger.Info(MessageKey, "SFX API Request with DOI metadata", AriadneKey, sfxAPIRequestLogEntry)
+
+	sfxResponse, err := tenant.SFXClient.Do(ctx, sfxRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if server.shouldLogDumpedResponses(requestID) {
+		sfxAPIResponseLogEntry := server.makeNewSFXAPIResponseLogEntry(requestID, queryString, sfxResponse.DumpedHTTPResponse)
+		server.logger.Debug(MessageKey, "SFX API Response with DOI metadata", AriadneKey, sfxAPIResponseLogEntry)
+	}
+
+	server.removeTargets(tenant, requestID, queryString, sfxResponse)
+
+	return sfxResponse, nil
+}
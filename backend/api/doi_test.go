@@ -0,0 +1,174 @@
+package api
+
+import (
+	"ariadne/crossref"
+	"ariadne/testutils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+const testDOI = "10.1000/the-new-yorker.2021.10.25"
+
+const testCrossrefWorkJSON = `{
+  "status": "ok",
+  "message-type": "work",
+  "message": {
+    "DOI": "10.1000/the-new-yorker.2021.10.25",
+    "type": "journal-article",
+    "title": ["The Talk of the Town"],
+    "container-title": ["The New Yorker"],
+    "ISSN": ["0028-792X"],
+    "volume": "97",
+    "issue": "34",
+    "issued": {"date-parts": [[2021, 10, 25]]}
+  }
+}`
+
+func TestDOIResolution(t *testing.T) {
+	t.Parallel()
+
+	foundTestCase := getTestCase(t, "the-new-yorker")
+	notFoundTestCase := getTestCase(t, "the-sino-tibetan-languages")
+
+	testCases := []struct {
+		name string
+		// Whether SFX finds anything for the enriched OpenURL
+		sfxFindsEnriched  bool
+		crossrefStatus    int
+		queryString       string
+		expectedFound     bool
+		expectedFirstLink string
+		// Whether the last link is the DOI link
+		expectedDOILink          bool
+		expectedCrossrefRequests int32
+		expectedSFXRequests      int32
+	}{
+		{"SFX finds enriched OpenURL", true, http.StatusOK, "rft_id=info:doi/" + testDOI,
+			true, "E Journal Full Text", false, 1, 2},
+		{"DOI link as last resort", false, http.StatusOK, "doi=" + testDOI,
+			true, "Bobst Library  Interlibrary Loan", true, 1, 2},
+		{"Unknown DOI", false, http.StatusNotFound, "rft_id=info:doi/" + testDOI,
+			false, "Bobst Library  Interlibrary Loan", false, 1, 1},
+		// Server errors are retried.
+		{"Crossref unavailable", false, http.StatusInternalServerError, "rft_id=info:doi/" + testDOI,
+			false, "Bobst Library  Interlibrary Loan", false, 3, 1},
+		{"No DOI", false, http.StatusOK, "title=The%20Sino-Tibetan%20Languages",
+			false, "Bobst Library  Interlibrary Loan", false, 0, 1},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var numSFXRequests atomic.Int32
+			fakeSFXServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					numSFXRequests.Add(1)
+					sfxTestCase := notFoundTestCase
+					if testCase.sfxFindsEnriched && r.URL.Query().Get("rft.issn") == "0028-792X" {
+						sfxTestCase = foundTestCase
+					}
+					sfxFakeResponse, err := testutils.GetSFXFakeResponse(sfxTestCase)
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					}
+
+					fmt.Fprint(w, sfxFakeResponse)
+				}),
+			)
+			defer fakeSFXServer.Close()
+
+			var numCrossrefRequests atomic.Int32
+			fakeCrossrefServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					numCrossrefRequests.Add(1)
+					if testCase.crossrefStatus != http.StatusOK {
+						http.Error(w, "Resource not found.", testCase.crossrefStatus)
+						return
+					}
+
+					fmt.Fprint(w, testCrossrefWorkJSON)
+				}),
+			)
+			defer fakeCrossrefServer.Close()
+			fakePrimoServer := newFakePrimoServer(notFoundTestCase)
+			defer fakePrimoServer.Close()
+
+			server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+				Crossref: crossref.NewClient(fakeCrossrefServer.URL, crossref.ClientOptions{
+					HTTPClient: crossref.NewHTTPClient(crossref.DefaultTimeout, nil),
+				}),
+			})
+
+			response := doResolverRequest(t, server, testCase.queryString)
+			var apiResponse Response
+			err := json.NewDecoder(response.Body).Decode(&apiResponse)
+			if err != nil {
+				t.Fatalf("Could not decode response: %s", err)
+			}
+
+			if apiResponse.Found != testCase.expectedFound {
+				t.Errorf("Expected found to be %t, got %t", testCase.expectedFound, apiResponse.Found)
+			}
+			links := apiResponse.Records[0].Links
+			if len(links) == 0 || links[0].DisplayName != testCase.expectedFirstLink {
+				t.Fatalf("Expected first link \"%s\", got %+v", testCase.expectedFirstLink, links)
+			}
+			lastLink := links[len(links)-1]
+			isDOILink := lastLink.DisplayName == doiLinkDisplayName && lastLink.Url == "https://doi.org/"+testDOI
+			if isDOILink != testCase.expectedDOILink {
+				t.Errorf("Expected last link to be the DOI link: %t, got %+v", testCase.expectedDOILink, lastLink)
+			}
+			if numCrossrefRequests.Load() != testCase.expectedCrossrefRequests {
+				t.Errorf("Expected %d Crossref requests, got %d", testCase.expectedCrossrefRequests, numCrossrefRequests.Load())
+			}
+			if numSFXRequests.Load() != testCase.expectedSFXRequests {
+				t.Errorf("Expected %d SFX requests, got %d", testCase.expectedSFXRequests, numSFXRequests.Load())
+			}
+		})
+	}
+}
+
+func TestDOIResolutionCitation(t *testing.T) {
+	t.Parallel()
+
+	fakeSFXServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sfxFakeResponse, _ := testutils.GetSFXFakeResponse(getTestCase(t, "the-sino-tibetan-languages"))
+			fmt.Fprint(w, sfxFakeResponse)
+		}),
+	)
+	defer fakeSFXServer.Close()
+	fakeCrossrefServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, testCrossrefWorkJSON)
+		}),
+	)
+	defer fakeCrossrefServer.Close()
+	fakePrimoServer := newFakePrimoServer(getTestCase(t, "the-sino-tibetan-languages"))
+	defer fakePrimoServer.Close()
+
+	server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+		Crossref: crossref.NewClient(fakeCrossrefServer.URL, crossref.ClientOptions{}),
+	})
+
+	response := doResolverRequest(t, server, "rft_id=info:doi/"+testDOI)
+	var apiResponse Response
+	err := json.NewDecoder(response.Body).Decode(&apiResponse)
+	if err != nil {
+		t.Fatalf("Could not decode response: %s", err)
+	}
+
+	citationSupplemental := apiResponse.Records[0].CitationSupplemental
+	if citationSupplemental.Title != "The New Yorker" || citationSupplemental.ArticleTitle != "The Talk of the Town" ||
+		citationSupplemental.ISSN != "0028-792X" || citationSupplemental.Date != "2021-10-25" ||
+		citationSupplemental.DOI != testDOI {
+		t.Errorf("Expected citation to be filled in from DOI metadata, got %+v", citationSupplemental)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"ariadne/primo"
+	"ariadne/resilience"
+	"ariadne/sfx"
+	"ariadne/upstream"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error codes returned in `Response.Errors`.  Those that correspond to upstream
+// error kinds have the same value as the kind.
+const (
+	// The OpenURL could not be parsed.  Retrying won't help.
+	ErrorCodeInvalidRequest = "invalid_request"
+	// One of the OpenURL's ISBNs is the wrong length or has the wrong check
+	// digit, and so was skipped.  An error if the request couldn't be resolved,
+	// otherwise a warning.
+	ErrorCodeInvalidISBN = "invalid_isbn"
+	// Something went wrong in Ariadne itself.
+	ErrorCodeInternal = "internal"
+	// The upstream service has been failing, and is not being contacted
+	// until it has had time to recover.  Retrying later might help.
+	ErrorCodeUpstreamUnavailable = "upstream_unavailable"
+	// The request path specified an institution that this deployment doesn't serve.
+	ErrorCodeUnknownTenant = "unknown_tenant"
+
+	ErrorCodeEmptyContextObject = string(sfx.ErrorKindEmptyContextObject)
+	ErrorCodeNetwork            = string(upstream.ErrorKindNetwork)
+	ErrorCodeParse              = string(upstream.ErrorKindParse)
+	ErrorCodeTimeout            = string(upstream.ErrorKindTimeout)
+	ErrorCodeUpstreamStatus     = string(upstream.ErrorKindUpstreamStatus)
+)
+
+// Values for `Error.Source`
+const (
+	ErrorSourceAriadne = "ariadne"
+	ErrorSourcePrimo   = "primo"
+	ErrorSourceRequest = "request"
+	ErrorSourceSFX     = "sfx"
+)
+
+type resolverError struct {
+	err            error
+	errors         []Error
+	httpStatusCode int
+}
+
+// Returns the `Response.Errors` entry and HTTP status code for an error returned
+// by the sfx or primo package.
+func newUpstreamError(source string, err error) (Error, int) {
+	apiError := Error{
+		Code:    ErrorCodeInternal,
+		Source:  source,
+		Message: err.Error(),
+	}
+
+	if errors.Is(err, resilience.ErrCircuitOpen) {
+		apiError.Code = ErrorCodeUpstreamUnavailable
+		return apiError, http.StatusServiceUnavailable
+	}
+
+	var upstreamError *upstream.Error
+	if !errors.As(err, &upstreamError) {
+		return apiError, http.StatusInternalServerError
+	}
+	apiError.Code = string(upstreamError.Kind)
+
+	if apiError.Code == ErrorCodeTimeout {
+		return apiError, http.StatusGatewayTimeout
+	}
+
+	return apiError, http.StatusBadGateway
+}
+
+// Returns an `ErrorCodeInvalidISBN` error for each of the invalid ISBNs in a
+// `primo.PrimoRequest`, with why it is invalid.
+func makeInvalidISBNErrors(invalidISBNs []string) []Error {
+	invalidISBNErrors := []Error{}
+	for _, isbn := range invalidISBNs {
+		_, err := primo.NormalizeISBN(isbn)
+		invalidISBNErrors = append(invalidISBNErrors,
+			Error{ErrorCodeInvalidISBN, ErrorSourceRequest, fmt.Sprintf("Skipped %v", err)})
+	}
+
+	return invalidISBNErrors
+}
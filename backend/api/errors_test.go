@@ -0,0 +1,93 @@
+package api
+
+import (
+	"ariadne/resilience"
+	"ariadne/sfx"
+	"ariadne/upstream"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestNewUpstreamError(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		source                 string
+		err                    error
+		expectedCode           string
+		expectedHTTPStatusCode int
+	}{
+		{
+			"Circuit breaker open",
+			ErrorSourceSFX,
+			&upstream.Error{Kind: upstream.ErrorKindNetwork, Err: fmt.Errorf("SFX %w", resilience.ErrCircuitOpen)},
+			ErrorCodeUpstreamUnavailable,
+			http.StatusServiceUnavailable,
+		},
+		{
+			"SFX timeout",
+			ErrorSourceSFX,
+			&upstream.Error{Kind: upstream.ErrorKindTimeout, Err: errors.New("timeout")},
+			ErrorCodeTimeout,
+			http.StatusGatewayTimeout,
+		},
+		{
+			"SFX upstream status",
+			ErrorSourceSFX,
+			&upstream.Error{Kind: upstream.ErrorKindUpstreamStatus, StatusCode: 500, Err: errors.New("500")},
+			ErrorCodeUpstreamStatus,
+			http.StatusBadGateway,
+		},
+		{
+			"SFX empty context object",
+			ErrorSourceSFX,
+			&upstream.Error{Kind: sfx.ErrorKindEmptyContextObject, Err: errors.New("empty")},
+			ErrorCodeEmptyContextObject,
+			http.StatusBadGateway,
+		},
+		{
+			"Wrapped Primo parse error",
+			ErrorSourcePrimo,
+			fmt.Errorf("wrapped: %w", &upstream.Error{Kind: upstream.ErrorKindParse, Err: errors.New("parse")}),
+			ErrorCodeParse,
+			http.StatusBadGateway,
+		},
+		{
+			"Primo network error",
+			ErrorSourcePrimo,
+			&upstream.Error{Kind: upstream.ErrorKindNetwork, Err: errors.New("connection refused")},
+			ErrorCodeNetwork,
+			http.StatusBadGateway,
+		},
+		{
+			"Unclassified error",
+			ErrorSourceSFX,
+			errors.New("unknown"),
+			ErrorCodeInternal,
+			http.StatusInternalServerError,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			apiError, httpStatusCode := newUpstreamError(testCase.source, testCase.err)
+			if apiError.Code != testCase.expectedCode {
+				t.Errorf("newUpstreamError returned code '%s', expecting '%s'",
+					apiError.Code, testCase.expectedCode)
+			}
+			if apiError.Source != testCase.source {
+				t.Errorf("newUpstreamError returned source '%s', expecting '%s'",
+					apiError.Source, testCase.source)
+			}
+			if apiError.Message != testCase.err.Error() {
+				t.Errorf("newUpstreamError returned message '%s', expecting '%s'",
+					apiError.Message, testCase.err.Error())
+			}
+			if httpStatusCode != testCase.expectedHTTPStatusCode {
+				t.Errorf("newUpstreamError returned HTTP status code %d, expecting %d",
+					httpStatusCode, testCase.expectedHTTPStatusCode)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"ariadne/log"
+	"ariadne/sfx"
 	"net/url"
 	"strings"
 )
@@ -25,6 +27,39 @@ type ariadneAPIResponseLogEntry struct {
 	APIResponse ariadneAPIResponse `json:"apiResponse"`
 }
 
+type cacheLogEntry struct {
+	sharedLogEntryFields
+	Cache cacheStatus `json:"cache"`
+}
+
+type cacheStatus struct {
+	Status  string `json:"status"`
+	Key     string `json:"key"`
+	Backend string `json:"backend,omitempty"`
+}
+
+// `EnrichedQueryString` is the OpenURL with the DOI metadata filled in, if the
+// lookup added anything.
+type doiLookup struct {
+	DOI                 string `json:"doi"`
+	EnrichedQueryString string `json:"enrichedQueryString,omitempty"`
+}
+
+type doiLookupLogEntry struct {
+	sharedLogEntryFields
+	DOILookup doiLookup `json:"doiLookup"`
+}
+
+type openAccessLookup struct {
+	DOI      string `json:"doi"`
+	NumLinks int    `json:"numLinks"`
+}
+
+type openAccessLookupLogEntry struct {
+	sharedLogEntryFields
+	OpenAccessLookup openAccessLookup `json:"openAccessLookup"`
+}
+
 type primoAPIFRBRMemberRequest struct {
 	Type                        string `json:"type"`
 	DumpedFRBRMemberHTTPRequest string `json:"dumpedFRBRMemberHTTPRequest"`
@@ -45,31 +80,41 @@ type primoAPIFRBRMemberResponseLogEntry struct {
 	APIResponse primoAPIFRBRMemberResponse `json:"apiResponse"`
 }
 
-type primoAPIISBNSearchRequest struct {
-	Type                        string `json:"type"`
-	DumpedISBNSearchHTTPRequest string `json:"dumpedISBNSearchHTTPRequest"`
+// `Strategy` is the Primo search strategy, e.g. "isbn".
+type primoAPISearchRequest struct {
+	Type                    string `json:"type"`
+	Strategy                string `json:"strategy"`
+	DumpedSearchHTTPRequest string `json:"dumpedSearchHTTPRequest"`
 }
 
-type primoAPIISBNSearchResponse struct {
-	Type                         string `json:"type"`
-	DumpedISBNSearchHTTPResponse string `json:"dumpedISBNSearchHTTPResponse"`
+type primoAPISearchResponse struct {
+	Type                     string `json:"type"`
+	Strategy                 string `json:"strategy"`
+	DumpedSearchHTTPResponse string `json:"dumpedSearchHTTPResponse"`
 }
 
-type primoAPIISBNSearchRequestLogEntry struct {
+type primoAPISearchRequestLogEntry struct {
 	sharedLogEntryFields
-	APIRequest primoAPIISBNSearchRequest `json:"apiRequest"`
+	APIRequest primoAPISearchRequest `json:"apiRequest"`
 }
 
-type primoAPIISBNSearchResponseLogEntry struct {
+type primoAPISearchResponseLogEntry struct {
 	sharedLogEntryFields
-	APIResponse primoAPIISBNSearchResponse `json:"apiResponse"`
+	APIResponse primoAPISearchResponse `json:"apiResponse"`
 }
 
+// `RequestID` correlates all the log entries for a single resolver request.
 type sharedLogEntryFields struct {
+	RequestID   string     `json:"requestId"`
 	QueryString string     `json:"queryString"`
 	QueryParams url.Values `json:"queryParams"`
 }
 
+type removedTargetLogEntry struct {
+	sharedLogEntryFields
+	Target *sfx.Target `json:"target"`
+}
+
 type sfxAPIRequest struct {
 	Type              string `json:"type"`
 	DumpedHTTPRequest string `json:"dumpedHTTPRequest"`
@@ -90,12 +135,22 @@ type sfxAPIResponseLogEntry struct {
 	APIResponse sfxAPIResponse `json:"apiResponse"`
 }
 
+// Types of dumped HTTP requests and responses, for `log.Dumps`
+const (
+	DumpTypePrimoRequest  = "primoRequest"
+	DumpTypePrimoResponse = "primoResponse"
+	DumpTypeSFXRequest    = "sfxRequest"
+	DumpTypeSFXResponse   = "sfxResponse"
+)
+
+var DumpTypes = []string{DumpTypePrimoRequest, DumpTypePrimoResponse, DumpTypeSFXRequest, DumpTypeSFXResponse}
+
 const AriadneKey = "ariadne"
 const MessageKey = "message"
 
 const prefixToTrim = "?"
 
-func getSharedLogEntryFields(queryString string) sharedLogEntryFields {
+func (server *Server) getSharedLogEntryFields(requestID string, queryString string) sharedLogEntryFields {
 	if strings.HasPrefix(queryString, prefixToTrim) {
 		queryString = strings.TrimPrefix(queryString, prefixToTrim)
 	}
@@ -109,25 +164,26 @@ func getSharedLogEntryFields(queryString string) sharedLogEntryFields {
 	params, _ := url.ParseQuery(queryString)
 
 	return sharedLogEntryFields{
-		QueryString: queryString,
-		QueryParams: params,
+		RequestID:   requestID,
+		QueryString: server.redactor.redactQueryString(queryString),
+		QueryParams: server.redactor.redactQueryParams(params),
 	}
 }
 
-func makeAriadneAPIErrorResponseLogEntry(queryString string, err error, httpStatusCode int, apiResponse Response) ariadneAPIErrorResponseLogEntry {
-	sharedLogEntryFields := getSharedLogEntryFields(queryString)
+func (server *Server) makeAriadneAPIErrorResponseLogEntry(requestID string, queryString string, err error, httpStatusCode int, apiResponse Response) ariadneAPIErrorResponseLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
 
 	return ariadneAPIErrorResponseLogEntry{
 		sharedLogEntryFields: sharedLogEntryFields,
 		Response: ariadneAPIErrorResponse{
 			Status: httpStatusCode,
-			Body:   apiResponse,
+			Body:   server.redactor.redactResponse(apiResponse),
 		},
 	}
 }
 
-func makeAriadneAPIResponseLogEntry(queryString string, apiResponse Response) ariadneAPIResponseLogEntry {
-	sharedLogEntryFields := getSharedLogEntryFields(queryString)
+func (server *Server) makeAriadneAPIResponseLogEntry(requestID string, queryString string, apiResponse Response) ariadneAPIResponseLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
 
 	return ariadneAPIResponseLogEntry{
 		sharedLogEntryFields: sharedLogEntryFields,
@@ -138,74 +194,134 @@ func makeAriadneAPIResponseLogEntry(queryString string, apiResponse Response) ar
 	}
 }
 
-func makePrimoAPIFRBRMemberRequestLogEntry(queryString string, dumpedHTTPRequest string) primoAPIFRBRMemberRequestLogEntry {
-	sharedLogEntryFields := getSharedLogEntryFields(queryString)
+func (server *Server) makeCacheLogEntry(requestID string, queryString string, status string, key string, backend string) cacheLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
+
+	return cacheLogEntry{
+		sharedLogEntryFields: sharedLogEntryFields,
+		Cache: cacheStatus{
+			Status:  status,
+			Key:     server.redactor.redactQueryString(key),
+			Backend: backend,
+		},
+	}
+}
+
+func (server *Server) makeDOILookupLogEntry(requestID string, queryString string, doi string, enrichedQueryString string) doiLookupLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
+
+	return doiLookupLogEntry{
+		sharedLogEntryFields: sharedLogEntryFields,
+		DOILookup: doiLookup{
+			DOI:                 doi,
+			EnrichedQueryString: server.redactor.redactQueryString(enrichedQueryString),
+		},
+	}
+}
+
+func (server *Server) makeOpenAccessLookupLogEntry(requestID string, queryString string, doi string, numLinks int) openAccessLookupLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
+
+	return openAccessLookupLogEntry{
+		sharedLogEntryFields: sharedLogEntryFields,
+		OpenAccessLookup: openAccessLookup{
+			DOI:      doi,
+			NumLinks: numLinks,
+		},
+	}
+}
+
+func (server *Server) makePrimoAPIFRBRMemberRequestLogEntry(requestID string, queryString string, dumpedHTTPRequest string) primoAPIFRBRMemberRequestLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
 
 	return primoAPIFRBRMemberRequestLogEntry{
 		sharedLogEntryFields: sharedLogEntryFields,
 		APIRequest: primoAPIFRBRMemberRequest{
 			Type:                        "primoRequest",
-			DumpedFRBRMemberHTTPRequest: dumpedHTTPRequest,
+			DumpedFRBRMemberHTTPRequest: server.prepareDump(DumpTypePrimoRequest, requestID, dumpedHTTPRequest),
 		},
 	}
 }
 
-func makePrimoAPIFRBRMemberResponseLogEntry(queryString string, dumpedHTTPResponse string) primoAPIFRBRMemberResponseLogEntry {
-	sharedLogEntryFields := getSharedLogEntryFields(queryString)
+func (server *Server) makePrimoAPIFRBRMemberResponseLogEntry(requestID string, queryString string, dumpedHTTPResponse string) primoAPIFRBRMemberResponseLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
 
 	return primoAPIFRBRMemberResponseLogEntry{
 		sharedLogEntryFields: sharedLogEntryFields,
 		APIResponse: primoAPIFRBRMemberResponse{
 			Type:                         "primoResponse",
-			DumpedFRBRMemberHTTPResponse: dumpedHTTPResponse,
+			DumpedFRBRMemberHTTPResponse: server.prepareDump(DumpTypePrimoResponse, requestID, dumpedHTTPResponse),
 		},
 	}
 }
 
-func makePrimoAPIISBNSearchRequestLogEntry(queryString string, dumpedHTTPRequest string) primoAPIISBNSearchRequestLogEntry {
-	sharedLogEntryFields := getSharedLogEntryFields(queryString)
+func (server *Server) makePrimoAPISearchRequestLogEntry(requestID string, queryString string, strategy string, dumpedHTTPRequest string) primoAPISearchRequestLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
 
-	return primoAPIISBNSearchRequestLogEntry{
+	return primoAPISearchRequestLogEntry{
 		sharedLogEntryFields: sharedLogEntryFields,
-		APIRequest: primoAPIISBNSearchRequest{
-			Type:                        "primoRequest",
-			DumpedISBNSearchHTTPRequest: dumpedHTTPRequest,
+		APIRequest: primoAPISearchRequest{
+			Type:                    "primoRequest",
+			Strategy:                strategy,
+			DumpedSearchHTTPRequest: server.prepareDump(DumpTypePrimoRequest, requestID, dumpedHTTPRequest),
 		},
 	}
 }
 
-func makePrimoAPIISBNSearchResponseLogEntry(queryString string, dumpedHTTPResponse string) primoAPIISBNSearchResponseLogEntry {
-	sharedLogEntryFields := getSharedLogEntryFields(queryString)
+func (server *Server) makePrimoAPISearchResponseLogEntry(requestID string, queryString string, strategy string, dumpedHTTPResponse string) primoAPISearchResponseLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
 
-	return primoAPIISBNSearchResponseLogEntry{
+	return primoAPISearchResponseLogEntry{
 		sharedLogEntryFields: sharedLogEntryFields,
-		APIResponse: primoAPIISBNSearchResponse{
-			Type:                         "primoResponse",
-			DumpedISBNSearchHTTPResponse: dumpedHTTPResponse,
+		APIResponse: primoAPISearchResponse{
+			Type:                     "primoResponse",
+			Strategy:                 strategy,
+			DumpedSearchHTTPResponse: server.prepareDump(DumpTypePrimoResponse, requestID, dumpedHTTPResponse),
 		},
 	}
 }
 
-func makeNewSFXAPIRequestLogEntry(queryString string, dumpedHTTPRequest string) sfxAPIRequestLogEntry {
-	sharedLogEntryFields := getSharedLogEntryFields(queryString)
+func (server *Server) makeRemovedTargetLogEntry(requestID string, queryString string, target *sfx.Target) removedTargetLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
+
+	return removedTargetLogEntry{
+		sharedLogEntryFields: sharedLogEntryFields,
+		Target:               target,
+	}
+}
+
+func (server *Server) makeNewSFXAPIRequestLogEntry(requestID string, queryString string, dumpedHTTPRequest string) sfxAPIRequestLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
 
 	return sfxAPIRequestLogEntry{
 		sharedLogEntryFields: sharedLogEntryFields,
 		APIRequest: sfxAPIRequest{
 			Type:              "sfxRequest",
-			DumpedHTTPRequest: dumpedHTTPRequest,
+			DumpedHTTPRequest: server.prepareDump(DumpTypeSFXRequest, requestID, dumpedHTTPRequest),
 		},
 	}
 }
 
-func makeNewSFXAPIResponseLogEntry(queryString string, dumpedHTTPResponse string) sfxAPIResponseLogEntry {
-	sharedLogEntryFields := getSharedLogEntryFields(queryString)
+func (server *Server) makeNewSFXAPIResponseLogEntry(requestID string, queryString string, dumpedHTTPResponse string) sfxAPIResponseLogEntry {
+	sharedLogEntryFields := server.getSharedLogEntryFields(requestID, queryString)
 
 	return sfxAPIResponseLogEntry{
 		sharedLogEntryFields: sharedLogEntryFields,
 		APIResponse: sfxAPIResponse{
 			Type:               "sfxResponse",
-			DumpedHTTPResponse: dumpedHTTPResponse,
+			DumpedHTTPResponse: server.prepareDump(DumpTypeSFXResponse, requestID, dumpedHTTPResponse),
 		},
 	}
 }
+
+// Redacts `dump`, and then truncates it and writes it to the dump sink as
+// configured for `dumpType`.
+func (server *Server) prepareDump(dumpType string, requestID string, dump string) string {
+	return server.dumps.Prepare(dumpType, requestID, server.redactor.redactText(dump))
+}
+
+// Whether to log debug entries containing dumped SFX and Primo responses for
+// the request.  These are sampled and rate limited, since they can be huge.
+func (server *Server) shouldLogDumpedResponses(requestID string) bool {
+	return server.logger.Enabled(log.LevelDebug) && server.dumps.Sample(requestID)
+}
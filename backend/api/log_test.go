@@ -0,0 +1,125 @@
+package api
+
+import (
+	"ariadne/log"
+	"ariadne/testutils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+var truncatedDumpRegexp = regexp.MustCompile(`\n\[truncated: \d+ of \d+ bytes, sha256:[0-9a-f]{64}\]$`)
+
+func TestDumpLimits(t *testing.T) {
+	t.Parallel()
+
+	testCase := getTestCase(t, "contrived-frbr-group-test-case")
+	fakeSFXServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sfxFakeResponse, err := testutils.GetSFXFakeResponse(testCase)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, sfxFakeResponse)
+		}),
+	)
+	defer fakeSFXServer.Close()
+	fakePrimoServer := newFakePrimoServer(testCase)
+	defer fakePrimoServer.Close()
+
+	t.Run("Truncated", func(t *testing.T) {
+		var sink bytes.Buffer
+		logEntries := doDumpLimitsRequest(t, fakeSFXServer.URL, fakePrimoServer.URL, log.DumpOptions{
+			MaxSizes:   map[string]int{DumpTypeSFXResponse: 100, DumpTypePrimoResponse: 100},
+			SampleRate: 1,
+			Sink:       &sink,
+		})
+
+		sfxResponseEntry, ok := logEntries["SFX API Response"]
+		if !ok {
+			t.Fatal("Expected an \"SFX API Response\" log entry")
+		}
+		dumpedHTTPResponse := sfxResponseEntry["dumpedHTTPResponse"]
+		if !truncatedDumpRegexp.MatchString(dumpedHTTPResponse) {
+			t.Errorf("Expected truncated SFX response dump, got:\n%s", dumpedHTTPResponse)
+		}
+		primoResponseEntry := logEntries["Primo API Search Response"]
+		dumpedSearchHTTPResponse := primoResponseEntry["dumpedSearchHTTPResponse"]
+		if !truncatedDumpRegexp.MatchString(dumpedSearchHTTPResponse) {
+			t.Errorf("Expected truncated Primo response dump, got:\n%s", dumpedSearchHTTPResponse)
+		}
+
+		sfxRequestEntry := logEntries["SFX API Request"]
+		dumpedHTTPRequest := sfxRequestEntry["dumpedHTTPRequest"]
+		if dumpedHTTPRequest == "" || truncatedDumpRegexp.MatchString(dumpedHTTPRequest) {
+			t.Errorf("Expected full SFX request dump, got:\n%s", dumpedHTTPRequest)
+		}
+
+		if sink.Len() == 0 {
+			t.Error("Expected full dumps to be written to the sink")
+		}
+	})
+
+	t.Run("Not sampled", func(t *testing.T) {
+		logEntries := doDumpLimitsRequest(t, fakeSFXServer.URL, fakePrimoServer.URL, log.DumpOptions{
+			SampleRate: 0,
+		})
+
+		for _, message := range []string{"SFX API Response", "Primo API Search Response"} {
+			if _, ok := logEntries[message]; ok {
+				t.Errorf("Expected no \"%s\" log entry", message)
+			}
+		}
+		for _, message := range []string{"SFX API Request", "Primo API Search Request", "Ariadne API response"} {
+			if _, ok := logEntries[message]; !ok {
+				t.Errorf("Expected a \"%s\" log entry", message)
+			}
+		}
+	})
+}
+
+// Returns the API request or response fields of the debug log entries for a
+// request, by message.
+func doDumpLimitsRequest(t *testing.T, sfxURL string, primoURL string, dumpOptions log.DumpOptions) map[string]map[string]string {
+	t.Helper()
+
+	var logOutput bytes.Buffer
+	server := newTestServer(t, sfxURL, primoURL, Options{
+		Dumps:  log.NewDumps(dumpOptions),
+		Logger: log.New(&logOutput, log.LevelDebug),
+	})
+	doResolverRequest(t, server, getTestCase(t, "contrived-frbr-group-test-case").QueryString)
+
+	logEntries := map[string]map[string]string{}
+	decoder := json.NewDecoder(&logOutput)
+	for decoder.More() {
+		var logEntry struct {
+			Message string `json:"message"`
+			Ariadne struct {
+				APIRequest  map[string]interface{} `json:"apiRequest"`
+				APIResponse map[string]interface{} `json:"apiResponse"`
+			} `json:"ariadne"`
+		}
+		err := decoder.Decode(&logEntry)
+		if err != nil {
+			t.Fatalf("Could not decode log entry: %s", err)
+		}
+		fields := map[string]string{}
+		for _, apiFields := range []map[string]interface{}{logEntry.Ariadne.APIRequest, logEntry.Ariadne.APIResponse} {
+			for name, value := range apiFields {
+				if stringValue, ok := value.(string); ok {
+					fields[name] = stringValue
+				}
+			}
+		}
+		logEntries[logEntry.Message] = fields
+	}
+
+	return logEntries
+}
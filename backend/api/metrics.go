@@ -0,0 +1,124 @@
+package api
+
+import (
+	"ariadne/metrics"
+	"ariadne/primo"
+	"context"
+	"errors"
+	"time"
+)
+
+// Outcomes of resolver requests, for the `outcome` label of
+// ariadne_resolver_requests_total.
+const (
+	outcomeDOILinkOnly     = "doi_link_only"
+	outcomeError           = "error"
+	outcomeHelperLinksOnly = "helper_links_only"
+	outcomeOpenAccessFound = "open_access_found"
+	outcomePrimoFound      = "primo_found"
+	outcomeSFXFound        = "sfx_found"
+)
+
+// Reasons SFX targets are removed, for the `reason` label of
+// ariadne_sfx_removed_targets_total.
+const (
+	removedTargetReasonAskALibrarian  = "ask_a_librarian"
+	removedTargetReasonEmptyTargetURL = "empty_target_url"
+	removedTargetReasonTenant         = "tenant_removed_target"
+)
+
+// Request types of SFX, Crossref, and Unpaywall requests, for the `request`
+// label of ariadne_upstream_request_duration_seconds.  Primo request types are
+// defined in the primo package.
+const (
+	crossrefRequestTypeLookup  = "lookup"
+	sfxRequestTypeResolve      = "resolve"
+	unpaywallRequestTypeLookup = "lookup"
+)
+
+// The metrics served at /metrics.  Upstream request latency is reported by the
+// upstream clients, so for it to be recorded the clients must be created with
+// `ObserveSFXRequest`, `ObservePrimoRequest`, `ObserveCrossrefRequest`, and
+// `ObserveUnpaywallRequest` as their request observers.
+type Metrics struct {
+	registry *metrics.Registry
+
+	frbrMemberRequests      *metrics.CounterVec
+	recoveredPanics         *metrics.CounterVec
+	removedTargets          *metrics.CounterVec
+	resolverRequests        *metrics.CounterVec
+	upstreamRequestDuration *metrics.HistogramVec
+}
+
+func NewMetrics() *Metrics {
+	registry := metrics.NewRegistry()
+
+	return &Metrics{
+		registry: registry,
+
+		resolverRequests: registry.NewCounterVec("ariadne_resolver_requests_total",
+			"Resolver requests, by outcome: sfx_found, primo_found, open_access_found, doi_link_only, helper_links_only, or error.",
+			"outcome"),
+		upstreamRequestDuration: registry.NewHistogramVec("ariadne_upstream_request_duration_seconds",
+			"Duration of HTTP requests to SFX, Primo, Crossref, and Unpaywall, including retries.",
+			metrics.DefaultBuckets, "upstream", "request", "result"),
+		frbrMemberRequests: registry.NewCounterVec("ariadne_primo_frbr_member_requests_total",
+			"Primo FRBR member requests."),
+		removedTargets: registry.NewCounterVec("ariadne_sfx_removed_targets_total",
+			"SFX targets removed from responses, by reason.",
+			"reason"),
+		recoveredPanics: registry.NewCounterVec("ariadne_recovered_panics_total",
+			"Panics in request handlers that were recovered and returned as 500 errors."),
+	}
+}
+
+// Request observer for SFX clients.
+func (metrics *Metrics) ObserveSFXRequest(duration time.Duration, err error) {
+	metrics.upstreamRequestDuration.ObserveDuration(duration, "sfx", sfxRequestTypeResolve, requestResult(err))
+}
+
+// Request observer for Crossref clients.
+func (metrics *Metrics) ObserveCrossrefRequest(duration time.Duration, err error) {
+	metrics.upstreamRequestDuration.ObserveDuration(duration, "crossref", crossrefRequestTypeLookup, requestResult(err))
+}
+
+// Request observer for Unpaywall clients.
+func (metrics *Metrics) ObserveUnpaywallRequest(duration time.Duration, err error) {
+	metrics.upstreamRequestDuration.ObserveDuration(duration, "unpaywall", unpaywallRequestTypeLookup, requestResult(err))
+}
+
+// Request observer for Primo clients.
+func (metrics *Metrics) ObservePrimoRequest(requestType string, duration time.Duration, err error) {
+	metrics.upstreamRequestDuration.ObserveDuration(duration, "primo", requestType, requestResult(err))
+	if requestType == primo.RequestTypeFRBRMemberSearch {
+		metrics.frbrMemberRequests.Inc()
+	}
+}
+
+// Cached responses are counted by the outcome of the request that was cached.
+func (metrics *Metrics) observeResolution(backend string, response Response) {
+	outcome := outcomeSFXFound
+	if backend == backendPrimo {
+		outcome = outcomePrimoFound
+	} else if backend == backendOpenAccess {
+		outcome = outcomeOpenAccessFound
+	} else if backend == backendDOI {
+		outcome = outcomeDOILinkOnly
+	} else if !response.Found {
+		outcome = outcomeHelperLinksOnly
+	}
+	metrics.resolverRequests.Inc(outcome)
+}
+
+// Requests that were cancelled because their results were no longer needed,
+// e.g. Primo ISBN searches once SFX has found something, aren't errors.
+func requestResult(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	if err != nil {
+		return "error"
+	}
+
+	return "ok"
+}
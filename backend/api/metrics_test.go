@@ -0,0 +1,123 @@
+package api
+
+import (
+	"ariadne/log"
+	"ariadne/primo"
+	"ariadne/sfx"
+	"ariadne/testutils"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	var currentTestCase testutils.TestCase
+	fakeSFXServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sfxFakeResponse, err := testutils.GetSFXFakeResponse(currentTestCase)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, sfxFakeResponse)
+		}),
+	)
+	defer fakeSFXServer.Close()
+	fakePrimoServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var primoFakeResponse string
+			var err error
+			if r.URL.Query().Get(primo.FRBRMemberSearchQueryParamName) == "" {
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseSearch(currentTestCase)
+			} else {
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseFRBRMemberSearch(currentTestCase)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, primoFakeResponse)
+		}),
+	)
+	defer fakePrimoServer.Close()
+
+	metrics := NewMetrics()
+	logger := log.New(io.Discard, log.LevelDisabled)
+	tenant := newTestTenant(DefaultTenantName, fakeSFXServer.URL, fakePrimoServer.URL, logger)
+	tenant.PrimoClient = primo.NewClient(fakePrimoServer.URL, primo.ClientOptions{
+		HTTPClient:      primo.NewHTTPClient(primo.DefaultTimeout, nil),
+		Logger:          logger,
+		RequestObserver: metrics.ObservePrimoRequest,
+	})
+	tenant.SFXClient = sfx.NewClient(fakeSFXServer.URL, sfx.ClientOptions{
+		HTTPClient:      sfx.NewHTTPClient(sfx.DefaultTimeout, nil),
+		Logger:          logger,
+		RequestObserver: metrics.ObserveSFXRequest,
+	})
+	server := newTestServer(t, "", "", Options{
+		Logger:  logger,
+		Metrics: metrics,
+		Tenants: []*Tenant{tenant},
+	})
+
+	// SFX found, Primo found after FRBR member requests, and Primo found
+	for _, key := range []string{"the-new-yorker", "contrived-frbr-group-test-case", "hamlet"} {
+		currentTestCase = getTestCase(t, key)
+		doResolverRequest(t, server, currentTestCase.QueryString)
+	}
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v0/nyush/?issn=0028-792X", nil))
+	server.recoverWrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("Test panic")
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v0/", nil))
+
+	responseRecorder := httptest.NewRecorder()
+	server.ServeHTTP(responseRecorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := responseRecorder.Body.String()
+
+	expectedLines := []string{
+		`ariadne_resolver_requests_total{outcome="error"} 1`,
+		`ariadne_resolver_requests_total{outcome="primo_found"} 2`,
+		`ariadne_resolver_requests_total{outcome="sfx_found"} 1`,
+		`ariadne_upstream_request_duration_seconds_count{upstream="primo",request="frbr_member_search",result="ok"} 2`,
+		`ariadne_upstream_request_duration_seconds_count{upstream="primo",request="isbn_search",result="ok"} 2`,
+		`ariadne_upstream_request_duration_seconds_count{upstream="sfx",request="resolve",result="ok"} 3`,
+		`ariadne_primo_frbr_member_requests_total 2`,
+		`ariadne_sfx_removed_targets_total{reason="ask_a_librarian"} 3`,
+		`ariadne_recovered_panics_total 1`,
+	}
+	for _, expectedLine := range expectedLines {
+		if !strings.Contains(body, "\n"+expectedLine+"\n") {
+			t.Errorf("Expected metrics to contain line \"%s\", got:\n%s", expectedLine, body)
+		}
+	}
+}
+
+func TestRequestResult(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"No error", nil, "ok"},
+		{"Error", errors.New("upstream error"), "error"},
+		{"Timeout", fmt.Errorf("Could not do request: %w", context.DeadlineExceeded), "error"},
+		{"Canceled", fmt.Errorf("Could not do request: %w", context.Canceled), "canceled"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if actual := requestResult(testCase.err); actual != testCase.expected {
+				t.Errorf("Expected \"%s\", got \"%s\"", testCase.expected, actual)
+			}
+		})
+	}
+}
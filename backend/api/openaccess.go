@@ -0,0 +1,103 @@
+package api
+
+import (
+	"ariadne/unpaywall"
+	"ariadne/upstream"
+	"context"
+	"fmt"
+)
+
+// Adds links to free, legal copies of the work to a response with no subscribed
+// full text, ahead of the SFX "helper" links like interlibrary loan, so that
+// patrons see them first.  The DOI is from the citation, so it can come from
+// the OpenURL or from SFX.  Lookup failures are logged but never fail the
+// request.
+func (server *Server) addOpenAccessLinks(ctx context.Context, requestID string, queryString string, resolution resolution) resolution {
+	if server.unpaywallClient == nil || hasSubscribedFullText(resolution) {
+		return resolution
+	}
+
+	record := &resolution.response.Records[0]
+	doi := record.CitationSupplemental.DOI
+	if doi == "" {
+		return resolution
+	}
+
+	work, err := server.unpaywallClient.Lookup(ctx, doi)
+	if err != nil {
+		logMessage := server.logger.Warn
+		if upstream.IsNotFound(err) {
+			logMessage = server.logger.Info
+		}
+		logMessage(MessageKey, server.redactor.redactText(fmt.Sprintf("Open access lookup failed: %v", err)),
+			AriadneKey, server.makeOpenAccessLookupLogEntry(requestID, queryString, doi, 0))
+		// A work that Unpaywall doesn't know about will still be unknown the next
+		// time, but other errors might be transient.
+		resolution.partial = resolution.partial || !upstream.IsNotFound(err)
+		return resolution
+	}
+
+	links := makeOpenAccessLinks(work)
+	server.logger.Info(MessageKey, "Open access lookup",
+		AriadneKey, server.makeOpenAccessLookupLogEntry(requestID, queryString, doi, len(links)))
+	if len(links) == 0 {
+		return resolution
+	}
+
+	record.Links = append(links, record.Links...)
+	resolution.response.Found = true
+	resolution.backend = backendOpenAccess
+
+	return resolution
+}
+
+// Primo links and found SFX links are the library's own full text.  The DOI link
+// doesn't count, since it often leads to a paywall.
+func hasSubscribedFullText(resolution resolution) bool {
+	switch resolution.backend {
+	case backendPrimo:
+		return true
+	case backendSFX:
+		return resolution.response.Found
+	default:
+		return false
+	}
+}
+
+func makeOpenAccessLinks(work *unpaywall.Work) []Link {
+	links := []Link{}
+	for _, location := range work.Locations() {
+		links = append(links, Link{
+			DisplayName:    makeOpenAccessLinkDisplayName(location),
+			Url:            location.URL,
+			Coverage:       []Coverage{},
+			CoverageStatus: CoverageStatusUnknown,
+			Source:         LinkSourceOpenAccess,
+			License:        location.License,
+			Version:        location.Version,
+		})
+	}
+
+	return links
+}
+
+// E.g. "Free copy from the publisher" or "Free copy from Europe PMC (accepted
+// manuscript)".  Versions other than the published version are noted, since
+// they might differ from what the patron is expecting.
+func makeOpenAccessLinkDisplayName(location unpaywall.Location) string {
+	displayName := "Free copy from a repository"
+	if location.HostType == unpaywall.HostTypePublisher {
+		displayName = "Free copy from the publisher"
+	} else if location.RepositoryInstitution != "" {
+		displayName = "Free copy from " + location.RepositoryInstitution
+	}
+
+	switch location.Version {
+	case unpaywall.VersionAccepted:
+		displayName += " (accepted manuscript)"
+	case unpaywall.VersionSubmitted:
+		displayName += " (preprint)"
+	}
+
+	return displayName
+}
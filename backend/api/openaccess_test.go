@@ -0,0 +1,211 @@
+package api
+
+import (
+	"ariadne/crossref"
+	"ariadne/unpaywall"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+const testUnpaywallEmail = "library@example.edu"
+
+const testUnpaywallWorkJSON = `{
+  "doi": "10.1000/the-new-yorker.2021.10.25",
+  "is_oa": true,
+  "oa_locations": [
+    {
+      "host_type": "publisher",
+      "license": "cc-by",
+      "repository_institution": null,
+      "url": "https://example.com/the-new-yorker/2021/10/25",
+      "version": "publishedVersion"
+    },
+    {
+      "host_type": "repository",
+      "license": null,
+      "repository_institution": "Europe PMC",
+      "url": "https://europepmc.org/articles/pmc1234567",
+      "version": "acceptedVersion"
+    }
+  ]
+}`
+
+const testUnpaywallClosedWorkJSON = `{
+  "doi": "10.1000/the-new-yorker.2021.10.25",
+  "is_oa": false,
+  "oa_locations": []
+}`
+
+func TestOpenAccessLinks(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name                      string
+		sfxTestCaseKey            string
+		unpaywallStatus           int
+		unpaywallResponse         string
+		queryString               string
+		expectedFound             bool
+		expectedLinks             []string
+		expectedUnpaywallRequests int32
+	}{
+		{"Open access copies before helper links", "the-sino-tibetan-languages", http.StatusOK, testUnpaywallWorkJSON,
+			"rft_id=info:doi/" + testDOI, true,
+			[]string{"Free copy from the publisher", "Free copy from Europe PMC (accepted manuscript)", "Bobst Library  Interlibrary Loan"}, 1},
+		{"Subscribed full text", "the-new-yorker", http.StatusOK, testUnpaywallWorkJSON,
+			"rft_id=info:doi/" + testDOI, true, nil, 0},
+		{"Not open access", "the-sino-tibetan-languages", http.StatusOK, testUnpaywallClosedWorkJSON,
+			"doi=" + testDOI, false, []string{"Bobst Library  Interlibrary Loan"}, 1},
+		{"Unknown DOI", "the-sino-tibetan-languages", http.StatusNotFound, "",
+			"doi=" + testDOI, false, []string{"Bobst Library  Interlibrary Loan"}, 1},
+		{"No DOI", "the-sino-tibetan-languages", http.StatusOK, testUnpaywallWorkJSON,
+			"title=The%20Sino-Tibetan%20Languages", false, []string{"Bobst Library  Interlibrary Loan"}, 0},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeSFXServer := newFakeSFXServer(t, testCase.sfxTestCaseKey)
+			defer fakeSFXServer.Close()
+
+			var numUnpaywallRequests atomic.Int32
+			fakeUnpaywallServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					numUnpaywallRequests.Add(1)
+					if r.URL.Query().Get("email") != testUnpaywallEmail {
+						http.Error(w, "Missing email.", http.StatusUnprocessableEntity)
+						return
+					}
+					if testCase.unpaywallStatus != http.StatusOK {
+						http.Error(w, "DOI not found.", testCase.unpaywallStatus)
+						return
+					}
+
+					fmt.Fprint(w, testCase.unpaywallResponse)
+				}),
+			)
+			defer fakeUnpaywallServer.Close()
+			fakePrimoServer := newFakePrimoServer(getTestCase(t, testCase.sfxTestCaseKey))
+			defer fakePrimoServer.Close()
+
+			server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+				Unpaywall: unpaywall.NewClient(fakeUnpaywallServer.URL, testUnpaywallEmail, unpaywall.ClientOptions{}),
+			})
+
+			apiResponse := doOpenAccessRequest(t, server, testCase.queryString)
+
+			if apiResponse.Found != testCase.expectedFound {
+				t.Errorf("Expected found to be %t, got %t", testCase.expectedFound, apiResponse.Found)
+			}
+			if testCase.expectedLinks != nil {
+				actualLinks := []string{}
+				for _, link := range apiResponse.Records[0].Links {
+					actualLinks = append(actualLinks, link.DisplayName)
+				}
+				if !reflect.DeepEqual(actualLinks, testCase.expectedLinks) {
+					t.Errorf("Expected links %v, got %v", testCase.expectedLinks, actualLinks)
+				}
+			}
+			if numUnpaywallRequests.Load() != testCase.expectedUnpaywallRequests {
+				t.Errorf("Expected %d Unpaywall requests, got %d", testCase.expectedUnpaywallRequests, numUnpaywallRequests.Load())
+			}
+		})
+	}
+}
+
+func TestOpenAccessLinkFields(t *testing.T) {
+	t.Parallel()
+
+	fakeSFXServer := newFakeSFXServer(t, "the-sino-tibetan-languages")
+	defer fakeSFXServer.Close()
+	fakeUnpaywallServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, testUnpaywallWorkJSON)
+		}),
+	)
+	defer fakeUnpaywallServer.Close()
+	fakePrimoServer := newFakePrimoServer(getTestCase(t, "the-sino-tibetan-languages"))
+	defer fakePrimoServer.Close()
+
+	server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+		Unpaywall: unpaywall.NewClient(fakeUnpaywallServer.URL, testUnpaywallEmail, unpaywall.ClientOptions{}),
+	})
+
+	links := doOpenAccessRequest(t, server, "rft_id=info:doi/"+testDOI).Records[0].Links
+	expected := Link{
+		DisplayName:    "Free copy from Europe PMC (accepted manuscript)",
+		Url:            "https://europepmc.org/articles/pmc1234567",
+		Coverage:       []Coverage{},
+		CoverageStatus: CoverageStatusUnknown,
+		Source:         LinkSourceOpenAccess,
+		Version:        unpaywall.VersionAccepted,
+	}
+	if len(links) < 2 || !reflect.DeepEqual(links[1], expected) {
+		t.Errorf("Expected second link %+v, got %+v", expected, links)
+	}
+	if links[0].License != "cc-by" || links[0].Version != unpaywall.VersionPublished {
+		t.Errorf("Expected license and version of publisher copy, got %+v", links[0])
+	}
+	if links[len(links)-1].Source != "" {
+		t.Errorf("Expected SFX helper link to have no source, got %+v", links[len(links)-1])
+	}
+}
+
+// The DOI link is a last resort, so it stays after the open-access links.
+func TestOpenAccessLinksWithDOILink(t *testing.T) {
+	t.Parallel()
+
+	fakeSFXServer := newFakeSFXServer(t, "the-sino-tibetan-languages")
+	defer fakeSFXServer.Close()
+	fakeCrossrefServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, testCrossrefWorkJSON)
+		}),
+	)
+	defer fakeCrossrefServer.Close()
+	fakeUnpaywallServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, testUnpaywallWorkJSON)
+		}),
+	)
+	defer fakeUnpaywallServer.Close()
+	fakePrimoServer := newFakePrimoServer(getTestCase(t, "the-sino-tibetan-languages"))
+	defer fakePrimoServer.Close()
+
+	server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+		Crossref:  crossref.NewClient(fakeCrossrefServer.URL, crossref.ClientOptions{}),
+		Unpaywall: unpaywall.NewClient(fakeUnpaywallServer.URL, testUnpaywallEmail, unpaywall.ClientOptions{}),
+	})
+
+	apiResponse := doOpenAccessRequest(t, server, "rft_id=info:doi/"+testDOI)
+	links := apiResponse.Records[0].Links
+	if !apiResponse.Found || len(links) != 4 {
+		t.Fatalf("Expected found response with 4 links, got %+v", apiResponse)
+	}
+	if links[0].Source != LinkSourceOpenAccess || links[1].Source != LinkSourceOpenAccess {
+		t.Errorf("Expected open-access links first, got %+v", links)
+	}
+	if links[3].DisplayName != doiLinkDisplayName {
+		t.Errorf("Expected DOI link last, got %+v", links[3])
+	}
+}
+
+func doOpenAccessRequest(t *testing.T, server *Server, queryString string) Response {
+	t.Helper()
+
+	response := doResolverRequest(t, server, queryString)
+	var apiResponse Response
+	err := json.NewDecoder(response.Body).Decode(&apiResponse)
+	if err != nil {
+		t.Fatalf("Could not decode response: %s", err)
+	}
+
+	return apiResponse
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// How long /readyz reuses the result of a probe of an upstream dependency.
+// Probes are cached so that frequent readiness checks from the orchestrator and
+// dashboards don't add load to SFX and Primo.
+const DefaultReadinessCacheTTL = 10 * time.Second
+
+// Timeout for each probe of an upstream dependency.
+const DefaultReadinessProbeTimeout = 5 * time.Second
+
+const (
+	dependencyStatusOK          = "ok"
+	dependencyStatusUnavailable = "unavailable"
+)
+
+const (
+	readinessStatusDegraded    = "degraded"
+	readinessStatusDraining    = "draining"
+	readinessStatusOK          = "ok"
+	readinessStatusUnavailable = "unavailable"
+)
+
+type readinessResponse struct {
+	// "ok", "degraded" if Primo is unreachable, "unavailable" if SFX is
+	// unreachable, or "draining"
+	Status       string             `json:"status"`
+	Dependencies []dependencyStatus `json:"dependencies"`
+}
+
+type dependencyStatus struct {
+	// "sfx" or "primo"
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Tenants using this upstream instance
+	Tenants   []string  `json:"tenants"`
+	Status    string    `json:"status"`
+	LatencyMs int64     `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+	// The most recent probe error, which is kept after the dependency recovers.
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+}
+
+// An upstream instance, which may be shared by several tenants.
+type dependency struct {
+	ping func(ctx context.Context) error
+	// Held while probing, so that concurrent readiness checks wait for the
+	// probe in progress instead of starting their own.
+	mutex  sync.Mutex
+	status dependencyStatus
+}
+
+type readinessChecker struct {
+	cacheTTL     time.Duration
+	dependencies []*dependency
+	now          func() time.Time
+	probeTimeout time.Duration
+}
+
+// Makes a dependency for each distinct SFX and Primo URL used by `tenants`.
+func newReadinessChecker(tenants map[string]*Tenant, cacheTTL time.Duration, probeTimeout time.Duration) *readinessChecker {
+	checker := &readinessChecker{
+		cacheTTL:     cacheTTL,
+		now:          time.Now,
+		probeTimeout: probeTimeout,
+	}
+
+	tenantNames := []string{}
+	for name := range tenants {
+		tenantNames = append(tenantNames, name)
+	}
+	sort.Strings(tenantNames)
+
+	dependenciesByKey := map[string]*dependency{}
+	addDependency := func(name string, url string, tenantName string, ping func(ctx context.Context) error) {
+		key := name + " " + url
+		if existingDependency, ok := dependenciesByKey[key]; ok {
+			existingDependency.status.Tenants = append(existingDependency.status.Tenants, tenantName)
+			return
+		}
+		newDependency := &dependency{
+			ping: ping,
+			status: dependencyStatus{
+				Name:    name,
+				URL:     url,
+				Tenants: []string{tenantName},
+			},
+		}
+		dependenciesByKey[key] = newDependency
+		checker.dependencies = append(checker.dependencies, newDependency)
+	}
+	for _, name := range tenantNames {
+		tenant := tenants[name]
+		addDependency("sfx", tenant.SFXClient.URL(), name, tenant.SFXClient.Ping)
+		addDependency("primo", tenant.PrimoClient.URL(), name, tenant.PrimoClient.Ping)
+	}
+
+	// SFX first, since it's the one that determines readiness.
+	sort.SliceStable(checker.dependencies, func(i, j int) bool {
+		return checker.dependencies[i].status.Name > checker.dependencies[j].status.Name
+	})
+
+	return checker
+}
+
+// Probes all dependencies concurrently, except those probed within the cache
+// TTL, and returns their statuses.
+func (checker *readinessChecker) check() readinessResponse {
+	statuses := make([]dependencyStatus, len(checker.dependencies))
+	var waitGroup sync.WaitGroup
+	for i := range checker.dependencies {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			statuses[i] = checker.checkDependency(checker.dependencies[i])
+		}(i)
+	}
+	waitGroup.Wait()
+
+	status := readinessStatusOK
+	for _, dependencyStatus := range statuses {
+		if dependencyStatus.Status == dependencyStatusOK {
+			continue
+		}
+		// Resolver requests can't succeed without SFX, but without Primo only
+		// some book requests are affected.
+		if dependencyStatus.Name == "sfx" {
+			status = readinessStatusUnavailable
+		} else if status == readinessStatusOK {
+			status = readinessStatusDegraded
+		}
+	}
+
+	return readinessResponse{Status: status, Dependencies: statuses}
+}
+
+func (checker *readinessChecker) checkDependency(dependency *dependency) dependencyStatus {
+	dependency.mutex.Lock()
+	defer dependency.mutex.Unlock()
+
+	if !dependency.status.CheckedAt.IsZero() &&
+		checker.now().Sub(dependency.status.CheckedAt) < checker.cacheTTL {
+		return dependency.status
+	}
+
+	// Not cancelled with the readiness request, since the result is cached and
+	// a probe cut short by an impatient client would be recorded as a failure.
+	ctx, cancel := context.WithTimeout(context.Background(), checker.probeTimeout)
+	defer cancel()
+
+	start := checker.now()
+	err := dependency.ping(ctx)
+	checkedAt := checker.now()
+
+	dependency.status.CheckedAt = checkedAt
+	dependency.status.LatencyMs = checkedAt.Sub(start).Milliseconds()
+	if err != nil {
+		dependency.status.Status = dependencyStatusUnavailable
+		dependency.status.LastError = err.Error()
+		dependency.status.LastErrorTime = &checkedAt
+	} else {
+		dependency.status.Status = dependencyStatusOK
+	}
+
+	return dependency.status
+}
+
+// readinessCheck returns the status of the upstream dependencies of all tenants.
+// Unlike /healthcheck, which is for liveness, it fails with a 503 if SFX is
+// unreachable, or if the server is draining.
+func (server *Server) readinessCheck(w http.ResponseWriter, r *http.Request) {
+	response := server.readinessChecker.check()
+	if server.draining.Load() {
+		response.Status = readinessStatusDraining
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if response.Status == readinessStatusUnavailable || response.Status == readinessStatusDraining {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"ariadne/log"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadinessCheck(t *testing.T) {
+	t.Parallel()
+
+	newFakeUpstreamServer := func(statusCode *int32, numRequests *int32) *httptest.Server {
+		return httptest.NewServer(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(numRequests, 1)
+				w.WriteHeader(int(atomic.LoadInt32(statusCode)))
+			}),
+		)
+	}
+
+	var sfxStatusCode, primoStatusCode, numSFXRequests, numPrimoRequests int32
+	fakeSFXServer := newFakeUpstreamServer(&sfxStatusCode, &numSFXRequests)
+	defer fakeSFXServer.Close()
+	fakePrimoServer := newFakeUpstreamServer(&primoStatusCode, &numPrimoRequests)
+	defer fakePrimoServer.Close()
+
+	// Both tenants share the same upstreams, which must only be probed once.
+	logger := log.New(io.Discard, log.LevelDisabled)
+	server := newTestServer(t, "", "", Options{
+		Logger: logger,
+		Tenants: []*Tenant{
+			newTestTenant("nyu", fakeSFXServer.URL, fakePrimoServer.URL, logger),
+			newTestTenant("nyuad", fakeSFXServer.URL, fakePrimoServer.URL, logger),
+		},
+	})
+	currentTime := time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)
+	server.readinessChecker.now = func() time.Time { return currentTime }
+
+	testCases := []struct {
+		name                string
+		sfxStatusCode       int32
+		primoStatusCode     int32
+		advanceTime         time.Duration
+		expectedStatusCode  int
+		expectedStatus      string
+		expectedNumRequests int32
+		expectSFXLastError  bool
+	}{
+		{"All reachable", 200, 400, 0, http.StatusOK, "ok", 1, false},
+		{"Cached", 503, 503, DefaultReadinessCacheTTL - time.Second, http.StatusOK, "ok", 0, false},
+		{"SFX unreachable", 503, 200, time.Second, http.StatusServiceUnavailable, "unavailable", 1, true},
+		{"Primo unreachable", 200, 503, DefaultReadinessCacheTTL, http.StatusOK, "degraded", 1, true},
+	}
+
+	for _, testCase := range testCases {
+		atomic.StoreInt32(&sfxStatusCode, testCase.sfxStatusCode)
+		atomic.StoreInt32(&primoStatusCode, testCase.primoStatusCode)
+		atomic.StoreInt32(&numSFXRequests, 0)
+		atomic.StoreInt32(&numPrimoRequests, 0)
+		currentTime = currentTime.Add(testCase.advanceTime)
+
+		responseRecorder := httptest.NewRecorder()
+		server.ServeHTTP(responseRecorder, httptest.NewRequest("GET", "/readyz", nil))
+
+		var response readinessResponse
+		err := json.NewDecoder(responseRecorder.Body).Decode(&response)
+		if err != nil {
+			t.Fatalf("%s: error decoding readiness response body: %s", testCase.name, err)
+		}
+		if responseRecorder.Code != testCase.expectedStatusCode || response.Status != testCase.expectedStatus {
+			t.Errorf("%s: expected readiness status %d \"%s\", got %d \"%s\"", testCase.name,
+				testCase.expectedStatusCode, testCase.expectedStatus, responseRecorder.Code, response.Status)
+		}
+		if numSFXRequests != testCase.expectedNumRequests || numPrimoRequests != testCase.expectedNumRequests {
+			t.Errorf("%s: expected %d SFX and Primo probes, got %d and %d", testCase.name,
+				testCase.expectedNumRequests, numSFXRequests, numPrimoRequests)
+		}
+
+		if len(response.Dependencies) != 2 {
+			t.Fatalf("%s: expected 2 dependencies, got %+v", testCase.name, response.Dependencies)
+		}
+		sfxStatus := response.Dependencies[0]
+		if sfxStatus.Name != "sfx" || sfxStatus.URL != fakeSFXServer.URL || len(sfxStatus.Tenants) != 2 {
+			t.Errorf("%s: expected SFX dependency %s for both tenants first, got %+v", testCase.name,
+				fakeSFXServer.URL, sfxStatus)
+		}
+		// The last error is kept after SFX recovers.
+		if (sfxStatus.LastError != "") != testCase.expectSFXLastError {
+			t.Errorf("%s: expected SFX last error to be set: %t, got \"%s\"", testCase.name,
+				testCase.expectSFXLastError, sfxStatus.LastError)
+		}
+	}
+}
+
+func TestReadinessCheckDraining(t *testing.T) {
+	t.Parallel()
+
+	fakeUpstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fakeUpstreamServer.Close()
+
+	server := newTestServer(t, fakeUpstreamServer.URL, fakeUpstreamServer.URL, Options{})
+	server.StartDraining()
+
+	responseRecorder := httptest.NewRecorder()
+	server.ServeHTTP(responseRecorder, httptest.NewRequest("GET", "/readyz", nil))
+
+	var response readinessResponse
+	err := json.NewDecoder(responseRecorder.Body).Decode(&response)
+	if err != nil {
+		t.Fatalf("Error decoding readiness response body: %s", err)
+	}
+	if responseRecorder.Code != http.StatusServiceUnavailable || response.Status != "draining" {
+		t.Errorf("Expected readiness status %d \"draining\", got %d \"%s\"",
+			http.StatusServiceUnavailable, responseRecorder.Code, response.Status)
+	}
+}
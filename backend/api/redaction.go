@@ -0,0 +1,298 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// What to do with the value of a redacted query param or header in log entries.
+const (
+	// Removes the param or header entirely.
+	RedactionActionDrop = "drop"
+	// Replaces the value with a truncated SHA-256 hash, so that entries with the
+	// same value can still be correlated.  Values from a small set, like IPv4
+	// addresses, can be recovered by hashing every possibility, so hashing
+	// should not be relied on for anonymity.
+	RedactionActionHash = "hash"
+	// Replaces the value with `redactionMask`.
+	RedactionActionMask = "mask"
+)
+
+const redactionMask = "REDACTED"
+const redactionHashPrefix = "sha256:"
+
+// Query params and headers to redact from log entries, by case-insensitive name,
+// mapped to `RedactionAction*` values.
+type RedactionPolicy struct {
+	Headers map[string]string
+	Params  map[string]string
+}
+
+// WorldCat and other OpenURL sources send the user's IP and session identifiers
+// in `req.ip` and `rft.private_data` (`pid` in OpenURL 0.1).
+var DefaultRedactionPolicy = RedactionPolicy{
+	Headers: map[string]string{
+		"authorization": RedactionActionMask,
+		"cookie":        RedactionActionDrop,
+		"set-cookie":    RedactionActionDrop,
+	},
+	Params: map[string]string{
+		"pid":              RedactionActionDrop,
+		"req.ip":           RedactionActionHash,
+		"rft.private_data": RedactionActionDrop,
+	},
+}
+
+// Applies a `RedactionPolicy` to the query string and query params, and to
+// text containing dumped HTTP requests and responses or error messages.  In
+// text, params are redacted wherever they appear in a query string, e.g. in a
+// request line or a URL in an error message, or as an item in the SFX context
+// object attributes.
+type redactor struct {
+	headers map[string]string
+	params  map[string]string
+	// Nil if there are no headers or params to redact.
+	headerRegexp       *regexp.Regexp
+	perldataItemRegexp *regexp.Regexp
+	// Matches params in a query string on its own, whose values can contain
+	// anything but "&".
+	queryParamRegexp *regexp.Regexp
+	// Matches params in query strings in text, e.g. in a URL in an XML element.
+	textParamRegexp *regexp.Regexp
+}
+
+// Parses rules of the form "name:action", as used in the config file, into the
+// map used by `RedactionPolicy`.  The name can itself contain colons.
+func ParseRedactionRules(rules []string) (map[string]string, error) {
+	actions := map[string]string{}
+	for _, rule := range rules {
+		separatorIndex := strings.LastIndex(rule, ":")
+		if separatorIndex < 1 {
+			return nil, fmt.Errorf("Redaction rule \"%s\" is not of the form \"name:action\"", rule)
+		}
+
+		name, action := rule[:separatorIndex], rule[separatorIndex+1:]
+		err := validateRedactionAction(action)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid redaction rule \"%s\": %v", rule, err)
+		}
+		actions[strings.ToLower(name)] = action
+	}
+
+	return actions, nil
+}
+
+// The inverse of `ParseRedactionRules`, sorted by name.
+func FormatRedactionRules(actions map[string]string) []string {
+	rules := []string{}
+	for name, action := range actions {
+		rules = append(rules, name+":"+action)
+	}
+	sort.Strings(rules)
+
+	return rules
+}
+
+func newRedactor(policy RedactionPolicy) (*redactor, error) {
+	redactor := &redactor{
+		headers: map[string]string{},
+		params:  map[string]string{},
+	}
+
+	for _, names := range []struct {
+		actions  map[string]string
+		redacted map[string]string
+	}{
+		{policy.Headers, redactor.headers},
+		{policy.Params, redactor.params},
+	} {
+		for name, action := range names.actions {
+			err := validateRedactionAction(action)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid redaction action for \"%s\": %v", name, err)
+			}
+			names.redacted[strings.ToLower(name)] = action
+		}
+	}
+
+	if len(redactor.headers) > 0 {
+		redactor.headerRegexp = regexp.MustCompile(
+			`(?im)^(` + quotedAlternation(redactor.headers) + `):[ \t]*([^\r\n]*)(\r?\n)?`)
+	}
+	if len(redactor.params) > 0 {
+		names := quotedAlternation(redactor.params)
+		redactor.queryParamRegexp = regexp.MustCompile(`(?i)(^|&)(` + names + `)=([^&]*)`)
+		redactor.textParamRegexp = regexp.MustCompile(`(?i)(^|[?&;])(` + names + `)=([^&;#\s"'<>]*)`)
+		redactor.perldataItemRegexp = regexp.MustCompile(
+			`(?is)(key="(` + names + `)"(?:&gt;|>))(.*?)(&lt;/item&gt;|</item>)`)
+	}
+
+	return redactor, nil
+}
+
+func (redactor *redactor) redactQueryParams(params url.Values) url.Values {
+	if len(redactor.params) == 0 {
+		return params
+	}
+
+	redactedParams := url.Values{}
+	for name, values := range params {
+		action, ok := redactor.params[strings.ToLower(name)]
+		if !ok {
+			redactedParams[name] = values
+			continue
+		}
+		if action == RedactionActionDrop {
+			continue
+		}
+
+		redactedValues := []string{}
+		for _, value := range values {
+			redactedValues = append(redactedValues, redactValue(action, value))
+		}
+		redactedParams[name] = redactedValues
+	}
+
+	return redactedParams
+}
+
+func (redactor *redactor) redactQueryString(queryString string) string {
+	return redactor.redactParams(queryString, redactor.queryParamRegexp)
+}
+
+// Dropped params are removed along with their separator.
+func (redactor *redactor) redactParams(queryString string, paramRegexp *regexp.Regexp) string {
+	if paramRegexp == nil {
+		return queryString
+	}
+
+	var builder strings.Builder
+	lastIndex := 0
+	for _, match := range paramRegexp.FindAllStringSubmatchIndex(queryString, -1) {
+		// Submatch indexes: separator, name, value
+		separatorStart, separatorEnd := match[2], match[3]
+		nameStart, valueStart, valueEnd := match[4], match[6], match[7]
+		action := redactor.params[strings.ToLower(queryString[nameStart:match[5]])]
+
+		if action == RedactionActionDrop {
+			separator := queryString[separatorStart:separatorEnd]
+			// The separator may already have been dropped with the preceding param.
+			if (separator == "&" || separator == ";") && separatorStart >= lastIndex {
+				builder.WriteString(queryString[lastIndex:separatorStart])
+				lastIndex = valueEnd
+			} else {
+				// First param: keep the "?", if any, and drop the following separator instead.
+				builder.WriteString(queryString[lastIndex:nameStart])
+				lastIndex = valueEnd
+				if lastIndex < len(queryString) && strings.ContainsRune("&;", rune(queryString[lastIndex])) {
+					lastIndex++
+				}
+			}
+			continue
+		}
+
+		value := queryString[valueStart:valueEnd]
+		decodedValue, err := url.QueryUnescape(value)
+		if err == nil {
+			value = decodedValue
+		}
+		builder.WriteString(queryString[lastIndex:valueStart])
+		builder.WriteString(redactValue(action, value))
+		lastIndex = valueEnd
+	}
+	builder.WriteString(queryString[lastIndex:])
+
+	return builder.String()
+}
+
+// Redacts params in query strings and SFX context object attributes, and
+// header lines.  Dropped context object attributes are left with an empty value.
+func (redactor *redactor) redactText(text string) string {
+	if redactor.perldataItemRegexp != nil {
+		text = redactor.perldataItemRegexp.ReplaceAllStringFunc(text, func(item string) string {
+			submatches := redactor.perldataItemRegexp.FindStringSubmatch(item)
+			opening, name, value, closing := submatches[1], submatches[2], submatches[3], submatches[4]
+			action := redactor.params[strings.ToLower(name)]
+			if action == RedactionActionDrop {
+				return opening + closing
+			}
+
+			return opening + redactValue(action, html.UnescapeString(value)) + closing
+		})
+	}
+
+	text = redactor.redactParams(text, redactor.textParamRegexp)
+
+	if redactor.headerRegexp != nil {
+		text = redactor.headerRegexp.ReplaceAllStringFunc(text, func(line string) string {
+			submatches := redactor.headerRegexp.FindStringSubmatch(line)
+			name, value, lineEnding := submatches[1], submatches[2], submatches[3]
+			action := redactor.headers[strings.ToLower(name)]
+			if action == RedactionActionDrop {
+				return ""
+			}
+
+			return name + ": " + redactValue(action, value) + lineEnding
+		})
+	}
+
+	return text
+}
+
+// Returns a copy of `response` with redacted error messages, which can contain
+// upstream URLs.
+func (redactor *redactor) redactResponse(response Response) Response {
+	if len(response.Errors) == 0 {
+		return response
+	}
+
+	redactedErrors := []Error{}
+	for _, apiError := range response.Errors {
+		apiError.Message = redactor.redactText(apiError.Message)
+		redactedErrors = append(redactedErrors, apiError)
+	}
+	response.Errors = redactedErrors
+
+	return response
+}
+
+func redactValue(action string, value string) string {
+	if action == RedactionActionHash {
+		sum := sha256.Sum256([]byte(value))
+		return redactionHashPrefix + hex.EncodeToString(sum[:8])
+	}
+
+	return redactionMask
+}
+
+func quotedAlternation(names map[string]string) string {
+	quotedNames := []string{}
+	for name := range names {
+		quotedNames = append(quotedNames, regexp.QuoteMeta(name))
+	}
+	// Longest first, so that a name that is a prefix of another doesn't match first.
+	sort.Slice(quotedNames, func(i, j int) bool {
+		if len(quotedNames[i]) != len(quotedNames[j]) {
+			return len(quotedNames[i]) > len(quotedNames[j])
+		}
+		return quotedNames[i] < quotedNames[j]
+	})
+
+	return strings.Join(quotedNames, "|")
+}
+
+func validateRedactionAction(action string) error {
+	switch action {
+	case RedactionActionDrop, RedactionActionHash, RedactionActionMask:
+		return nil
+	default:
+		return fmt.Errorf("action must be \"%s\", \"%s\", or \"%s\", got \"%s\"",
+			RedactionActionDrop, RedactionActionHash, RedactionActionMask, action)
+	}
+}
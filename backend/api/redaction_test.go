@@ -0,0 +1,185 @@
+package api
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// sha256("209.150.44.95"), truncated
+const testIPHash = "sha256:d4ca05389f698cc1"
+
+func TestRedactQueryString(t *testing.T) {
+	redactor := newTestRedactor(t)
+
+	testCases := []struct {
+		name        string
+		queryString string
+		expected    string
+	}{
+		{"Nothing to redact", "issn=0028-792X&title=New+Yorker", "issn=0028-792X&title=New+Yorker"},
+		{"Hash", "issn=0028-792X&req.ip=209.150.44.95", "issn=0028-792X&req.ip=" + testIPHash},
+		{"Hash of escaped value", "req.ip=209%2E150%2E44%2E95&issn=0028-792X", "req.ip=" + testIPHash + "&issn=0028-792X"},
+		{"Drop first", "rft.private_data=909782404<fssessid>0<%2Ffssessid>&issn=0028-792X", "issn=0028-792X"},
+		{"Drop middle", "issn=0028-792X&pid=Ross&title=New+Yorker", "issn=0028-792X&title=New+Yorker"},
+		{"Drop last", "issn=0028-792X&rft.private_data=909782404", "issn=0028-792X"},
+		{"Drop consecutive", "pid=Ross&rft.private_data=909782404&issn=0028-792X", "issn=0028-792X"},
+		{"Mask", "issn=0028-792X&Session=abc123", "issn=0028-792X&Session=" + redactionMask},
+		{"Case-insensitive", "REQ.IP=209.150.44.95", "REQ.IP=" + testIPHash},
+		{"Name suffix only", "rft.pid=1234", "rft.pid=1234"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := redactor.redactQueryString(testCase.queryString)
+			if actual != testCase.expected {
+				t.Errorf("Expected \"%s\", got \"%s\"", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRedactQueryParams(t *testing.T) {
+	redactor := newTestRedactor(t)
+
+	params := url.Values{
+		"issn":             {"0028-792X"},
+		"req.ip":           {"209.150.44.95"},
+		"rft.private_data": {"909782404<fssessid>0</fssessid>"},
+		"session":          {"abc123", "def456"},
+	}
+	expected := url.Values{
+		"issn":    {"0028-792X"},
+		"req.ip":  {testIPHash},
+		"session": {redactionMask, redactionMask},
+	}
+
+	actual := redactor.redactQueryParams(params)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	if params.Get("req.ip") != "209.150.44.95" {
+		t.Errorf("Redaction modified the original params: %v", params)
+	}
+}
+
+func TestRedactText(t *testing.T) {
+	redactor := newTestRedactor(t)
+
+	testCases := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			"Request line and headers",
+			"GET /?pid=Ross&req.ip=209.150.44.95&issn=0028-792X HTTP/1.1\r\n" +
+				"Host: sfx.example.edu\r\nAuthorization: Bearer secret\r\nCookie: session=abc123\r\n\r\n",
+			"GET /?req.ip=" + testIPHash + "&issn=0028-792X HTTP/1.1\r\n" +
+				"Host: sfx.example.edu\r\nAuthorization: " + redactionMask + "\r\n\r\n",
+		},
+		{
+			"Escaped SFX context object attributes",
+			`&lt;item key="req.ip"&gt;209.150.44.95&lt;/item&gt;&lt;item key="rft.private_data"&gt;909782404&lt;/item&gt;`,
+			`&lt;item key="req.ip"&gt;` + testIPHash + `&lt;/item&gt;&lt;item key="rft.private_data"&gt;&lt;/item&gt;`,
+		},
+		{
+			"URL in XML element",
+			"<target_url>http://example.edu/?req.ip=209.150.44.95</target_url>",
+			"<target_url>http://example.edu/?req.ip=" + testIPHash + "</target_url>",
+		},
+		{
+			"URL in error message",
+			`Get "http://sfx.example.edu/?issn=0028-792X&rft.private_data=909782404": context deadline exceeded`,
+			`Get "http://sfx.example.edu/?issn=0028-792X": context deadline exceeded`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := redactor.redactText(testCase.text)
+			if actual != testCase.expected {
+				t.Errorf("Expected:\n%q\ngot:\n%q", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseRedactionRules(t *testing.T) {
+	testCases := []struct {
+		name          string
+		rules         []string
+		expected      map[string]string
+		expectedError bool
+	}{
+		{"Valid", []string{"req.ip:hash", "RFT.PRIVATE_DATA:drop"},
+			map[string]string{"req.ip": "hash", "rft.private_data": "drop"}, false},
+		{"Name containing a colon", []string{"sfx:session:mask"}, map[string]string{"sfx:session": "mask"}, false},
+		{"Empty", []string{}, map[string]string{}, false},
+		{"Missing action", []string{"req.ip"}, nil, true},
+		{"Missing name", []string{":drop"}, nil, true},
+		{"Invalid action", []string{"req.ip:encrypt"}, nil, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := ParseRedactionRules(testCase.rules)
+			if testCase.expectedError {
+				if err == nil {
+					t.Errorf("Expected error, got %v", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRedactionRules returned error: %s", err)
+			}
+			if !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("Expected %v, got %v", testCase.expected, actual)
+			}
+			if !reflect.DeepEqual(FormatRedactionRules(actual), FormatRedactionRules(testCase.expected)) {
+				t.Errorf("FormatRedactionRules did not round trip %v", actual)
+			}
+		})
+	}
+}
+
+func TestRedactionDisabled(t *testing.T) {
+	server := newTestServer(t, "http://sfx.example.edu", "http://primo.example.edu", Options{
+		Redaction: &RedactionPolicy{},
+	})
+
+	queryString := "req.ip=209.150.44.95&rft.private_data=909782404"
+	sharedLogEntryFields := server.getSharedLogEntryFields("", queryString)
+	if sharedLogEntryFields.QueryString != queryString || sharedLogEntryFields.QueryParams.Get("req.ip") != "209.150.44.95" {
+		t.Errorf("Expected no redaction, got %+v", sharedLogEntryFields)
+	}
+}
+
+func TestInvalidRedactionPolicy(t *testing.T) {
+	_, err := NewRouter(Options{
+		Redaction: &RedactionPolicy{Params: map[string]string{"req.ip": "encrypt"}},
+		Tenants:   []*Tenant{newTestTenant(DefaultTenantName, "http://sfx.example.edu", "http://primo.example.edu", nil)},
+	})
+	if err == nil {
+		t.Error("Expected NewRouter to return an error for an invalid redaction action")
+	}
+}
+
+func newTestRedactor(t *testing.T) *redactor {
+	t.Helper()
+
+	policy := RedactionPolicy{
+		Headers: DefaultRedactionPolicy.Headers,
+		Params:  map[string]string{"session": RedactionActionMask},
+	}
+	for name, action := range DefaultRedactionPolicy.Params {
+		policy.Params[name] = action
+	}
+
+	redactor, err := newRedactor(policy)
+	if err != nil {
+		t.Fatalf("newRedactor returned error: %s", err)
+	}
+
+	return redactor
+}
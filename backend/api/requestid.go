@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// Correlates the log entries for a single resolver request.  Accepted from the
+// request if valid, and always set on the response.
+const RequestIDHeader = "X-Request-ID"
+
+// Incoming request IDs that don't match are replaced with a generated ID, since
+// they end up in every log entry for the request.  This allows UUIDs, hex, and
+// base64 IDs of reasonable length.
+var validRequestIDRegexp = regexp.MustCompile(`^[A-Za-z0-9+/=._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// Stores the request ID in the request context and echoes it in the response
+// header, so that a user report containing the ID leads to the exact log
+// entries for the request.
+func (server *Server) requestIDWrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestIDRegexp.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+	})
+}
+
+// Returns the ID of the request that `ctx` belongs to, or "" if there isn't one.
+func getRequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+
+	return requestID
+}
+
+func newRequestID() string {
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		// crypto/rand doesn't fail on the platforms we run on.
+		panic(fmt.Sprintf("Could not generate request ID: %v", err))
+	}
+
+	return hex.EncodeToString(randomBytes)
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"ariadne/log"
+	"ariadne/testutils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var generatedRequestIDRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+func TestRequestIDHeader(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name              string
+		incomingRequestID string
+		expectedRequestID string
+	}{
+		{"UUID", "0b6f1f5e-3c3a-4d2e-9a4b-8c1d2e3f4a5b", "0b6f1f5e-3c3a-4d2e-9a4b-8c1d2e3f4a5b"},
+		{"Base64", "q2Vf+/9x==", "q2Vf+/9x=="},
+		{"Missing", "", ""},
+		{"Too long", strings.Repeat("a", 129), ""},
+		{"Contains whitespace", "request id", ""},
+		{"Contains quote", `request"id`, ""},
+	}
+
+	// The tenant lookup fails before any upstream requests are made.
+	server := newTestServer(t, "http://sfx.example.edu", "http://primo.example.edu", Options{})
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			header := http.Header{}
+			if testCase.incomingRequestID != "" {
+				header.Set(RequestIDHeader, testCase.incomingRequestID)
+			}
+			response := doUnknownTenantRequest(server, header)
+
+			requestID := response.Header.Get(RequestIDHeader)
+			if testCase.expectedRequestID == "" {
+				if !generatedRequestIDRegexp.MatchString(requestID) {
+					t.Errorf("Expected generated request ID, got \"%s\"", requestID)
+				}
+			} else if requestID != testCase.expectedRequestID {
+				t.Errorf("Expected request ID \"%s\", got \"%s\"", testCase.expectedRequestID, requestID)
+			}
+
+			var body Response
+			err := json.NewDecoder(response.Body).Decode(&body)
+			if err != nil {
+				t.Fatalf("Could not decode error response: %s", err)
+			}
+			if body.RequestID != requestID {
+				t.Errorf("Expected error response request ID \"%s\", got \"%s\"", requestID, body.RequestID)
+			}
+		})
+	}
+}
+
+func TestRequestIDIsGeneratedPerRequest(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, "http://sfx.example.edu", "http://primo.example.edu", Options{})
+
+	first := doUnknownTenantRequest(server, http.Header{}).Header.Get(RequestIDHeader)
+	second := doUnknownTenantRequest(server, http.Header{}).Header.Get(RequestIDHeader)
+	if first == second {
+		t.Errorf("Expected different request IDs, got \"%s\" twice", first)
+	}
+}
+
+func TestRequestIDInLogEntries(t *testing.T) {
+	t.Parallel()
+
+	testCase := getTestCase(t, "contrived-frbr-group-test-case")
+	fakeSFXServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sfxFakeResponse, err := testutils.GetSFXFakeResponse(testCase)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, sfxFakeResponse)
+		}),
+	)
+	defer fakeSFXServer.Close()
+	fakePrimoServer := newFakePrimoServer(testCase)
+	defer fakePrimoServer.Close()
+
+	var logOutput bytes.Buffer
+	server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+		Logger: log.New(&logOutput, log.LevelDebug),
+	})
+
+	header := http.Header{}
+	header.Set(RequestIDHeader, "support-ticket-1234")
+	doResolverRequestWithHeader(t, server, testCase.QueryString, header)
+	// Primo can't be searched for this one, which should be logged too.
+	doResolverRequestWithHeader(t, server, "isbn=9780198129104", header)
+
+	decoder := json.NewDecoder(&logOutput)
+	numEntries := 0
+	foundInvalidPrimoRequestEntry := false
+	foundInvalidISBNsEntry := false
+	for decoder.More() {
+		var logEntry struct {
+			Message string `json:"message"`
+			Ariadne struct {
+				RequestID string `json:"requestId"`
+			} `json:"ariadne"`
+		}
+		err := decoder.Decode(&logEntry)
+		if err != nil {
+			t.Fatalf("Could not decode log entry: %s", err)
+		}
+		numEntries++
+
+		if logEntry.Ariadne.RequestID != "support-ticket-1234" {
+			t.Errorf("Log entry \"%s\" has request ID \"%s\"", logEntry.Message, logEntry.Ariadne.RequestID)
+		}
+		if strings.HasPrefix(logEntry.Message, invalidPrimoRequestErrorMessage) &&
+			strings.Contains(logEntry.Message, "invalid ISBN \"9780198129104\"") {
+			foundInvalidPrimoRequestEntry = true
+		}
+		if logEntry.Message == invalidISBNsMessage+": 9780198129104" {
+			foundInvalidISBNsEntry = true
+		}
+	}
+	if numEntries == 0 {
+		t.Error("Expected log entries")
+	}
+	if !foundInvalidPrimoRequestEntry {
+		t.Error("Expected a log entry for the invalid Primo request")
+	}
+	if !foundInvalidISBNsEntry {
+		t.Error("Expected a log entry for the invalid ISBN")
+	}
+}
+
+func doUnknownTenantRequest(server *Server, header http.Header) *http.Response {
+	request := httptest.NewRequest("GET", "/v0/unknown/", nil)
+	request.Header = header
+
+	responseRecorder := httptest.NewRecorder()
+	server.ServeHTTP(responseRecorder, request)
+
+	return responseRecorder.Result()
+}
@@ -1,12 +1,93 @@
 package api
 
-// TODO
-type CitationSupplemental struct{}
+// Values for `Link.Source`
+const (
+	// A free, legal copy found by the open-access lookup
+	LinkSourceOpenAccess = "open_access"
+)
+
+// Normalized citation for the resource identified by the OpenURL, built from
+// the OpenURL query params and the context object attributes returned by SFX.
+type CitationSupplemental struct {
+	Genre        string   `json:"genre"`
+	Title        string   `json:"title"`
+	ArticleTitle string   `json:"article_title"`
+	Authors      []string `json:"authors"`
+	ISSN         string   `json:"issn"`
+	EISSN        string   `json:"eissn"`
+	ISBN         string   `json:"isbn"`
+	DOI          string   `json:"doi"`
+	PMID         string   `json:"pmid"`
+	Volume       string   `json:"volume"`
+	Issue        string   `json:"issue"`
+	StartPage    string   `json:"start_page"`
+	EndPage      string   `json:"end_page"`
+	Pages        string   `json:"pages"`
+	Date         string   `json:"date"`
+}
+
+// `Code` is machine-readable, and indicates whether retrying might help.  See
+// the ErrorCode* constants.  `Source` is where the error occurred: "request",
+// "sfx", "primo", or "ariadne".
+type Error struct {
+	Code    string `json:"code"`
+	Source  string `json:"source"`
+	Message string `json:"message"`
+}
+
+// A single coverage range for an SFX target.  A target can have several, for
+// example when a publisher's backfile and current content are licensed separately.
+type Coverage struct {
+	// Either or both of `From` and `To` can be nil, meaning the range is open-ended.
+	From    *CoverageLimit `json:"from,omitempty"`
+	To      *CoverageLimit `json:"to,omitempty"`
+	Embargo *Embargo       `json:"embargo,omitempty"`
+	// All coverage and embargo statements for this range, in the order that
+	// SFX returned them: e.g. "Available from 1992/01/01 until 2010/12/31" and
+	// "Most recent 1 year(s) not available".
+	Statements        []string `json:"statements"`
+	EmbargoStatements []string `json:"embargo_statements"`
+}
+
+// Zero values mean that SFX did not specify that part of the limit.  Volume and
+// issue are strings because they are not always numeric.
+type CoverageLimit struct {
+	Year   int    `json:"year,omitempty"`
+	Month  int    `json:"month,omitempty"`
+	Day    int    `json:"day,omitempty"`
+	Volume string `json:"volume,omitempty"`
+	Issue  string `json:"issue,omitempty"`
+}
+
+// A moving wall.  `Availability` is "available" if only the most recent
+// years/months/days are available, and "not_available" if the most recent
+// years/months/days are not available.  `Days` is the total length of the
+// embargo; SFX also gives it in years or months, whichever it was entered in.
+type Embargo struct {
+	Availability string `json:"availability"`
+	Years        int    `json:"years,omitempty"`
+	Months       int    `json:"months,omitempty"`
+	Days         int    `json:"days,omitempty"`
+}
 
 type Link struct {
-	DisplayName  string `json:"display_name"`
-	Url          string `json:"url"`
-	CoverageText string `json:"coverage_text"`
+	DisplayName string `json:"display_name"`
+	Url         string `json:"url"`
+	// The first coverage statement(s) of the first coverage range, joined.
+	// Kept for clients that don't need the full `Coverage`.
+	CoverageText string     `json:"coverage_text"`
+	Coverage     []Coverage `json:"coverage"`
+	// Whether `Coverage` includes the citation's date, volume, and issue.  See
+	// the CoverageStatus* constants.
+	CoverageStatus string `json:"coverage_status"`
+	// Only set for links that don't come from the library's subscriptions.  See
+	// the LinkSource* constants.
+	Source string `json:"source,omitempty"`
+	// For open-access links: the license, e.g. "cc-by", if known, and the
+	// version of the work: "publishedVersion", "acceptedVersion", or
+	// "submittedVersion".
+	License string `json:"license,omitempty"`
+	Version string `json:"version,omitempty"`
 }
 
 type Record struct {
@@ -15,7 +96,14 @@ type Record struct {
 }
 
 type Response struct {
-	Errors  []string `json:"errors"`
+	Errors  []Error  `json:"errors"`
 	Found   bool     `json:"found"`
 	Records []Record `json:"records"`
+	// Problems with the request that didn't stop it from being resolved, e.g.
+	// invalid ISBNs which were skipped.  In the same format as `Errors`, which
+	// clients treat as failures.
+	Warnings []Error `json:"warnings,omitempty"`
+	// Only set for error responses, so that a user reporting an error can give
+	// support staff the ID to search the logs for.
+	RequestID string `json:"request_id,omitempty"`
 }
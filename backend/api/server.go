@@ -1,142 +1,627 @@
 package api
 
 import (
+	"ariadne/crossref"
 	"ariadne/log"
 	"ariadne/primo"
 	"ariadne/sfx"
+	"ariadne/tracing"
+	"ariadne/unpaywall"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
+const invalidISBNsMessage = "Invalid ISBNs skipped"
 const invalidPrimoRequestErrorMessage = "Invalid Primo request"
 const invalidSFXRequestErrorMessage = "Invalid SFX request"
 
-// Setup a new mux router with the appropriate routes for this app
-func NewRouter() *http.ServeMux {
-	router := http.NewServeMux()
+// Query params which are for Ariadne itself, and so are not sent to SFX.
+var ariadneParams = map[string]struct{}{
+	HideOutOfCoverageParam: {},
+	TenantParam:            {},
+}
 
-	router.Handle("/healthcheck", http.HandlerFunc(healthCheck))
-	router.Handle("/v0/", recoverWrap(http.HandlerFunc(ResolverHandler)))
+// Default deadline for all upstream requests made on behalf of a single
+// resolver request.
+const DefaultResolverTimeout = 30 * time.Second
+
+// Origins allowed to make cross-origin requests to the API.  "*" allows all.
+var DefaultCORSAllowedOrigins = []string{"*"}
+
+// Options for `NewRouter`.  Zero values are replaced by the defaults noted.
+type Options struct {
+	// Defaults to `DefaultCORSAllowedOrigins`.
+	CORSAllowedOrigins []string
+	// Looks up metadata for OpenURLs with DOIs that SFX and Primo don't find
+	// anything for.  DOI lookups are disabled if nil.
+	Crossref *crossref.Client
+	// Name of the tenant used for requests that don't select one.  Defaults to
+	// `DefaultTenantName`.
+	DefaultTenant string
+	// Truncation and sampling of log entries containing dumped SFX and Primo
+	// requests and responses.  Defaults to logging all of them in full.
+	Dumps *log.Dumps
+	// Whether out-of-coverage SFX links are removed from responses for requests
+	// that don't have a `hide_out_of_coverage` query param.
+	HideOutOfCoverage bool
+	// Defaults to `log.Default()`.
+	Logger *log.Logger
+	// Defaults to `NewMetrics()`.  See `Metrics` regarding upstream latency.
+	Metrics *Metrics
+	// Returns the current time, which SFX coverage embargoes are relative to.
+	// Defaults to `time.Now`.
+	Now func() time.Time
+	// Query params and headers redacted from log entries.  Defaults to
+	// `DefaultRedactionPolicy`.
+	Redaction *RedactionPolicy
+	// How long /readyz reuses upstream probe results.  Defaults to
+	// `DefaultReadinessCacheTTL`.
+	ReadinessCacheTTL time.Duration
+	// Defaults to `DefaultReadinessProbeTimeout`.
+	ReadinessProbeTimeout time.Duration
+	// Deadline for all upstream requests made on behalf of a single resolver
+	// request.  Defaults to `DefaultResolverTimeout`.
+	ResolverTimeout time.Duration
+	// The response cache is disabled if `ResponseCache.Cache` is nil.
+	ResponseCache ResponseCacheOptions
+	// Defaults to a single "nyu" tenant with the default SFX and Primo clients.
+	Tenants []*Tenant
+	// Records a span for each resolver request, which is the parent of the
+	// SFX and Primo request spans.  Tracing is disabled if nil.
+	Tracer *tracing.Tracer
+	// Looks up free, legal copies of works with DOIs for responses with no
+	// subscribed full text.  Open-access lookups are disabled if nil.
+	Unpaywall *unpaywall.Client
+}
 
-	return router
+// Serves the API.  All configuration is per server, so that several servers
+// with different upstreams can run in the same process -- e.g. in parallel tests.
+type Server struct {
+	corsAllowedOrigins []string
+	crossrefClient     *crossref.Client
+	defaultTenant      *Tenant
+	// Set by `StartDraining`
+	draining          atomic.Bool
+	dumps             *log.Dumps
+	hideOutOfCoverage bool
+	logger            *log.Logger
+	metrics           *Metrics
+	now               func() time.Time
+	readinessChecker  *readinessChecker
+	redactor          *redactor
+	resolverTimeout   time.Duration
+	responseCache     ResponseCacheOptions
+	router            *http.ServeMux
+	tenants           map[string]*Tenant
+	tracer            *tracing.Tracer
+	unpaywallClient   *unpaywall.Client
+}
+
+type primoResult struct {
+	response *primo.PrimoResponse
+	err      error
+}
+
+// A Primo search for a single resolver request, which is only made once
+// started.  See `newPrimoLookup`.
+type primoLookup struct {
+	client      *primo.Client
+	queryString string
+	// Nil if Primo couldn't be searched for the OpenURL, in which case `result`
+	// already has the error.
+	request *primo.PrimoRequest
+	// See `primo.PrimoRequest.InvalidISBNs`.  Set even if `request` is nil.
+	invalidISBNs []string
+	// Buffered, so that the goroutine making the Primo requests never blocks if
+	// the result ends up not being needed.
+	result  chan primoResult
+	started bool
+}
+
+// Returned by `awaitPrimoResponse` when Primo couldn't be searched for the
+// OpenURL, so no request was made.
+type invalidPrimoRequestError struct {
+	err error
+}
+
+func (err *invalidPrimoRequestError) Error() string {
+	return fmt.Sprintf("%s: %v", invalidPrimoRequestErrorMessage, err.err)
+}
+
+func (err *invalidPrimoRequestError) Unwrap() error {
+	return err.err
+}
+
+type resolution struct {
+	// Which backend produced the response: "sfx", "primo", or "doi", which means
+	// that the only full text link is the DOI link.
+	backend  string
+	response Response
+	// True if the response was produced without SFX, because it was unavailable.
+	degraded bool
+	// True if a Primo, Crossref, or Unpaywall lookup failed, so that the
+	// response might be missing links that it would otherwise have had.  Like
+	// degraded responses, partial responses are not cached, so that one
+	// transient upstream error isn't served for the whole cache TTL.
+	partial bool
+	// The upstream responses the response was made from.  Nil if not queried.
+	primoResponse *primo.PrimoResponse
+	sfxResponse   *sfx.SFXResponse
+}
+
+// Returns a server with the appropriate routes for this app.  Returns an error
+// if the tenants are invalid.
+func NewRouter(options Options) (*Server, error) {
+	server := &Server{
+		corsAllowedOrigins: options.CORSAllowedOrigins,
+		crossrefClient:     options.Crossref,
+		dumps:              options.Dumps,
+		hideOutOfCoverage:  options.HideOutOfCoverage,
+		logger:             options.Logger,
+		metrics:            options.Metrics,
+		now:                options.Now,
+		resolverTimeout:    options.ResolverTimeout,
+		responseCache:      options.ResponseCache,
+		tracer:             options.Tracer,
+		unpaywallClient:    options.Unpaywall,
+	}
+	if server.corsAllowedOrigins == nil {
+		server.corsAllowedOrigins = DefaultCORSAllowedOrigins
+	}
+	if server.dumps == nil {
+		server.dumps = log.NewDumps(log.DumpOptions{SampleRate: 1})
+	}
+	if server.logger == nil {
+		server.logger = log.Default()
+	}
+	if server.metrics == nil {
+		server.metrics = NewMetrics()
+	}
+	if server.now == nil {
+		server.now = time.Now
+	}
+	if server.resolverTimeout == 0 {
+		server.resolverTimeout = DefaultResolverTimeout
+	}
+	if server.responseCache.Timeout <= 0 {
+		server.responseCache.Timeout = DefaultCacheTimeout
+	}
+
+	redactionPolicy := DefaultRedactionPolicy
+	if options.Redaction != nil {
+		redactionPolicy = *options.Redaction
+	}
+	redactor, err := newRedactor(redactionPolicy)
+	if err != nil {
+		return nil, err
+	}
+	server.redactor = redactor
+
+	tenants := options.Tenants
+	if len(tenants) == 0 {
+		tenants = []*Tenant{newDefaultTenant(server.logger)}
+	}
+	defaultTenantName := options.DefaultTenant
+	if defaultTenantName == "" {
+		defaultTenantName = DefaultTenantName
+	}
+	err = server.setTenants(tenants, defaultTenantName)
+	if err != nil {
+		return nil, err
+	}
+
+	readinessCacheTTL := options.ReadinessCacheTTL
+	if readinessCacheTTL == 0 {
+		readinessCacheTTL = DefaultReadinessCacheTTL
+	}
+	readinessProbeTimeout := options.ReadinessProbeTimeout
+	if readinessProbeTimeout == 0 {
+		readinessProbeTimeout = DefaultReadinessProbeTimeout
+	}
+	server.readinessChecker = newReadinessChecker(server.tenants, readinessCacheTTL, readinessProbeTimeout)
+
+	server.router = http.NewServeMux()
+	server.router.Handle("/healthcheck", http.HandlerFunc(server.healthCheck))
+	server.router.Handle("/metrics", server.metrics.registry.Handler())
+	server.router.Handle("/readyz", http.HandlerFunc(server.readinessCheck))
+	server.router.Handle("/v0/", server.requestIDWrap(server.traceWrap(server.recoverWrap(http.HandlerFunc(server.ResolverHandler)))))
+
+	return server, nil
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.router.ServeHTTP(w, r)
+}
+
+// Makes /healthcheck and /readyz fail, so that the load balancer stops routing new requests
+// to this server while in-flight requests are drained.  Resolver requests are
+// still served normally.
+func (server *Server) StartDraining() {
+	server.draining.Store(true)
 }
 
 // Handler for the endpoint used by the frontend
-func ResolverHandler(w http.ResponseWriter, r *http.Request) {
-	setHeaders(&w)
+func (server *Server) ResolverHandler(w http.ResponseWriter, r *http.Request) {
+	server.setHeaders(w, r)
+
+	requestID := getRequestID(r.Context())
+	span := tracing.SpanFromContext(r.Context())
+	span.SetAttribute("ariadne.request_id", requestID)
 
-	sfxResponse, err := getSFXResponse(r.URL.RawQuery)
+	tenant, err := server.getTenant(r)
 	if err != nil {
-		handleBadRequestError(err, r, w, err.Error())
+		server.metrics.resolverRequests.Inc(outcomeError)
+		server.handleError(err, r, w, []Error{{ErrorCodeUnknownTenant, ErrorSourceRequest, err.Error()}}, http.StatusNotFound)
 		return
 	}
+	span.SetAttribute("ariadne.tenant", tenant.Name)
+
+	cache := server.responseCache.Cache
+	cacheKey := ""
+	if cache != nil {
+		cacheKey = makeCacheKey(tenant.Name, r.URL.RawQuery)
+
+		if r.Header.Get(CacheBypassHeader) != "" {
+			server.logCacheStatus(requestID, r.URL.RawQuery, cacheStatusBypass, cacheKey, "")
+			w.Header().Set(CacheStatusHeader, cacheStatusBypass)
+		} else if entry, ok := server.getCachedResponse(r.Context(), r.URL.RawQuery, cacheKey); ok {
+			server.logCacheStatus(requestID, r.URL.RawQuery, cacheStatusHit, cacheKey, entry.Backend)
+			w.Header().Set(CacheStatusHeader, cacheStatusHit)
+			span.SetAttribute("ariadne.cache", cacheStatusHit)
+			server.metrics.observeResolution(entry.Backend, entry.Response)
+			server.writeAriadneResponse(w, r, entry.Response)
+			return
+		} else {
+			server.logCacheStatus(requestID, r.URL.RawQuery, cacheStatusMiss, cacheKey, "")
+			w.Header().Set(CacheStatusHeader, cacheStatusMiss)
+		}
+	}
 
-	sfxAPIResponseLogEntry := makeNewSFXAPIResponseLogEntry(r.URL.RawQuery, sfxResponse.DumpedHTTPResponse)
-	log.Debug(MessageKey, "SFX API Response", AriadneKey, sfxAPIResponseLogEntry)
+	resolution, resolverErr := server.resolve(r.Context(), tenant, r.URL.RawQuery)
+	if resolverErr != nil {
+		server.metrics.resolverRequests.Inc(outcomeError)
+		server.handleError(resolverErr.err, r, w, resolverErr.errors, resolverErr.httpStatusCode)
+		return
+	}
+	server.metrics.observeResolution(resolution.backend, resolution.response)
+	span.SetAttribute("ariadne.backend", resolution.backend)
+	span.SetAttribute("ariadne.degraded", resolution.degraded)
+	span.SetAttribute("ariadne.partial", resolution.partial)
+
+	// Degraded and partial responses are not cached, so that the full response
+	// is returned as soon as the failed upstreams are available again.
+	if cache != nil && !resolution.degraded && !resolution.partial {
+		server.setCachedResponse(r.Context(), r.URL.RawQuery, cacheKey, resolution)
+	}
 
-	var ariadneResponse Response
+	server.writeAriadneResponse(w, r, resolution.response)
+}
 
-	if sfxResponse.IsFound() {
-		ariadneResponse = makeAriadneResponseFromSFXResponse(sfxResponse)
-	} else {
-		primoResponse, err := getPrimoResponse(r.URL.RawQuery)
-		if err != nil {
-			// If we got this far, we already know that Ariadne was able to
-			// successfully query SFX request, so we do not want this Primo request
-			// error to be fatal, since this we still technically have a valid
-			// Ariadne request.  We return the SFX results, which at least will
-			// have "helper" links.
-			ariadneResponse = makeAriadneResponseFromSFXResponse(sfxResponse)
+func (server *Server) logCacheStatus(requestID string, queryString string, status string, key string, backend string) {
+	cacheLogEntry := server.makeCacheLogEntry(requestID, queryString, status, key, backend)
+	server.logger.Info(MessageKey, "Response cache "+status, AriadneKey, cacheLogEntry)
+}
+
+// Queries SFX and Primo for the OpenURL in `queryString`.  Returns a non-nil
+// *resolverError if no response could be produced.
+func (server *Server) resolve(ctx context.Context, tenant *Tenant, queryString string) (resolved resolution, resolverErr *resolverError) {
+	// All upstream requests made on behalf of this request share the same
+	// deadline, and are cancelled if the client goes away.
+	ctx, cancel := context.WithTimeout(ctx, server.resolverTimeout)
+	defer cancel()
+
+	requestID := getRequestID(ctx)
+
+	sfxRequest, err := server.newSFXRequest(tenant.SFXClient, requestID, queryString)
+	if err != nil {
+		return resolution{}, &resolverError{
+			err,
+			[]Error{{ErrorCodeInvalidRequest, ErrorSourceRequest, err.Error()}},
+			http.StatusBadRequest,
+		}
+	}
+
+	// The Primo result is only used if SFX doesn't find anything.  That's common
+	// for books, so ISBN searches are started before the SFX lookup so that they
+	// run concurrently, and we will have already spent most or all of the time
+	// waiting for Primo by the time SFX comes up empty.  Other OpenURLs are
+	// mostly for articles, which SFX usually finds, so their Primo searches wait
+	// for SFX rather than loading Primo with searches whose results are thrown
+	// away.
+	primoLookup := server.newPrimoLookup(ctx, tenant.PrimoClient, queryString)
+	if primoLookup.request != nil && primoLookup.request.Strategy == primo.SearchStrategyISBN {
+		server.startPrimoLookup(ctx, primoLookup)
+	}
+
+	// Invalid ISBNs are reported however the request is resolved, and whether or
+	// not Primo ended up being searched.
+	defer func() {
+		invalidISBNErrors := makeInvalidISBNErrors(primoLookup.invalidISBNs)
+		if resolverErr != nil {
+			resolverErr.errors = append(resolverErr.errors, invalidISBNErrors...)
 		} else {
-			for i, dumpedFRBRMemberHTTPRequest := range primoResponse.DumpedFRBRMemberHTTPRequests {
-				primoAPIFRBRMemberRequestLogEntry :=
-					makePrimoAPIFRBRMemberRequestLogEntry(r.URL.RawQuery, dumpedFRBRMemberHTTPRequest)
-				log.Info(MessageKey, fmt.Sprintf("Primo API FRBR member request #%d", i+1),
-					AriadneKey, primoAPIFRBRMemberRequestLogEntry)
+			resolved.response.Warnings = append(resolved.response.Warnings, invalidISBNErrors...)
+		}
+	}()
+
+	sfxResponse, err := tenant.SFXClient.Do(ctx, sfxRequest)
+	if err != nil {
+		// SFX is down, erroring, or its circuit breaker is open.  Degrade to
+		// Primo, which can at least handle books.  If Primo doesn't find anything
+		// either, there are no "helper" links to fall back on, so the request fails.
+		server.logger.Warn(MessageKey, server.redactor.redactText(fmt.Sprintf("SFX unavailable, falling back to Primo: %v", err)),
+			"circuitBreakers", tenant.getCircuitBreakerStates(),
+			AriadneKey, server.getSharedLogEntryFields(requestID, queryString))
+
+		primoResponse, primoErr := server.awaitPrimoResponse(ctx, primoLookup)
+		if primoErr != nil || !primoResponse.IsFound() {
+			sfxError, httpStatusCode := newUpstreamError(ErrorSourceSFX, err)
+			apiErrors := []Error{sfxError}
+			var invalidPrimoRequestErr *invalidPrimoRequestError
+			switch {
+			case primoErr == nil:
+			case errors.As(primoErr, &invalidPrimoRequestErr):
+				// An invalid Primo request just means that Primo couldn't be used for
+				// this OpenURL, which is not an error from the user's point of view.
+				// Invalid ISBNs are reported for all requests.
+			default:
+				primoError, _ := newUpstreamError(ErrorSourcePrimo, primoErr)
+				apiErrors = append(apiErrors, primoError)
 			}
 
-			primoAPIISBNSearchResponseLogEntry :=
-				makePrimoAPIISBNSearchResponseLogEntry(r.URL.RawQuery, primoResponse.DumpedHTTPResponses[0])
-			log.Debug(MessageKey, "Primo API ISBN Search Response",
-				AriadneKey, primoAPIISBNSearchResponseLogEntry)
+			return resolution{}, &resolverError{err, apiErrors, httpStatusCode}
+		}
 
-			for i := 1; i < len(primoResponse.DumpedHTTPResponses); i++ {
-				primoAPIFRBRMemberResponseLogEntry :=
-					makePrimoAPIFRBRMemberResponseLogEntry(r.URL.RawQuery, primoResponse.DumpedHTTPResponses[1])
-				log.Debug(MessageKey, fmt.Sprintf("Primo API FRBR Member Response #%d", i),
-					AriadneKey, primoAPIFRBRMemberResponseLogEntry)
-			}
+		citationSupplemental := server.makeCitationSupplemental(requestID, queryString, &sfx.SFXResponse{})
+		return resolution{
+			backend:       backendPrimo,
+			response:      makeAriadneResponseFromPrimoResponse(primoResponse, citationSupplemental),
+			degraded:      true,
+			primoResponse: primoResponse,
+		}, nil
+	}
 
-			if primoResponse.IsFound() {
-				ariadneResponse = makeAriadneResponseFromPrimoResponse(primoResponse)
-			} else {
-				// Back to SFX again, which at least has some "helper" link
-				ariadneResponse = makeAriadneResponseFromSFXResponse(sfxResponse)
+	if server.shouldLogDumpedResponses(requestID) {
+		sfxAPIResponseLogEntry := server.makeNewSFXAPIResponseLogEntry(requestID, queryString, sfxResponse.DumpedHTTPResponse)
+		server.logger.Debug(MessageKey, "SFX API Response", AriadneKey, sfxAPIResponseLogEntry)
+	}
+
+	server.removeTargets(tenant, requestID, queryString, sfxResponse)
+
+	citationSupplemental := server.makeCitationSupplemental(requestID, queryString, sfxResponse)
+
+	var primoResponse *primo.PrimoResponse
+	partial := false
+	if !sfxResponse.IsFound() {
+		primoResponse, err = server.awaitPrimoResponse(ctx, primoLookup)
+		if err != nil {
+			// An invalid Primo request has already been logged, and will be
+			// invalid the next time too.
+			var invalidPrimoRequestErr *invalidPrimoRequestError
+			if !errors.As(err, &invalidPrimoRequestErr) {
+				server.logger.Warn(MessageKey, server.redactor.redactText(fmt.Sprintf("Primo lookup failed: %v", err)),
+					AriadneKey, server.getSharedLogEntryFields(requestID, queryString))
+				partial = true
 			}
+			primoResponse = nil
+		} else if primoResponse.IsFound() {
+			return resolution{
+				backend:       backendPrimo,
+				response:      makeAriadneResponseFromPrimoResponse(primoResponse, citationSupplemental),
+				primoResponse: primoResponse,
+				sfxResponse:   sfxResponse,
+			}, nil
 		}
+
+		// Last chance: if the OpenURL has a DOI, its metadata might be enough
+		// for SFX to find something.
+		doiResolution, ok, doiErr := server.resolveDOI(ctx, tenant, requestID, queryString, sfxResponse)
+		if ok {
+			doiResolution.primoResponse = primoResponse
+			doiResolution.partial = doiResolution.partial || partial
+			return server.addOpenAccessLinks(ctx, requestID, queryString, doiResolution), nil
+		}
+		if doiErr != nil {
+			partial = true
+		}
+
+		// If we got this far, we already know that Ariadne was able to
+		// successfully query SFX request, so we do not want a Primo request
+		// error to be fatal, since this we still technically have a valid
+		// Ariadne request.  We return the SFX results, which at least will
+		// have "helper" links.
 	}
 
-	ariadneAPIResponseLogEntry :=
-		makeAriadneAPIResponseLogEntry(r.URL.RawQuery, ariadneResponse)
-	log.Info(MessageKey, "Ariadne API response", AriadneKey, ariadneAPIResponseLogEntry)
+	sfxResolution := resolution{
+		backend:       backendSFX,
+		response:      makeAriadneResponseFromSFXResponse(sfxResponse, citationSupplemental, shouldHideOutOfCoverage(queryString, server.hideOutOfCoverage), server.now()),
+		partial:       partial,
+		primoResponse: primoResponse,
+		sfxResponse:   sfxResponse,
+	}
 
-	responseJSON := makeAriadneResponseJSON(ariadneResponse)
+	return server.addOpenAccessLinks(ctx, requestID, queryString, sfxResolution), nil
+}
 
-	fmt.Fprintln(w, responseJSON)
+// Starts the Primo lookup if it hasn't been started yet, waits for its result,
+// and logs the FRBR member requests and, if sampled, all responses if it
+// succeeded.
+func (server *Server) awaitPrimoResponse(ctx context.Context, primoLookup *primoLookup) (*primo.PrimoResponse, error) {
+	requestID := getRequestID(ctx)
+	queryString := primoLookup.queryString
+
+	server.startPrimoLookup(ctx, primoLookup)
+	primoResult := <-primoLookup.result
+	primoResponse, err := primoResult.response, primoResult.err
+	if err != nil {
+		return primoResponse, err
+	}
+
+	for i, dumpedFRBRMemberHTTPRequest := range primoResponse.DumpedFRBRMemberHTTPRequests {
+		primoAPIFRBRMemberRequestLogEntry :=
+			server.makePrimoAPIFRBRMemberRequestLogEntry(requestID, queryString, dumpedFRBRMemberHTTPRequest)
+		server.logger.Info(MessageKey, fmt.Sprintf("Primo API FRBR member request #%d", i+1),
+			AriadneKey, primoAPIFRBRMemberRequestLogEntry)
+	}
+
+	if !server.shouldLogDumpedResponses(requestID) {
+		return primoResponse, nil
+	}
+
+	primoAPISearchResponseLogEntry := server.makePrimoAPISearchResponseLogEntry(
+		requestID, queryString, primoResponse.Strategy, primoResponse.DumpedHTTPResponses[0])
+	server.logger.Debug(MessageKey, "Primo API Search Response",
+		AriadneKey, primoAPISearchResponseLogEntry)
+
+	for i := 1; i < len(primoResponse.DumpedHTTPResponses); i++ {
+		primoAPIFRBRMemberResponseLogEntry :=
+			server.makePrimoAPIFRBRMemberResponseLogEntry(requestID, queryString, primoResponse.DumpedHTTPResponses[i])
+		server.logger.Debug(MessageKey, fmt.Sprintf("Primo API FRBR Member Response #%d", i),
+			AriadneKey, primoAPIFRBRMemberResponseLogEntry)
+	}
+
+	return primoResponse, nil
 }
 
-func getPrimoResponse(queryString string) (*primo.PrimoResponse, error) {
-	primoRequest, err := primo.NewPrimoRequest(queryString)
+func (server *Server) newSFXRequest(sfxClient *sfx.Client, requestID string, queryString string) (*sfx.SFXRequest, error) {
+	sfxRequest, err := sfxClient.NewRequest(removeAriadneParams(queryString))
 	if err != nil {
-		return &primo.PrimoResponse{}, errors.New(invalidPrimoRequestErrorMessage)
+		return sfxRequest, fmt.Errorf("%s: %v", invalidSFXRequestErrorMessage, err)
 	}
 
-	primoAPIISBNSearchRequestLogEntry :=
-		makePrimoAPIISBNSearchRequestLogEntry(queryString, primoRequest.DumpedISBNSearchHTTPRequest)
-	log.Info(MessageKey, "Primo API ISBN Search Request", AriadneKey, primoAPIISBNSearchRequestLogEntry)
+	sfxAPIRequestLogEntry := server.makeNewSFXAPIRequestLogEntry(requestID, queryString, sfxRequest.DumpedHTTPRequest)
+	server.logger.Info(MessageKey, "SFX API Request", AriadneKey, sfxAPIRequestLogEntry)
+
+	return sfxRequest, nil
+}
+
+// Removes the `ariadneParams` from the query string.  Everything else is left
+// as is, including anything that `url.ParseQuery` would reject, so that the SFX
+// request gets the same parse errors as the original query string.
+func removeAriadneParams(queryString string) string {
+	prefix := ""
+	if strings.HasPrefix(queryString, "?") {
+		prefix = "?"
+		queryString = queryString[1:]
+	}
+
+	params := []string{}
+	for _, param := range strings.Split(queryString, "&") {
+		paramName, _, _ := strings.Cut(param, "=")
+		if unescapedParamName, err := url.QueryUnescape(paramName); err == nil {
+			paramName = unescapedParamName
+		}
+		if _, ok := ariadneParams[strings.ToLower(paramName)]; ok {
+			continue
+		}
+		params = append(params, param)
+	}
 
-	return primo.Do(primoRequest)
+	return prefix + strings.Join(params, "&")
 }
 
-func getSFXResponse(queryString string) (*sfx.SFXResponse, error) {
-	sfxResponse := sfx.SFXResponse{}
+// Creates the Primo request for the OpenURL in `queryString`, or logs why there
+// can't be one, without making any HTTP requests.  See `startPrimoLookup`.
+func (server *Server) newPrimoLookup(ctx context.Context, primoClient *primo.Client, queryString string) *primoLookup {
+	primoLookup := &primoLookup{
+		client:      primoClient,
+		queryString: queryString,
+		result:      make(chan primoResult, 1),
+	}
+
+	requestID := getRequestID(ctx)
+
+	primoRequest, err := primoClient.NewRequest(queryString)
+
+	// Invalid ISBNs are worth knowing about even if there was something else to
+	// search for.
+	primoLookup.invalidISBNs = primoRequest.InvalidISBNs
+	if len(primoLookup.invalidISBNs) > 0 {
+		server.logger.Info(MessageKey,
+			server.redactor.redactText(fmt.Sprintf("%s: %s", invalidISBNsMessage, strings.Join(primoLookup.invalidISBNs, ", "))),
+			AriadneKey, server.getSharedLogEntryFields(requestID, queryString))
+	}
 
-	sfxRequest, err := sfx.NewSFXRequest(queryString)
 	if err != nil {
-		return &sfxResponse, errors.New(invalidSFXRequestErrorMessage)
+		err = &invalidPrimoRequestError{err}
+		// Most OpenURLs without anything for Primo to search for are for articles,
+		// which Primo isn't needed for.
+		server.logger.Debug(MessageKey, server.redactor.redactText(err.Error()),
+			AriadneKey, server.getSharedLogEntryFields(requestID, queryString))
+
+		primoLookup.result <- primoResult{&primo.PrimoResponse{}, err}
+		primoLookup.started = true
+		return primoLookup
 	}
 
-	sfxAPIRequestLogEntry := makeNewSFXAPIRequestLogEntry(queryString, sfxRequest.DumpedHTTPRequest)
-	log.Info(MessageKey, "SFX API Request", AriadneKey, sfxAPIRequestLogEntry)
+	primoLookup.request = primoRequest
 
-	return sfx.Do(sfxRequest)
+	return primoLookup
 }
 
-func handleBadRequestError(err error, r *http.Request, w http.ResponseWriter, message string) {
+// Does nothing if the lookup has already been started.  The Primo request is
+// logged synchronously so that log entries are written in a deterministic
+// order.  Only the HTTP requests to Primo are made in the background.  Must
+// only be called from the goroutine handling the resolver request.
+func (server *Server) startPrimoLookup(ctx context.Context, primoLookup *primoLookup) {
+	if primoLookup.started {
+		return
+	}
+	primoLookup.started = true
+
+	primoRequest := primoLookup.request
+	primoAPISearchRequestLogEntry := server.makePrimoAPISearchRequestLogEntry(
+		getRequestID(ctx), primoLookup.queryString, primoRequest.Strategy, primoRequest.DumpedSearchHTTPRequest)
+	server.logger.Info(MessageKey, "Primo API Search Request", AriadneKey, primoAPISearchRequestLogEntry)
+
+	go func() {
+		primoResponse, err := primoLookup.client.Do(ctx, primoRequest)
+		primoLookup.result <- primoResult{primoResponse, err}
+	}()
+}
+
+func (server *Server) handleError(err error, r *http.Request, w http.ResponseWriter, apiErrors []Error, httpStatusCode int) {
+	requestID := getRequestID(r.Context())
 	response := Response{
-		Errors:  []string{message},
-		Found:   false,
-		Records: []Record{},
+		Errors:    apiErrors,
+		Found:     false,
+		Records:   []Record{},
+		RequestID: requestID,
 	}
 	responseJSON, _ := json.MarshalIndent(response, "", "    ")
 
 	ariadneAPIErrorResponseLogEntry :=
-		makeAriadneAPIErrorResponseLogEntry(r.URL.RawQuery, err, http.StatusBadRequest, response)
-	log.Error(MessageKey, err.Error(), AriadneKey, ariadneAPIErrorResponseLogEntry)
+		server.makeAriadneAPIErrorResponseLogEntry(requestID, r.URL.RawQuery, err, httpStatusCode, response)
+	server.logger.Error(MessageKey, server.redactor.redactText(err.Error()), AriadneKey, ariadneAPIErrorResponseLogEntry)
 
-	http.Error(w, string(responseJSON), http.StatusBadRequest)
+	http.Error(w, string(responseJSON), httpStatusCode)
 }
 
-// healthCheck returns a successful response, that's it
-func healthCheck(w http.ResponseWriter, r *http.Request) {
+// healthCheck returns a successful response, along with the state of the
+// default tenant's upstream circuit breakers, unless the server is draining.
+// An open breaker doesn't make this service unhealthy, since requests are
+// degraded rather than failed.
+func (server *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
+	status := "ok"
 	w.Header().Add("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{"status": "ok"})
+	if server.draining.Load() {
+		status = "draining"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":          status,
+		"circuitBreakers": server.defaultTenant.getCircuitBreakerStates(),
+	})
 }
 
-func makeAriadneResponseFromPrimoResponse(primoResponse *primo.PrimoResponse) Response {
+func makeAriadneResponseFromPrimoResponse(primoResponse *primo.PrimoResponse, citationSupplemental CitationSupplemental) Response {
 	links := []Link{}
 	for _, primoLink := range primoResponse.Links {
 		displayName := primoLink.HyperlinkText
@@ -144,9 +629,10 @@ func makeAriadneResponseFromPrimoResponse(primoResponse *primo.PrimoResponse) Re
 			displayName = "Link to Online Resource"
 		}
 		links = append(links, Link{
-			displayName,
-			primoLink.LinkURL,
-			"",
+			DisplayName:    displayName,
+			Url:            primoLink.LinkURL,
+			Coverage:       []Coverage{},
+			CoverageStatus: CoverageStatusUnknown,
 		})
 	}
 
@@ -154,65 +640,58 @@ func makeAriadneResponseFromPrimoResponse(primoResponse *primo.PrimoResponse) Re
 	// multiple records later.
 	records := []Record{
 		{
-			CitationSupplemental{},
+			citationSupplemental,
 			links,
 		},
 	}
 
 	return Response{
-		Errors:  []string{},
+		Errors:  []Error{},
 		Found:   primoResponse.IsFound(),
 		Records: records,
 	}
 }
 
-func makeAriadneResponseFromSFXResponse(sfxResponse *sfx.SFXResponse) Response {
-	// Remove the Ask a Librarian target -- for details, see:
-	// https://nyu-lib.monday.com/boards/765008773/pulses/3548498827
-	sfxResponse.RemoveTarget(sfx.AskALibrarianLink)
-	emptyTarget := sfxResponse.GetTarget("")
-	if emptyTarget != nil {
-		log.Warn(MessageKey, "Removing target with empty TargetURL", emptyTarget)
-		sfxResponse.RemoveTarget("")
-	}
-
+func makeAriadneResponseFromSFXResponse(sfxResponse *sfx.SFXResponse, citationSupplemental CitationSupplemental, hideOutOfCoverage bool, now time.Time) Response {
+	// The tenant's removed targets have already been removed.
 	links := []Link{}
 	targets := (*(*sfxResponse.XMLResponseBody.ContextObject)[0].SFXContextObjectTargets)[0].Targets
 	for _, target := range *targets {
-		coverageText := ""
-		if target.Coverage != nil {
-			firstCoverage := (*(target.Coverage))[0]
-			if firstCoverage.CoverageText != nil {
-				firstCoverageText := (*(firstCoverage.CoverageText))[0]
-				if firstCoverageText.ThresholdText != nil {
-					firstThresholdText := (*(firstCoverageText.ThresholdText))[0]
-					coverageText = strings.Join(firstThresholdText.CoverageStatement, ". ")
-				}
-			}
-		}
 		links = append(links, Link{
-			target.TargetPublicName,
-			target.TargetUrl,
-			coverageText,
+			DisplayName:  target.TargetPublicName,
+			Url:          target.TargetUrl,
+			CoverageText: makeCoverageText(target),
+			Coverage:     makeCoverage(target),
 		})
 	}
+	links = rankLinksByCoverage(links, citationSupplemental, hideOutOfCoverage, now)
 
 	// For now only return one record, but anticipate needing to be able to deliver
 	// multiple records later.
 	records := []Record{
 		{
-			CitationSupplemental{},
+			citationSupplemental,
 			links,
 		},
 	}
 
 	return Response{
-		Errors:  []string{},
+		Errors:  []Error{},
 		Found:   sfxResponse.IsFound(),
 		Records: records,
 	}
 }
 
+func (server *Server) writeAriadneResponse(w http.ResponseWriter, r *http.Request, ariadneResponse Response) {
+	ariadneAPIResponseLogEntry :=
+		server.makeAriadneAPIResponseLogEntry(getRequestID(r.Context()), r.URL.RawQuery, ariadneResponse)
+	server.logger.Info(MessageKey, "Ariadne API response", AriadneKey, ariadneAPIResponseLogEntry)
+
+	responseJSON := makeAriadneResponseJSON(ariadneResponse)
+
+	fmt.Fprintln(w, responseJSON)
+}
+
 func makeAriadneResponseJSON(ariadneResponse Response) string {
 	responseJSONBytes, err := json.MarshalIndent(ariadneResponse, "", "    ")
 	// Very unlikely that this will error out.  At the moment, can't even think
@@ -228,7 +707,11 @@ func makeAriadneResponseJSON(ariadneResponse Response) string {
 	// }
 	if err != nil {
 		ariadneResponse = Response{
-			Errors:  []string{fmt.Sprintf("Could not marshal ariadne response to JSON: %v", err)},
+			Errors: []Error{{
+				ErrorCodeInternal,
+				ErrorSourceAriadne,
+				fmt.Sprintf("Could not marshal ariadne response to JSON: %v", err),
+			}},
 			Records: []Record{},
 		}
 
@@ -244,7 +727,7 @@ func makeAriadneResponseJSON(ariadneResponse Response) string {
 // Based on accepted answer for:
 //
 //	https://stackoverflow.com/questions/28745648/global-recover-handler-for-golang-http-panic
-func recoverWrap(handler http.Handler) http.Handler {
+func (server *Server) recoverWrap(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			recoverValue := recover()
@@ -259,16 +742,19 @@ func recoverWrap(handler http.Handler) http.Handler {
 					err = errors.New("Unknown error")
 				}
 
+				requestID := getRequestID(r.Context())
 				response := Response{
-					Errors:  []string{err.Error()},
-					Found:   false,
-					Records: []Record{},
+					Errors:    []Error{{ErrorCodeInternal, ErrorSourceAriadne, err.Error()}},
+					Found:     false,
+					Records:   []Record{},
+					RequestID: requestID,
 				}
 				responseJSON, _ := json.MarshalIndent(response, "", "    ")
 
 				ariadneAPIErrorResponseLogEntry :=
-					makeAriadneAPIErrorResponseLogEntry(r.URL.RawQuery, err, http.StatusBadRequest, response)
-				log.Error(MessageKey, err.Error(), AriadneKey, ariadneAPIErrorResponseLogEntry)
+					server.makeAriadneAPIErrorResponseLogEntry(requestID, r.URL.RawQuery, err, http.StatusInternalServerError, response)
+				server.logger.Error(MessageKey, server.redactor.redactText(err.Error()), AriadneKey, ariadneAPIErrorResponseLogEntry)
+				server.metrics.recoveredPanics.Inc()
 
 				http.Error(w, string(responseJSON), http.StatusInternalServerError)
 			}
@@ -278,7 +764,32 @@ func recoverWrap(handler http.Handler) http.Handler {
 	})
 }
 
-func setHeaders(w *http.ResponseWriter) {
-	(*w).Header().Set("Access-Control-Allow-Origin", "*")
-	(*w).Header().Set("Content-Type", "application/json")
+func (server *Server) setHeaders(w http.ResponseWriter, r *http.Request) {
+	allowedOrigin := server.getAllowedOrigin(r.Header.Get("Origin"))
+	if allowedOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
+		// So that the frontend can show the request ID with errors.
+		w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
+	}
+	// The header value depends on the request origin unless all origins are
+	// allowed, so caches must not share responses across origins.
+	if allowedOrigin != "*" {
+		w.Header().Add("Vary", "Origin")
+	}
+	w.Header().Set("Content-Type", "application/json")
+}
+
+// Returns the `Access-Control-Allow-Origin` value for a request from `origin`,
+// or "" if the origin is not allowed.
+func (server *Server) getAllowedOrigin(origin string) string {
+	for _, allowedOrigin := range server.corsAllowedOrigins {
+		if allowedOrigin == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(allowedOrigin, origin) {
+			return origin
+		}
+	}
+
+	return ""
 }
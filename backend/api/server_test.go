@@ -3,30 +3,47 @@ package api
 import (
 	"ariadne/log"
 	"ariadne/primo"
+	"ariadne/resilience"
 	"ariadne/sfx"
 	"ariadne/testutils"
 	"ariadne/util"
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"reflect"
 	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
 )
 
 const elidedHost = "Host: [ELIDED]"
 const elidedDatestamp = "Date: [ELIDED]"
 const elidedTimestamp = "\"time\":\"[ELIDED]\""
 
+// Sent with golden file test requests, since otherwise a random ID would be
+// generated.
+const testRequestID = "test-request-id"
+
 // Lookup table for logging test cases
 var loggingTestCaseKeys = map[string]struct{}{
 	"contrived-frbr-group-test-case":                          {},
 	"efficiency-of-geospatial-technology_unescaped-semicolon": {},
+	// Has `req.ip` and `rft.private_data`, which are redacted
+	"the-new-yorker": {},
 }
 
 var logOutputStringDatestampRegexp = regexp.MustCompile("Date:.*GMT")
@@ -54,6 +71,8 @@ func TestMain(m *testing.M) {
 }
 
 func TestResponseJSONRoute(t *testing.T) {
+	t.Parallel()
+
 	var currentTestCase testutils.TestCase
 
 	// Set up Primo service fake
@@ -65,15 +84,15 @@ func TestResponseJSONRoute(t *testing.T) {
 			}
 
 			// There potentially two kinds of requests:
-			//     - ISBN search request: this is the initial request that is
+			//     - Search request: this is the initial request that is
 			//       always made if Primo is being used at all
 			//     - FRBR member search request: if the response to the initial
-			//       ISBN search request returns docs that indicate an active FRBR
+			//       search request returns docs that indicate an active FRBR
 			//       group, more requests are made with an extra query param added
-			//       to the query string of the ISBN search request.
+			//       to the query string of the search request.
 			var primoFakeResponse string
 			if params.Get(primo.FRBRMemberSearchQueryParamName) == "" {
-				primoFakeResponse, err = testutils.GetPrimoFakeResponseISBNSearch(currentTestCase)
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseSearch(currentTestCase)
 			} else {
 				primoFakeResponse, err = testutils.GetPrimoFakeResponseFRBRMemberSearch(currentTestCase)
 			}
@@ -90,8 +109,6 @@ func TestResponseJSONRoute(t *testing.T) {
 	)
 	defer fakePrimoServer.Close()
 
-	primo.SetPrimoURL(fakePrimoServer.URL)
-
 	// Set up SFX service fake
 	fakeSFXServer := httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -108,13 +125,9 @@ func TestResponseJSONRoute(t *testing.T) {
 	)
 	defer fakeSFXServer.Close()
 
-	sfx.SetSFXURL(fakeSFXServer.URL)
-
-	router := NewRouter()
-
-	// Disable logging or else we'll have a ton on noise in the test results
-	// output.
-	log.SetLevel(log.LevelDisabled)
+	// Logging is disabled by default for test servers, or else we'll have a ton
+	// on noise in the test results output.
+	router := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{})
 
 	for _, testCase := range testutils.TestCases {
 		t.Run(testCase.Name, func(t *testing.T) {
@@ -128,6 +141,7 @@ func TestResponseJSONRoute(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Error creating new HTTP request: %s", err)
 			}
+			request.Header.Set(RequestIDHeader, testRequestID)
 
 			responseRecorder := httptest.NewRecorder()
 			router.ServeHTTP(responseRecorder, request)
@@ -172,6 +186,8 @@ func TestResponseJSONRoute(t *testing.T) {
 }
 
 func TestLogging(t *testing.T) {
+	t.Parallel()
+
 	var currentTestCase testutils.TestCase
 
 	// Set up SFX service fake
@@ -190,8 +206,6 @@ func TestLogging(t *testing.T) {
 	)
 	defer fakeSFXServer.Close()
 
-	sfx.SetSFXURL(fakeSFXServer.URL)
-
 	// Set up Primo service fake
 	fakePrimoServer := httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -201,15 +215,15 @@ func TestLogging(t *testing.T) {
 			}
 
 			// There potentially two kinds of requests:
-			//     - ISBN search request: this is the initial request that is
+			//     - Search request: this is the initial request that is
 			//       always made if Primo is being used at all
 			//     - FRBR member search request: if the response to the initial
-			//       ISBN search request returns docs that indicate an active FRBR
+			//       search request returns docs that indicate an active FRBR
 			//       group, more requests are made with an extra query param added
-			//       to the query string of the ISBN search request.
+			//       to the query string of the search request.
 			var primoFakeResponse string
 			if params.Get(primo.FRBRMemberSearchQueryParamName) == "" {
-				primoFakeResponse, err = testutils.GetPrimoFakeResponseISBNSearch(currentTestCase)
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseSearch(currentTestCase)
 			} else {
 				primoFakeResponse, err = testutils.GetPrimoFakeResponseFRBRMemberSearch(currentTestCase)
 			}
@@ -226,10 +240,6 @@ func TestLogging(t *testing.T) {
 	)
 	defer fakePrimoServer.Close()
 
-	primo.SetPrimoURL(fakePrimoServer.URL)
-
-	router := NewRouter()
-
 	for _, testCase := range testutils.TestCases {
 		if _, ok := loggingTestCaseKeys[testCase.Key]; !ok {
 			continue
@@ -241,11 +251,12 @@ func TestLogging(t *testing.T) {
 			// Needed for golden file stuff
 			levelString := log.GetLevelOptionStringForLogLevel(level)
 
-			// Set logging level and redirect output to a buffer.
-			log.SetLevel(level)
+			// Each server logs at its own level to its own buffer.
 			var logOutput bytes.Buffer
 			logOutputWriter := bufio.NewWriter(&logOutput)
-			log.SetOutput(logOutputWriter)
+			router := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+				Logger: log.New(logOutputWriter, level),
+			})
 
 			t.Run(testCase.Name, func(t *testing.T) {
 				request, err := http.NewRequest(
@@ -256,6 +267,7 @@ func TestLogging(t *testing.T) {
 				if err != nil {
 					t.Fatalf("Error creating new HTTP request: %s", err)
 				}
+				request.Header.Set(RequestIDHeader, testRequestID)
 
 				// We're not recording any responses at the moment, but we need to pass
 				// in a http.ResponseWriter anyway to router.ServeHTTP, so why not make
@@ -306,6 +318,767 @@ func TestLogging(t *testing.T) {
 	}
 }
 
+// The SFX fake doesn't respond until the Primo fake has received the ISBN search
+// request, so this test would time out if the lookups were not made concurrently.
+func TestSFXAndPrimoLookupsAreConcurrent(t *testing.T) {
+	t.Parallel()
+
+	testCase := getTestCase(t, "contrived-frbr-group-test-case")
+
+	primoISBNSearchRequestReceived := make(chan struct{})
+	var once sync.Once
+
+	fakePrimoServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var primoFakeResponse string
+			var err error
+			if r.URL.Query().Get(primo.FRBRMemberSearchQueryParamName) == "" {
+				once.Do(func() { close(primoISBNSearchRequestReceived) })
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseSearch(testCase)
+			} else {
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseFRBRMemberSearch(testCase)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, primoFakeResponse)
+		}),
+	)
+	defer fakePrimoServer.Close()
+
+	fakeSFXServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-primoISBNSearchRequestReceived:
+			case <-r.Context().Done():
+				return
+			}
+
+			sfxFakeResponse, err := testutils.GetSFXFakeResponse(testCase)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, sfxFakeResponse)
+		}),
+	)
+	defer fakeSFXServer.Close()
+
+	server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+		ResolverTimeout: 5 * time.Second,
+	})
+
+	response := doResolverRequest(t, server, testCase.QueryString)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, response.StatusCode)
+	}
+
+	body, _ := io.ReadAll(response.Body)
+	goldenValue, err := testutils.GetAPIResponseGoldenValue(testCase)
+	if err != nil {
+		t.Fatalf("Error retrieving golden value for test case \"%s\": %s",
+			testCase.Name, err)
+	}
+
+	if string(body) != goldenValue {
+		t.Errorf("Expected response to match golden file %s, got:\n%s",
+			testutils.APIResponseGoldenFile(testCase), body)
+	}
+}
+
+// Citations without an ISBN are searched for in Primo too, when SFX doesn't
+// find anything.
+func TestPrimoSearchStrategies(t *testing.T) {
+	t.Parallel()
+
+	primoSearchResponse := `{"docs": [{"delivery": {"link": [
+		{"hyperlinkText": "Online access", "linkURL": "https://example.com/online", "linkType": "http://purl.org/pnx/linkType/linktorsrc"}
+	]}}]}`
+
+	testCases := []struct {
+		name          string
+		queryString   string
+		expectedQuery string
+	}{
+		{"ISSN", "genre=journal&issn=00182753&date=2002", "issn,exact,0018-2753"},
+		{"OCLC number", "rft_id=info:oclcnum/1760231", "any,contains,1760231"},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeSFXServer := newFakeSFXServer(t, "the-sino-tibetan-languages")
+			defer fakeSFXServer.Close()
+			fakePrimoServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Query().Get("q") != testCase.expectedQuery {
+						fmt.Fprint(w, testutils.PrimoFakeResponseNoResults)
+						return
+					}
+
+					fmt.Fprint(w, primoSearchResponse)
+				}),
+			)
+			defer fakePrimoServer.Close()
+
+			server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{})
+
+			response := doResolverRequest(t, server, testCase.queryString)
+			var apiResponse Response
+			err := json.NewDecoder(response.Body).Decode(&apiResponse)
+			if err != nil {
+				t.Fatalf("Could not decode response: %s", err)
+			}
+
+			links := apiResponse.Records[0].Links
+			if !apiResponse.Found || len(links) != 1 || links[0].Url != "https://example.com/online" {
+				t.Errorf("Expected the Primo link, got %+v", apiResponse)
+			}
+		})
+	}
+}
+
+// Only ISBN searches are started before SFX answers, so Primo isn't searched
+// for other citations that SFX finds.
+func TestPrimoNotSearchedWhenSFXFound(t *testing.T) {
+	t.Parallel()
+
+	fakeSFXServer := newFakeSFXServer(t, "the-new-yorker")
+	defer fakeSFXServer.Close()
+
+	var numPrimoRequests int32
+	fakePrimoServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&numPrimoRequests, 1)
+			fmt.Fprint(w, testutils.PrimoFakeResponseNoResults)
+		}),
+	)
+	defer fakePrimoServer.Close()
+
+	server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{})
+
+	for _, queryString := range []string{
+		"genre=journal&issn=0028-792X&date=2002",
+		"rft_id=info:oclcnum/909782404",
+		"genre=journal&title=New+Yorker",
+	} {
+		response := doResolverRequest(t, server, queryString)
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected status %d, got %d", queryString, http.StatusOK, response.StatusCode)
+		}
+	}
+
+	if numPrimoRequests != 0 {
+		t.Errorf("Expected no Primo requests, got %d", numPrimoRequests)
+	}
+}
+
+// Invalid ISBNs are skipped, logged, and reported as warnings, even when there is
+// something else to search Primo for.
+func TestInvalidISBNWarnings(t *testing.T) {
+	t.Parallel()
+
+	fakeSFXServer := newFakeSFXServer(t, "the-new-yorker")
+	defer fakeSFXServer.Close()
+	fakePrimoServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, testutils.PrimoFakeResponseNoResults)
+		}),
+	)
+	defer fakePrimoServer.Close()
+
+	var logOutput bytes.Buffer
+	server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+		Logger: log.New(&logOutput, log.LevelInfo),
+	})
+
+	invalidISBNWarning := Error{ErrorCodeInvalidISBN, ErrorSourceRequest, "Skipped invalid ISBN \"9780198129104\": wrong check digit"}
+	testCases := []struct {
+		name             string
+		queryString      string
+		expectedWarnings []Error
+	}{
+		{"Valid ISBN", "isbn=9780191732355", nil},
+		{"Valid and invalid ISBNs", "isbn=9780198129104&isbn=9780191732355", []Error{invalidISBNWarning}},
+		{"Invalid ISBN and ISSN", "isbn=9780198129104&issn=0028-792X", []Error{invalidISBNWarning}},
+		{"Invalid ISBN and title", "isbn=9780198129104&title=Hamlet", []Error{invalidISBNWarning}},
+	}
+
+	for _, testCase := range testCases {
+		logOutput.Reset()
+
+		response := doResolverRequest(t, server, testCase.queryString)
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected status %d, got %d", testCase.name, http.StatusOK, response.StatusCode)
+		}
+
+		var ariadneResponse Response
+		err := json.NewDecoder(response.Body).Decode(&ariadneResponse)
+		if err != nil {
+			t.Fatalf("%s: error decoding response body: %s", testCase.name, err)
+		}
+		if len(ariadneResponse.Errors) != 0 {
+			t.Errorf("%s: expected no errors, got %+v", testCase.name, ariadneResponse.Errors)
+		}
+		if !reflect.DeepEqual(ariadneResponse.Warnings, testCase.expectedWarnings) {
+			t.Errorf("%s: expected warnings %+v, got %+v", testCase.name, testCase.expectedWarnings, ariadneResponse.Warnings)
+		}
+
+		loggedInvalidISBNs := strings.Contains(logOutput.String(), `"message":"`+invalidISBNsMessage+`: 9780198129104"`)
+		if loggedInvalidISBNs != (testCase.expectedWarnings != nil) {
+			t.Errorf("%s: expected invalid ISBNs to be logged: %t, got log output:\n%s",
+				testCase.name, testCase.expectedWarnings != nil, logOutput.String())
+		}
+	}
+}
+
+func TestResolverTimeout(t *testing.T) {
+	t.Parallel()
+
+	// Neither fake responds until the request is cancelled.
+	hangingHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	fakePrimoServer := httptest.NewServer(hangingHandler)
+	defer fakePrimoServer.Close()
+
+	fakeSFXServer := httptest.NewServer(hangingHandler)
+	defer fakeSFXServer.Close()
+
+	server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+		ResolverTimeout: 100 * time.Millisecond,
+	})
+
+	start := time.Now()
+	response := doResolverRequest(t, server, getTestCase(t, "contrived-frbr-group-test-case").QueryString)
+	elapsed := time.Since(start)
+
+	if elapsed > 5*time.Second {
+		t.Errorf("Expected request to be cancelled after resolver timeout, took %s", elapsed)
+	}
+
+	if response.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("Expected status %d, got %d", http.StatusGatewayTimeout, response.StatusCode)
+	}
+
+	var ariadneResponse Response
+	err := json.NewDecoder(response.Body).Decode(&ariadneResponse)
+	if err != nil {
+		t.Fatalf("Error decoding response body: %s", err)
+	}
+
+	if len(ariadneResponse.Errors) == 0 {
+		t.Fatalf("Expected response to contain errors, got none")
+	}
+	for _, apiError := range ariadneResponse.Errors {
+		if apiError.Code != ErrorCodeTimeout {
+			t.Errorf("Expected error code '%s', got '%s' (source: %s)",
+				ErrorCodeTimeout, apiError.Code, apiError.Source)
+		}
+	}
+	if ariadneResponse.Errors[0].Source != ErrorSourceSFX {
+		t.Errorf("Expected first error source to be '%s', got '%s'",
+			ErrorSourceSFX, ariadneResponse.Errors[0].Source)
+	}
+}
+
+func TestSFXUnavailable(t *testing.T) {
+	t.Parallel()
+
+	testCase := getTestCase(t, "contrived-frbr-group-test-case")
+
+	fakePrimoServer := newFakePrimoServer(testCase)
+	defer fakePrimoServer.Close()
+
+	var numSFXRequests int32
+	fakeSFXServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&numSFXRequests, 1)
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		}),
+	)
+	defer fakeSFXServer.Close()
+
+	server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{})
+	sfxCircuitBreaker := server.defaultTenant.SFXClient.CircuitBreaker()
+
+	testCases := []struct {
+		name               string
+		queryString        string
+		expectedStatusCode int
+		expectedFound      bool
+		expectedErrors     []Error
+	}{
+		{"Degrades to Primo", testCase.QueryString, http.StatusOK, true, []Error{}},
+		{
+			// Nothing that Primo can be searched for
+			"No Primo fallback",
+			"date=2002&volume=97",
+			http.StatusBadGateway,
+			false,
+			[]Error{{ErrorCodeUpstreamStatus, ErrorSourceSFX, "SFX server responded with HTTP status 503 Service Unavailable"}},
+		},
+		{
+			"No Primo fallback for an invalid ISBN",
+			"isbn=9780198129104",
+			http.StatusBadGateway,
+			false,
+			[]Error{
+				{ErrorCodeUpstreamStatus, ErrorSourceSFX, "SFX server responded with HTTP status 503 Service Unavailable"},
+				{
+					ErrorCodeInvalidISBN,
+					ErrorSourceRequest,
+					"Skipped invalid ISBN \"9780198129104\": wrong check digit",
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Each request retries SFX, so the breaker could otherwise trip partway
+			// through the test cases.
+			sfxCircuitBreaker.Reset()
+
+			response := doResolverRequest(t, server, testCase.queryString)
+			if response.StatusCode != testCase.expectedStatusCode {
+				t.Errorf("Expected status %d, got %d", testCase.expectedStatusCode, response.StatusCode)
+			}
+
+			var ariadneResponse Response
+			err := json.NewDecoder(response.Body).Decode(&ariadneResponse)
+			if err != nil {
+				t.Fatalf("Error decoding response body: %s", err)
+			}
+
+			if ariadneResponse.Found != testCase.expectedFound {
+				t.Errorf("Expected found to be %t, got %t", testCase.expectedFound, ariadneResponse.Found)
+			}
+
+			if !reflect.DeepEqual(ariadneResponse.Errors, testCase.expectedErrors) {
+				t.Errorf("Expected errors %+v, got %+v", testCase.expectedErrors, ariadneResponse.Errors)
+			}
+		})
+	}
+
+	// Keep failing until the breaker trips, after which SFX should no longer be
+	// contacted at all.
+	for sfxCircuitBreaker.State() != resilience.StateOpen {
+		doResolverRequest(t, server, testCase.QueryString)
+	}
+	numSFXRequestsBeforeOpen := atomic.LoadInt32(&numSFXRequests)
+
+	response := doResolverRequest(t, server, testCase.QueryString)
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected status %d while SFX circuit breaker is open, got %d",
+			http.StatusOK, response.StatusCode)
+	}
+	if atomic.LoadInt32(&numSFXRequests) != numSFXRequestsBeforeOpen {
+		t.Errorf("SFX was contacted while its circuit breaker was open")
+	}
+
+	response = doResolverRequest(t, server, "date=2002&volume=97")
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d with no Primo fallback while SFX circuit breaker is open, got %d",
+			http.StatusServiceUnavailable, response.StatusCode)
+	}
+	var ariadneResponse Response
+	err := json.NewDecoder(response.Body).Decode(&ariadneResponse)
+	if err != nil {
+		t.Fatalf("Error decoding response body: %s", err)
+	}
+	if len(ariadneResponse.Errors) != 1 || ariadneResponse.Errors[0].Code != ErrorCodeUpstreamUnavailable {
+		t.Errorf("Expected a single '%s' error, got %+v", ErrorCodeUpstreamUnavailable, ariadneResponse.Errors)
+	}
+
+	healthCheckResponseRecorder := httptest.NewRecorder()
+	server.ServeHTTP(healthCheckResponseRecorder, httptest.NewRequest("GET", "/healthcheck", nil))
+	var healthCheckResponse struct {
+		CircuitBreakers map[string]string `json:"circuitBreakers"`
+	}
+	err = json.NewDecoder(healthCheckResponseRecorder.Body).Decode(&healthCheckResponse)
+	if err != nil {
+		t.Fatalf("Error decoding healthcheck response body: %s", err)
+	}
+	if healthCheckResponse.CircuitBreakers["sfx"] != "open" {
+		t.Errorf("Expected healthcheck to report SFX circuit breaker as open, got '%s'",
+			healthCheckResponse.CircuitBreakers["sfx"])
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	t.Parallel()
+
+	testCase := getTestCase(t, "contrived-frbr-group-test-case")
+
+	fakePrimoServer := newFakePrimoServer(testCase)
+	defer fakePrimoServer.Close()
+
+	var numSFXRequests int32
+	fakeSFXServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&numSFXRequests, 1)
+
+			sfxFakeResponse, err := testutils.GetSFXFakeResponse(testCase)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, sfxFakeResponse)
+		}),
+	)
+	defer fakeSFXServer.Close()
+
+	redisServer := miniredis.RunT(t)
+	redisCache, err := NewRedisCache(redisServer.Addr())
+	if err != nil {
+		t.Fatalf("NewRedisCache returned error: %s", err)
+	}
+	defer redisCache.Close()
+
+	caches := []struct {
+		name  string
+		cache Cache
+	}{
+		{"Memory", NewMemoryCache(DefaultCacheSize)},
+		{"Redis", redisCache},
+	}
+
+	goldenValue, err := testutils.GetAPIResponseGoldenValue(testCase)
+	if err != nil {
+		t.Fatalf("Error retrieving golden value for test case \"%s\": %s",
+			testCase.Name, err)
+	}
+
+	equivalentQueryString := "rft.isbn=9781111111113&rft.date=1999&rft.title=Contrived+FRBR+Group+Test+Case&ctx_tim=2023-04-01"
+
+	requests := []struct {
+		name                   string
+		queryString            string
+		header                 http.Header
+		expectedCacheStatus    string
+		expectedNumSFXRequests int32
+	}{
+		{"First request", testCase.QueryString, http.Header{}, cacheStatusMiss, 1},
+		{"Same request", testCase.QueryString, http.Header{}, cacheStatusHit, 1},
+		{"Equivalent request", equivalentQueryString, http.Header{}, cacheStatusHit, 1},
+		{"Bypass", testCase.QueryString, http.Header{CacheBypassHeader: {"1"}}, cacheStatusBypass, 2},
+	}
+
+	for _, cache := range caches {
+		t.Run(cache.name, func(t *testing.T) {
+			atomic.StoreInt32(&numSFXRequests, 0)
+
+			server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+				ResponseCache: ResponseCacheOptions{
+					Cache:                  cache.cache,
+					CacheUpstreamResponses: true,
+					TTL:                    DefaultCacheTTL,
+				},
+			})
+
+			for _, request := range requests {
+				response := doResolverRequestWithHeader(t, server, request.queryString, request.header)
+
+				cacheStatus := response.Header.Get(CacheStatusHeader)
+				if cacheStatus != request.expectedCacheStatus {
+					t.Errorf("%s: expected %s header \"%s\", got \"%s\"",
+						request.name, CacheStatusHeader, request.expectedCacheStatus, cacheStatus)
+				}
+
+				if numSFXRequests != request.expectedNumSFXRequests {
+					t.Errorf("%s: expected %d total SFX requests, got %d",
+						request.name, request.expectedNumSFXRequests, numSFXRequests)
+				}
+
+				body, _ := io.ReadAll(response.Body)
+				if string(body) != goldenValue {
+					t.Errorf("%s: expected response to match golden file %s, got:\n%s",
+						request.name, testutils.APIResponseGoldenFile(testCase), body)
+				}
+			}
+
+			cachedUpstreamResponses, err :=
+				GetCachedUpstreamResponses(context.Background(), cache.cache, DefaultTenantName, testCase.QueryString)
+			if err != nil {
+				t.Fatalf("GetCachedUpstreamResponses returned error: %s", err)
+			}
+
+			sfxFakeResponse, _ := testutils.GetSFXFakeResponse(testCase)
+			if cachedUpstreamResponses.SFX != sfxFakeResponse {
+				t.Errorf("Expected cached SFX response to match fixture, got:\n%s", cachedUpstreamResponses.SFX)
+			}
+
+			// The ISBN search response plus one FRBR member response
+			var primoAPIResponses []primo.APIResponse
+			err = json.Unmarshal(cachedUpstreamResponses.Primo, &primoAPIResponses)
+			if err != nil || len(primoAPIResponses) != 2 {
+				t.Errorf("Expected 2 cached Primo responses, got error '%v' and %d responses",
+					err, len(primoAPIResponses))
+			}
+		})
+	}
+}
+
+func TestResponseCacheTimeout(t *testing.T) {
+	t.Parallel()
+
+	testCase := getTestCase(t, "contrived-frbr-group-test-case")
+
+	fakePrimoServer := newFakePrimoServer(testCase)
+	defer fakePrimoServer.Close()
+
+	fakeSFXServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sfxFakeResponse, _ := testutils.GetSFXFakeResponse(testCase)
+			fmt.Fprint(w, sfxFakeResponse)
+		}),
+	)
+	defer fakeSFXServer.Close()
+
+	// A Redis server which accepts connections, but never replies.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	redisCache, err := NewRedisCache(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("NewRedisCache returned error: %s", err)
+	}
+	defer redisCache.Close()
+
+	server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+		ResponseCache: ResponseCacheOptions{
+			Cache:   redisCache,
+			Timeout: 50 * time.Millisecond,
+			TTL:     DefaultCacheTTL,
+		},
+	})
+
+	result := make(chan *http.Response, 1)
+	go func() {
+		result <- doResolverRequest(t, server, testCase.QueryString)
+	}()
+
+	select {
+	case response := <-result:
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("Expected status %d, got %d", http.StatusOK, response.StatusCode)
+		}
+		if cacheStatus := response.Header.Get(CacheStatusHeader); cacheStatus != cacheStatusMiss {
+			t.Errorf("Expected %s header \"%s\", got \"%s\"", CacheStatusHeader, cacheStatusMiss, cacheStatus)
+		}
+	// Well under `redis.DefaultReadTimeout`, so that this fails unless the cache
+	// timeout is applied.
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Resolver request blocked on the cache")
+	}
+}
+
+// A response made while an upstream was failing could be missing links, so it
+// must not be served from the cache once the upstream is back.
+func TestResponseCachePrimoError(t *testing.T) {
+	t.Parallel()
+
+	testCase := getTestCase(t, "contrived-frbr-group-test-case")
+
+	fakePrimoServer := newFakePrimoServer(testCase)
+	defer fakePrimoServer.Close()
+
+	var primoAvailable atomic.Bool
+	flakyPrimoServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !primoAvailable.Load() {
+				http.Error(w, "Bad request", http.StatusBadRequest)
+				return
+			}
+			fakePrimoServer.Config.Handler.ServeHTTP(w, r)
+		}),
+	)
+	defer flakyPrimoServer.Close()
+
+	fakeSFXServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sfxFakeResponse, _ := testutils.GetSFXFakeResponse(testCase)
+			fmt.Fprint(w, sfxFakeResponse)
+		}),
+	)
+	defer fakeSFXServer.Close()
+
+	server := newTestServer(t, fakeSFXServer.URL, flakyPrimoServer.URL, Options{
+		ResponseCache: ResponseCacheOptions{
+			Cache: NewMemoryCache(DefaultCacheSize),
+			TTL:   DefaultCacheTTL,
+		},
+	})
+
+	requests := []struct {
+		name                string
+		primoAvailable      bool
+		expectedCacheStatus string
+		// SFX doesn't find anything for the test case, but Primo does.
+		expectedFound bool
+	}{
+		{"Primo error", false, cacheStatusMiss, false},
+		{"Primo available", true, cacheStatusMiss, true},
+		{"Cached Primo response", true, cacheStatusHit, true},
+	}
+
+	for _, request := range requests {
+		primoAvailable.Store(request.primoAvailable)
+
+		response := doResolverRequest(t, server, testCase.QueryString)
+
+		cacheStatus := response.Header.Get(CacheStatusHeader)
+		if cacheStatus != request.expectedCacheStatus {
+			t.Errorf("%s: expected %s header \"%s\", got \"%s\"",
+				request.name, CacheStatusHeader, request.expectedCacheStatus, cacheStatus)
+		}
+
+		var ariadneResponse Response
+		err := json.NewDecoder(response.Body).Decode(&ariadneResponse)
+		if err != nil {
+			t.Fatalf("%s: error decoding response body: %s", request.name, err)
+		}
+		if ariadneResponse.Found != request.expectedFound {
+			t.Errorf("%s: expected found %t, got %t", request.name, request.expectedFound, ariadneResponse.Found)
+		}
+	}
+}
+
+func doResolverRequest(t *testing.T, server *Server, queryString string) *http.Response {
+	return doResolverRequestWithHeader(t, server, queryString, http.Header{})
+}
+
+func doResolverRequestWithHeader(t *testing.T, server *Server, queryString string, header http.Header) *http.Response {
+	request, err := http.NewRequest(
+		"GET",
+		"/v0/?"+queryString,
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("Error creating new HTTP request: %s", err)
+	}
+	request.Header = header
+
+	responseRecorder := httptest.NewRecorder()
+	server.ServeHTTP(responseRecorder, request)
+
+	return responseRecorder.Result()
+}
+
+// Embargoes are relative to the current time, so the golden files would
+// otherwise change over time.
+var testNow = time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+// Returns a server whose tenants use their own HTTP clients, and so their own
+// circuit breakers, so that tests using it can run in parallel.  Unless set in
+// `options`, the server has a single default tenant using the given fakes,
+// logging is disabled, and the current time is `testNow`.
+func newTestServer(t *testing.T, sfxURL string, primoURL string, options Options) *Server {
+	t.Helper()
+
+	if options.Logger == nil {
+		options.Logger = log.New(io.Discard, log.LevelDisabled)
+	}
+	if options.Now == nil {
+		options.Now = func() time.Time { return testNow }
+	}
+	if options.Tenants == nil {
+		options.Tenants = []*Tenant{newTestTenant(DefaultTenantName, sfxURL, primoURL, options.Logger)}
+	}
+
+	server, err := NewRouter(options)
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %s", err)
+	}
+
+	return server
+}
+
+func newTestTenant(name string, sfxURL string, primoURL string, logger *log.Logger) *Tenant {
+	return &Tenant{
+		Name:             name,
+		AskALibrarianURL: sfx.AskALibrarianLink,
+		PrimoClient: primo.NewClient(primoURL, primo.ClientOptions{
+			HTTPClient: primo.NewHTTPClient(primo.DefaultTimeout, nil),
+			Logger:     logger,
+		}),
+		RemovedTargetURLs: []string{},
+		SFXClient: sfx.NewClient(sfxURL, sfx.ClientOptions{
+			HTTPClient: sfx.NewHTTPClient(sfx.DefaultTimeout, nil),
+			Logger:     logger,
+		}),
+	}
+}
+
+// Serves the Primo fixtures for `testCase`.
+func newFakePrimoServer(testCase testutils.TestCase) *httptest.Server {
+	return httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var primoFakeResponse string
+			var err error
+			if r.URL.Query().Get(primo.FRBRMemberSearchQueryParamName) == "" {
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseSearch(testCase)
+			} else {
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseFRBRMemberSearch(testCase)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, primoFakeResponse)
+		}),
+	)
+}
+
+// Serves the SFX fixture for the test case with key `testCaseKey`.
+func newFakeSFXServer(t *testing.T, testCaseKey string) *httptest.Server {
+	t.Helper()
+
+	sfxFakeResponse, err := testutils.GetSFXFakeResponse(getTestCase(t, testCaseKey))
+	if err != nil {
+		t.Fatalf("Could not get SFX fake response: %s", err)
+	}
+
+	return httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, sfxFakeResponse)
+		}),
+	)
+}
+
+func getTestCase(t *testing.T, key string) testutils.TestCase {
+	for _, testCase := range testutils.TestCases {
+		if testCase.Key == key {
+			return testCase
+		}
+	}
+
+	t.Fatalf("No test case with key \"%s\"", key)
+
+	return testutils.TestCase{}
+}
+
 func normalizeLogOutputString(logOutputString string) string {
 	result := logOutputStringDatestampRegexp.ReplaceAllString(logOutputString, elidedDatestamp)
 	result = logOutputStringHostRegexp.ReplaceAllString(result, elidedHost)
@@ -337,3 +1110,100 @@ func writeActualAPIResponseToTmp(testCase testutils.TestCase, actual string) err
 func writeActualLogOutputToTmp(testCase testutils.TestCase, actual string) error {
 	return os.WriteFile(tmpLogOutputFile(testCase), []byte(actual), 0644)
 }
+
+func TestRemoveAriadneParams(t *testing.T) {
+	testCases := []struct {
+		name        string
+		queryString string
+		expected    string
+	}{
+		{"No Ariadne params", "issn=0028-792X&rft_id=info:doi/10.1/a", "issn=0028-792X&rft_id=info:doi/10.1/a"},
+		{"First param", "?institution=nyuad&issn=0028-792X", "?issn=0028-792X"},
+		{"Middle and last params", "issn=0028-792X&hide_out_of_coverage=1&date=2002&institution=nyuad", "issn=0028-792X&date=2002"},
+		{"Case-insensitive", "INSTITUTION=nyuad&issn=0028-792X", "issn=0028-792X"},
+		{"Escaped name", "hide%5Fout%5Fof%5Fcoverage=true&issn=0028-792X", "issn=0028-792X"},
+		{"Unescaped semicolon is kept", "au=Masoud,%20Ahmed%20M;Quoc%20Bao%20Pham&institution=nyu", "au=Masoud,%20Ahmed%20M;Quoc%20Bao%20Pham"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := removeAriadneParams(testCase.queryString)
+			if actual != testCase.expected {
+				t.Errorf("Expected \"%s\", got \"%s\"", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCORSHeaders(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		allowedOrigins []string
+		origin         string
+		expected       string
+	}{
+		{"All origins allowed", []string{"*"}, "https://example.com", "*"},
+		{"Allowed origin", []string{"https://library.nyu.edu", "https://example.com"}, "https://example.com", "https://example.com"},
+		{"Disallowed origin", []string{"https://library.nyu.edu"}, "https://example.com", ""},
+		{"No origin", []string{"https://library.nyu.edu"}, "", ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := newTestServer(t, sfx.DefaultSFXURL, primo.DefaultPrimoURL, Options{
+				CORSAllowedOrigins: testCase.allowedOrigins,
+			})
+
+			request := httptest.NewRequest("GET", "/v0/", nil)
+			if testCase.origin != "" {
+				request.Header.Set("Origin", testCase.origin)
+			}
+			responseRecorder := httptest.NewRecorder()
+			server.setHeaders(responseRecorder, request)
+
+			got := responseRecorder.Header().Get("Access-Control-Allow-Origin")
+			if got != testCase.expected {
+				t.Errorf("Expected Access-Control-Allow-Origin '%s', got '%s'", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestHealthCheckDraining(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, sfx.DefaultSFXURL, primo.DefaultPrimoURL, Options{})
+
+	testCases := []struct {
+		name               string
+		draining           bool
+		expectedStatusCode int
+		expectedStatus     string
+	}{
+		{"Serving", false, http.StatusOK, "ok"},
+		{"Draining", true, http.StatusServiceUnavailable, "draining"},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.draining {
+			server.StartDraining()
+		}
+
+		responseRecorder := httptest.NewRecorder()
+		server.ServeHTTP(responseRecorder, httptest.NewRequest("GET", "/healthcheck", nil))
+
+		var healthCheckResponse struct {
+			Status string `json:"status"`
+		}
+		err := json.NewDecoder(responseRecorder.Body).Decode(&healthCheckResponse)
+		if err != nil {
+			t.Fatalf("%s: error decoding healthcheck response body: %s", testCase.name, err)
+		}
+		if responseRecorder.Code != testCase.expectedStatusCode || healthCheckResponse.Status != testCase.expectedStatus {
+			t.Errorf("%s: expected healthcheck status %d \"%s\", got %d \"%s\"", testCase.name,
+				testCase.expectedStatusCode, testCase.expectedStatus, responseRecorder.Code, healthCheckResponse.Status)
+		}
+	}
+}
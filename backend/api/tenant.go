@@ -0,0 +1,139 @@
+package api
+
+import (
+	"ariadne/log"
+	"ariadne/primo"
+	"ariadne/sfx"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Query param used to select a tenant when the request path doesn't.  This is
+// the same param the frontend uses to pick the banner, so OpenURLs from Primo
+// for a specific institution are resolved against that institution's SFX instance.
+const TenantParam = "institution"
+
+const DefaultTenantName = "nyu"
+
+// An institution served by this deployment, with its own SFX instance and
+// Primo view.  Tenants are selected by path prefix, e.g. /v0/nyuad/, or by the
+// `institution` query param.
+type Tenant struct {
+	// Lowercase, used in the path prefix and query param
+	Name string
+	// Removed from SFX responses, since the frontend shows its own Ask a
+	// Librarian link.
+	AskALibrarianURL string
+	PrimoClient      *primo.Client
+	// Other SFX targets that should never be shown to this tenant's users.
+	RemovedTargetURLs []string
+	SFXClient         *sfx.Client
+}
+
+// Used if no tenants are configured.
+func newDefaultTenant(logger *log.Logger) *Tenant {
+	return &Tenant{
+		Name:              DefaultTenantName,
+		AskALibrarianURL:  sfx.AskALibrarianLink,
+		PrimoClient:       primo.NewClient(primo.DefaultPrimoURL, primo.ClientOptions{Logger: logger}),
+		RemovedTargetURLs: []string{},
+		SFXClient:         sfx.NewClient(sfx.DefaultSFXURL, sfx.ClientOptions{Logger: logger}),
+	}
+}
+
+// `defaultTenantName` is used for requests which don't select a tenant, and
+// must be one of `tenants`.
+func (server *Server) setTenants(tenants []*Tenant, defaultTenantName string) error {
+	tenantsByName := map[string]*Tenant{}
+	for _, tenant := range tenants {
+		name := strings.ToLower(tenant.Name)
+		if _, ok := tenantsByName[name]; ok {
+			return fmt.Errorf("Duplicate tenant \"%s\"", name)
+		}
+		tenantsByName[name] = tenant
+	}
+
+	defaultTenant, ok := tenantsByName[strings.ToLower(defaultTenantName)]
+	if !ok {
+		return fmt.Errorf("Default tenant \"%s\" is not one of the configured tenants", defaultTenantName)
+	}
+
+	server.tenants = tenantsByName
+	server.defaultTenant = defaultTenant
+
+	return nil
+}
+
+// Returns the tenant selected by the path prefix, or if there isn't one, by
+// the `institution` query param.  Unknown path prefixes are an error, but unknown
+// query param values are not: OpenURLs from other systems often have an
+// `institution` param with values like "01NYU_INST", which just mean the default.
+func (server *Server) getTenant(r *http.Request) (*Tenant, error) {
+	pathTenantName := ""
+	if strings.HasPrefix(r.URL.Path, "/v0/") {
+		pathTenantName = strings.Trim(strings.TrimPrefix(r.URL.Path, "/v0/"), "/")
+	}
+	if pathTenantName != "" {
+		tenant, ok := server.tenants[strings.ToLower(pathTenantName)]
+		if !ok {
+			return nil, fmt.Errorf("Unknown institution \"%s\"", pathTenantName)
+		}
+
+		return tenant, nil
+	}
+
+	// See comment in `getSharedLogEntryFields` regarding `url.ParseQuery` errors,
+	// which apply here too.
+	queryParams, _ := url.ParseQuery(r.URL.RawQuery)
+	for paramName, values := range queryParams {
+		if strings.EqualFold(paramName, TenantParam) && len(values) > 0 {
+			if tenant, ok := server.tenants[strings.ToLower(values[0])]; ok {
+				return tenant, nil
+			}
+		}
+	}
+
+	return server.defaultTenant, nil
+}
+
+// Removes the Ask a Librarian target, the tenant's other removed targets, and
+// any targets with no URL.  This must be done before checking whether SFX
+// found anything, since those targets don't count.  Removed targets are counted
+// in the server's metrics.  `requestID` and `queryString` identify the request
+// in log entries.
+func (server *Server) removeTargets(tenant *Tenant, requestID string, queryString string, sfxResponse *sfx.SFXResponse) {
+	// Remove the Ask a Librarian target -- for details, see:
+	// https://nyu-lib.monday.com/boards/765008773/pulses/3548498827
+	if tenant.AskALibrarianURL != "" {
+		numRemoved := sfxResponse.RemoveTarget(tenant.AskALibrarianURL)
+		server.metrics.removedTargets.Add(float64(numRemoved), removedTargetReasonAskALibrarian)
+	}
+	for _, targetURL := range tenant.RemovedTargetURLs {
+		numRemoved := sfxResponse.RemoveTarget(targetURL)
+		server.metrics.removedTargets.Add(float64(numRemoved), removedTargetReasonTenant)
+	}
+
+	emptyTarget := sfxResponse.GetTarget("")
+	if emptyTarget != nil {
+		server.logger.Warn(MessageKey, "Removing target with empty TargetURL",
+			AriadneKey, server.makeRemovedTargetLogEntry(requestID, queryString, emptyTarget))
+		numRemoved := sfxResponse.RemoveTarget("")
+		server.metrics.removedTargets.Add(float64(numRemoved), removedTargetReasonEmptyTargetURL)
+	}
+}
+
+// Returns the states of the circuit breakers of the tenant's SFX and Primo HTTP
+// clients, if they have them.
+func (tenant *Tenant) getCircuitBreakerStates() map[string]string {
+	states := map[string]string{}
+	if circuitBreaker := tenant.PrimoClient.CircuitBreaker(); circuitBreaker != nil {
+		states["primo"] = circuitBreaker.State().String()
+	}
+	if circuitBreaker := tenant.SFXClient.CircuitBreaker(); circuitBreaker != nil {
+		states["sfx"] = circuitBreaker.State().String()
+	}
+
+	return states
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"ariadne/log"
+	"ariadne/primo"
+	"ariadne/sfx"
+	"ariadne/testutils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTenants(t *testing.T) {
+	t.Parallel()
+
+	testCase := getTestCase(t, "the-new-yorker")
+	removedTargetURL := "http://proxy.library.nyu.edu/login?url=http://archives.newyorker.com/#folio=C1"
+
+	newFakeSFXServer := func(numRequests *int32) *httptest.Server {
+		return httptest.NewServer(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(numRequests, 1)
+
+				sfxFakeResponse, err := testutils.GetSFXFakeResponse(testCase)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				fmt.Fprint(w, sfxFakeResponse)
+			}),
+		)
+	}
+
+	var numNYURequests, numNYUADRequests int32
+	fakeNYUSFXServer := newFakeSFXServer(&numNYURequests)
+	defer fakeNYUSFXServer.Close()
+	fakeNYUADSFXServer := newFakeSFXServer(&numNYUADRequests)
+	defer fakeNYUADSFXServer.Close()
+
+	logger := log.New(io.Discard, log.LevelDisabled)
+	nyuadTenant := newTestTenant("nyuad", fakeNYUADSFXServer.URL, primo.DefaultPrimoURL, logger)
+	nyuadTenant.RemovedTargetURLs = []string{removedTargetURL}
+	server := newTestServer(t, "", "", Options{
+		DefaultTenant: "nyu",
+		Logger:        logger,
+		Tenants: []*Tenant{
+			newTestTenant("nyu", fakeNYUSFXServer.URL, primo.DefaultPrimoURL, logger),
+			nyuadTenant,
+		},
+	})
+
+	queryString := strings.TrimPrefix(testCase.QueryString, "?")
+
+	requests := []struct {
+		name                   string
+		url                    string
+		expectedStatusCode     int
+		expectedNYURequests    int32
+		expectedNYUADRequests  int32
+		expectRemovedTargetURL bool
+	}{
+		{"No tenant", "/v0/?" + queryString, http.StatusOK, 1, 0, true},
+		{"Path prefix", "/v0/nyuad/?" + queryString, http.StatusOK, 0, 1, false},
+		{"Path prefix without trailing slash", "/v0/nyuad?" + queryString, http.StatusOK, 0, 1, false},
+		{"Query param", "/v0/?institution=NYUAD&" + queryString, http.StatusOK, 0, 1, false},
+		{"Unknown query param value", "/v0/?institution=01NYU_INST&" + queryString, http.StatusOK, 1, 0, true},
+		{"Unknown path prefix", "/v0/nyush/?" + queryString, http.StatusNotFound, 0, 0, false},
+	}
+
+	for _, request := range requests {
+		t.Run(request.name, func(t *testing.T) {
+			atomic.StoreInt32(&numNYURequests, 0)
+			atomic.StoreInt32(&numNYUADRequests, 0)
+
+			responseRecorder := httptest.NewRecorder()
+			server.ServeHTTP(responseRecorder, httptest.NewRequest("GET", request.url, nil))
+			response := responseRecorder.Result()
+
+			if response.StatusCode != request.expectedStatusCode {
+				t.Errorf("Expected HTTP status %d, got %d", request.expectedStatusCode, response.StatusCode)
+			}
+			if numNYURequests != request.expectedNYURequests || numNYUADRequests != request.expectedNYUADRequests {
+				t.Errorf("Expected %d NYU and %d NYUAD SFX requests, got %d and %d",
+					request.expectedNYURequests, request.expectedNYUADRequests, numNYURequests, numNYUADRequests)
+			}
+
+			body, _ := io.ReadAll(response.Body)
+			var apiResponse Response
+			err := json.Unmarshal(body, &apiResponse)
+			if err != nil {
+				t.Fatalf("Could not unmarshal response: %s", err)
+			}
+
+			if request.expectedStatusCode != http.StatusOK {
+				if len(apiResponse.Errors) != 1 || apiResponse.Errors[0].Code != ErrorCodeUnknownTenant {
+					t.Errorf("Expected a single %s error, got %+v", ErrorCodeUnknownTenant, apiResponse.Errors)
+				}
+				return
+			}
+
+			foundRemovedTargetURL := false
+			for _, link := range apiResponse.Records[0].Links {
+				if link.Url == removedTargetURL {
+					foundRemovedTargetURL = true
+				}
+			}
+			if foundRemovedTargetURL != request.expectRemovedTargetURL {
+				t.Errorf("Expected link %s to be present: %t", removedTargetURL, request.expectRemovedTargetURL)
+			}
+		})
+	}
+}
+
+func TestNewRouterTenants(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name              string
+		tenantNames       []string
+		defaultTenantName string
+		expectedError     string
+	}{
+		{"Valid", []string{"nyu", "nyuad"}, "nyuad", ""},
+		{"Default tenant name defaults to nyu", []string{"nyuad", "nyu"}, "", ""},
+		{"Duplicate", []string{"nyu", "NYU"}, "nyu", "Duplicate tenant \"nyu\""},
+		{"Unknown default", []string{"nyu"}, "nyush", "Default tenant \"nyush\" is not one of the configured tenants"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			tenants := []*Tenant{}
+			for _, name := range testCase.tenantNames {
+				tenants = append(tenants, newTestTenant(name, sfx.DefaultSFXURL, primo.DefaultPrimoURL, nil))
+			}
+
+			server, err := NewRouter(Options{DefaultTenant: testCase.defaultTenantName, Tenants: tenants})
+			if testCase.expectedError == "" {
+				expectedDefaultTenantName := testCase.defaultTenantName
+				if expectedDefaultTenantName == "" {
+					expectedDefaultTenantName = DefaultTenantName
+				}
+				if err != nil {
+					t.Errorf("NewRouter returned error: %s", err)
+				} else if server.defaultTenant.Name != expectedDefaultTenantName {
+					t.Errorf("Expected default tenant \"%s\", got \"%s\"", expectedDefaultTenantName, server.defaultTenant.Name)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != testCase.expectedError {
+				t.Errorf("NewRouter returned error '%v', expecting '%s'", err, testCase.expectedError)
+			}
+		})
+	}
+}
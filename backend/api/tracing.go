@@ -0,0 +1,45 @@
+package api
+
+import (
+	"ariadne/tracing"
+	"fmt"
+	"net/http"
+)
+
+// Records the HTTP status written by the wrapped handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (recorder *statusRecorder) WriteHeader(statusCode int) {
+	recorder.statusCode = statusCode
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Runs `handler` in a server span, which continues the trace in the request's
+// `traceparent` header, if any.  The SFX and Primo client spans are children of
+// this span.
+func (server *Server) traceWrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if server.tracer == nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		parent, _ := tracing.Extract(r.Header)
+		ctx, span := server.tracer.StartSpan(r.Context(), r.Method+" /v0/", tracing.SpanKindServer, parent)
+		defer span.End()
+		// The query string is not recorded, since it can contain patron data.
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("url.path", r.URL.Path)
+
+		recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		handler.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttribute("http.response.status_code", recorder.statusCode)
+		if recorder.statusCode >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("HTTP status %d", recorder.statusCode))
+		}
+	})
+}
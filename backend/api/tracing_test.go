@@ -0,0 +1,140 @@
+package api
+
+import (
+	"ariadne/primo"
+	"ariadne/testutils"
+	"ariadne/tracing"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	t.Parallel()
+
+	testCase := getTestCase(t, "contrived-frbr-group-test-case")
+
+	var mutex sync.Mutex
+	upstreamTraceparents := []string{}
+	recordTraceparent := func(r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		upstreamTraceparents = append(upstreamTraceparents, r.Header.Get(tracing.TraceparentHeader))
+	}
+
+	fakeSFXServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recordTraceparent(r)
+			sfxFakeResponse, err := testutils.GetSFXFakeResponse(testCase)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, sfxFakeResponse)
+		}),
+	)
+	defer fakeSFXServer.Close()
+	fakePrimoServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recordTraceparent(r)
+			var primoFakeResponse string
+			var err error
+			if r.URL.Query().Get(primo.FRBRMemberSearchQueryParamName) == "" {
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseSearch(testCase)
+			} else {
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseFRBRMemberSearch(testCase)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, primoFakeResponse)
+		}),
+	)
+	defer fakePrimoServer.Close()
+
+	var spanOutput bytes.Buffer
+	server := newTestServer(t, fakeSFXServer.URL, fakePrimoServer.URL, Options{
+		Tracer: tracing.NewTracer(tracing.NewWriterExporter(&spanOutput, "ariadne")),
+	})
+
+	incomingTraceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	header := http.Header{}
+	header.Set(tracing.TraceparentHeader, incomingTraceparent)
+	doResolverRequestWithHeader(t, server, testCase.QueryString, header)
+
+	// Decode only the span fields needed to check the structure of the trace.
+	type span struct {
+		TraceID      string `json:"traceId"`
+		SpanID       string `json:"spanId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+	}
+	spans := []span{}
+	decoder := json.NewDecoder(&spanOutput)
+	for decoder.More() {
+		var exportRequest struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []span `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		err := decoder.Decode(&exportRequest)
+		if err != nil {
+			t.Fatalf("Could not decode exported span: %s", err)
+		}
+		spans = append(spans, exportRequest.ResourceSpans[0].ScopeSpans[0].Spans...)
+	}
+
+	var serverSpan span
+	for _, span := range spans {
+		if span.Name == "GET /v0/" {
+			serverSpan = span
+		}
+	}
+	if serverSpan.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || serverSpan.ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatalf("Expected server span continuing trace %s, got %+v", incomingTraceparent, spans)
+	}
+
+	childSpanNames := []string{}
+	childSpanIDs := map[string]bool{}
+	for _, span := range spans {
+		if span == serverSpan {
+			continue
+		}
+		if span.TraceID != serverSpan.TraceID || span.ParentSpanID != serverSpan.SpanID {
+			t.Errorf("Expected span %s to be a child of the server span, got %+v", span.Name, span)
+		}
+		childSpanNames = append(childSpanNames, span.Name)
+		childSpanIDs[span.SpanID] = true
+	}
+	sort.Strings(childSpanNames)
+	expectedChildSpanNames := []string{
+		"Primo FRBR member search",
+		"Primo ISBN search",
+		"SFX request",
+	}
+	if strings.Join(childSpanNames, ", ") != strings.Join(expectedChildSpanNames, ", ") {
+		t.Errorf("Expected child spans %v, got %v", expectedChildSpanNames, childSpanNames)
+	}
+
+	// Each upstream request carries the trace context of its own client span.
+	if len(upstreamTraceparents) != len(expectedChildSpanNames) {
+		t.Errorf("Expected %d upstream requests, got %d", len(expectedChildSpanNames), len(upstreamTraceparents))
+	}
+	for _, traceparent := range upstreamTraceparents {
+		spanContext, err := tracing.ParseTraceparent(traceparent)
+		if err != nil || spanContext.TraceID.String() != serverSpan.TraceID || !childSpanIDs[spanContext.SpanID.String()] {
+			t.Errorf("Upstream request traceparent \"%s\" does not match a client span", traceparent)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	ariadneconfig "ariadne/config"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var configFile string
+
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Configuration utilities",
+}
+
+var printCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective config",
+	Long: "Print the config that `ariadne server` would use, as YAML: the defaults merged with the config file " +
+		"and " + ariadneconfig.EnvVarPrefix + "* environment variables.  " +
+		"Flags passed to `ariadne server` are not included.  " +
+		"Exits with an error after printing if the config is invalid.",
+	Example: "ARIADNE_SFX_TIMEOUT=5s ariadne config print --config ariadne.yaml",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if configFile == "" {
+			configFile = os.Getenv(ariadneconfig.FileEnvVar)
+		}
+
+		effectiveConfig, err := ariadneconfig.Load(configFile)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(effectiveConfig)
+
+		return effectiveConfig.Validate()
+	},
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func init() {
+	printCmd.Flags().StringVar(&configFile, "config", "",
+		"YAML config file.  Defaults to the value of "+ariadneconfig.FileEnvVar+", if set.")
+
+	ConfigCmd.AddCommand(printCmd)
+}
@@ -25,10 +25,12 @@ var dumpJSONCmd = &cobra.Command{
 }
 
 func dumpJSON(queryString string) string {
+	// The default options can't be invalid.
+	server, _ := api.NewRouter(api.Options{})
 	request := httptest.NewRequest("GET",
 		fmt.Sprintf("http://localhost/does-no-matter/?%s", queryString), nil)
 	responseWriter := httptest.NewRecorder()
-	api.ResolverHandler(responseWriter, request)
+	server.ResolverHandler(responseWriter, request)
 	response := responseWriter.Result()
 	responseJSON, _ := io.ReadAll(response.Body)
 
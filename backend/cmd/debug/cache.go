@@ -0,0 +1,58 @@
+package debug
+
+import (
+	"ariadne/api"
+	"ariadne/redis"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+var redisAddress string
+var tenantName string
+
+func init() {
+	dumpCachedUpstreamResponsesCmd.Flags().StringVar(&redisAddress, "redis-address", redis.DefaultAddress,
+		"Address of the Redis server used by the API server's response cache")
+	dumpCachedUpstreamResponsesCmd.Flags().StringVar(&tenantName, "institution", api.DefaultTenantName,
+		"Institution (tenant) that the query string was resolved for")
+	DebugCmd.AddCommand(dumpCachedUpstreamResponsesCmd)
+}
+
+var dumpCachedUpstreamResponsesCmd = &cobra.Command{
+	Use:   "cached-upstream-responses [query string]",
+	Short: "Dump the SFX and Primo responses for query string cached by an API server running with --cache-backend redis --cache-upstream-responses",
+	Example: "ariadne debug cached-upstream-responses --redis-address localhost:6379 " +
+		"'?sid=&aulast=Shakespeare&aufirst=William&genre=book&title=The%20Oxford%20Shakespeare:%20Hamlet&date=1987&isbn=9780198129103'",
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var queryString = args[0]
+		dump, err := dumpCachedUpstreamResponses(queryString)
+		if err != nil {
+			fmt.Println(err)
+		}
+
+		fmt.Println(dump)
+	},
+}
+
+func dumpCachedUpstreamResponses(queryString string) (string, error) {
+	cache, err := api.NewRedisCache(redisAddress)
+	if err != nil {
+		return "", err
+	}
+	defer cache.Close()
+
+	cachedUpstreamResponses, err := api.GetCachedUpstreamResponses(context.Background(), cache, tenantName, queryString)
+	if err != nil {
+		return "", err
+	}
+
+	dumpBytes, err := json.MarshalIndent(cachedUpstreamResponses, "", "    ")
+	if err != nil {
+		return "", fmt.Errorf("Could not marshal cached upstream responses to JSON: %v", err)
+	}
+
+	return string(dumpBytes), nil
+}
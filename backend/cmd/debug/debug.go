@@ -2,6 +2,8 @@ package debug
 
 import (
 	"ariadne/log"
+	"ariadne/primo"
+	"ariadne/sfx"
 	"github.com/spf13/cobra"
 )
 
@@ -10,6 +12,10 @@ var DebugCmd = &cobra.Command{
 	Short: "Debugging utilities",
 }
 
+// The debug commands query the default SFX instance and Primo view.
+var primoClient = primo.NewClient(primo.DefaultPrimoURL, primo.ClientOptions{})
+var sfxClient = sfx.NewClient(sfx.DefaultSFXURL, sfx.ClientOptions{})
+
 func init() {
 	log.SetLevel(log.LevelError)
 }
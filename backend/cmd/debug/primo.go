@@ -1,11 +1,12 @@
 package debug
 
 import (
+	"ariadne/primo"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/spf13/cobra"
-
-	"ariadne/primo"
+	"strings"
 )
 
 func init() {
@@ -34,7 +35,7 @@ var dumpPrimoAPIResponsesCmd = &cobra.Command{
 
 var dumpPrimoFRBRMemberRequestsCmd = &cobra.Command{
 	Use:     "primo-frbr-member-requests [query string]",
-	Short:   "Dump Primo HTTP requests for query string: all FRBR member requests after the initial ISBN search request",
+	Short:   "Dump Primo HTTP requests for query string: all FRBR member requests after the initial search request",
 	Example: "ariadne debug primo-frbr-member-requests '?sid=&aulast=Shakespeare&aufirst=William&genre=book&title=The%20Oxford%20Shakespeare:%20Hamlet&date=1987&isbn=9780198129103'",
 	Args:    cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -66,7 +67,8 @@ var dumpPrimoHTTPResponsesCmd = &cobra.Command{
 
 var dumpPrimoISBNSearchHTTPRequestCmd = &cobra.Command{
 	Use:     "primo-isbn-search-request [query string]",
-	Short:   "Dump Primo HTTP request for query string: initial ISBN search request only",
+	Aliases: []string{"primo-search-request"},
+	Short:   "Dump Primo HTTP request for query string: initial search request only, whatever the search strategy, preceded by the ISBNs searched for, if any",
 	Example: "ariadne debug primo-isbn-search-request '?sid=&aulast=Shakespeare&aufirst=William&genre=book&title=The%20Oxford%20Shakespeare:%20Hamlet&date=1987&isbn=9780198129103'",
 	Args:    cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -97,12 +99,12 @@ var primoLinksJSONCmd = &cobra.Command{
 }
 
 func dumpPrimoAPIResponses(queryString string) (string, error) {
-	primoRequest, err := primo.NewPrimoRequest(queryString)
+	primoRequest, err := primoClient.NewRequest(queryString)
 	if err != nil {
 		return queryString, err
 	}
 
-	primoResponse, err := primo.Do(primoRequest)
+	primoResponse, err := primoClient.Do(context.Background(), primoRequest)
 	if err != nil {
 		return queryString, err
 	}
@@ -116,12 +118,12 @@ func dumpPrimoAPIResponses(queryString string) (string, error) {
 }
 
 func dumpPrimoFRBRMemberRequests(queryString string) (string, error) {
-	primoRequest, err := primo.NewPrimoRequest(queryString)
+	primoRequest, err := primoClient.NewRequest(queryString)
 	if err != nil {
 		return queryString, err
 	}
 
-	primoResponse, err := primo.Do(primoRequest)
+	primoResponse, err := primoClient.Do(context.Background(), primoRequest)
 	if err != nil {
 		return queryString, err
 	}
@@ -135,12 +137,12 @@ func dumpPrimoFRBRMemberRequests(queryString string) (string, error) {
 }
 
 func dumpPrimoHTTPResponses(queryString string) (string, error) {
-	primoRequest, err := primo.NewPrimoRequest(queryString)
+	primoRequest, err := primoClient.NewRequest(queryString)
 	if err != nil {
 		return queryString, err
 	}
 
-	primoResponse, err := primo.Do(primoRequest)
+	primoResponse, err := primoClient.Do(context.Background(), primoRequest)
 	if err != nil {
 		return queryString, err
 	}
@@ -154,21 +156,25 @@ func dumpPrimoHTTPResponses(queryString string) (string, error) {
 }
 
 func dumpPrimoISBNSearchHTTPRequest(queryString string) (string, error) {
-	primoRequest, err := primo.NewPrimoRequest(queryString)
+	primoRequest, err := primoClient.NewRequest(queryString)
 	if err != nil {
 		return queryString, err
 	}
 
-	return primoRequest.DumpedISBNSearchHTTPRequest, nil
+	if primoRequest.Strategy != primo.SearchStrategyISBN {
+		return primoRequest.DumpedSearchHTTPRequest, nil
+	}
+
+	return fmt.Sprintf("ISBNs: %s\n\n%s", strings.Join(primoRequest.ISBNs, ", "), primoRequest.DumpedSearchHTTPRequest), nil
 }
 
 func linksJSON(queryString string) (string, error) {
-	primoRequest, err := primo.NewPrimoRequest(queryString)
+	primoRequest, err := primoClient.NewRequest(queryString)
 	if err != nil {
 		return queryString, err
 	}
 
-	primoResponse, err := primo.Do(primoRequest)
+	primoResponse, err := primoClient.Do(context.Background(), primoRequest)
 	if err != nil {
 		return queryString, err
 	}
@@ -182,7 +188,7 @@ func linksJSON(queryString string) (string, error) {
 }
 
 func formatDumpedHTTPRequestEntry(dumpedHTTPRequest string, i int) string {
-	return formatDumpedEntry("DumpedISBNSearchHTTPRequest", dumpedHTTPRequest, i)
+	return formatDumpedEntry("DumpedSearchHTTPRequest", dumpedHTTPRequest, i)
 }
 
 func formatDumpedHTTPResponseEntry(dumpedHTTPResponse string, i int) string {
@@ -1,11 +1,10 @@
 package debug
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/spf13/cobra"
-
-	"ariadne/sfx"
 )
 
 func init() {
@@ -47,7 +46,7 @@ var dumpSFXHTTPResponseCmd = &cobra.Command{
 }
 
 func dumpSFXHTTPRequest(queryString string) (string, error) {
-	sfxRequest, err := sfx.NewSFXRequest(queryString)
+	sfxRequest, err := sfxClient.NewRequest(queryString)
 	if err != nil {
 		return queryString, err
 	}
@@ -56,12 +55,12 @@ func dumpSFXHTTPRequest(queryString string) (string, error) {
 }
 
 func dumpSFXHTTPResponse(queryString string) (string, error) {
-	sfxRequest, err := sfx.NewSFXRequest(queryString)
+	sfxRequest, err := sfxClient.NewRequest(queryString)
 	if err != nil {
 		return queryString, err
 	}
 
-	sfxResponse, err := sfx.Do(sfxRequest)
+	sfxResponse, err := sfxClient.Do(context.Background(), sfxRequest)
 	if err != nil {
 		return queryString, err
 	}
@@ -86,12 +85,12 @@ var targetsJSONCmd = &cobra.Command{
 }
 
 func targetsJSON(queryString string) (string, error) {
-	sfxRequest, err := sfx.NewSFXRequest(queryString)
+	sfxRequest, err := sfxClient.NewRequest(queryString)
 	if err != nil {
 		return queryString, err
 	}
 
-	sfxResponse, err := sfx.Do(sfxRequest)
+	sfxResponse, err := sfxClient.Do(context.Background(), sfxRequest)
 	if err != nil {
 		return queryString, err
 	}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"ariadne/cmd/config"
 	"ariadne/cmd/debug"
 	"ariadne/cmd/server"
 )
@@ -25,6 +26,7 @@ func Execute() {
 }
 
 func init() {
+	rootCmd.AddCommand(config.ConfigCmd)
 	rootCmd.AddCommand(debug.DebugCmd)
 	rootCmd.AddCommand(server.ServerCmd)
 }
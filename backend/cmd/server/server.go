@@ -2,46 +2,426 @@ package server
 
 import (
 	"ariadne/api"
+	"ariadne/config"
+	"ariadne/crossref"
 	"ariadne/log"
+	"ariadne/primo"
+	"ariadne/redis"
+	"ariadne/sfx"
+	"ariadne/tracing"
+	"ariadne/unpaywall"
+	"context"
 	"fmt"
 	"github.com/spf13/cobra"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 )
 
-const defaultPort = "8080"
+// How long buffered trace spans have to be exported on shutdown, after draining
+const traceExportTimeout = 5 * time.Second
 
+var cacheBackend string
+var cacheSize int
+var cacheTTL time.Duration
+var cacheUpstreamResponses bool
+var configFile string
+var hideOutOfCoverage bool
 var loggingLevel string
 var port string
+var redisAddress string
+var primoTimeout time.Duration
+var resolverTimeout time.Duration
+var sfxTimeout time.Duration
 
 var ServerCmd = &cobra.Command{
 	Use:     "server",
 	Short:   "Start API server",
-	Example: "ariadne server",
+	Example: "ariadne server --config ariadne.yaml",
 	Run: func(cmd *cobra.Command, args []string) {
-		start()
+		serverConfig, err := loadConfig(cmd)
+		if err != nil {
+			log.Fatal(api.MessageKey, err)
+		}
+
+		start(serverConfig)
 	},
 }
 
 func init() {
+	ServerCmd.Flags().StringVar(&configFile, "config", "",
+		"YAML config file.  Defaults to the value of "+config.FileEnvVar+", if set.  "+
+			"Flags take precedence over "+config.EnvVarPrefix+"* environment variables, "+
+			"which take precedence over the config file.")
+	ServerCmd.Flags().StringVar(&cacheBackend, "cache-backend", config.CacheBackendMemory,
+		"Response cache backend: "+config.CacheBackendMemory+", "+config.CacheBackendRedis)
+	ServerCmd.Flags().IntVar(&cacheSize, "cache-size", api.DefaultCacheSize,
+		"Maximum number of responses to cache in memory.  Set to 0 to disable the response cache.")
+	ServerCmd.Flags().DurationVar(&cacheTTL, "cache-ttl", api.DefaultCacheTTL,
+		"How long to cache responses for")
+	ServerCmd.Flags().BoolVar(&cacheUpstreamResponses, "cache-upstream-responses", false,
+		"Also cache raw SFX and Primo responses, for replay by `ariadne debug cached-upstream-responses`")
+	ServerCmd.Flags().BoolVar(&hideOutOfCoverage, "hide-out-of-coverage", false,
+		"Remove SFX links whose coverage doesn't include the citation's date, volume, and issue.  "+
+			"Can be overridden per request with the \""+api.HideOutOfCoverageParam+"\" query param.")
 	ServerCmd.Flags().StringVarP(&loggingLevel, "logging-level", "l",
 		log.DefaultLevelStringOption,
 		"Sets logging level: "+strings.Join(log.GetValidLevelOptionStrings(), ", ")+"")
-	ServerCmd.Flags().StringVarP(&port, "port", "p", defaultPort, "Port to run server on")
+	ServerCmd.Flags().StringVarP(&port, "port", "p", config.DefaultPort, "Port to run server on")
+	ServerCmd.Flags().StringVar(&redisAddress, "redis-address", redis.DefaultAddress,
+		"Address of Redis server used by the redis cache backend: host:port or redis://[[username]:password@]host:port[/database]")
+	ServerCmd.Flags().DurationVar(&primoTimeout, "primo-timeout",
+		primo.DefaultTimeout, "Timeout for each individual HTTP request to Primo")
+	ServerCmd.Flags().DurationVar(&sfxTimeout, "sfx-timeout",
+		sfx.DefaultTimeout, "Timeout for each individual HTTP request to SFX")
+	ServerCmd.Flags().DurationVarP(&resolverTimeout, "resolver-timeout", "t",
+		api.DefaultResolverTimeout, "Deadline for the SFX and Primo requests made for each resolver request")
 }
 
-func start() {
-	router := api.NewRouter()
+// Returns the validated config from the config file and environment variables,
+// overridden by any flags that were explicitly set.
+func loadConfig(cmd *cobra.Command) (config.Config, error) {
+	if configFile == "" {
+		configFile = os.Getenv(config.FileEnvVar)
+	}
+
+	serverConfig, err := config.Load(configFile)
+	if err != nil {
+		return serverConfig, err
+	}
 
-	normalizedLogLevel := strings.ToLower(loggingLevel)
+	flags := cmd.Flags()
+	if flags.Changed("cache-backend") {
+		serverConfig.Cache.Backend = cacheBackend
+	}
+	if flags.Changed("cache-size") {
+		serverConfig.Cache.Size = cacheSize
+	}
+	if flags.Changed("cache-ttl") {
+		serverConfig.Cache.TTL = config.Duration(cacheTTL)
+	}
+	if flags.Changed("cache-upstream-responses") {
+		serverConfig.Cache.UpstreamResponses = cacheUpstreamResponses
+	}
+	if flags.Changed("hide-out-of-coverage") {
+		serverConfig.Server.HideOutOfCoverage = hideOutOfCoverage
+	}
+	if flags.Changed("logging-level") {
+		serverConfig.Logging.Level = loggingLevel
+	}
+	if flags.Changed("port") {
+		serverConfig.Server.Port = port
+	}
+	if flags.Changed("redis-address") {
+		serverConfig.Cache.RedisAddress = redisAddress
+	}
+	if flags.Changed("primo-timeout") {
+		serverConfig.Primo.Timeout = config.Duration(primoTimeout)
+	}
+	if flags.Changed("resolver-timeout") {
+		serverConfig.Server.ResolverTimeout = config.Duration(resolverTimeout)
+	}
+	if flags.Changed("sfx-timeout") {
+		serverConfig.SFX.Timeout = config.Duration(sfxTimeout)
+	}
+
+	return serverConfig, serverConfig.Validate()
+}
+
+func start(serverConfig config.Config) {
+	normalizedLogLevel := strings.ToLower(serverConfig.Logging.Level)
 	err := log.SetLevelByString(normalizedLogLevel)
 	if err != nil {
 		log.Fatal(api.MessageKey, err)
 	}
 
 	log.Info(api.MessageKey, fmt.Sprintf("Logging level set to \"%s\"", normalizedLogLevel))
+	if configFile != "" {
+		log.Info(api.MessageKey, fmt.Sprintf("Using config file %s", configFile))
+	}
 
-	log.Info(api.MessageKey, "Listening on port "+port)
+	responseCacheOptions, err := makeResponseCacheOptions(serverConfig.Cache)
+	if err != nil {
+		log.Fatal(api.MessageKey, err)
+	}
+
+	// Already validated
+	redactionPolicy, _ := serverConfig.RedactionPolicy()
+
+	dumps, err := makeDumps(serverConfig)
+	if err != nil {
+		log.Fatal(api.MessageKey, err)
+	}
+
+	metrics := api.NewMetrics()
+	tracer := makeTracer(serverConfig.Tracing)
+	router, err := api.NewRouter(api.Options{
+		CORSAllowedOrigins:    serverConfig.Server.CORSAllowedOrigins,
+		Crossref:              makeCrossrefClient(serverConfig.Crossref, metrics),
+		DefaultTenant:         serverConfig.Server.DefaultTenant,
+		Dumps:                 dumps,
+		HideOutOfCoverage:     serverConfig.Server.HideOutOfCoverage,
+		Logger:                log.Default(),
+		Metrics:               metrics,
+		ReadinessCacheTTL:     time.Duration(serverConfig.Server.ReadinessCacheTTL),
+		ReadinessProbeTimeout: time.Duration(serverConfig.Server.ReadinessProbeTimeout),
+		Redaction:             &redactionPolicy,
+		ResolverTimeout:       time.Duration(serverConfig.Server.ResolverTimeout),
+		ResponseCache:         responseCacheOptions,
+		Tenants:               makeTenants(serverConfig, metrics),
+		Tracer:                tracer,
+		Unpaywall:             makeUnpaywallClient(serverConfig.Unpaywall, metrics),
+	})
+	if err != nil {
+		log.Fatal(api.MessageKey, fmt.Errorf("Could not configure tenants: %v", err))
+	}
+	log.Info(api.MessageKey, fmt.Sprintf("Default tenant set to \"%s\"", serverConfig.Server.DefaultTenant))
+	log.Info(api.MessageKey, fmt.Sprintf("Resolver timeout set to %s", time.Duration(serverConfig.Server.ResolverTimeout)))
+
+	httpServer := newHTTPServer(serverConfig.Server, router)
+
+	// Stop listening for signals once the first one is received, so that a
+	// second SIGINT kills the process without waiting for the drain.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErrors := make(chan error, 1)
+	go func() {
+		log.Info(api.MessageKey, "Listening on port "+serverConfig.Server.Port)
+		serveErrors <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErrors:
+		log.Fatal(api.MessageKey, err)
+	case <-ctx.Done():
+		stop()
+	}
+
+	err = shutdown(httpServer, router, tracer, serverConfig.Server)
+	if err != nil {
+		log.Fatal(api.MessageKey, err)
+	}
+
+	log.Info(api.MessageKey, "Server stopped")
+}
+
+func newHTTPServer(serverConfig config.Server, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              ":" + serverConfig.Port,
+		Handler:           handler,
+		IdleTimeout:       time.Duration(serverConfig.IdleTimeout),
+		MaxHeaderBytes:    serverConfig.MaxHeaderBytes,
+		ReadHeaderTimeout: time.Duration(serverConfig.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(serverConfig.ReadTimeout),
+		WriteTimeout:      time.Duration(serverConfig.WriteTimeout),
+	}
+}
+
+// Fails /healthcheck, waits for the drain delay so that the load balancer
+// notices, and then stops accepting connections and waits up to the shutdown
+// timeout for in-flight requests to complete.  Requests still in flight after
+// that are cut off.  Buffered trace spans are exported last, even if draining
+// failed.
+func shutdown(httpServer *http.Server, router *api.Server, tracer *tracing.Tracer, serverConfig config.Server) error {
+	drainDelay := time.Duration(serverConfig.DrainDelay)
+	shutdownTimeout := time.Duration(serverConfig.ShutdownTimeout)
+
+	router.StartDraining()
+	log.Info(api.MessageKey, fmt.Sprintf("Shutting down: draining in %s, shutdown timeout %s",
+		drainDelay, shutdownTimeout))
+	time.Sleep(drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := httpServer.Shutdown(ctx)
+	if err != nil {
+		httpServer.Close()
+		err = fmt.Errorf("Could not drain in-flight requests: %v", err)
+	}
+
+	// The spans of cut off requests are exported too.  The shutdown timeout may
+	// have been used up draining, so exporting gets its own.
+	traceExportCtx, cancelTraceExport := context.WithTimeout(context.Background(), traceExportTimeout)
+	defer cancelTraceExport()
+
+	traceExportErr := tracer.Shutdown(traceExportCtx)
+	if traceExportErr != nil {
+		traceExportErr = fmt.Errorf("Could not export trace spans: %v", traceExportErr)
+		if err != nil {
+			log.Error(api.MessageKey, traceExportErr.Error())
+		} else {
+			err = traceExportErr
+		}
+	}
+
+	return err
+}
+
+func makeDumps(serverConfig config.Config) (*log.Dumps, error) {
+	loggingConfig := serverConfig.Logging
+	// Already validated
+	maxSizes, _ := serverConfig.DumpMaxSizes()
+	options := log.DumpOptions{
+		MaxSizes:   maxSizes,
+		RateLimit:  loggingConfig.DumpRateLimit,
+		SampleRate: loggingConfig.DumpSampleRate,
+	}
+
+	if loggingConfig.DumpFile != "" {
+		dumpFile, err := log.NewRotatingFile(loggingConfig.DumpFile,
+			int64(loggingConfig.DumpFileMaxSize), loggingConfig.DumpFileMaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("Could not open dump file: %v", err)
+		}
+		options.Sink = dumpFile
+		log.Info(api.MessageKey, "Writing full SFX and Primo dumps to "+loggingConfig.DumpFile)
+	}
+
+	return log.NewDumps(options), nil
+}
+
+// Returns nil if tracing is disabled.
+func makeTracer(tracingConfig config.Tracing) *tracing.Tracer {
+	switch tracingConfig.Exporter {
+	case config.TracingExporterOTLP:
+		log.Info(api.MessageKey, "Tracing enabled: exporting spans to "+tracingConfig.OTLPEndpoint)
+		return tracing.NewTracer(tracing.NewOTLPExporter(tracingConfig.ServiceName, tracing.OTLPExporterOptions{
+			Endpoint: tracingConfig.OTLPEndpoint,
+			OnError: func(err error) {
+				log.Warn(api.MessageKey, err.Error())
+			},
+		}))
+	case config.TracingExporterStdout:
+		log.Info(api.MessageKey, "Tracing enabled: writing spans to stdout")
+		return tracing.NewTracer(tracing.NewWriterExporter(os.Stdout, tracingConfig.ServiceName))
+	}
+
+	return nil
+}
+
+// Returns nil if DOI lookups are disabled.
+func makeCrossrefClient(crossrefConfig config.Crossref, metrics *api.Metrics) *crossref.Client {
+	if crossrefConfig.URL == "" {
+		log.Info(api.MessageKey, "DOI lookups disabled")
+		return nil
+	}
+
+	log.Info(api.MessageKey, "DOI lookups enabled: "+crossrefConfig.URL)
+	return crossref.NewClient(crossrefConfig.URL, crossref.ClientOptions{
+		HTTPClient:      crossref.NewHTTPClient(time.Duration(crossrefConfig.Timeout), log.Default()),
+		Logger:          log.Default(),
+		Mailto:          crossrefConfig.Mailto,
+		RequestObserver: metrics.ObserveCrossrefRequest,
+	})
+}
+
+// Returns nil if open-access lookups are disabled.
+func makeUnpaywallClient(unpaywallConfig config.Unpaywall, metrics *api.Metrics) *unpaywall.Client {
+	if unpaywallConfig.URL == "" {
+		log.Info(api.MessageKey, "Open-access lookups disabled")
+		return nil
+	}
+
+	log.Info(api.MessageKey, "Open-access lookups enabled: "+unpaywallConfig.URL)
+	return unpaywall.NewClient(unpaywallConfig.URL, unpaywallConfig.Email, unpaywall.ClientOptions{
+		HTTPClient:      unpaywall.NewHTTPClient(time.Duration(unpaywallConfig.Timeout), log.Default()),
+		Logger:          log.Default(),
+		RequestObserver: metrics.ObserveUnpaywallRequest,
+	})
+}
+
+// Each tenant gets its own SFX and Primo clients.  Tenants with the same SFX URL
+// share one SFX HTTP client, and so one circuit breaker, so that an SFX instance
+// that is down doesn't trip the breaker for tenants whose instances are up.  All
+// tenants share one Primo HTTP client, since they all use the same Primo URL.
+// The tenants' SFX and Primo clients report upstream request latency to `metrics`.
+func makeTenants(serverConfig config.Config, metrics *api.Metrics) []*api.Tenant {
+	sfxHTTPClients := map[string]*http.Client{}
+	primoHTTPClient := primo.NewHTTPClient(time.Duration(serverConfig.Primo.Timeout), log.Default())
+
+	tenants := []*api.Tenant{}
+	for _, tenantConfig := range serverConfig.ResolvedTenants() {
+		sfxHTTPClient, ok := sfxHTTPClients[tenantConfig.SFXURL]
+		if !ok {
+			sfxHTTPClient = sfx.NewHTTPClient(time.Duration(serverConfig.SFX.Timeout), log.Default())
+			sfxHTTPClients[tenantConfig.SFXURL] = sfxHTTPClient
+		}
+
+		sfxClient := sfx.NewClient(tenantConfig.SFXURL, sfx.ClientOptions{
+			HTTPClient:      sfxHTTPClient,
+			Logger:          log.Default(),
+			RequestObserver: metrics.ObserveSFXRequest,
+		})
+		// So that state change log entries say which SFX instance's breaker it is
+		if !ok {
+			sfxClient.CircuitBreaker().Name = "SFX " + tenantConfig.SFXURL
+		}
+
+		tenants = append(tenants, &api.Tenant{
+			Name:             tenantConfig.Name,
+			AskALibrarianURL: tenantConfig.AskALibrarianURL,
+			PrimoClient: primo.NewClient(serverConfig.Primo.URL, primo.ClientOptions{
+				HTTPClient:                primoHTTPClient,
+				Logger:                    log.Default(),
+				MaxConcurrentFRBRRequests: serverConfig.Primo.MaxConcurrentFRBRRequests,
+				RequestObserver:           metrics.ObservePrimoRequest,
+				SearchParams: &primo.SearchParams{
+					Institution: tenantConfig.PrimoInstitution,
+					Limit:       serverConfig.Primo.Limit,
+					Scope:       serverConfig.Primo.Scope,
+					View:        tenantConfig.PrimoView,
+				},
+			}),
+			RemovedTargetURLs: tenantConfig.RemovedTargetURLs,
+			SFXClient:         sfxClient,
+		})
+		log.Info(api.MessageKey, fmt.Sprintf("Tenant \"%s\": SFX %s, Primo %s (inst=%s, vid=%s)",
+			tenantConfig.Name, tenantConfig.SFXURL, serverConfig.Primo.URL,
+			tenantConfig.PrimoInstitution, tenantConfig.PrimoView))
+	}
+
+	return tenants
+}
+
+func makeResponseCacheOptions(cacheConfig config.Cache) (api.ResponseCacheOptions, error) {
+	cacheTTL := time.Duration(cacheConfig.TTL)
+	options := api.ResponseCacheOptions{
+		CacheUpstreamResponses: cacheConfig.UpstreamResponses,
+		TTL:                    cacheTTL,
+	}
+
+	switch cacheConfig.Backend {
+	case config.CacheBackendMemory:
+		if cacheConfig.Size <= 0 {
+			log.Info(api.MessageKey, "Response cache disabled")
+			return api.ResponseCacheOptions{}, nil
+		}
+
+		options.Cache = api.NewMemoryCache(cacheConfig.Size)
+		log.Info(api.MessageKey, fmt.Sprintf("Response cache enabled: memory, size %d, TTL %s", cacheConfig.Size, cacheTTL))
+	case config.CacheBackendRedis:
+		redisCache, err := api.NewRedisCache(cacheConfig.RedisAddress)
+		if err != nil {
+			return options, fmt.Errorf("Could not create Redis cache: %v", err)
+		}
+
+		// Not fatal, since the server might come up later, and cache errors are
+		// treated as misses.
+		err = redisCache.Ping(context.Background())
+		if err != nil {
+			log.Warn(api.MessageKey, fmt.Sprintf("Could not ping Redis server: %v", err))
+		}
+
+		options.Cache = redisCache
+		log.Info(api.MessageKey, fmt.Sprintf("Response cache enabled: redis, TTL %s", cacheTTL))
+	default:
+		return options, fmt.Errorf("Invalid cache backend \"%s\"", cacheConfig.Backend)
+	}
 
-	log.Fatal(api.MessageKey, http.ListenAndServe(":"+port, router))
+	return options, nil
 }
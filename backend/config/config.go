@@ -0,0 +1,747 @@
+package config
+
+import (
+	"ariadne/api"
+	"ariadne/crossref"
+	"ariadne/log"
+	"ariadne/primo"
+	"ariadne/redis"
+	"ariadne/sfx"
+	"ariadne/tracing"
+	"ariadne/unpaywall"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const CacheBackendMemory = "memory"
+const CacheBackendRedis = "redis"
+
+const DefaultTracingServiceName = "ariadne"
+
+const TracingExporterNone = "none"
+const TracingExporterOTLP = "otlp"
+const TracingExporterStdout = "stdout"
+
+const DefaultPort = "8080"
+
+const DefaultDumpFileMaxBackups = 5
+const DefaultDumpFileMaxSize = 100 << 20
+
+// Long enough for a load balancer to notice the failing /healthcheck after a
+// couple of health checks at typical intervals.
+const DefaultDrainDelay = 5 * time.Second
+
+// `http.Server` defaults.  The write timeout leaves time to write the response
+// after the resolver timeout.
+const DefaultIdleTimeout = 120 * time.Second
+const DefaultMaxHeaderBytes = 1 << 20
+const DefaultReadHeaderTimeout = 10 * time.Second
+const DefaultReadTimeout = 30 * time.Second
+const DefaultShutdownTimeout = 30 * time.Second
+const DefaultWriteTimeout = api.DefaultResolverTimeout + 30*time.Second
+
+// Environment variable containing the path of the config file.  Used if the
+// `--config` flag is not set.
+const FileEnvVar = "ARIADNE_CONFIG"
+
+// Replaces credentials in the output of `Config.String`
+const redactionMask = "REDACTED"
+
+// Prefix of the environment variables which override config file values.  The
+// rest of the name is the section and key, upper-cased: e.g. `sfx.url` is
+// overridden by ARIADNE_SFX_URL, and `cache.redis_address` by
+// ARIADNE_CACHE_REDIS_ADDRESS.  Lists are comma-separated.
+const EnvVarPrefix = "ARIADNE_"
+
+// Effective configuration for `ariadne server`.  Values are merged in order of
+// increasing precedence: defaults, config file, environment variables, and then
+// command line flags.  Tenants can only be set in the config file.
+type Config struct {
+	Cache     Cache     `yaml:"cache"`
+	Crossref  Crossref  `yaml:"crossref"`
+	Logging   Logging   `yaml:"logging"`
+	Primo     Primo     `yaml:"primo"`
+	Server    Server    `yaml:"server"`
+	SFX       SFX       `yaml:"sfx"`
+	Tenants   []Tenant  `yaml:"tenants"`
+	Tracing   Tracing   `yaml:"tracing"`
+	Unpaywall Unpaywall `yaml:"unpaywall"`
+}
+
+type Cache struct {
+	// "memory" or "redis"
+	Backend      string `yaml:"backend"`
+	RedisAddress string `yaml:"redis_address"`
+	// Only used by the memory backend.  0 disables the response cache.
+	Size              int      `yaml:"size"`
+	TTL               Duration `yaml:"ttl"`
+	UpstreamResponses bool     `yaml:"upstream_responses"`
+}
+
+// DOI metadata lookups for OpenURLs that SFX and Primo don't find anything for.
+type Crossref struct {
+	// Contact email for Crossref's "polite" pool
+	Mailto  string   `yaml:"mailto"`
+	Timeout Duration `yaml:"timeout"`
+	// Empty disables DOI lookups.
+	URL string `yaml:"url"`
+}
+
+type Logging struct {
+	// Dumped SFX and Primo requests and responses written in full, if set.
+	DumpFile           string `yaml:"dump_file"`
+	DumpFileMaxBackups int    `yaml:"dump_file_max_backups"`
+	DumpFileMaxSize    int    `yaml:"dump_file_max_size"`
+	// "type:bytes" rules.  See `api.DumpTypes`.
+	DumpMaxSizes   []string `yaml:"dump_max_sizes"`
+	DumpRateLimit  float64  `yaml:"dump_rate_limit"`
+	DumpSampleRate float64  `yaml:"dump_sample_rate"`
+	Level          string   `yaml:"level"`
+	// Headers and query params redacted from log entries, as "name:action"
+	// rules.  See `api.RedactionPolicy`.
+	RedactedHeaders []string `yaml:"redacted_headers"`
+	RedactedParams  []string `yaml:"redacted_params"`
+}
+
+type Primo struct {
+	Institution string `yaml:"institution"`
+	Limit       int    `yaml:"limit"`
+	// Maximum number of FRBR member requests made at once for each resolver
+	// request
+	MaxConcurrentFRBRRequests int      `yaml:"max_concurrent_frbr_requests"`
+	Scope                     string   `yaml:"scope"`
+	Timeout                   Duration `yaml:"timeout"`
+	URL                       string   `yaml:"url"`
+	View                      string   `yaml:"view"`
+}
+
+type Server struct {
+	CORSAllowedOrigins []string `yaml:"cors_allowed_origins"`
+	// Tenant used for requests that don't select one
+	DefaultTenant string `yaml:"default_tenant"`
+	// How long to keep serving, with /healthcheck failing, after SIGINT or
+	// SIGTERM before draining, so that the load balancer stops routing to this
+	// instance.  Can be set to 0 when there is no load balancer, e.g. locally.
+	DrainDelay        Duration `yaml:"drain_delay"`
+	HideOutOfCoverage bool     `yaml:"hide_out_of_coverage"`
+	IdleTimeout       Duration `yaml:"idle_timeout"`
+	MaxHeaderBytes    int      `yaml:"max_header_bytes"`
+	Port              string   `yaml:"port"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout"`
+	ReadTimeout       Duration `yaml:"read_timeout"`
+	// How long /readyz reuses the results of SFX and Primo probes
+	ReadinessCacheTTL     Duration `yaml:"readiness_cache_ttl"`
+	ReadinessProbeTimeout Duration `yaml:"readiness_probe_timeout"`
+	ResolverTimeout       Duration `yaml:"resolver_timeout"`
+	// Deadline for in-flight requests to complete after draining starts
+	ShutdownTimeout Duration `yaml:"shutdown_timeout"`
+	// Must be longer than the resolver timeout, or slow responses are cut off.
+	WriteTimeout Duration `yaml:"write_timeout"`
+}
+
+type SFX struct {
+	Timeout Duration `yaml:"timeout"`
+	URL     string   `yaml:"url"`
+}
+
+// An institution served by this deployment.  Empty SFX and Primo values are
+// inherited from the top-level `sfx` and `primo` sections.
+type Tenant struct {
+	// Lowercase, used in the /v0/<name>/ path prefix and `institution` query param
+	Name              string   `yaml:"name"`
+	AskALibrarianURL  string   `yaml:"ask_a_librarian_url,omitempty"`
+	PrimoInstitution  string   `yaml:"primo_institution,omitempty"`
+	PrimoView         string   `yaml:"primo_view,omitempty"`
+	RemovedTargetURLs []string `yaml:"removed_target_urls,omitempty"`
+	SFXURL            string   `yaml:"sfx_url,omitempty"`
+}
+
+type Tracing struct {
+	// "none", "otlp", or "stdout"
+	Exporter string `yaml:"exporter"`
+	// OTLP/HTTP traces endpoint of the collector
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	ServiceName  string `yaml:"service_name"`
+}
+
+// Open-access copies of works with DOIs, for responses with no subscribed full
+// text.
+type Unpaywall struct {
+	// Required by Unpaywall with every request
+	Email   string   `yaml:"email"`
+	Timeout Duration `yaml:"timeout"`
+	// Empty disables open-access lookups.
+	URL string `yaml:"url"`
+}
+
+// `time.Duration` that is read and written as a string like "30s", rather than
+// as a number of nanoseconds.
+type Duration time.Duration
+
+func (duration Duration) MarshalYAML() (any, error) {
+	return time.Duration(duration).String(), nil
+}
+
+func (duration *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return duration.parse(node.Value)
+}
+
+func (duration *Duration) parse(value string) error {
+	parsedDuration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*duration = Duration(parsedDuration)
+
+	return nil
+}
+
+// Query params in URLs whose values are redacted by `Config.String`, e.g.
+// "apikey" or "access_token".
+var credentialParamRegexp = regexp.MustCompile(`(?i)key|token|secret|password|signature`)
+
+var durationType = reflect.TypeOf(Duration(0))
+
+var tenantNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+func Default() Config {
+	return Config{
+		Cache: Cache{
+			Backend:      CacheBackendMemory,
+			RedisAddress: redis.DefaultAddress,
+			Size:         api.DefaultCacheSize,
+			TTL:          Duration(api.DefaultCacheTTL),
+		},
+		Crossref: Crossref{
+			Timeout: Duration(crossref.DefaultTimeout),
+		},
+		Logging: Logging{
+			DumpFileMaxBackups: DefaultDumpFileMaxBackups,
+			DumpFileMaxSize:    DefaultDumpFileMaxSize,
+			DumpMaxSizes:       []string{},
+			DumpSampleRate:     1,
+			Level:              log.DefaultLevelStringOption,
+			RedactedHeaders:    api.FormatRedactionRules(api.DefaultRedactionPolicy.Headers),
+			RedactedParams:     api.FormatRedactionRules(api.DefaultRedactionPolicy.Params),
+		},
+		Primo: Primo{
+			Institution:               primo.DefaultSearchParams.Institution,
+			Limit:                     primo.DefaultSearchParams.Limit,
+			MaxConcurrentFRBRRequests: primo.DefaultMaxConcurrentFRBRRequests,
+			Scope:                     primo.DefaultSearchParams.Scope,
+			Timeout:                   Duration(primo.DefaultTimeout),
+			URL:                       primo.DefaultPrimoURL,
+			View:                      primo.DefaultSearchParams.View,
+		},
+		Server: Server{
+			CORSAllowedOrigins:    api.DefaultCORSAllowedOrigins,
+			DefaultTenant:         api.DefaultTenantName,
+			DrainDelay:            Duration(DefaultDrainDelay),
+			IdleTimeout:           Duration(DefaultIdleTimeout),
+			MaxHeaderBytes:        DefaultMaxHeaderBytes,
+			Port:                  DefaultPort,
+			ReadHeaderTimeout:     Duration(DefaultReadHeaderTimeout),
+			ReadTimeout:           Duration(DefaultReadTimeout),
+			ReadinessCacheTTL:     Duration(api.DefaultReadinessCacheTTL),
+			ReadinessProbeTimeout: Duration(api.DefaultReadinessProbeTimeout),
+			ResolverTimeout:       Duration(api.DefaultResolverTimeout),
+			ShutdownTimeout:       Duration(DefaultShutdownTimeout),
+			WriteTimeout:          Duration(DefaultWriteTimeout),
+		},
+		SFX: SFX{
+			Timeout: Duration(sfx.DefaultTimeout),
+			URL:     sfx.DefaultSFXURL,
+		},
+		Tenants: []Tenant{
+			{
+				Name:             api.DefaultTenantName,
+				AskALibrarianURL: sfx.AskALibrarianLink,
+			},
+		},
+		Tracing: Tracing{
+			Exporter:     TracingExporterNone,
+			OTLPEndpoint: tracing.DefaultOTLPEndpoint,
+			ServiceName:  DefaultTracingServiceName,
+		},
+		// Disabled by default, since Unpaywall requires an email address.
+		Unpaywall: Unpaywall{
+			Timeout: Duration(unpaywall.DefaultTimeout),
+		},
+	}
+}
+
+// Returns the defaults overridden by the YAML config file at `path`, if not empty,
+// and then by ARIADNE_* environment variables.  The result is not validated,
+// since command line flags might still override it.
+func Load(path string) (Config, error) {
+	config := Default()
+
+	if path != "" {
+		err := config.mergeFile(path)
+		if err != nil {
+			return config, err
+		}
+	}
+
+	err := config.mergeEnv(os.LookupEnv)
+	if err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// Returns the tenants with empty SFX and Primo values filled in from the
+// top-level `sfx` and `primo` sections.
+func (config Config) ResolvedTenants() []Tenant {
+	resolvedTenants := []Tenant{}
+	for _, tenant := range config.Tenants {
+		if tenant.PrimoInstitution == "" {
+			tenant.PrimoInstitution = config.Primo.Institution
+		}
+		if tenant.PrimoView == "" {
+			tenant.PrimoView = config.Primo.View
+		}
+		if tenant.SFXURL == "" {
+			tenant.SFXURL = config.SFX.URL
+		}
+		resolvedTenants = append(resolvedTenants, tenant)
+	}
+
+	return resolvedTenants
+}
+
+// Returns the maximum size of each type of dump in log entries.  Returns an
+// error if a rule is not of the form "type:bytes" with a known type.
+func (config Config) DumpMaxSizes() (map[string]int, error) {
+	maxSizes := map[string]int{}
+	for _, rule := range config.Logging.DumpMaxSizes {
+		dumpType, sizeString, ok := strings.Cut(rule, ":")
+		if !ok {
+			return nil, fmt.Errorf("\"%s\" is not of the form \"type:bytes\"", rule)
+		}
+		if !contains(api.DumpTypes, dumpType) {
+			return nil, fmt.Errorf("\"%s\" is not one of %s", dumpType, strings.Join(api.DumpTypes, ", "))
+		}
+		size, err := strconv.Atoi(sizeString)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("size in \"%s\" must be a positive number of bytes", rule)
+		}
+		maxSizes[dumpType] = size
+	}
+
+	return maxSizes, nil
+}
+
+// Returns the policy for redacting log entries.  Returns an error if the
+// redaction rules are invalid.
+func (config Config) RedactionPolicy() (api.RedactionPolicy, error) {
+	headers, err := api.ParseRedactionRules(config.Logging.RedactedHeaders)
+	if err != nil {
+		return api.RedactionPolicy{}, err
+	}
+	params, err := api.ParseRedactionRules(config.Logging.RedactedParams)
+	if err != nil {
+		return api.RedactionPolicy{}, err
+	}
+
+	return api.RedactionPolicy{Headers: headers, Params: params}, nil
+}
+
+// Returns the config as YAML, in the same format as the config file, with the
+// credentials in URLs redacted so that it can be printed and logged safely.
+func (config Config) String() string {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	// Very unlikely to error, since the config only contains simple types.
+	err := encoder.Encode(config.redacted())
+	if err != nil {
+		return fmt.Sprintf("Could not marshal config to YAML: %v", err)
+	}
+
+	return buffer.String()
+}
+
+// Returns a copy of the config with the userinfo and credential query params of
+// every URL redacted.  See `redactURL`.
+func (config Config) redacted() Config {
+	config.Cache.RedisAddress = redactURL(config.Cache.RedisAddress)
+	config.Crossref.URL = redactURL(config.Crossref.URL)
+	config.Primo.URL = redactURL(config.Primo.URL)
+	config.SFX.URL = redactURL(config.SFX.URL)
+	config.Tracing.OTLPEndpoint = redactURL(config.Tracing.OTLPEndpoint)
+	config.Unpaywall.URL = redactURL(config.Unpaywall.URL)
+
+	// Copied so that the original config's tenants aren't changed.
+	if config.Tenants != nil {
+		tenants := make([]Tenant, len(config.Tenants))
+		for i, tenant := range config.Tenants {
+			tenant.AskALibrarianURL = redactURL(tenant.AskALibrarianURL)
+			tenant.SFXURL = redactURL(tenant.SFXURL)
+			tenants[i] = tenant
+		}
+		config.Tenants = tenants
+	}
+
+	return config
+}
+
+// Returns an error listing every invalid value, not just the first.
+func (config Config) Validate() error {
+	problems := []string{}
+	addProblem := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch config.Cache.Backend {
+	case CacheBackendMemory:
+		if config.Cache.Size < 0 {
+			addProblem("cache.size must not be negative")
+		}
+	case CacheBackendRedis:
+		if config.Cache.RedisAddress == "" {
+			addProblem("cache.redis_address is required for the redis cache backend")
+		}
+	default:
+		addProblem("cache.backend must be \"%s\" or \"%s\", got \"%s\"",
+			CacheBackendMemory, CacheBackendRedis, config.Cache.Backend)
+	}
+	if config.Cache.TTL <= 0 {
+		addProblem("cache.ttl must be positive")
+	}
+
+	if config.Crossref.Timeout <= 0 {
+		addProblem("crossref.timeout must be positive")
+	}
+	if config.Crossref.URL != "" {
+		if err := validateUpstreamURL(config.Crossref.URL); err != nil {
+			addProblem("crossref.url %v", err)
+		}
+	}
+
+	if config.Logging.DumpFileMaxBackups < 0 {
+		addProblem("logging.dump_file_max_backups must not be negative")
+	}
+	if config.Logging.DumpFileMaxSize <= 0 {
+		addProblem("logging.dump_file_max_size must be positive")
+	}
+	if _, err := config.DumpMaxSizes(); err != nil {
+		addProblem("logging.dump_max_sizes: %v", err)
+	}
+	if config.Logging.DumpRateLimit < 0 {
+		addProblem("logging.dump_rate_limit must not be negative")
+	}
+	if config.Logging.DumpSampleRate < 0 || config.Logging.DumpSampleRate > 1 {
+		addProblem("logging.dump_sample_rate must be from 0 to 1, got %v", config.Logging.DumpSampleRate)
+	}
+	validLevels := log.GetValidLevelOptionStrings()
+	if !contains(validLevels, strings.ToLower(config.Logging.Level)) {
+		addProblem("logging.level must be one of %s, got \"%s\"",
+			strings.Join(validLevels, ", "), config.Logging.Level)
+	}
+	if _, err := api.ParseRedactionRules(config.Logging.RedactedHeaders); err != nil {
+		addProblem("logging.redacted_headers: %v", err)
+	}
+	if _, err := api.ParseRedactionRules(config.Logging.RedactedParams); err != nil {
+		addProblem("logging.redacted_params: %v", err)
+	}
+
+	if config.Primo.Institution == "" {
+		addProblem("primo.institution is required")
+	}
+	if config.Primo.Limit <= 0 {
+		addProblem("primo.limit must be positive")
+	}
+	if config.Primo.MaxConcurrentFRBRRequests <= 0 {
+		addProblem("primo.max_concurrent_frbr_requests must be positive")
+	}
+	if config.Primo.Scope == "" {
+		addProblem("primo.scope is required")
+	}
+	if config.Primo.Timeout <= 0 {
+		addProblem("primo.timeout must be positive")
+	}
+	if err := validateUpstreamURL(config.Primo.URL); err != nil {
+		addProblem("primo.url %v", err)
+	}
+	if config.Primo.View == "" {
+		addProblem("primo.view is required")
+	}
+
+	for _, origin := range config.Server.CORSAllowedOrigins {
+		if err := validateOrigin(origin); err != nil {
+			addProblem("server.cors_allowed_origins %v", err)
+		}
+	}
+	port, err := strconv.Atoi(config.Server.Port)
+	if err != nil || port < 1 || port > 65535 {
+		addProblem("server.port must be a number from 1 to 65535, got \"%s\"", config.Server.Port)
+	}
+	if config.Server.ResolverTimeout <= 0 {
+		addProblem("server.resolver_timeout must be positive")
+	}
+	if config.Server.DrainDelay < 0 {
+		addProblem("server.drain_delay must not be negative")
+	}
+	if config.Server.IdleTimeout <= 0 {
+		addProblem("server.idle_timeout must be positive")
+	}
+	if config.Server.MaxHeaderBytes <= 0 {
+		addProblem("server.max_header_bytes must be positive")
+	}
+	if config.Server.ReadHeaderTimeout <= 0 {
+		addProblem("server.read_header_timeout must be positive")
+	}
+	if config.Server.ReadTimeout <= 0 {
+		addProblem("server.read_timeout must be positive")
+	}
+	if config.Server.ReadinessCacheTTL <= 0 {
+		addProblem("server.readiness_cache_ttl must be positive")
+	}
+	if config.Server.ReadinessProbeTimeout <= 0 {
+		addProblem("server.readiness_probe_timeout must be positive")
+	}
+	if config.Server.ShutdownTimeout <= 0 {
+		addProblem("server.shutdown_timeout must be positive")
+	}
+	if config.Server.WriteTimeout <= config.Server.ResolverTimeout {
+		addProblem("server.write_timeout must be longer than server.resolver_timeout")
+	}
+
+	if config.SFX.Timeout <= 0 {
+		addProblem("sfx.timeout must be positive")
+	}
+	if err := validateUpstreamURL(config.SFX.URL); err != nil {
+		addProblem("sfx.url %v", err)
+	}
+
+	if len(config.Tenants) == 0 {
+		addProblem("tenants must not be empty")
+	}
+	tenantNames := []string{}
+	for i, tenant := range config.Tenants {
+		if !tenantNameRegexp.MatchString(tenant.Name) {
+			addProblem("tenants[%d].name must be lowercase letters, digits, and dashes, got \"%s\"", i, tenant.Name)
+		} else if contains(tenantNames, tenant.Name) {
+			addProblem("tenants[%d].name \"%s\" is not unique", i, tenant.Name)
+		}
+		tenantNames = append(tenantNames, tenant.Name)
+
+		if tenant.SFXURL != "" {
+			if err := validateUpstreamURL(tenant.SFXURL); err != nil {
+				addProblem("tenants[%d].sfx_url %v", i, err)
+			}
+		}
+	}
+	if len(config.Tenants) > 0 && !contains(tenantNames, config.Server.DefaultTenant) {
+		addProblem("server.default_tenant must be one of the tenants, got \"%s\"", config.Server.DefaultTenant)
+	}
+
+	switch config.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterStdout:
+	case TracingExporterOTLP:
+		if err := validateUpstreamURL(config.Tracing.OTLPEndpoint); err != nil {
+			addProblem("tracing.otlp_endpoint %v", err)
+		}
+	default:
+		addProblem("tracing.exporter must be \"%s\", \"%s\", or \"%s\", got \"%s\"",
+			TracingExporterNone, TracingExporterOTLP, TracingExporterStdout, config.Tracing.Exporter)
+	}
+	if config.Tracing.ServiceName == "" {
+		addProblem("tracing.service_name is required")
+	}
+
+	if config.Unpaywall.Timeout <= 0 {
+		addProblem("unpaywall.timeout must be positive")
+	}
+	if config.Unpaywall.URL != "" {
+		if err := validateUpstreamURL(config.Unpaywall.URL); err != nil {
+			addProblem("unpaywall.url %v", err)
+		}
+		if config.Unpaywall.Email == "" {
+			addProblem("unpaywall.email is required if unpaywall.url is set")
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func (config *Config) mergeFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Could not open config file: %v", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	// Catch typos, which would otherwise silently leave the default in place.
+	decoder.KnownFields(true)
+	err = decoder.Decode(config)
+	// An empty file is a valid config file.
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("Could not parse config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// Overrides values for which `lookupEnv` returns a value.  `lookupEnv` is
+// `os.LookupEnv` except in tests.
+func (config *Config) mergeEnv(lookupEnv func(string) (string, bool)) error {
+	sections := reflect.ValueOf(config).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		// Tenants are a list of structs, which don't map onto single variables.
+		if section.Kind() != reflect.Struct {
+			continue
+		}
+		sectionName := sections.Type().Field(i).Tag.Get("yaml")
+		for j := 0; j < section.NumField(); j++ {
+			key := section.Type().Field(j).Tag.Get("yaml")
+			envVar := EnvVarPrefix + strings.ToUpper(sectionName+"_"+key)
+			value, ok := lookupEnv(envVar)
+			if !ok {
+				continue
+			}
+
+			err := setFromString(section.Field(j), value)
+			if err != nil {
+				return fmt.Errorf("Invalid value for %s: %v", envVar, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func setFromString(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		return field.Addr().Interface().(*Duration).parse(value)
+	}
+
+	switch field.Kind() {
+	case reflect.Bool:
+		parsedValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsedValue)
+	case reflect.Float64:
+		parsedValue, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsedValue)
+	case reflect.Int:
+		parsedValue, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(parsedValue))
+	case reflect.Slice:
+		values := []string{}
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				values = append(values, item)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	case reflect.String:
+		field.SetString(value)
+	default:
+		return fmt.Errorf("unsupported config field type %s", field.Type())
+	}
+
+	return nil
+}
+
+func validateUpstreamURL(rawURL string) error {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("is not a valid URL: %v", err)
+	}
+	if (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return fmt.Errorf("must be an absolute http or https URL, got \"%s\"", rawURL)
+	}
+
+	return nil
+}
+
+// Origins are "*" or a scheme and host with no path: e.g. "https://library.nyu.edu".
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+
+	parsedURL, err := url.Parse(origin)
+	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" || parsedURL.Path != "" {
+		return fmt.Errorf("must be \"*\" or an origin like \"https://example.com\", got \"%s\"", origin)
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Replaces the password in `rawURL`, or the whole userinfo if there is no
+// password, and the values of query params matching `credentialParamRegexp`,
+// with `redactionMask`.  Values that aren't URLs, like Redis "host:port"
+// addresses, are returned as is.  Unparseable URLs are redacted entirely, since
+// their credentials can't be found.
+func redactURL(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return redactionMask
+	}
+	if parsedURL.Host == "" {
+		return rawURL
+	}
+
+	if parsedURL.User != nil {
+		if _, ok := parsedURL.User.Password(); ok {
+			parsedURL.User = url.UserPassword(parsedURL.User.Username(), redactionMask)
+		} else {
+			parsedURL.User = url.User(redactionMask)
+		}
+	}
+
+	params := parsedURL.Query()
+	redactedParams := false
+	for name := range params {
+		if credentialParamRegexp.MatchString(name) {
+			params[name] = []string{redactionMask}
+			redactedParams = true
+		}
+	}
+	if redactedParams {
+		parsedURL.RawQuery = params.Encode()
+	}
+
+	return parsedURL.String()
+}
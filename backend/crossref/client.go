@@ -0,0 +1,145 @@
+package crossref
+
+import (
+	"ariadne/log"
+	"ariadne/resilience"
+	"ariadne/tracing"
+	"ariadne/upstream"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Crossref REST API works endpoint.  Metadata for a DOI is at <url>/<DOI>.  DOI
+// lookups are disabled unless the server's `crossref.url` is set, e.g. to this.
+const DefaultCrossrefURL = "https://api.crossref.org/works"
+
+// Default timeout for each individual HTTP request made to Crossref.  DOI
+// lookups are only made when SFX and Primo have found nothing, so they should
+// not take up much of the resolver timeout.
+const DefaultTimeout = 5 * time.Second
+
+const messageKey = "message"
+
+// Called after each HTTP request to Crossref completes, e.g. to record metrics.
+// `err` is non-nil if the request failed, returned a non-2xx status, or could not
+// be parsed.
+type RequestObserver func(duration time.Duration, err error)
+
+type ClientOptions struct {
+	// Defaults to an HTTP client returned by `NewHTTPClient(DefaultTimeout, nil)`
+	// which is shared by all clients that don't set one.
+	HTTPClient *http.Client
+	// Defaults to `log.Default()`.
+	Logger *log.Logger
+	// Contact email sent with each request, which gets requests routed to
+	// Crossref's "polite" pool.  Optional, but recommended by Crossref.
+	Mailto string
+	// Optional
+	RequestObserver RequestObserver
+}
+
+// Looks up DOI metadata in the Crossref REST API, or any service with the same
+// API, like a local fake in tests.
+type Client struct {
+	url             string
+	httpClient      *http.Client
+	logger          *log.Logger
+	mailto          string
+	requestObserver RequestObserver
+}
+
+// The "message" of a Crossref works response.
+type apiResponse struct {
+	Message Work `json:"message"`
+}
+
+var defaultHTTPClient = NewHTTPClient(DefaultTimeout, nil)
+
+func NewClient(url string, options ClientOptions) *Client {
+	client := &Client{
+		url:             strings.TrimRight(url, "/"),
+		httpClient:      options.HTTPClient,
+		logger:          options.Logger,
+		mailto:          options.Mailto,
+		requestObserver: options.RequestObserver,
+	}
+	if client.httpClient == nil {
+		client.httpClient = defaultHTTPClient
+	}
+	if client.logger == nil {
+		client.logger = log.Default()
+	}
+
+	return client
+}
+
+// Returns the circuit breaker of the client's HTTP client, or nil if it doesn't
+// have one.
+func (client *Client) CircuitBreaker() *resilience.CircuitBreaker {
+	return upstream.CircuitBreaker(client.httpClient)
+}
+
+// Returns the metadata for `doi`.  Returns an error for which
+// `upstream.IsNotFound` is true if Crossref doesn't know the DOI.  The request is
+// made in its own trace span, which is propagated to Crossref, and is cancelled
+// if `ctx` is done before it completes.
+func (client *Client) Lookup(ctx context.Context, doi string) (*Work, error) {
+	start := time.Now()
+	work, err := client.lookup(ctx, doi)
+	if client.requestObserver != nil {
+		client.requestObserver(time.Since(start), err)
+	}
+	if err != nil {
+		client.logger.Debug(messageKey,
+			fmt.Sprintf("Crossref request for DOI %s failed after %s: %v", doi, time.Since(start), err))
+	}
+
+	return work, err
+}
+
+func (client *Client) URL() string {
+	return client.url
+}
+
+func (client *Client) lookup(ctx context.Context, doi string) (work *Work, err error) {
+	ctx, span := tracing.StartSpan(ctx, "Crossref lookup", tracing.SpanKindClient)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	var response apiResponse
+	err = upstream.GetJSON(ctx, client.httpClient, "Crossref", client.queryURL(doi), &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Message.DOI == "" {
+		return nil, &upstream.Error{
+			Kind: upstream.ErrorKindParse,
+			Err:  fmt.Errorf("Crossref response for DOI %s has no DOI", doi),
+		}
+	}
+
+	return &response.Message, nil
+}
+
+// DOIs can contain characters like "#" and ";", so the DOI is escaped as a
+// single path segment.  Crossref accepts the "/" escaped.
+func (client *Client) queryURL(doi string) string {
+	queryURL := client.url + "/" + url.PathEscape(doi)
+	if client.mailto != "" {
+		queryURL += "?" + url.Values{"mailto": {client.mailto}}.Encode()
+	}
+
+	return queryURL
+}
+
+// Returns an HTTP client suitable for use in `ClientOptions`.  See
+// `upstream.NewHTTPClient`.
+func NewHTTPClient(timeout time.Duration, logger *log.Logger) *http.Client {
+	return upstream.NewHTTPClient("Crossref", timeout, logger)
+}
@@ -0,0 +1,105 @@
+package crossref
+
+import (
+	"ariadne/upstream"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testWorkJSON = `{
+  "status": "ok",
+  "message-type": "work",
+  "message": {
+    "DOI": "10.1525/ahr.2021.103.4.86",
+    "type": "journal-article",
+    "title": ["\"Life\" Magazine and the Power of Photography"],
+    "container-title": ["The Art Bulletin"],
+    "ISSN": ["0004-3079", "1559-6478"],
+    "issn-type": [{"value": "0004-3079", "type": "print"}, {"value": "1559-6478", "type": "electronic"}],
+    "volume": "103",
+    "issue": "4",
+    "page": "86-90",
+    "issued": {"date-parts": [[2021, 12]]},
+    "author": [{"given": "Kate", "family": "Palmer Albers"}],
+    "publisher": "Informa UK Limited"
+  }
+}`
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	var requestedURIs []string
+	fakeCrossrefServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestedURIs = append(requestedURIs, r.URL.RequestURI())
+			switch r.URL.Path {
+			case "/works/10.1525/ahr.2021.103.4.86":
+				fmt.Fprint(w, testWorkJSON)
+			case "/works/10.1000/invalid-json":
+				fmt.Fprint(w, "<html>")
+			default:
+				http.Error(w, "Resource not found.", http.StatusNotFound)
+			}
+		}),
+	)
+	defer fakeCrossrefServer.Close()
+
+	client := NewClient(fakeCrossrefServer.URL+"/works/", ClientOptions{Mailto: "lib-appdev@nyu.edu"})
+
+	work, err := client.Lookup(context.Background(), "10.1525/ahr.2021.103.4.86")
+	if err != nil {
+		t.Fatalf("Lookup returned error: %s", err)
+	}
+	if work.DOI != "10.1525/ahr.2021.103.4.86" || work.Type != "journal-article" || work.Volume != "103" {
+		t.Errorf("Unexpected work %+v", work)
+	}
+	expectedURI := "/works/10.1525%2Fahr.2021.103.4.86?mailto=lib-appdev%40nyu.edu"
+	if len(requestedURIs) != 1 || requestedURIs[0] != expectedURI {
+		t.Errorf("Expected request for %s, got %v", expectedURI, requestedURIs)
+	}
+
+	_, err = client.Lookup(context.Background(), "10.1000/unknown")
+	if !upstream.IsNotFound(err) {
+		t.Errorf("Expected not found error for unknown DOI, got %v", err)
+	}
+
+	_, err = client.Lookup(context.Background(), "10.1000/invalid-json")
+	var crossrefError *upstream.Error
+	if !errors.As(err, &crossrefError) || crossrefError.Kind != upstream.ErrorKindParse {
+		t.Errorf("Expected parse error, got %v", err)
+	}
+}
+
+func TestLookupTimeout(t *testing.T) {
+	t.Parallel()
+
+	// The fake never responds, so requests can only complete by timing out.
+	fakeCrossrefServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}),
+	)
+	defer fakeCrossrefServer.Close()
+
+	var observedErr error
+	client := NewClient(fakeCrossrefServer.URL, ClientOptions{
+		HTTPClient: NewHTTPClient(50*time.Millisecond, nil),
+		RequestObserver: func(duration time.Duration, err error) {
+			observedErr = err
+		},
+	})
+
+	_, err := client.Lookup(context.Background(), "10.1525/ahr.2021.103.4.86")
+	var crossrefError *upstream.Error
+	if !errors.As(err, &crossrefError) || crossrefError.Kind != upstream.ErrorKindTimeout {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+	if observedErr != err {
+		t.Errorf("Expected request observer to be called with %v, got %v", err, observedErr)
+	}
+}
@@ -0,0 +1,177 @@
+package crossref
+
+import (
+	"ariadne/openurl"
+	"fmt"
+	"strings"
+)
+
+// Base of the publisher links returned by `Work.URL`.
+const DOIResolverURL = "https://doi.org/"
+
+// Crossref work types, from https://api.crossref.org/types, which map to
+// OpenURL genres.  Types not listed are left for SFX to figure out.
+var genres = map[string]string{
+	"book":                "book",
+	"book-chapter":        "bookitem",
+	"book-part":           "bookitem",
+	"book-section":        "bookitem",
+	"edited-book":         "book",
+	"journal-article":     "article",
+	"monograph":           "book",
+	"proceedings-article": "proceeding",
+	"reference-book":      "book",
+	"report":              "report",
+}
+
+// Metadata for a single DOI, as returned in the "message" of a Crossref works
+// response.  Only the fields used to enrich OpenURLs are mapped.  Example:
+//
+//	https://api.crossref.org/works/10.1525/ahr.2021.103.4.86
+type Work struct {
+	Authors        []Author `json:"author"`
+	ContainerTitle []string `json:"container-title"`
+	DOI            string   `json:"DOI"`
+	ISBN           []string `json:"ISBN"`
+	ISSN           []string `json:"ISSN"`
+	ISSNTypes      []ISSN   `json:"issn-type"`
+	Issue          string   `json:"issue"`
+	Issued         Date     `json:"issued"`
+	Page           string   `json:"page"`
+	Publisher      string   `json:"publisher"`
+	Title          []string `json:"title"`
+	Type           string   `json:"type"`
+	Volume         string   `json:"volume"`
+}
+
+type Author struct {
+	Family string `json:"family"`
+	Given  string `json:"given"`
+}
+
+// Partial dates: `DateParts` is `[[year, month, day]]`, where month and day are
+// optional.
+type Date struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+// `Type` is "print" or "electronic".
+type ISSN struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Fills in empty referent fields of `contextObject` from the work's metadata.
+// Values already in the OpenURL are never overwritten.  Returns whether any
+// fields were filled in.
+func (work *Work) Enrich(contextObject *openurl.ContextObject) bool {
+	referent := &contextObject.Referent
+	enriched := false
+
+	setField := func(field *string, value string) {
+		value = strings.TrimSpace(value)
+		if *field == "" && value != "" {
+			*field = value
+			enriched = true
+		}
+	}
+
+	genre, isKnownType := genres[work.Type]
+	if referent.Genre == "" && isKnownType {
+		setField(&referent.Genre, genre)
+		contextObject.MetadataFormat = openurl.MetadataFormatJournal
+		if genre == "book" || genre == "bookitem" || genre == "report" {
+			contextObject.MetadataFormat = openurl.MetadataFormatBook
+		}
+	}
+
+	title := firstValue(work.Title)
+	containerTitle := firstValue(work.ContainerTitle)
+	switch genre {
+	case "book", "report":
+		setField(&referent.BTitle, title)
+	case "bookitem":
+		setField(&referent.ATitle, title)
+		setField(&referent.BTitle, containerTitle)
+	default:
+		setField(&referent.ATitle, title)
+		setField(&referent.JTitle, containerTitle)
+	}
+
+	printISSN, electronicISSN := work.issns()
+	setField(&referent.ISSN, openurl.NormalizeISSN(printISSN))
+	setField(&referent.EISSN, openurl.NormalizeISSN(electronicISSN))
+	isbn := referent.ISBN
+	setField(&referent.ISBN, firstValue(work.ISBN))
+	if referent.ISBN != isbn {
+		referent.ISBNs = append([]string{referent.ISBN}, referent.ISBNs...)
+	}
+
+	setField(&referent.Volume, work.Volume)
+	setField(&referent.Issue, work.Issue)
+	setField(&referent.Date, work.Issued.String())
+	setField(&referent.Pub, work.Publisher)
+
+	if work.Page != "" {
+		setField(&referent.Pages, work.Page)
+		startPage, endPage, _ := strings.Cut(work.Page, "-")
+		setField(&referent.SPage, startPage)
+		setField(&referent.EPage, endPage)
+	}
+
+	if len(referent.Authors()) == 0 && len(work.Authors) > 0 {
+		setField(&referent.AuLast, work.Authors[0].Family)
+		setField(&referent.AuFirst, work.Authors[0].Given)
+	}
+
+	return enriched
+}
+
+// The DOI resolver link for the work, which redirects to the publisher's
+// landing page.
+func (work *Work) URL() string {
+	return DOIResolverURL + work.DOI
+}
+
+// Returns the date in OpenURL form: "YYYY", "YYYY-MM", or "YYYY-MM-DD".  Returns
+// "" if there is no year.
+func (date Date) String() string {
+	if len(date.DateParts) == 0 || len(date.DateParts[0]) == 0 || date.DateParts[0][0] == 0 {
+		return ""
+	}
+
+	parts := date.DateParts[0]
+	formatted := fmt.Sprintf("%04d", parts[0])
+	for _, part := range parts[1:] {
+		formatted += fmt.Sprintf("-%02d", part)
+	}
+
+	return formatted
+}
+
+// Returns the print and electronic ISSNs.  Older records only have the untyped
+// `ISSN` list, whose first value is treated as the print ISSN.
+func (work *Work) issns() (string, string) {
+	printISSN, electronicISSN := "", ""
+	for _, issn := range work.ISSNTypes {
+		switch issn.Type {
+		case "print":
+			printISSN = issn.Value
+		case "electronic":
+			electronicISSN = issn.Value
+		}
+	}
+	if printISSN == "" && electronicISSN == "" {
+		printISSN = firstValue(work.ISSN)
+	}
+
+	return printISSN, electronicISSN
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
@@ -0,0 +1,139 @@
+package crossref
+
+import (
+	"ariadne/openurl"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEnrich(t *testing.T) {
+	var response apiResponse
+	err := json.Unmarshal([]byte(testWorkJSON), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	work := response.Message
+
+	testCases := []struct {
+		name             string
+		queryString      string
+		expectedEnriched bool
+		expected         openurl.Referent
+	}{
+		{
+			"DOI only",
+			"rft_id=info:doi/10.1525/ahr.2021.103.4.86",
+			true,
+			openurl.Referent{
+				ATitle:  "\"Life\" Magazine and the Power of Photography",
+				AuFirst: "Kate",
+				AuLast:  "Palmer Albers",
+				Date:    "2021-12",
+				DOI:     "10.1525/ahr.2021.103.4.86",
+				EISSN:   "1559-6478",
+				EPage:   "90",
+				Genre:   "article",
+				ISSN:    "0004-3079",
+				Issue:   "4",
+				JTitle:  "The Art Bulletin",
+				Pages:   "86-90",
+				Pub:     "Informa UK Limited",
+				SPage:   "86",
+				Volume:  "103",
+			},
+		},
+		{
+			"OpenURL values are kept",
+			"doi=10.1525/ahr.2021.103.4.86&genre=article&volume=99&aulast=Albers&date=2021",
+			true,
+			openurl.Referent{
+				ATitle: "\"Life\" Magazine and the Power of Photography",
+				AuLast: "Albers",
+				Date:   "2021",
+				DOI:    "10.1525/ahr.2021.103.4.86",
+				EISSN:  "1559-6478",
+				EPage:  "90",
+				Genre:  "article",
+				ISSN:   "0004-3079",
+				Issue:  "4",
+				JTitle: "The Art Bulletin",
+				Pages:  "86-90",
+				Pub:    "Informa UK Limited",
+				SPage:  "86",
+				Volume: "99",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			contextObject, err := openurl.Parse(testCase.queryString)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			enriched := work.Enrich(contextObject)
+			if enriched != testCase.expectedEnriched {
+				t.Errorf("Enrich returned %t, expecting %t", enriched, testCase.expectedEnriched)
+			}
+			actualJSON, _ := json.Marshal(contextObject.Referent)
+			expectedJSON, _ := json.Marshal(testCase.expected)
+			if string(actualJSON) != string(expectedJSON) {
+				t.Errorf("Expected referent:\n%s\ngot:\n%s", expectedJSON, actualJSON)
+			}
+		})
+	}
+}
+
+func TestEnrichBookChapter(t *testing.T) {
+	work := Work{
+		ContainerTitle: []string{"Our Lady of Everyday Life"},
+		DOI:            "10.1093/acprof:oso/9780195118582.003.0004",
+		ISBN:           []string{"9780195118582"},
+		Title:          []string{"Guadalupe and the Catholic Imagination"},
+		Type:           "book-chapter",
+	}
+
+	contextObject, _ := openurl.Parse("rft_id=info:doi/10.1093/acprof:oso/9780195118582.003.0004")
+	work.Enrich(contextObject)
+
+	referent := contextObject.Referent
+	if contextObject.MetadataFormat != openurl.MetadataFormatBook || referent.Genre != "bookitem" {
+		t.Errorf("Expected book format and bookitem genre, got %s and %s", contextObject.MetadataFormat, referent.Genre)
+	}
+	if referent.ATitle != work.Title[0] || referent.BTitle != work.ContainerTitle[0] || referent.ISBN != work.ISBN[0] {
+		t.Errorf("Unexpected referent %+v", referent)
+	}
+	if !reflect.DeepEqual(referent.ISBNs, work.ISBN) {
+		t.Errorf("Expected ISBNs %v, got %v", work.ISBN, referent.ISBNs)
+	}
+}
+
+func TestEnrichNothingToAdd(t *testing.T) {
+	work := Work{DOI: "10.1000/xyz", Title: []string{"Title"}}
+
+	contextObject, _ := openurl.Parse("doi=10.1000/xyz&atitle=Title")
+	if work.Enrich(contextObject) {
+		t.Errorf("Expected Enrich to return false, got referent %+v", contextObject.Referent)
+	}
+}
+
+func TestDateString(t *testing.T) {
+	testCases := []struct {
+		date     Date
+		expected string
+	}{
+		{Date{[][]int{{2021, 12, 1}}}, "2021-12-01"},
+		{Date{[][]int{{2021, 3}}}, "2021-03"},
+		{Date{[][]int{{2021}}}, "2021"},
+		{Date{[][]int{{}}}, ""},
+		{Date{}, ""},
+	}
+
+	for _, testCase := range testCases {
+		if actual := testCase.date.String(); actual != testCase.expected {
+			t.Errorf("Expected %v to be \"%s\", got \"%s\"", testCase.date.DateParts, testCase.expected, actual)
+		}
+	}
+}
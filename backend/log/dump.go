@@ -0,0 +1,166 @@
+package log
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits the size and number of log entries containing dumped HTTP requests and
+// responses, which can be tens of thousands of lines, so that debug logging can
+// be enabled in production.  Safe for concurrent use.
+type Dumps struct {
+	maxSizes   map[string]int
+	now        func() time.Time
+	sampleRate float64
+	sink       io.Writer
+	sinkMutex  sync.Mutex
+	// Nil if verbose entries are not rate limited.
+	limiter *rateLimiter
+}
+
+type DumpOptions struct {
+	// Dumps of each type longer than this many bytes are truncated.  Dumps of
+	// types not listed are not truncated.
+	MaxSizes map[string]int
+	// Maximum number of verbose entries per second across all requests.  0 is
+	// unlimited.
+	RateLimit float64
+	// Probability from 0 to 1 that the verbose entries for a request are logged.
+	SampleRate float64
+	// Full dumps are written here as lines of JSON, if not nil.  See `DumpRecord`.
+	Sink io.Writer
+}
+
+// A full dump, as written to the sink.  `SHA256` matches the hash in the
+// truncated dump in the log entry.
+type DumpRecord struct {
+	Time   time.Time `json:"time"`
+	Key    string    `json:"key"`
+	Type   string    `json:"type"`
+	Size   int       `json:"size"`
+	SHA256 string    `json:"sha256"`
+	Dump   string    `json:"dump"`
+}
+
+// Tokens are added continuously at `rate` per second, up to one second's worth.
+type rateLimiter struct {
+	mutex      sync.Mutex
+	lastRefill time.Time
+	rate       float64
+	tokens     float64
+}
+
+// Note that a zero `options.SampleRate` logs no verbose entries.
+func NewDumps(options DumpOptions) *Dumps {
+	dumps := &Dumps{
+		maxSizes:   options.MaxSizes,
+		now:        time.Now,
+		sampleRate: options.SampleRate,
+		sink:       options.Sink,
+	}
+	if options.RateLimit > 0 {
+		dumps.limiter = &rateLimiter{rate: options.RateLimit, tokens: math.Max(options.RateLimit, 1)}
+	}
+
+	return dumps
+}
+
+// Returns whether a verbose entry for the request identified by `key` should be
+// logged.  Sampling is by key, so that either all or none of a request's verbose
+// entries are logged, subject to the rate limit.
+func (dumps *Dumps) Sample(key string) bool {
+	if dumps.sampleRate <= 0 {
+		return false
+	}
+	if dumps.sampleRate < 1 {
+		var sample float64
+		if key == "" {
+			sample = rand.Float64()
+		} else {
+			sum := sha256.Sum256([]byte(key))
+			sample = float64(binary.BigEndian.Uint64(sum[:8])) / float64(math.MaxUint64)
+		}
+		if sample >= dumps.sampleRate {
+			return false
+		}
+	}
+
+	return dumps.limiter == nil || dumps.limiter.allow(dumps.now())
+}
+
+// Returns `dump` truncated to the maximum size for `dumpType`, if longer, with
+// the full size and SHA-256 hash appended.  Writes the full dump to the sink,
+// if any.
+func (dumps *Dumps) Prepare(dumpType string, key string, dump string) string {
+	maxSize, limited := dumps.maxSizes[dumpType]
+	if dumps.sink == nil && (!limited || len(dump) <= maxSize) {
+		return dump
+	}
+
+	sum := sha256.Sum256([]byte(dump))
+	hash := hex.EncodeToString(sum[:])
+
+	if dumps.sink != nil {
+		dumps.writeRecord(DumpRecord{
+			Time:   dumps.now(),
+			Key:    key,
+			Type:   dumpType,
+			Size:   len(dump),
+			SHA256: hash,
+			Dump:   dump,
+		})
+	}
+
+	if !limited || len(dump) <= maxSize {
+		return dump
+	}
+
+	// Don't split a UTF-8 sequence.
+	truncatedSize := maxSize
+	for truncatedSize > 0 && !utf8.RuneStart(dump[truncatedSize]) {
+		truncatedSize--
+	}
+
+	return fmt.Sprintf("%s\n[truncated: %d of %d bytes, sha256:%s]", dump[:truncatedSize], truncatedSize, len(dump), hash)
+}
+
+// Sink errors are ignored, since they shouldn't affect the request, and there
+// is nowhere better to report them.
+func (dumps *Dumps) writeRecord(record DumpRecord) {
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+
+	dumps.sinkMutex.Lock()
+	defer dumps.sinkMutex.Unlock()
+
+	dumps.sink.Write(append(recordJSON, '\n'))
+}
+
+func (limiter *rateLimiter) allow(now time.Time) bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if !limiter.lastRefill.IsZero() {
+		elapsed := now.Sub(limiter.lastRefill).Seconds()
+		limiter.tokens = math.Min(limiter.tokens+elapsed*limiter.rate, math.Max(limiter.rate, 1))
+	}
+	limiter.lastRefill = now
+
+	if limiter.tokens < 1 {
+		return false
+	}
+	limiter.tokens--
+
+	return true
+}
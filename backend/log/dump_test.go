@@ -0,0 +1,136 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDumpsPrepare(t *testing.T) {
+	dump := "HTTP/1.1 200 OK\r\n\r\n" + strings.Repeat("é", 10)
+	// sha256 of `dump`
+	hash := "7a21b49b461ee57326a7939456f38d038dc340163056c605d51d4c360ca47951"
+
+	testCases := []struct {
+		name     string
+		maxSizes map[string]int
+		dumpType string
+		expected string
+	}{
+		{"Type not limited", map[string]int{"sfxResponse": 20}, "sfxRequest", dump},
+		{"Under limit", map[string]int{"sfxResponse": len(dump)}, "sfxResponse", dump},
+		{
+			"Truncated",
+			map[string]int{"sfxResponse": 21},
+			"sfxResponse",
+			"HTTP/1.1 200 OK\r\n\r\né\n[truncated: 21 of 39 bytes, sha256:" + hash + "]",
+		},
+		{
+			// The 21st byte is the second byte of the first "é".
+			"Truncated at rune boundary",
+			map[string]int{"sfxResponse": 20},
+			"sfxResponse",
+			"HTTP/1.1 200 OK\r\n\r\n\n[truncated: 19 of 39 bytes, sha256:" + hash + "]",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			dumps := NewDumps(DumpOptions{MaxSizes: testCase.maxSizes, SampleRate: 1})
+			actual := dumps.Prepare(testCase.dumpType, "request-id", dump)
+			if actual != testCase.expected {
+				t.Errorf("Expected:\n%q\ngot:\n%q", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDumpsSink(t *testing.T) {
+	var sink bytes.Buffer
+	dumps := NewDumps(DumpOptions{
+		MaxSizes:   map[string]int{"primoResponse": 10},
+		SampleRate: 1,
+		Sink:       &sink,
+	})
+	fullDump := strings.Repeat("x", 100)
+
+	truncatedDump := dumps.Prepare("primoResponse", "request-id", fullDump)
+	dumps.Prepare("primoRequest", "request-id", "GET / HTTP/1.1")
+
+	records := []DumpRecord{}
+	decoder := json.NewDecoder(&sink)
+	for decoder.More() {
+		var record DumpRecord
+		err := decoder.Decode(&record)
+		if err != nil {
+			t.Fatalf("Could not decode dump record: %s", err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 dump records, got %d", len(records))
+	}
+	record := records[0]
+	if record.Dump != fullDump || record.Key != "request-id" || record.Type != "primoResponse" || record.Size != 100 {
+		t.Errorf("Unexpected dump record %+v", record)
+	}
+	if !strings.HasSuffix(truncatedDump, fmt.Sprintf("sha256:%s]", record.SHA256)) {
+		t.Errorf("Truncated dump \"%s\" does not have the hash of the full dump %s", truncatedDump, record.SHA256)
+	}
+}
+
+func TestDumpsSample(t *testing.T) {
+	keys := []string{}
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, fmt.Sprintf("request-%d", i))
+	}
+	countSampled := func(dumps *Dumps) int {
+		numSampled := 0
+		for _, key := range keys {
+			if dumps.Sample(key) {
+				numSampled++
+			}
+		}
+		return numSampled
+	}
+
+	if numSampled := countSampled(NewDumps(DumpOptions{SampleRate: 1})); numSampled != len(keys) {
+		t.Errorf("Expected all %d requests sampled with rate 1, got %d", len(keys), numSampled)
+	}
+	if numSampled := countSampled(NewDumps(DumpOptions{SampleRate: 0})); numSampled != 0 {
+		t.Errorf("Expected no requests sampled with rate 0, got %d", numSampled)
+	}
+
+	dumps := NewDumps(DumpOptions{SampleRate: 0.25})
+	numSampled := countSampled(dumps)
+	if numSampled < 200 || numSampled > 300 {
+		t.Errorf("Expected about 250 requests sampled with rate 0.25, got %d", numSampled)
+	}
+	// The decision is the same for each of a request's entries.
+	if countSampled(dumps) != numSampled {
+		t.Error("Expected sampling to be deterministic by key")
+	}
+}
+
+func TestDumpsRateLimit(t *testing.T) {
+	now := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	dumps := NewDumps(DumpOptions{RateLimit: 2, SampleRate: 1})
+	dumps.now = func() time.Time { return now }
+
+	expected := []bool{true, true, false}
+	for i, expectedAllowed := range expected {
+		if allowed := dumps.Sample("request-id"); allowed != expectedAllowed {
+			t.Errorf("Sample #%d returned %t, expecting %t", i+1, allowed, expectedAllowed)
+		}
+	}
+
+	// Tokens are replenished at the rate limit.
+	now = now.Add(500 * time.Millisecond)
+	if !dumps.Sample("request-id") || dumps.Sample("request-id") {
+		t.Error("Expected exactly one entry to be allowed after half a second")
+	}
+}
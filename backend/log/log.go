@@ -1,6 +1,7 @@
 package log
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"golang.org/x/exp/slog"
@@ -42,19 +43,84 @@ var logLevelStringOptions = map[string]Level{
 	"disabled": LevelDisabled,
 }
 
-var programLevel = new(slog.LevelVar)
-var slogger *slog.Logger
+// A logger with its own output and level, so that e.g. each API server in a
+// test can log to its own buffer.  The package-level functions use the default
+// logger.
+type Logger struct {
+	level   *slog.LevelVar
+	slogger *slog.Logger
+}
+
+var defaultLogger = New(os.Stdout, Level(defaultSlogLevel))
+
+// Returns a logger which writes JSON log entries at or above `level` to
+// `logWriter`.
+func New(logWriter io.Writer, level Level) *Logger {
+	logger := &Logger{level: new(slog.LevelVar)}
+	logger.SetLevel(level)
+	logger.SetOutput(logWriter)
+
+	return logger
+}
+
+// The logger used by the package-level functions.
+func Default() *Logger {
+	return defaultLogger
+}
+
+func (logger *Logger) Debug(args ...any) {
+	logger.slogger.Debug(emptyMsg, args...)
+}
+
+// Whether entries at `level` are written, so that callers can skip building
+// expensive entries.
+func (logger *Logger) Enabled(level Level) bool {
+	return logger.slogger.Enabled(context.Background(), slog.Level(level))
+}
+
+func (logger *Logger) Error(args ...any) {
+	logger.slogger.Error(emptyMsg, args...)
+}
+
+func (logger *Logger) Info(args ...any) {
+	logger.slogger.Info(emptyMsg, args...)
+}
+
+func (logger *Logger) SetLevel(level Level) {
+	logger.level.Set(slog.Level(level))
+}
+
+// Useful for setting the level based on a string value set by a user via a flag
+// in CLI mode.
+func (logger *Logger) SetLevelByString(levelStringArg string) error {
+	level, ok := logLevelStringOptions[levelStringArg]
+	if !ok {
+		return errors.New(fmt.Sprintf("\"%s\" is not a valid error string option.  Valid options: %s",
+			levelStringArg, strings.Join(GetValidLevelOptionStrings(), ", ")))
+	}
 
-func init() {
-	slogger = newDefaultSlogger()
+	logger.SetLevel(level)
+
+	return nil
+}
+
+// Redirect output to another stream besides stdout, or to a bytes.Buffer for
+// testing.
+func (logger *Logger) SetOutput(logWriter io.Writer) {
+	handler := slog.HandlerOptions{Level: logger.level}.NewJSONHandler(logWriter)
+	logger.slogger = slog.New(handler)
+}
+
+func (logger *Logger) Warn(args ...any) {
+	logger.slogger.Warn(emptyMsg, args...)
 }
 
 func Debug(args ...any) {
-	slogger.Debug(emptyMsg, args...)
+	defaultLogger.Debug(args...)
 }
 
 func Error(args ...any) {
-	slogger.Error(emptyMsg, args...)
+	defaultLogger.Error(args...)
 }
 
 func Fatal(args ...any) {
@@ -100,35 +166,23 @@ func GetValidLevelOptionStrings() []string {
 }
 
 func Info(args ...any) {
-	slogger.Info(emptyMsg, args...)
+	defaultLogger.Info(args...)
 }
 
 func SetLevel(level Level) {
-	programLevel.Set(slog.Level(level))
+	defaultLogger.SetLevel(level)
 }
 
-// Useful for setting the level based on a string value set by a user via a flag
-// in CLI mode.
 func SetLevelByString(levelStringArg string) error {
-	level, ok := logLevelStringOptions[levelStringArg]
-	if ok {
-		programLevel.Set(slog.Level(level))
-		return nil
-	} else {
-		return errors.New(fmt.Sprintf("\"%s\" is not a valid error string option.  Valid options: %s",
-			levelStringArg, strings.Join(GetValidLevelOptionStrings(), ", ")))
-	}
+	return defaultLogger.SetLevelByString(levelStringArg)
 }
 
-// Redirect output to another stream besides stdout, or to a bytes.Buffer for
-// testing.
 func SetOutput(logWriter io.Writer) {
-	handler := slog.HandlerOptions{Level: programLevel}.NewJSONHandler(logWriter)
-	slogger = slog.New(handler)
+	defaultLogger.SetOutput(logWriter)
 }
 
 func Warn(args ...any) {
-	slogger.Warn(emptyMsg, args...)
+	defaultLogger.Warn(args...)
 }
 
 func getLevelOptionStringForSlogLevel(levelArg slog.Level) string {
@@ -140,10 +194,3 @@ func getLevelOptionStringForSlogLevel(levelArg slog.Level) string {
 
 	return ""
 }
-
-func newDefaultSlogger() *slog.Logger {
-	programLevel.Set(defaultSlogLevel)
-	handler := slog.HandlerOptions{Level: programLevel}.NewJSONHandler(os.Stdout)
-
-	return slog.New(handler)
-}
@@ -113,6 +113,43 @@ func TestSetLevel(t *testing.T) {
 	}
 }
 
+// Tests that loggers returned by `New()` have their own output and level, and
+// don't affect the default logger.
+func TestNew(t *testing.T) {
+	var debugLogOutput, warnLogOutput, defaultLogOutput bytes.Buffer
+	SetOutput(&defaultLogOutput)
+	SetLevel(LevelError)
+	defer SetOutput(os.Stdout)
+
+	debugLogger := New(&debugLogOutput, LevelDebug)
+	warnLogger := New(&warnLogOutput, LevelInfo)
+	warnLogger.SetLevel(LevelWarn)
+
+	for _, logger := range []*Logger{debugLogger, warnLogger} {
+		logger.Debug(messageKey, "debug")
+		logger.Info(messageKey, "info")
+		logger.Warn(messageKey, "warn")
+		logger.Error(messageKey, "error")
+	}
+
+	testCases := []struct {
+		name              string
+		logOutput         string
+		expectedLogSeries string
+	}{
+		{"Debug logger", debugLogOutput.String(), "DEBUG|debug\nINFO|info\nWARN|warn\nERROR|error"},
+		{"Warn logger", warnLogOutput.String(), "WARN|warn\nERROR|error"},
+		{"Default logger", defaultLogOutput.String(), ""},
+	}
+
+	for _, testCase := range testCases {
+		actualLogSeries := getLogSeriesString(testCase.logOutput)
+		if actualLogSeries != testCase.expectedLogSeries {
+			t.Errorf("%s logged:\n%s\nExpected:\n%s", testCase.name, actualLogSeries, testCase.expectedLogSeries)
+		}
+	}
+}
+
 // Helper function to generate a simple multiline string view of the log output JSON,
 // showing only the data of interest.
 //
@@ -0,0 +1,104 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// A file which is rotated before a write would make it larger than `maxSize`
+// bytes: the file is renamed with a ".1" suffix, ".1" to ".2", and so on, keeping
+// at most `maxBackups` old files.  Safe for concurrent use.
+type RotatingFile struct {
+	file       *os.File
+	maxBackups int
+	maxSize    int64
+	mutex      sync.Mutex
+	path       string
+	size       int64
+}
+
+// Appends to the file at `path`, if it exists.
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	rotatingFile := &RotatingFile{
+		maxBackups: maxBackups,
+		maxSize:    maxSize,
+		path:       path,
+	}
+
+	err := rotatingFile.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return rotatingFile, nil
+}
+
+// A single write larger than the maximum size is written to a file of its own.
+func (rotatingFile *RotatingFile) Write(p []byte) (int, error) {
+	rotatingFile.mutex.Lock()
+	defer rotatingFile.mutex.Unlock()
+
+	if rotatingFile.size > 0 && rotatingFile.size+int64(len(p)) > rotatingFile.maxSize {
+		err := rotatingFile.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rotatingFile.file.Write(p)
+	rotatingFile.size += int64(n)
+
+	return n, err
+}
+
+func (rotatingFile *RotatingFile) Close() error {
+	rotatingFile.mutex.Lock()
+	defer rotatingFile.mutex.Unlock()
+
+	return rotatingFile.file.Close()
+}
+
+func (rotatingFile *RotatingFile) open() error {
+	file, err := os.OpenFile(rotatingFile.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not open log file: %v", err)
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("Could not get size of log file: %v", err)
+	}
+
+	rotatingFile.file = file
+	rotatingFile.size = fileInfo.Size()
+
+	return nil
+}
+
+func (rotatingFile *RotatingFile) rotate() error {
+	err := rotatingFile.file.Close()
+	if err != nil {
+		return fmt.Errorf("Could not close log file for rotation: %v", err)
+	}
+
+	if rotatingFile.maxBackups > 0 {
+		for i := rotatingFile.maxBackups - 1; i > 0; i-- {
+			// Missing backups are expected until there have been enough rotations.
+			os.Rename(rotatingFile.backupPath(i), rotatingFile.backupPath(i+1))
+		}
+		err = os.Rename(rotatingFile.path, rotatingFile.backupPath(1))
+	} else {
+		err = os.Remove(rotatingFile.path)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not rotate log file: %v", err)
+	}
+
+	return rotatingFile.open()
+}
+
+func (rotatingFile *RotatingFile) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", rotatingFile.path, n)
+}
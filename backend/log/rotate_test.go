@@ -0,0 +1,66 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dumps.log")
+	rotatingFile, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("NewRotatingFile returned error: %s", err)
+	}
+	defer rotatingFile.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err = rotatingFile.Write([]byte(line))
+		if err != nil {
+			t.Fatalf("Write returned error: %s", err)
+		}
+	}
+
+	expectedContents := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for expectedPath, expectedContent := range expectedContents {
+		content, err := os.ReadFile(expectedPath)
+		if err != nil {
+			t.Errorf("Could not read %s: %s", expectedPath, err)
+			continue
+		}
+		if string(content) != expectedContent {
+			t.Errorf("Expected %s to contain %q, got %q", expectedPath, expectedContent, content)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected at most 2 backups, found %s.3", path)
+	}
+}
+
+func TestRotatingFileNoBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dumps.log")
+	rotatingFile, err := NewRotatingFile(path, 10, 0)
+	if err != nil {
+		t.Fatalf("NewRotatingFile returned error: %s", err)
+	}
+	defer rotatingFile.Close()
+
+	rotatingFile.Write([]byte("first\n"))
+	rotatingFile.Write([]byte("second\n"))
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read %s: %s", path, err)
+	}
+	if string(content) != "second\n" {
+		t.Errorf("Expected %q, got %q", "second\n", content)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("Expected no backups, found %s.1", path)
+	}
+}
@@ -0,0 +1,288 @@
+// Package metrics implements the subset of Prometheus metric types that we use,
+// and serves them in the Prometheus text exposition format.  See
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Histogram buckets, in seconds, suitable for upstream request latencies.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// A set of metrics which are written together.  Metrics are written in the
+// order in which they were registered, and their series are sorted by label
+// values, so that output is deterministic.
+type Registry struct {
+	mutex   sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Fields shared by all metric types.  Series are keyed by their label values,
+// joined with a separator that can't appear in label values.
+type family struct {
+	help       string
+	labelNames []string
+	mutex      sync.Mutex
+	name       string
+}
+
+const labelValuesSeparator = "\xff"
+
+// A counter with zero or more labels.  A counter with no labels has a single
+// series.
+type CounterVec struct {
+	family
+	values map[string]float64
+}
+
+// A histogram with zero or more labels.
+type HistogramVec struct {
+	family
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	// Non-cumulative -- cumulated when written.
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (registry *Registry) NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	counter := &CounterVec{
+		family: family{help: help, labelNames: labelNames, name: name},
+		values: map[string]float64{},
+	}
+	registry.register(counter)
+
+	return counter
+}
+
+// `buckets` are upper bounds in increasing order.  The "+Inf" bucket is added
+// automatically.
+func (registry *Registry) NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	histogram := &HistogramVec{
+		family:  family{help: help, labelNames: labelNames, name: name},
+		buckets: buckets,
+		series:  map[string]*histogramSeries{},
+	}
+	registry.register(histogram)
+
+	return histogram
+}
+
+func (registry *Registry) register(metric metric) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.metrics = append(registry.metrics, metric)
+}
+
+// Writes all metrics in the Prometheus text format.
+func (registry *Registry) Write(w io.Writer) error {
+	registry.mutex.Lock()
+	metrics := append([]metric{}, registry.metrics...)
+	registry.mutex.Unlock()
+
+	bufferedWriter := bufio.NewWriter(w)
+	for _, metric := range metrics {
+		metric.write(bufferedWriter)
+	}
+
+	return bufferedWriter.Flush()
+}
+
+// Handler for the endpoint scraped by Prometheus.
+func (registry *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		registry.Write(w)
+	})
+}
+
+func (counter *CounterVec) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+// Panics if `value` is negative or the number of label values is wrong, since
+// either is a programming error.
+func (counter *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", counter.name))
+	}
+	key := counter.key(labelValues)
+
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	counter.values[key] += value
+}
+
+// Returns the current value of the series, mostly for tests.
+func (counter *CounterVec) Value(labelValues ...string) float64 {
+	key := counter.key(labelValues)
+
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	return counter.values[key]
+}
+
+func (counter *CounterVec) write(w *bufio.Writer) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	counter.writeHeader(w, "counter")
+	// A counter with no labels is written even if it has never been incremented,
+	// so that it's clear that it's zero rather than missing.
+	if len(counter.labelNames) == 0 && len(counter.values) == 0 {
+		counter.writeSample(w, counter.name, "", nil, 0)
+	}
+	for _, key := range sortedKeys(counter.values) {
+		counter.writeSample(w, counter.name, key, nil, counter.values[key])
+	}
+}
+
+func (histogram *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := histogram.key(labelValues)
+
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	series, ok := histogram.series[key]
+	if !ok {
+		series = &histogramSeries{bucketCounts: make([]uint64, len(histogram.buckets))}
+		histogram.series[key] = series
+	}
+	for i, upperBound := range histogram.buckets {
+		if value <= upperBound {
+			series.bucketCounts[i]++
+			break
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+// Observes `duration` in seconds.
+func (histogram *HistogramVec) ObserveDuration(duration time.Duration, labelValues ...string) {
+	histogram.Observe(duration.Seconds(), labelValues...)
+}
+
+// Returns the number of observations in the series, mostly for tests.
+func (histogram *HistogramVec) Count(labelValues ...string) uint64 {
+	key := histogram.key(labelValues)
+
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	series, ok := histogram.series[key]
+	if !ok {
+		return 0
+	}
+
+	return series.count
+}
+
+func (histogram *HistogramVec) write(w *bufio.Writer) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	histogram.writeHeader(w, "histogram")
+	for _, key := range sortedKeys(histogram.series) {
+		series := histogram.series[key]
+		var cumulativeCount uint64
+		for i, upperBound := range histogram.buckets {
+			cumulativeCount += series.bucketCounts[i]
+			histogram.writeSample(w, histogram.name+"_bucket", key,
+				[]string{"le", formatFloat(upperBound)}, float64(cumulativeCount))
+		}
+		histogram.writeSample(w, histogram.name+"_bucket", key, []string{"le", "+Inf"}, float64(series.count))
+		histogram.writeSample(w, histogram.name+"_sum", key, nil, series.sum)
+		histogram.writeSample(w, histogram.name+"_count", key, nil, float64(series.count))
+	}
+}
+
+func (family *family) key(labelValues []string) string {
+	if len(labelValues) != len(family.labelNames) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got values %v", family.name, family.labelNames, labelValues))
+	}
+
+	return strings.Join(labelValues, labelValuesSeparator)
+}
+
+func (family *family) writeHeader(w *bufio.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", family.name, escapeHelp(family.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", family.name, metricType)
+}
+
+// `extraLabel` is a name and value pair, e.g. a histogram bucket's "le" label.
+func (family *family) writeSample(w *bufio.Writer, name string, key string, extraLabel []string, value float64) {
+	labels := []string{}
+	if len(family.labelNames) > 0 {
+		for i, labelValue := range strings.Split(key, labelValuesSeparator) {
+			labels = append(labels, fmt.Sprintf("%s=\"%s\"", family.labelNames[i], escapeLabelValue(labelValue)))
+		}
+	}
+	if extraLabel != nil {
+		labels = append(labels, fmt.Sprintf("%s=\"%s\"", extraLabel[0], extraLabel[1]))
+	}
+
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteString("{" + strings.Join(labels, ",") + "}")
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func escapeLabelValue(labelValue string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(labelValue)
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	registry := NewRegistry()
+
+	requests := registry.NewCounterVec("test_requests_total", "Requests, by outcome.", "outcome")
+	panics := registry.NewCounterVec("test_panics_total", "Recovered panics.")
+	latency := registry.NewHistogramVec("test_latency_seconds", "Latency.\nIn seconds.",
+		[]float64{0.1, 1}, "upstream")
+
+	requests.Inc("found")
+	requests.Inc("found")
+	requests.Add(3, "not \"found\"")
+	latency.ObserveDuration(50*time.Millisecond, "sfx")
+	latency.ObserveDuration(500*time.Millisecond, "sfx")
+	latency.Observe(2, "sfx")
+	latency.Observe(0.1, "primo")
+
+	expected := `# HELP test_requests_total Requests, by outcome.
+# TYPE test_requests_total counter
+test_requests_total{outcome="found"} 2
+test_requests_total{outcome="not \"found\""} 3
+# HELP test_panics_total Recovered panics.
+# TYPE test_panics_total counter
+test_panics_total 0
+# HELP test_latency_seconds Latency.\nIn seconds.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{upstream="primo",le="0.1"} 1
+test_latency_seconds_bucket{upstream="primo",le="1"} 1
+test_latency_seconds_bucket{upstream="primo",le="+Inf"} 1
+test_latency_seconds_sum{upstream="primo"} 0.1
+test_latency_seconds_count{upstream="primo"} 1
+test_latency_seconds_bucket{upstream="sfx",le="0.1"} 1
+test_latency_seconds_bucket{upstream="sfx",le="1"} 2
+test_latency_seconds_bucket{upstream="sfx",le="+Inf"} 3
+test_latency_seconds_sum{upstream="sfx"} 2.55
+test_latency_seconds_count{upstream="sfx"} 3
+`
+
+	var buffer bytes.Buffer
+	err := registry.Write(&buffer)
+	if err != nil {
+		t.Fatalf("Write returned error: %s", err)
+	}
+	if buffer.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buffer.String())
+	}
+
+	if requests.Value("found") != 2 || panics.Value() != 0 || latency.Count("sfx") != 3 {
+		t.Errorf("Expected values 2, 0, and 3, got %g, %g, and %d",
+			requests.Value("found"), panics.Value(), latency.Count("sfx"))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounterVec("test_total", "Test.").Inc()
+
+	responseRecorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(responseRecorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if responseRecorder.Header().Get("Content-Type") != ContentType {
+		t.Errorf("Expected Content-Type %s, got %s", ContentType, responseRecorder.Header().Get("Content-Type"))
+	}
+	expected := "# HELP test_total Test.\n# TYPE test_total counter\ntest_total 1\n"
+	if responseRecorder.Body.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, responseRecorder.Body.String())
+	}
+}
+
+func TestWrongNumberOfLabelValues(t *testing.T) {
+	counter := NewRegistry().NewCounterVec("test_total", "Test.", "outcome")
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected panic for missing label value")
+		}
+	}()
+	counter.Inc()
+}
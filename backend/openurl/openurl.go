@@ -0,0 +1,532 @@
+package openurl
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const KEVVersion = "Z39.88-2004"
+
+const metadataFormatPrefix = "info:ofi/fmt:kev:mtx:"
+
+const MetadataFormatBook = "book"
+const MetadataFormatJournal = "journal"
+
+const prefixToTrim = "?"
+const referentKeyPrefix = "rft."
+
+// Some sources put several ISBNs in one param, e.g. "9780198129103; 0198129106".
+var isbnSeparatorRegexp = regexp.MustCompile(`[,;|]`)
+
+// Typed representation of an OpenURL context object, parsed from either an
+// OpenURL 0.1 query string (`genre=article&aulast=...&issn=...&id=doi:...`)
+// or an OpenURL 1.0 KEV query string (`rft.genre=article&rft.aulast=...&rft_id=info:doi/...`).
+type ContextObject struct {
+	// "book" or "journal", from `rft_val_fmt` or inferred from the referent.
+	MetadataFormat string
+	// Params which are not part of the referent or referrer and which need to be
+	// passed through to the backends as-is: `pid`, `rft_dat`, `req.*`, `ctx_*`, etc.
+	Other url.Values
+	// `rfr_id` (1.0) or `sid` (0.1)
+	ReferrerID string
+	Referent   Referent
+}
+
+type Referent struct {
+	ArtNum  string
+	ATitle  string
+	Au      []string
+	AuCorp  string
+	AuFirst string
+	AuInit  string
+	AuLast  string
+	BTitle  string
+	CODEN   string
+	Date    string
+	DOI     string
+	Edition string
+	EISBN   string
+	EISSN   string
+	EPage   string
+	Genre   string
+	ISBN    string
+	ISSN    string
+	Issue   string
+	JTitle  string
+	LCCN    string
+	OCLCNum string
+	Pages   string
+	Part    string
+	Place   string
+	PMID    string
+	Pub     string
+	Series  string
+	SPage   string
+	STitle  string
+	Title   string
+	Volume  string
+	// The values after the first of repeated fields, by key without the "rft."
+	// prefix: e.g. the electronic ISSN of a journal that sends both as `issn`,
+	// or a second `rft_id=info:doi/...`.  Nil if no fields were repeated.
+	AdditionalValues map[string][]string
+	// Every ISBN in the OpenURL, print before electronic: `ISBN` and its
+	// additional values, including `rft_id=urn:ISBN:...` URNs, then `EISBN` and
+	// its additional values.  Values with several ISBNs are split up.  Not
+	// normalized or validated.  Derived from the fields above by
+	// `NewContextObject`, and so not serialized by `KEV()`.  Nil if there are no
+	// ISBNs.
+	ISBNs []string
+}
+
+// Identifiers which can be passed in as `rft_id` (1.0) or `id` (0.1) URIs,
+// in addition to their own keys.
+type identifier struct {
+	// The key of the field in Referent
+	key string
+	// The field in Referent
+	value func(referent *Referent) *string
+	// The prefix used when serializing to `rft_id`
+	infoURIPrefix string
+	// All prefixes recognized when parsing `rft_id` and `id`
+	prefixes []string
+}
+
+// Referent metadata keys.  `key` is the OpenURL 1.0 KEV key without the "rft."
+// prefix, which in most cases is also the OpenURL 0.1 key.  `aliases` are other
+// non-prefixed keys that we see in the wild.
+type referentField struct {
+	key     string
+	aliases []string
+	value   func(referent *Referent) *string
+}
+
+var identifiers = []identifier{
+	{"doi", func(r *Referent) *string { return &r.DOI }, "info:doi/", []string{"info:doi/", "doi:", "https://doi.org/", "http://dx.doi.org/"}},
+	{"pmid", func(r *Referent) *string { return &r.PMID }, "info:pmid/", []string{"info:pmid/", "pmid:"}},
+	{"oclcnum", func(r *Referent) *string { return &r.OCLCNum }, "info:oclcnum/", []string{"info:oclcnum/", "oclcnum:"}},
+	{"lccn", func(r *Referent) *string { return &r.LCCN }, "info:lccn/", []string{"info:lccn/", "lccn:"}},
+}
+
+// The ISBN and ISSN are sometimes passed in as URNs in `rft_id`: e.g.
+// `rft_id=urn:ISSN:0028-792X`.  These are used only if there is no
+// `rft.issn`/`issn` etc., and otherwise are additional values.  Either way, they
+// are serialized back out as `rft.isbn`/`rft.issn`, not `rft_id`.
+var urnIdentifiers = []identifier{
+	{"isbn", func(r *Referent) *string { return &r.ISBN }, "", []string{"urn:isbn:"}},
+	{"issn", func(r *Referent) *string { return &r.ISSN }, "", []string{"urn:issn:"}},
+}
+
+var referentFields = []referentField{
+	{"artnum", nil, func(r *Referent) *string { return &r.ArtNum }},
+	{"atitle", nil, func(r *Referent) *string { return &r.ATitle }},
+	{"aucorp", nil, func(r *Referent) *string { return &r.AuCorp }},
+	{"aufirst", nil, func(r *Referent) *string { return &r.AuFirst }},
+	{"auinit", nil, func(r *Referent) *string { return &r.AuInit }},
+	{"aulast", nil, func(r *Referent) *string { return &r.AuLast }},
+	{"btitle", nil, func(r *Referent) *string { return &r.BTitle }},
+	{"coden", nil, func(r *Referent) *string { return &r.CODEN }},
+	{"date", []string{"rft.year", "year"}, func(r *Referent) *string { return &r.Date }},
+	{"doi", nil, func(r *Referent) *string { return &r.DOI }},
+	{"edition", nil, func(r *Referent) *string { return &r.Edition }},
+	{"eisbn", nil, func(r *Referent) *string { return &r.EISBN }},
+	{"eissn", nil, func(r *Referent) *string { return &r.EISSN }},
+	{"epage", nil, func(r *Referent) *string { return &r.EPage }},
+	{"genre", nil, func(r *Referent) *string { return &r.Genre }},
+	{"isbn", nil, func(r *Referent) *string { return &r.ISBN }},
+	{"issn", nil, func(r *Referent) *string { return &r.ISSN }},
+	{"issue", nil, func(r *Referent) *string { return &r.Issue }},
+	{"jtitle", nil, func(r *Referent) *string { return &r.JTitle }},
+	{"lccn", nil, func(r *Referent) *string { return &r.LCCN }},
+	{"oclcnum", nil, func(r *Referent) *string { return &r.OCLCNum }},
+	{"pages", nil, func(r *Referent) *string { return &r.Pages }},
+	{"part", nil, func(r *Referent) *string { return &r.Part }},
+	{"place", nil, func(r *Referent) *string { return &r.Place }},
+	{"pmid", nil, func(r *Referent) *string { return &r.PMID }},
+	{"pub", nil, func(r *Referent) *string { return &r.Pub }},
+	{"series", nil, func(r *Referent) *string { return &r.Series }},
+	{"spage", nil, func(r *Referent) *string { return &r.SPage }},
+	{"stitle", nil, func(r *Referent) *string { return &r.STitle }},
+	{"title", nil, func(r *Referent) *string { return &r.Title }},
+	{"volume", nil, func(r *Referent) *string { return &r.Volume }},
+}
+
+// Params which are regenerated by `KEV()` and so are not kept in
+// ContextObject.Other.
+var administrativeKeys = map[string]struct{}{
+	"ctx_ver":     {},
+	"rfr_id":      {},
+	"rft_val_fmt": {},
+	"sid":         {},
+	"url_ver":     {},
+}
+
+var bookGenres = map[string]struct{}{
+	"book":     {},
+	"bookitem": {},
+	"document": {},
+	"report":   {},
+}
+
+// Parses an OpenURL query string into a ContextObject.  Like `url.ParseQuery`,
+// which it uses, this returns the first decoding error encountered, if any, but
+// still returns a ContextObject built from all the params that could be parsed.
+// Callers decide whether the error should be fatal.
+func Parse(queryString string) (*ContextObject, error) {
+	queryString = strings.TrimPrefix(queryString, prefixToTrim)
+
+	params, err := url.ParseQuery(queryString)
+
+	return NewContextObject(params), err
+}
+
+// Builds a ContextObject from already parsed params.  Param names are treated
+// case-insensitively, and `rft.*` keys are preferred over their 0.1 equivalents.
+// All the distinct values of repeated fields are kept, with the first in the
+// field and the rest in `Referent.AdditionalValues`.
+func NewContextObject(params url.Values) *ContextObject {
+	contextObject := &ContextObject{
+		Other: url.Values{},
+	}
+
+	// Params whose names differ only in case are merged in order of name, so that
+	// the order of their values doesn't depend on map iteration order.
+	paramNames := make([]string, 0, len(params))
+	for paramName := range params {
+		paramNames = append(paramNames, paramName)
+	}
+	sort.Strings(paramNames)
+
+	lowercasedParams := url.Values{}
+	for _, paramName := range paramNames {
+		normalizedParamName := strings.ToLower(paramName)
+		lowercasedParams[normalizedParamName] = append(lowercasedParams[normalizedParamName], params[paramName]...)
+	}
+
+	consumed := map[string]struct{}{}
+	for key := range administrativeKeys {
+		consumed[key] = struct{}{}
+	}
+
+	referent := &contextObject.Referent
+	for _, field := range referentFields {
+		paramNames := append([]string{referentKeyPrefix + field.key, field.key}, field.aliases...)
+		for _, value := range getValues(lowercasedParams, paramNames...) {
+			referent.addValue(field.key, field.value(referent), value)
+		}
+		for _, paramName := range paramNames {
+			consumed[paramName] = struct{}{}
+		}
+	}
+
+	for _, paramName := range []string{"rft.au", "au"} {
+		for _, value := range lowercasedParams[paramName] {
+			value = strings.TrimSpace(value)
+			if value != "" {
+				referent.Au = append(referent.Au, value)
+			}
+		}
+		consumed[paramName] = struct{}{}
+	}
+
+	// Identifier URIs.  URIs which we don't recognize (e.g. `info:oai/...`) are
+	// passed through untouched.
+	consumed["rft_id"] = struct{}{}
+	consumed["id"] = struct{}{}
+	for _, paramName := range paramNames {
+		normalizedParamName := strings.ToLower(paramName)
+		if normalizedParamName != "rft_id" && normalizedParamName != "id" {
+			continue
+		}
+
+		for _, value := range params[paramName] {
+			if !parseIdentifierURI(referent, value) {
+				contextObject.Other.Add(paramName, value)
+			}
+		}
+	}
+
+	referent.ISSN = NormalizeISSN(referent.ISSN)
+	referent.EISSN = NormalizeISSN(referent.EISSN)
+	referent.Genre = strings.ToLower(referent.Genre)
+	referent.normalizeAdditionalValues()
+	referent.ISBNs = referent.getISBNs()
+
+	contextObject.ReferrerID = getFirstValue(lowercasedParams, "rfr_id", "sid")
+
+	contextObject.MetadataFormat = strings.TrimPrefix(
+		strings.ToLower(getFirstValue(lowercasedParams, "rft_val_fmt")), metadataFormatPrefix)
+	if contextObject.MetadataFormat == "" {
+		contextObject.MetadataFormat = inferMetadataFormat(referent)
+	}
+
+	for paramName, values := range params {
+		if paramName == "" {
+			continue
+		}
+		if _, ok := consumed[strings.ToLower(paramName)]; ok {
+			continue
+		}
+		contextObject.Other[paramName] = append(contextObject.Other[paramName], values...)
+	}
+
+	return contextObject
+}
+
+// Returns the canonical OpenURL 1.0 KEV serialization of the context object.
+// Empty values are dropped, identifiers are serialized as `rft_id` info URIs,
+// and params in `Other` are passed through unchanged.
+func (contextObject *ContextObject) KEV() url.Values {
+	kev := url.Values{}
+
+	for paramName, values := range contextObject.Other {
+		kev[paramName] = append(kev[paramName], values...)
+	}
+
+	kev.Set("url_ver", KEVVersion)
+	kev.Set("ctx_ver", KEVVersion)
+	kev.Set("rft_val_fmt", metadataFormatPrefix+contextObject.MetadataFormat)
+
+	if contextObject.ReferrerID != "" {
+		kev.Set("rfr_id", contextObject.ReferrerID)
+	}
+
+	referent := contextObject.Referent
+	for _, field := range referentFields {
+		if isIdentifierField(field) {
+			continue
+		}
+
+		value := *field.value(&referent)
+		if value != "" {
+			kev.Set(referentKeyPrefix+field.key, value)
+		}
+		for _, additionalValue := range referent.AdditionalValues[field.key] {
+			kev.Add(referentKeyPrefix+field.key, additionalValue)
+		}
+	}
+
+	for _, au := range referent.Au {
+		kev.Add(referentKeyPrefix+"au", au)
+	}
+
+	for _, identifier := range identifiers {
+		value := *identifier.value(&referent)
+		if value != "" {
+			kev.Add("rft_id", identifier.infoURIPrefix+value)
+		}
+		for _, additionalValue := range referent.AdditionalValues[identifier.key] {
+			kev.Add("rft_id", identifier.infoURIPrefix+additionalValue)
+		}
+	}
+
+	return kev
+}
+
+// Returns author names in "Last, First" or full name form.  Full names given
+// in `au` take precedence over names built from `aulast`/`aufirst`/`auinit`.
+// SFX returns `rft.au` values like "Ross," so trailing commas are trimmed.
+func (referent Referent) Authors() []string {
+	authors := []string{}
+	for _, au := range referent.Au {
+		author := normalizeAuthor(au)
+		if author != "" {
+			authors = append(authors, author)
+		}
+	}
+
+	if len(authors) > 0 {
+		return authors
+	}
+
+	lastName := normalizeAuthor(referent.AuLast)
+	if lastName == "" {
+		return authors
+	}
+
+	firstName := referent.AuFirst
+	if firstName == "" {
+		firstName = referent.AuInit
+	}
+	if firstName != "" {
+		return append(authors, lastName+", "+firstName)
+	}
+
+	return append(authors, lastName)
+}
+
+// ISSNs are sent to us with and without the hyphen (e.g. "00182753" and
+// "0028-792X").  Returns the hyphenated form, or the original value if it
+// doesn't look like an ISSN.
+func NormalizeISSN(issn string) string {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(issn, "-", ""), " ", ""))
+	if len(normalized) != 8 {
+		return issn
+	}
+
+	return normalized[:4] + "-" + normalized[4:]
+}
+
+// Sets `field` to `value` if it is empty, otherwise adds `value` to the field's
+// additional values, unless it is a duplicate.
+func (referent *Referent) addValue(key string, field *string, value string) {
+	if *field == "" {
+		*field = value
+		return
+	}
+	if value == *field {
+		return
+	}
+	for _, additionalValue := range referent.AdditionalValues[key] {
+		if value == additionalValue {
+			return
+		}
+	}
+
+	if referent.AdditionalValues == nil {
+		referent.AdditionalValues = map[string][]string{}
+	}
+	referent.AdditionalValues[key] = append(referent.AdditionalValues[key], value)
+}
+
+// Applies the same normalization as the fields themselves get, and removes the
+// values which turn out to be duplicates, e.g. "0028792X" after "0028-792X".
+func (referent *Referent) normalizeAdditionalValues() {
+	if referent.AdditionalValues == nil {
+		return
+	}
+
+	additionalValues := referent.AdditionalValues
+	referent.AdditionalValues = nil
+	fieldsByKey := map[string]*string{}
+	for _, field := range referentFields {
+		fieldsByKey[field.key] = field.value(referent)
+	}
+
+	keys := make([]string, 0, len(additionalValues))
+	for key := range additionalValues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range additionalValues[key] {
+			switch key {
+			case "eissn", "issn":
+				value = NormalizeISSN(value)
+			case "genre":
+				value = strings.ToLower(value)
+			}
+			referent.addValue(key, fieldsByKey[key], value)
+		}
+	}
+}
+
+// See `Referent.ISBNs`.  Exact duplicates are dropped.
+func (referent *Referent) getISBNs() []string {
+	var isbns []string
+	seen := map[string]struct{}{}
+	for _, key := range []string{"isbn", "eisbn"} {
+		field := referent.ISBN
+		if key == "eisbn" {
+			field = referent.EISBN
+		}
+
+		for _, value := range append([]string{field}, referent.AdditionalValues[key]...) {
+			for _, isbn := range isbnSeparatorRegexp.Split(value, -1) {
+				isbn = strings.TrimSpace(isbn)
+				if isbn == "" {
+					continue
+				}
+				if _, ok := seen[isbn]; ok {
+					continue
+				}
+				seen[isbn] = struct{}{}
+				isbns = append(isbns, isbn)
+			}
+		}
+	}
+
+	return isbns
+}
+
+// Returns the first non-empty value for any of the param names, checked in the
+// order given.
+func getFirstValue(params url.Values, paramNames ...string) string {
+	for _, paramName := range paramNames {
+		for _, value := range params[paramName] {
+			value = strings.TrimSpace(value)
+			if value != "" {
+				return value
+			}
+		}
+	}
+
+	return ""
+}
+
+// Returns all the non-empty values for the param names, in the order given.
+func getValues(params url.Values, paramNames ...string) []string {
+	values := []string{}
+	for _, paramName := range paramNames {
+		for _, value := range params[paramName] {
+			value = strings.TrimSpace(value)
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+
+	return values
+}
+
+func inferMetadataFormat(referent *Referent) string {
+	if _, ok := bookGenres[referent.Genre]; ok {
+		return MetadataFormatBook
+	}
+
+	if referent.Genre == "" && referent.ISBN != "" && referent.ISSN == "" {
+		return MetadataFormatBook
+	}
+
+	return MetadataFormatJournal
+}
+
+func isIdentifierField(field referentField) bool {
+	switch field.key {
+	case "doi", "pmid", "oclcnum", "lccn":
+		return true
+	default:
+		return false
+	}
+}
+
+func normalizeAuthor(author string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(author), ","))
+}
+
+// Sets the matching referent field if `uri` is an identifier URI we recognize,
+// or adds it to the field's additional values if the field has already been set.
+// Returns false if the URI was not recognized.
+func parseIdentifierURI(referent *Referent, uri string) bool {
+	lowercasedURI := strings.ToLower(strings.TrimSpace(uri))
+	for _, identifier := range append(identifiers, urnIdentifiers...) {
+		for _, prefix := range identifier.prefixes {
+			if !strings.HasPrefix(lowercasedURI, prefix) {
+				continue
+			}
+
+			value := strings.TrimSpace(strings.TrimSpace(uri)[len(prefix):])
+			// Empty identifiers like `rft_id=info:doi/` are common.  We consider
+			// them recognized so that they are dropped.
+			if value != "" {
+				referent.addValue(identifier.key, identifier.value(referent), value)
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,253 @@
+package openurl
+
+import (
+	"ariadne/testutils"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+const testISBN = "9780198129103"
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name                string
+		queryString         string
+		expectedReferent    Referent
+		expectedReferrerID  string
+		expectedFormat      string
+		expectedOther       url.Values
+		expectedErrorNotNil bool
+	}{
+		{
+			name:        "OpenURL 0.1",
+			queryString: "?sid=google&auinit=TS&aulast=Rai&atitle=Moral+psychology&id=doi:10.1037/a0021867&title=Psychological+Review&volume=118&issue=1&date=2011&spage=57&issn=0033295X",
+			expectedReferent: Referent{
+				ATitle: "Moral psychology",
+				AuInit: "TS",
+				AuLast: "Rai",
+				Date:   "2011",
+				DOI:    "10.1037/a0021867",
+				ISSN:   "0033-295X",
+				Issue:  "1",
+				SPage:  "57",
+				Title:  "Psychological Review",
+				Volume: "118",
+			},
+			expectedReferrerID: "google",
+			expectedFormat:     MetadataFormatJournal,
+			expectedOther:      url.Values{},
+		},
+		{
+			name:        "OpenURL 1.0",
+			queryString: "url_ver=Z39.88-2004&ctx_tim=2021-10-22T12%3A29%3A27-04%3A00&rft.aulast=Ross&rft.date=2002&rft.genre=journal&rft.issn=0028-792X&rft.jtitle=New+Yorker&rft.lccn=++2011201780&rft_val_fmt=info%3Aofi%2Ffmt%3Akev%3Amtx%3Ajournal&rft_id=info%3Aoclcnum%2F909782404&rft_id=urn%3AISSN%3A0028-792X&rft_id=info:oai/&req.ip=209.150.44.95&rfr_id=info%3Asid%2FFirstSearch%3AWorldCat",
+			expectedReferent: Referent{
+				AuLast:  "Ross",
+				Date:    "2002",
+				Genre:   "journal",
+				ISSN:    "0028-792X",
+				JTitle:  "New Yorker",
+				LCCN:    "2011201780",
+				OCLCNum: "909782404",
+			},
+			expectedReferrerID: "info:sid/FirstSearch:WorldCat",
+			expectedFormat:     MetadataFormatJournal,
+			expectedOther: url.Values{
+				"ctx_tim": {"2021-10-22T12:29:27-04:00"},
+				"req.ip":  {"209.150.44.95"},
+				"rft_id":  {"info:oai/"},
+			},
+		},
+		{
+			name:        "`rft.*` preferred over 0.1 keys, empty values ignored",
+			queryString: "isbn=1111111111111&rft.isbn=" + testISBN + "&genre=&rft.genre=BOOK&id=pmid:&rft_id=info:pmid/18509570",
+			expectedReferent: Referent{
+				Genre:            "book",
+				ISBN:             testISBN,
+				PMID:             "18509570",
+				AdditionalValues: map[string][]string{"isbn": {"1111111111111"}},
+				ISBNs:            []string{testISBN, "1111111111111"},
+			},
+			expectedFormat: MetadataFormatBook,
+			expectedOther:  url.Values{},
+		},
+		{
+			name: "Repeated fields and identifiers",
+			queryString: "issn=0028792X&rft.issn=0028-792X&issn=1234-5678&rft_id=urn:ISSN:1234-5678&eissn=2163-3827" +
+				"&isbn=" + testISBN + "&rft_id=urn:ISBN:0198129106&isbn=0198129106" +
+				"&rft_id=info:doi/10.1037/a0021867&rft_id=info:doi/10.1037/a0021868&id=doi:10.1037/a0021867",
+			expectedReferent: Referent{
+				DOI:   "10.1037/a0021867",
+				EISSN: "2163-3827",
+				ISBN:  testISBN,
+				ISSN:  "0028-792X",
+				AdditionalValues: map[string][]string{
+					"doi":  {"10.1037/a0021868"},
+					"isbn": {"0198129106"},
+					"issn": {"1234-5678"},
+				},
+				ISBNs: []string{testISBN, "0198129106"},
+			},
+			expectedFormat: MetadataFormatJournal,
+			expectedOther:  url.Values{},
+		},
+		{
+			name: "ISBNs from all fields, print before electronic",
+			queryString: "rft.eisbn=9780191732355&eisbn=9780198129110" +
+				"&isbn=9780521532525%3B%20" + testISBN + "%20(pbk.)&rft_id=urn:ISBN:0198129106&isbn=" + testISBN,
+			expectedReferent: Referent{
+				EISBN: "9780191732355",
+				ISBN:  "9780521532525; " + testISBN + " (pbk.)",
+				AdditionalValues: map[string][]string{
+					"eisbn": {"9780198129110"},
+					"isbn":  {testISBN, "0198129106"},
+				},
+				ISBNs: []string{"9780521532525", testISBN + " (pbk.)", testISBN, "0198129106", "9780191732355", "9780198129110"},
+			},
+			expectedFormat: MetadataFormatBook,
+			expectedOther:  url.Values{},
+		},
+		{
+			name:        "Unescaped semicolon",
+			queryString: "au=Masoud,%20Ahmed%20M;Quoc%20Bao%20Pham&genre=article",
+			expectedReferent: Referent{
+				Genre: "article",
+			},
+			expectedFormat:      MetadataFormatJournal,
+			expectedOther:       url.Values{},
+			expectedErrorNotNil: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			contextObject, err := Parse(testCase.queryString)
+			if testCase.expectedErrorNotNil && err == nil {
+				t.Errorf("Parse returned no error, expecting an error")
+			}
+			if !testCase.expectedErrorNotNil && err != nil {
+				t.Errorf("Parse returned error '%v', expecting no errors", err)
+			}
+			if !reflect.DeepEqual(contextObject.Referent, testCase.expectedReferent) {
+				t.Errorf("Parse returned incorrect Referent: expected '%+v', got '%+v'",
+					testCase.expectedReferent, contextObject.Referent)
+			}
+			if contextObject.ReferrerID != testCase.expectedReferrerID {
+				t.Errorf("Parse returned incorrect ReferrerID: expected '%s', got '%s'",
+					testCase.expectedReferrerID, contextObject.ReferrerID)
+			}
+			if contextObject.MetadataFormat != testCase.expectedFormat {
+				t.Errorf("Parse returned incorrect MetadataFormat: expected '%s', got '%s'",
+					testCase.expectedFormat, contextObject.MetadataFormat)
+			}
+			if !reflect.DeepEqual(contextObject.Other, testCase.expectedOther) {
+				t.Errorf("Parse returned incorrect Other: expected '%v', got '%v'",
+					testCase.expectedOther, contextObject.Other)
+			}
+		})
+	}
+}
+
+// ISBN param names have been seen in every combination of case.
+func TestParseISBNParamNameCaseInsensitivity(t *testing.T) {
+	genericParams := url.Values{
+		"param1": {"1"},
+		"param2": {"2"},
+		"param3": {"3"},
+	}
+	testCases := []struct {
+		queryStringValues url.Values
+		expectedISBN      string
+	}{
+		{testutils.MergeURLValues(genericParams, url.Values{"isbn": {testISBN}}), testISBN},
+		{testutils.MergeURLValues(genericParams, url.Values{"rft.isbn": {testISBN}}), testISBN},
+		{testutils.MergeURLValues(genericParams, url.Values{"ISBN": {testISBN}}), testISBN},
+		{testutils.MergeURLValues(genericParams, url.Values{"RFT.ISBN": {testISBN}}), testISBN},
+		{testutils.MergeURLValues(genericParams, url.Values{"iSbN": {testISBN}}), testISBN},
+		{testutils.MergeURLValues(genericParams, url.Values{"rFt.iSbN": {testISBN}}), testISBN},
+		{genericParams, ""},
+		{url.Values{}, ""},
+	}
+
+	for _, testCase := range testCases {
+		isbn := NewContextObject(testCase.queryStringValues).Referent.ISBN
+		if isbn != testCase.expectedISBN {
+			t.Errorf(
+				"NewContextObject returned incorrect ISBN value for '%v': "+
+					"expected '%s', got '%s'",
+				testCase.queryStringValues,
+				testCase.expectedISBN,
+				isbn,
+			)
+		}
+	}
+}
+
+func TestKEV(t *testing.T) {
+	testCases := []struct {
+		name        string
+		queryString string
+		expected    string
+	}{
+		{
+			// `sid` values like this trigger SFX "XSS violation" errors, while
+			// the same value in `rfr_id` doesn't.
+			name:        "`sid` is serialized as `rfr_id`",
+			queryString: "sid=EBSCO:Scopus\\®&issn=19447485&pid=xyz",
+			expected:    "ctx_ver=Z39.88-2004&pid=xyz&rfr_id=EBSCO%3AScopus%5C%C2%AE&rft.issn=1944-7485&rft_val_fmt=info%3Aofi%2Ffmt%3Akev%3Amtx%3Ajournal&url_ver=Z39.88-2004",
+		},
+		{
+			name:        "Identifiers are serialized as `rft_id` info URIs",
+			queryString: "id=doi:10.1037/a0021867&pmid=18509570&rft.oclcnum=909782404&rft_id=info:oai/",
+			expected:    "ctx_ver=Z39.88-2004&rft_id=info%3Aoai%2F&rft_id=info%3Adoi%2F10.1037%2Fa0021867&rft_id=info%3Apmid%2F18509570&rft_id=info%3Aoclcnum%2F909782404&rft_val_fmt=info%3Aofi%2Ffmt%3Akev%3Amtx%3Ajournal&url_ver=Z39.88-2004",
+		},
+		{
+			name:        "Repeated fields and identifiers are all serialized",
+			queryString: "isbn=" + testISBN + "&rft.isbn=0198129106&rft_id=info:doi/10.1037/a0021867&rft_id=info:doi/10.1037/a0021868",
+			expected:    "ctx_ver=Z39.88-2004&rft.isbn=0198129106&rft.isbn=" + testISBN + "&rft_id=info%3Adoi%2F10.1037%2Fa0021867&rft_id=info%3Adoi%2F10.1037%2Fa0021868&rft_val_fmt=info%3Aofi%2Ffmt%3Akev%3Amtx%3Abook&url_ver=Z39.88-2004",
+		},
+		{
+			name:        "Book with multiple authors",
+			queryString: "genre=book&au=Shakespeare,+William&au=Wells,+Stanley&isbn=" + testISBN,
+			expected:    "ctx_ver=Z39.88-2004&rft.au=Shakespeare%2C+William&rft.au=Wells%2C+Stanley&rft.genre=book&rft.isbn=" + testISBN + "&rft_val_fmt=info%3Aofi%2Ffmt%3Akev%3Amtx%3Abook&url_ver=Z39.88-2004",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			contextObject, err := Parse(testCase.queryString)
+			if err != nil {
+				t.Fatalf("Parse returned error '%v', expecting no errors", err)
+			}
+
+			got := contextObject.KEV().Encode()
+			if got != testCase.expected {
+				t.Errorf("KEV returned incorrect serialization: expected '%s', got '%s'",
+					testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestAuthors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		referent Referent
+		expected []string
+	}{
+		{"`au` values", Referent{Au: []string{"Ross,", " "}, AuLast: "Ignored"}, []string{"Ross"}},
+		{"`aulast` and `aufirst`", Referent{AuLast: "Shakespeare", AuFirst: "William", AuInit: "W"}, []string{"Shakespeare, William"}},
+		{"`aulast` and `auinit`", Referent{AuLast: "Ownsworth", AuInit: "TL"}, []string{"Ownsworth, TL"}},
+		{"`aulast` only", Referent{AuLast: "Zanbar, L."}, []string{"Zanbar, L."}},
+		{"No authors", Referent{}, []string{}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			got := testCase.referent.Authors()
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Errorf("Authors returned '%v', expecting '%v'", got, testCase.expected)
+			}
+		})
+	}
+}
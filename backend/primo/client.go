@@ -1,14 +1,156 @@
 package primo
 
+import (
+	"ariadne/log"
+	"ariadne/resilience"
+	"ariadne/upstream"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
 // Primo service URL
 const DefaultPrimoURL = "https://bobcat.library.nyu.edu/primo_library/libweb/webservices/rest/primo-explore/v1/pnxs"
 
-var primoURL = DefaultPrimoURL
+// Params that are the same for every Primo request.
+type SearchParams struct {
+	Institution string
+	// Maximum number of docs returned per request
+	Limit int
+	Scope string
+	View  string
+}
+
+var DefaultSearchParams = SearchParams{
+	Institution: "NYU",
+	Limit:       50,
+	Scope:       "all",
+	View:        "NYU",
+}
+
+// Default timeout for each individual HTTP request made to Primo.
+const DefaultTimeout = 20 * time.Second
+
+// Default maximum number of FRBR member requests made concurrently for a single
+// Primo request.
+const DefaultMaxConcurrentFRBRRequests = 4
+
+const messageKey = "message"
+
+// Types of HTTP requests made to Primo, passed to `RequestObserver`.  There is
+// a search request type for each search strategy.
+const (
+	RequestTypeISBNSearch        = "isbn_search"
+	RequestTypeISSNSearch        = "issn_search"
+	RequestTypeOCLCNumSearch     = "oclcnum_search"
+	RequestTypeLCCNSearch        = "lccn_search"
+	RequestTypeTitleAuthorSearch = "title_author_search"
+	RequestTypeFRBRMemberSearch  = "frbr_member_search"
+)
+
+// Called after each HTTP request to Primo completes, e.g. to record metrics.
+// `err` is non-nil if the request failed or returned a non-2xx status.
+type RequestObserver func(requestType string, duration time.Duration, err error)
+
+type ClientOptions struct {
+	// Defaults to an HTTP client returned by `NewHTTPClient(DefaultTimeout, nil)`
+	// which is shared by all clients that don't set one, so that connections to
+	// Primo are pooled.
+	HTTPClient *http.Client
+	// Defaults to `log.Default()`.
+	Logger *log.Logger
+	// A search can return up to `SearchParams.Limit` FRBR groups, each of which
+	// needs its own request, and each request can be retried.  This limits how
+	// many are made at once, so that a single Primo request can't flood Primo and
+	// trip its circuit breaker.  Defaults to `DefaultMaxConcurrentFRBRRequests`.
+	MaxConcurrentFRBRRequests int
+	// Optional
+	RequestObserver RequestObserver
+	// Defaults to `DefaultSearchParams`.
+	SearchParams *SearchParams
+}
+
+// A single Primo view.
+type Client struct {
+	url                       string
+	httpClient                *http.Client
+	logger                    *log.Logger
+	maxConcurrentFRBRRequests int
+	requestObserver           RequestObserver
+	searchParams              SearchParams
+}
+
+var defaultHTTPClient = NewHTTPClient(DefaultTimeout, nil)
+
+func NewClient(url string, options ClientOptions) *Client {
+	client := &Client{
+		url:                       url,
+		httpClient:                options.HTTPClient,
+		logger:                    options.Logger,
+		maxConcurrentFRBRRequests: options.MaxConcurrentFRBRRequests,
+		requestObserver:           options.RequestObserver,
+		searchParams:              DefaultSearchParams,
+	}
+	if client.httpClient == nil {
+		client.httpClient = defaultHTTPClient
+	}
+	if client.logger == nil {
+		client.logger = log.Default()
+	}
+	if client.maxConcurrentFRBRRequests <= 0 {
+		client.maxConcurrentFRBRRequests = DefaultMaxConcurrentFRBRRequests
+	}
+	if options.SearchParams != nil {
+		client.searchParams = *options.SearchParams
+	}
+
+	return client
+}
+
+// Returns the circuit breaker of the client's HTTP client, or nil if it doesn't
+// have one.
+func (client *Client) CircuitBreaker() *resilience.CircuitBreaker {
+	return upstream.CircuitBreaker(client.httpClient)
+}
+
+func (client *Client) NewRequest(queryString string) (*PrimoRequest, error) {
+	primoRequest, err := newPrimoRequest(client.url, client.searchParams, queryString)
+	primoRequest.maxConcurrentFRBRRequests = client.maxConcurrentFRBRRequests
+	primoRequest.requestObserver = client.requestObserver
+
+	return primoRequest, err
+}
+
+// The search request and any FRBR member requests are cancelled if `ctx`
+// is done before they complete.
+func (client *Client) Do(ctx context.Context, request *PrimoRequest) (*PrimoResponse, error) {
+	start := time.Now()
+	primoResponse, err := request.do(ctx, client.httpClient)
+	if err != nil {
+		client.logger.Debug(messageKey,
+			fmt.Sprintf("Primo request to %s failed after %s: %v", client.url, time.Since(start), err))
+	}
+
+	return primoResponse, err
+}
+
+func (client *Client) SearchParams() SearchParams {
+	return client.searchParams
+}
+
+// Checks that Primo is reachable, for readiness probes.  See `resilience.Probe`.
+func (client *Client) Ping(ctx context.Context) error {
+	return resilience.Probe(ctx, client.httpClient, client.url)
+}
 
-func Do(request *PrimoRequest) (*PrimoResponse, error) {
-	return request.do()
+func (client *Client) URL() string {
+	return client.url
 }
 
-func SetPrimoURL(dependencyInjectedURL string) {
-	primoURL = dependencyInjectedURL
+// Returns an HTTP client suitable for use in `ClientOptions`.  Primo clients which
+// share the returned client also share its circuit breaker.  See
+// `upstream.NewHTTPClient`.
+func NewHTTPClient(timeout time.Duration, logger *log.Logger) *http.Client {
+	return upstream.NewHTTPClient("Primo", timeout, logger)
 }
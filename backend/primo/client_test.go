@@ -0,0 +1,287 @@
+package primo
+
+import (
+	"ariadne/testutils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientOptions(t *testing.T) {
+	t.Parallel()
+
+	testCase := testutils.TestCase{Key: "contrived-frbr-group-test-case"}
+
+	fakePrimoServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var primoFakeResponse string
+			var err error
+			if r.URL.Query().Get(FRBRMemberSearchQueryParamName) == "" {
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseSearch(testCase)
+			} else {
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseFRBRMemberSearch(testCase)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, primoFakeResponse)
+		}),
+	)
+	defer fakePrimoServer.Close()
+
+	transport := &countingTransport{}
+	client := NewClient(fakePrimoServer.URL, ClientOptions{HTTPClient: &http.Client{Transport: transport}})
+
+	request, err := client.NewRequest("isbn=9781111111113")
+	if err != nil {
+		t.Fatalf("NewRequest returned an error: %s", err)
+	}
+
+	primoResponse, err := client.Do(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Do returned an error: %s", err)
+	}
+
+	// The ISBN search request plus one FRBR member request should have been made
+	// using the injected client.
+	expectedNumRequests := 1 + len(primoResponse.FRBRMemberHTTPRequests)
+	if expectedNumRequests != 2 {
+		t.Errorf("Expected 1 FRBR member request, got %d", len(primoResponse.FRBRMemberHTTPRequests))
+	}
+	if int(transport.numRequests) != expectedNumRequests {
+		t.Errorf("Expected %d requests to be made with the injected client, got %d",
+			expectedNumRequests, transport.numRequests)
+	}
+}
+
+func TestClientSearchParams(t *testing.T) {
+	t.Parallel()
+
+	testCase := testutils.TestCase{Key: "contrived-frbr-group-test-case"}
+
+	var numNYUADRequests int32
+	fakePrimoServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if r.URL.Path == "/nyuad" && query.Get("inst") == "NYUAD" && query.Get("vid") == "NYUAD" &&
+				query.Get("limit") == "10" && query.Get("scope") == "nyuad" {
+				atomic.AddInt32(&numNYUADRequests, 1)
+			}
+
+			var primoFakeResponse string
+			var err error
+			if query.Get(FRBRMemberSearchQueryParamName) == "" {
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseSearch(testCase)
+			} else {
+				primoFakeResponse, err = testutils.GetPrimoFakeResponseFRBRMemberSearch(testCase)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			fmt.Fprint(w, primoFakeResponse)
+		}),
+	)
+	defer fakePrimoServer.Close()
+
+	client := NewClient(fakePrimoServer.URL+"/nyuad", ClientOptions{
+		SearchParams: &SearchParams{
+			Institution: "NYUAD",
+			Limit:       10,
+			Scope:       "nyuad",
+			View:        "NYUAD",
+		},
+	})
+
+	request, err := client.NewRequest("isbn=9781111111113")
+	if err != nil {
+		t.Fatalf("NewRequest returned an error: %s", err)
+	}
+
+	primoResponse, err := client.Do(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Do returned an error: %s", err)
+	}
+
+	// The FRBR member requests must use the client's URL and view too.
+	expectedNumRequests := 1 + len(primoResponse.FRBRMemberHTTPRequests)
+	if int(numNYUADRequests) != expectedNumRequests {
+		t.Errorf("Expected %d requests with the client's URL and search params, got %d",
+			expectedNumRequests, numNYUADRequests)
+	}
+}
+
+func TestClientSearchStrategies(t *testing.T) {
+	t.Parallel()
+
+	// Two docs, only the first of which is the cited work.
+	searchResponse := `{
+  "docs": [
+    {
+      "delivery": {"link": [{"hyperlinkText": "Hamlet", "linkURL": "https://example.com/hamlet", "linkType": "` + linkToSrcType + `"}]},
+      "pnx": {
+        "addata": {"oclcid": ["(OCoLC)17772522"]},
+        "search": {"creatorcontrib": ["Shakespeare, William"], "issn": ["1234-5678"], "title": ["Hamlet"]}
+      }
+    },
+    {
+      "delivery": {"link": [{"hyperlinkText": "Hamlet's Mill", "linkURL": "https://example.com/hamlets-mill", "linkType": "` + linkToSrcType + `"}]},
+      "pnx": {"search": {"creatorcontrib": ["Santillana, Giorgio de"], "title": ["Hamlet's mill"]}}
+    }
+  ]
+}`
+
+	testCases := []struct {
+		name             string
+		queryString      string
+		expectedQuery    string
+		expectedStrategy string
+		expectedLinks    []string
+	}{
+		// Identifier searches are exact, so all docs are used.
+		{"Multiple ISBNs", "isbn=0198129106&eisbn=9780191732355", "isbn,exact,9780198129103,OR;isbn,exact,9780191732355",
+			SearchStrategyISBN, []string{"Hamlet", "Hamlet's Mill"}},
+		{"ISSN", "issn=12345678&title=Hamlet", "issn,exact,1234-5678", SearchStrategyISSN,
+			[]string{"Hamlet", "Hamlet's Mill"}},
+		{"OCLC number", "rft_id=info:oclcnum/17772522", "any,contains,17772522", SearchStrategyOCLCNum,
+			[]string{"Hamlet", "Hamlet's Mill"}},
+		{"Title and author", "title=Hamlet&aulast=Shakespeare", "title,contains,Hamlet,AND;creator,contains,Shakespeare",
+			SearchStrategyTitleAuthor, []string{"Hamlet"}},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			var requestTypes []string
+			fakePrimoServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if query := r.URL.Query().Get("q"); query != testCase.expectedQuery {
+						http.Error(w, "Unexpected query: "+query, http.StatusBadRequest)
+						return
+					}
+
+					fmt.Fprint(w, searchResponse)
+				}),
+			)
+			defer fakePrimoServer.Close()
+
+			client := NewClient(fakePrimoServer.URL, ClientOptions{
+				RequestObserver: func(requestType string, duration time.Duration, err error) {
+					requestTypes = append(requestTypes, requestType)
+				},
+			})
+
+			request, err := client.NewRequest(testCase.queryString)
+			if err != nil {
+				t.Fatalf("NewRequest returned an error: %s", err)
+			}
+			if request.Strategy != testCase.expectedStrategy {
+				t.Errorf("Expected request strategy \"%s\", got \"%s\"", testCase.expectedStrategy, request.Strategy)
+			}
+
+			primoResponse, err := client.Do(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Do returned an error: %s", err)
+			}
+
+			if primoResponse.Strategy != testCase.expectedStrategy {
+				t.Errorf("Expected response strategy \"%s\", got \"%s\"", testCase.expectedStrategy, primoResponse.Strategy)
+			}
+			links := []string{}
+			for _, link := range primoResponse.Links {
+				links = append(links, link.HyperlinkText)
+			}
+			if !reflect.DeepEqual(links, testCase.expectedLinks) {
+				t.Errorf("Expected links %v, got %v", testCase.expectedLinks, links)
+			}
+			expectedRequestTypes := []string{testCase.expectedStrategy + "_search"}
+			if !reflect.DeepEqual(requestTypes, expectedRequestTypes) {
+				t.Errorf("Expected request types %v, got %v", expectedRequestTypes, requestTypes)
+			}
+		})
+	}
+}
+
+func TestClientMaxConcurrentFRBRRequests(t *testing.T) {
+	t.Parallel()
+
+	const numFRBRGroups = 10
+	const maxConcurrentFRBRRequests = 3
+
+	searchResponse := APIResponse{}
+	for i := 0; i < numFRBRGroups; i++ {
+		doc := Doc{}
+		doc.PNX.Facets.FRBRType = []string{activeFRBRGroupType}
+		doc.PNX.Facets.FRBRGroupID = []string{fmt.Sprintf("%d", i)}
+		searchResponse.Docs = append(searchResponse.Docs, doc)
+	}
+	searchResponseJSON, err := json.Marshal(searchResponse)
+	if err != nil {
+		t.Fatalf("Could not marshal fake search response: %s", err)
+	}
+
+	var numInFlight, maxInFlight int32
+	fakePrimoServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get(FRBRMemberSearchQueryParamName) == "" {
+				w.Write(searchResponseJSON)
+				return
+			}
+
+			inFlight := atomic.AddInt32(&numInFlight, 1)
+			defer atomic.AddInt32(&numInFlight, -1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if inFlight <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, inFlight) {
+					break
+				}
+			}
+			// Give the other FRBR member requests a chance to pile up.
+			time.Sleep(20 * time.Millisecond)
+
+			fmt.Fprint(w, `{"docs":[]}`)
+		}),
+	)
+	defer fakePrimoServer.Close()
+
+	client := NewClient(fakePrimoServer.URL, ClientOptions{MaxConcurrentFRBRRequests: maxConcurrentFRBRRequests})
+
+	request, err := client.NewRequest("isbn=9781111111113")
+	if err != nil {
+		t.Fatalf("NewRequest returned an error: %s", err)
+	}
+
+	primoResponse, err := client.Do(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Do returned an error: %s", err)
+	}
+
+	if len(primoResponse.FRBRMemberHTTPRequests) != numFRBRGroups {
+		t.Errorf("Expected %d FRBR member requests, got %d", numFRBRGroups, len(primoResponse.FRBRMemberHTTPRequests))
+	}
+	if maxInFlight > maxConcurrentFRBRRequests {
+		t.Errorf("Expected at most %d concurrent FRBR member requests, got %d", maxConcurrentFRBRRequests, maxInFlight)
+	}
+}
+
+// Counts requests passed through to the default transport.
+type countingTransport struct {
+	numRequests int32
+}
+
+func (transport *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&transport.numRequests, 1)
+
+	return http.DefaultTransport.RoundTrip(request)
+}
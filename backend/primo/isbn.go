@@ -0,0 +1,128 @@
+package primo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidISBN is wrapped by the errors returned for ISBNs that are the wrong
+// length or have the wrong check digit.
+var ErrInvalidISBN = errors.New("invalid ISBN")
+
+// An optional "ISBN" label followed by the digits, hyphens, and spaces of the
+// ISBN proper.  Anything after that, e.g. "(pbk.)" or "v. 2", is a qualifier.
+var isbnRegexp = regexp.MustCompile(`^(?i:isbn(?:-1[03])?:?)?\s*([0-9][0-9\- ]*[0-9Xx]?)`)
+
+// Returns the ISBN-13 form of `isbn`, which can be an ISBN-10 or ISBN-13 with
+// or without hyphens, spaces, an "ISBN" label, and trailing qualifiers like
+// "(pbk.)".  Returns an error wrapping `ErrInvalidISBN` if `isbn` is the wrong
+// length or has the wrong check digit.
+func NormalizeISBN(isbn string) (string, error) {
+	digits := stripISBN(isbn)
+
+	switch len(digits) {
+	case 10:
+		if isbn10CheckDigit(digits[:9]) != digits[9] {
+			return "", fmt.Errorf("%w \"%s\": wrong check digit", ErrInvalidISBN, isbn)
+		}
+		return isbn10To13(digits), nil
+	case 13:
+		if strings.Contains(digits, "X") || isbn13CheckDigit(digits[:12]) != digits[12] {
+			return "", fmt.Errorf("%w \"%s\": wrong check digit", ErrInvalidISBN, isbn)
+		}
+		return digits, nil
+	default:
+		return "", fmt.Errorf("%w \"%s\": not 10 or 13 digits", ErrInvalidISBN, isbn)
+	}
+}
+
+// Returns the ISBN-10 form of `isbn`, which can be in any form accepted by
+// `NormalizeISBN`.  Returns "" if `isbn` is a valid ISBN-13 with the "979"
+// prefix, which has no ISBN-10 form.
+func ToISBN10(isbn string) (string, error) {
+	isbn13, err := NormalizeISBN(isbn)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(isbn13, "978") {
+		return "", nil
+	}
+
+	return isbn13[3:12] + string(isbn10CheckDigit(isbn13[3:12])), nil
+}
+
+// Returns the ISBN-13 forms of the valid ISBNs in `isbns`, which are from
+// `openurl.Referent.ISBNs`, without duplicates and in the same order, so that
+// the same OpenURL always gets the same Primo query.  Invalid ISBNs are returned
+// separately, as given, along with the error for the first.
+func normalizeISBNs(isbns []string) ([]string, []string, error) {
+	normalizedISBNs := []string{}
+	var invalidISBNs []string
+	seen := map[string]struct{}{}
+	var firstErr error
+	for _, isbn := range isbns {
+		normalizedISBN, err := NormalizeISBN(isbn)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			invalidISBNs = append(invalidISBNs, isbn)
+			continue
+		}
+		if _, ok := seen[normalizedISBN]; ok {
+			continue
+		}
+		seen[normalizedISBN] = struct{}{}
+		normalizedISBNs = append(normalizedISBNs, normalizedISBN)
+	}
+
+	return normalizedISBNs, invalidISBNs, firstErr
+}
+
+// `first9` must be 9 digits.
+func isbn10CheckDigit(first9 string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(first9[i]-'0')
+	}
+	checkDigit := (11 - sum%11) % 11
+	if checkDigit == 10 {
+		return 'X'
+	}
+
+	return byte('0' + checkDigit)
+}
+
+// `first12` must be 12 digits.
+func isbn13CheckDigit(first12 string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(first12[i]-'0')
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
+
+// `isbn10` must be a valid ISBN-10 without formatting.
+func isbn10To13(isbn10 string) string {
+	first12 := "978" + isbn10[:9]
+
+	return first12 + string(isbn13CheckDigit(first12))
+}
+
+// Returns the digits, and the "X" check digit of an ISBN-10, if any, of the
+// ISBN at the start of `isbn`.
+func stripISBN(isbn string) string {
+	match := isbnRegexp.FindStringSubmatch(strings.TrimSpace(isbn))
+	if match == nil {
+		return ""
+	}
+
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(match[1]))
+}
@@ -0,0 +1,133 @@
+package primo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	testCases := []struct {
+		name          string
+		isbn          string
+		expected      string
+		expectedError bool
+	}{
+		{"ISBN-13", "9780198129103", "9780198129103", false},
+		{"ISBN-10", "0198129106", "9780198129103", false},
+		{"Hyphens", "978-0-19-812910-3", "9780198129103", false},
+		{"Spaces", " 0 19 812910 6 ", "9780198129103", false},
+		{"Qualifier", "0198129106 (pbk.)", "9780198129103", false},
+		{"Hyphens and qualifier", "978-0-19-812910-3 (Oxford Shakespeare : pbk.)", "9780198129103", false},
+		{"Label", "ISBN 978-0-19-812910-3", "9780198129103", false},
+		{"ISBN-10 label", "isbn-10: 0198129106", "9780198129103", false},
+		{"X check digit", "080442957X", "9780804429573", false},
+		{"Lowercase x check digit", "080442957x", "9780804429573", false},
+		{"979 prefix", "979-10-90636-07-1", "9791090636071", false},
+		{"Wrong ISBN-10 check digit", "0198129107", "", true},
+		{"Wrong ISBN-13 check digit", "9780198129104", "", true},
+		{"X in ISBN-13", "978019812910X", "", true},
+		{"Too short", "719101220", "", true},
+		{"Too long", "97801981291030", "", true},
+		{"Not an ISBN", "pbk.", "", true},
+		{"Empty", "", "", true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, err := NormalizeISBN(testCase.isbn)
+			if testCase.expectedError {
+				if !errors.Is(err, ErrInvalidISBN) {
+					t.Errorf("Expected an ErrInvalidISBN error, got \"%s\" and error %v", actual, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeISBN returned error: %s", err)
+			}
+			if actual != testCase.expected {
+				t.Errorf("Expected \"%s\", got \"%s\"", testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestToISBN10(t *testing.T) {
+	testCases := []struct {
+		isbn     string
+		expected string
+	}{
+		{"9780198129103", "0198129106"},
+		{"978-0-8044-2957-3", "080442957X"},
+		{"0198129106", "0198129106"},
+		{"9791090636071", ""},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := ToISBN10(testCase.isbn)
+		if err != nil {
+			t.Errorf("ToISBN10(\"%s\") returned error: %s", testCase.isbn, err)
+		} else if actual != testCase.expected {
+			t.Errorf("ToISBN10(\"%s\"): expected \"%s\", got \"%s\"", testCase.isbn, testCase.expected, actual)
+		}
+	}
+
+	if _, err := ToISBN10("0198129107"); !errors.Is(err, ErrInvalidISBN) {
+		t.Errorf("Expected an ErrInvalidISBN error for an invalid ISBN, got %v", err)
+	}
+}
+
+func TestNormalizeISBNs(t *testing.T) {
+	testCases := []struct {
+		name            string
+		isbns           []string
+		expected        []string
+		expectedInvalid []string
+		expectedError   bool
+	}{
+		{"No ISBNs", nil, []string{}, nil, false},
+		{"One ISBN", []string{"0198129106"}, []string{"9780198129103"}, nil, false},
+		{
+			"Order kept",
+			[]string{"9780521532525", "9780198129103", "0191732354"},
+			[]string{"9780521532525", "9780198129103", "9780191732355"},
+			nil,
+			false,
+		},
+		{"Qualifier", []string{"9780198129103 (pbk.)"}, []string{"9780198129103"}, nil, false},
+		{"Duplicates", []string{"9780198129103", "0-19-812910-6"}, []string{"9780198129103"}, nil, false},
+		{
+			"Invalid skipped",
+			[]string{"9780198129104", "9780191732355"},
+			[]string{"9780191732355"},
+			[]string{"9780198129104"},
+			true,
+		},
+		{
+			"All invalid",
+			[]string{"9780198129104", "719101220"},
+			[]string{},
+			[]string{"9780198129104", "719101220"},
+			true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual, actualInvalid, err := normalizeISBNs(testCase.isbns)
+			if testCase.expectedError {
+				if !errors.Is(err, ErrInvalidISBN) {
+					t.Errorf("Expected an ErrInvalidISBN error, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("normalizeISBNs returned error: %s", err)
+			}
+			if !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("Expected %v, got %v", testCase.expected, actual)
+			}
+			if !reflect.DeepEqual(actualInvalid, testCase.expectedInvalid) {
+				t.Errorf("Expected invalid ISBNs %v, got %v", testCase.expectedInvalid, actualInvalid)
+			}
+		})
+	}
+}
@@ -1,45 +1,75 @@
 package primo
 
 import (
+	"ariadne/openurl"
+	"ariadne/tracing"
+	"ariadne/upstream"
+	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"strings"
+	"strconv"
+	"time"
 )
 
 const activeFRBRGroupType = "5"
 const FRBRMemberSearchQueryParamName = "multiFacets"
-const normalizedQueryParamNameISBN = "isbn"
-const normalizedQueryParamNameRFTISBN = "rft.isbn"
+
+var errNoSearchStrategy = errors.New("query string params do not contain an ISBN, ISSN, OCLC number, LCCN, or title")
+
+var spanNames = map[string]string{
+	RequestTypeISBNSearch:        "Primo ISBN search",
+	RequestTypeISSNSearch:        "Primo ISSN search",
+	RequestTypeOCLCNumSearch:     "Primo OCLC number search",
+	RequestTypeLCCNSearch:        "Primo LCCN search",
+	RequestTypeTitleAuthorSearch: "Primo title/author search",
+	RequestTypeFRBRMemberSearch:  "Primo FRBR member search",
+}
 
 type PrimoRequest struct {
-	DumpedISBNSearchHTTPRequest string
-	ISBNSearchHTTPRequest       http.Request
-	QueryStringValues           url.Values
+	ContextObject           *openurl.ContextObject
+	DumpedSearchHTTPRequest string
+	// The valid ISBNs in the OpenURL, in ISBN-13 form, which are all searched for
+	// if the search strategy is SearchStrategyISBN
+	ISBNs []string
+	// The ISBNs in the OpenURL which are the wrong length or have the wrong check
+	// digit, as given.  They are never searched for, whatever the strategy.  Set
+	// even if `newPrimoRequest` returns an error.
+	InvalidISBNs      []string
+	SearchHTTPRequest http.Request
+	// The search strategy: one of the SearchStrategy* constants
+	Strategy string
+	citation citation
+	// Used for the FRBR member requests
+	maxConcurrentFRBRRequests int
+	primoURL                  string
+	requestObserver           RequestObserver
+	searchParams              SearchParams
+	strategy                  *searchStrategy
 }
 
-func (primoRequest PrimoRequest) do() (*PrimoResponse, error) {
-	primoResponse := &PrimoResponse{}
+func (primoRequest PrimoRequest) do(ctx context.Context, client *http.Client) (*PrimoResponse, error) {
+	primoResponse := &PrimoResponse{Strategy: primoRequest.Strategy}
 
-	client := http.Client{}
-	httpResponse, err := client.Do(&primoRequest.ISBNSearchHTTPRequest)
+	httpResponse, err := primoRequest.doHTTPRequest(ctx, client, &primoRequest.SearchHTTPRequest,
+		primoRequest.strategy.requestType, "Could not do request to Primo server: %w",
+		tracing.Attribute{Key: "primo.search_strategy", Value: primoRequest.Strategy})
 	if err != nil {
-		return &PrimoResponse{}, fmt.Errorf("Could not do request to Primo server: %v", err)
+		return primoResponse, err
 	}
 	defer httpResponse.Body.Close()
 
-	isbnSearchResponse, err := primoResponse.addHTTPResponseData(httpResponse)
+	searchResponse, err := primoResponse.addHTTPResponseData(httpResponse)
 	if err != nil {
-		return primoResponse, fmt.Errorf("Error adding to Primo response: %v", err)
+		return primoResponse, fmt.Errorf("Error adding to Primo response: %w", err)
 	}
 
-	isbn := getISBN(primoRequest.QueryStringValues)
-
 	// Getting the links is a slightly complicated process which might require
 	// additional HTTP requests to the Primo server.
-	err = primoResponse.getLinks(isbn, isbnSearchResponse)
+	err = primoResponse.getLinks(ctx, client, primoRequest, searchResponse)
 	if err != nil {
 		return primoResponse, err
 	}
@@ -47,55 +77,99 @@ func (primoRequest PrimoRequest) do() (*PrimoResponse, error) {
 	return primoResponse, nil
 }
 
-func NewPrimoRequest(queryString string) (*PrimoRequest, error) {
-	primoRequest := &PrimoRequest{}
+// Makes an HTTP request to Primo in its own trace span, which is propagated to
+// Primo, and reports it to the request observer.  Returns an error if the request
+// failed or returned a non-2xx status, otherwise the caller must close the
+// response body.  `errorFormat` wraps request errors.
+func (primoRequest PrimoRequest) doHTTPRequest(ctx context.Context, client *http.Client, httpRequest *http.Request,
+	requestType string, errorFormat string, attributes ...tracing.Attribute) (*http.Response, error) {
+	ctx, span := tracing.StartSpan(ctx, spanNames[requestType], tracing.SpanKindClient)
+	defer span.End()
+	span.SetAttribute("server.address", httpRequest.URL.Host)
+	for _, attribute := range attributes {
+		span.SetAttribute(attribute.Key, attribute.Value)
+	}
+
+	// Cloned so that the traceparent header isn't added to the request that's
+	// kept for logging.
+	httpRequest = httpRequest.Clone(ctx)
+	tracing.Inject(ctx, httpRequest.Header)
 
-	queryStringValues, err := url.ParseQuery(queryString)
+	start := time.Now()
+	httpResponse, err := client.Do(httpRequest)
+	if err != nil {
+		err = upstream.NewRequestError(err, errorFormat)
+	} else {
+		span.SetAttribute("http.response.status_code", httpResponse.StatusCode)
+		if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+			httpResponse.Body.Close()
+			err = upstream.NewStatusError("Primo", httpResponse.StatusCode, httpResponse.Status)
+		}
+	}
+	primoRequest.observe(requestType, start, err)
+	span.RecordError(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return httpResponse, nil
+}
+
+// Reports the request started at `start` to the client's request observer, if
+// it has one.
+func (primoRequest PrimoRequest) observe(requestType string, start time.Time, err error) {
+	if primoRequest.requestObserver != nil {
+		primoRequest.requestObserver(requestType, time.Since(start), err)
+	}
+}
+
+func newPrimoRequest(primoURL string, searchParams SearchParams, queryString string) (*PrimoRequest, error) {
+	primoRequest := &PrimoRequest{primoURL: primoURL, searchParams: searchParams}
+
+	contextObject, err := openurl.Parse(queryString)
 	if err != nil {
 		return primoRequest, err
 	}
 
-	primoRequest.QueryStringValues = queryStringValues
+	primoRequest.ContextObject = contextObject
+
+	// Mistyped ISBNs are dropped, so if they all are, the next best strategy is
+	// used.  The error is only returned if there is nothing else to search for,
+	// but the ISBNs are always in `InvalidISBNs`.
+	var invalidISBNErr error
+	primoRequest.citation, invalidISBNErr = newCitation(contextObject)
+	primoRequest.ISBNs = primoRequest.citation.isbns
+	primoRequest.InvalidISBNs = primoRequest.citation.invalidISBNs
+
+	primoRequest.strategy = chooseSearchStrategy(primoRequest.citation)
+	if primoRequest.strategy == nil {
+		if invalidISBNErr != nil {
+			return primoRequest, fmt.Errorf("Could not create new Primo request: %w", invalidISBNErr)
+		}
+		return primoRequest, fmt.Errorf("Could not create new Primo request: %v", errNoSearchStrategy)
+	}
+	primoRequest.Strategy = primoRequest.strategy.name
 
-	httpRequest, err := newPrimoISBNSearchHTTPRequest(queryStringValues)
+	httpRequest, err := primoRequest.newSearchHTTPRequest()
 	if err != nil {
 		return primoRequest, fmt.Errorf("Could not create new Primo request: %v", err)
 	}
 	// NOTE: This appears to drain httpRequest.Body, so when getting the dumped
-	// HTTP request later, make sure to get it from primoRequest.ISBNSearchHTTPRequest
+	// HTTP request later, make sure to get it from primoRequest.SearchHTTPRequest
 	// and not httpRequest.  If httpRequest is used later accidentally, probably
 	// no harm done since currently these requests don't have a body.
-	primoRequest.ISBNSearchHTTPRequest = (*httpRequest)
+	primoRequest.SearchHTTPRequest = (*httpRequest)
 
-	dumpedISBNSearchHTTPRequest, err := httputil.DumpRequest(&primoRequest.ISBNSearchHTTPRequest, true)
+	dumpedSearchHTTPRequest, err := httputil.DumpRequest(&primoRequest.SearchHTTPRequest, true)
 	if err != nil {
-		// TODO: Log this.  PrimoRequest.DumpedISBNSearchHTTPRequest field is for
+		// TODO: Log this.  PrimoRequest.DumpedSearchHTTPRequest field is for
 		// debugging only - it should not block the user request.
 	}
-	primoRequest.DumpedISBNSearchHTTPRequest = string(dumpedISBNSearchHTTPRequest)
+	primoRequest.DumpedSearchHTTPRequest = string(dumpedSearchHTTPRequest)
 
 	return primoRequest, nil
 }
 
-func getISBN(queryStringValues url.Values) string {
-	isbn := ""
-	for queryParamName, queryParamValue := range queryStringValues {
-		normalizedQueryParamName := strings.ToLower(queryParamName)
-		// Prefer rft.* param name over non-rft-prefixed param name.
-		if normalizedQueryParamName == normalizedQueryParamNameRFTISBN &&
-			queryParamValue[0] != "" {
-			isbn = queryParamValue[0]
-			break
-		} else if normalizedQueryParamName == normalizedQueryParamNameISBN &&
-			queryParamValue[0] != "" {
-			isbn = queryParamValue[0]
-			break
-		}
-	}
-
-	return isbn
-}
-
 func isActiveFRBRGroupType(doc Doc) bool {
 	result := false
 
@@ -108,28 +182,30 @@ func isActiveFRBRGroupType(doc Doc) bool {
 
 	return result
 }
-func newPrimoHTTPRequest(isbn string, frbrGroupID *string) (*http.Request, error) {
-	if isbn == "" {
-		return nil, fmt.Errorf("query string params do not contain required ISBN param")
+
+// `query` is the Primo `q` param.
+func (primoRequest PrimoRequest) newHTTPRequest(query string, frbrGroupID *string) (*http.Request, error) {
+	searchParams := primoRequest.searchParams
+
+	if query == "" {
+		return nil, errNoSearchStrategy
 	}
 
 	primoRequestParams := url.Values{
 		// Same for every request
-		"inst":   []string{"NYU"},
-		"limit":  []string{"50"},
+		"inst":   []string{searchParams.Institution},
+		"limit":  []string{strconv.Itoa(searchParams.Limit)},
 		"offset": []string{"0"},
-		"scope":  []string{"all"},
-		"vid":    []string{"NYU"},
-		// ISBN query
-		"q": []string{fmt.Sprintf(
-			"isbn,exact,%s", isbn)},
+		"scope":  []string{searchParams.Scope},
+		"vid":    []string{searchParams.View},
+		"q":      []string{query},
 	}
 
 	if frbrGroupID != nil {
 		primoRequestParams.Add(FRBRMemberSearchQueryParamName, fmt.Sprintf("facet_frbrgroupid,include,%s", *frbrGroupID))
 	}
 
-	queryURL := fmt.Sprintf("%s?%s", primoURL, primoRequestParams.Encode())
+	queryURL := fmt.Sprintf("%s?%s", primoRequest.primoURL, primoRequestParams.Encode())
 
 	request, err := http.NewRequest("GET", queryURL, nil)
 	if err != nil {
@@ -139,8 +215,10 @@ func newPrimoHTTPRequest(isbn string, frbrGroupID *string) (*http.Request, error
 	return request, nil
 }
 
-func newPrimoISBNSearchHTTPRequest(queryStringValues url.Values) (*http.Request, error) {
-	isbn := getISBN(queryStringValues)
+func (primoRequest PrimoRequest) newSearchHTTPRequest() (*http.Request, error) {
+	return primoRequest.newHTTPRequest(primoRequest.query(), nil)
+}
 
-	return newPrimoHTTPRequest(isbn, nil)
+func (primoRequest PrimoRequest) query() string {
+	return primoRequest.strategy.query(primoRequest.citation)
 }
@@ -5,139 +5,75 @@ import (
 	"errors"
 	"fmt"
 	"net/http/httputil"
-	"net/url"
+	"reflect"
 	"testing"
 )
 
-const testISBN = "1111111111111"
+const testISBN = "9781111111113"
 
 // Can't be const because need to generate a pointer to it.
 var testFRBRGroupID = "2222222222"
 
 var sharedTestCases = []struct {
-	name                                string
-	expectedDumpedISBNSearchHTTPRequest string
-	expectedError                       error
-	expectedQueryStringValues           url.Values
-	queryString                         string
+	name                            string
+	expectedDumpedSearchHTTPRequest string
+	expectedError                   error
+	expectedISBN                    string
+	queryString                     string
 }{
 	{
 		name: "Query string with `isbn` only",
-		expectedDumpedISBNSearchHTTPRequest: `GET /primo_library/libweb/webservices/rest/primo-explore/v1/pnxs?inst=NYU&limit=50&offset=0&q=isbn%2Cexact%2C` + testISBN + `&scope=all&vid=NYU HTTP/1.1
+		expectedDumpedSearchHTTPRequest: `GET /primo_library/libweb/webservices/rest/primo-explore/v1/pnxs?inst=NYU&limit=50&offset=0&q=isbn%2Cexact%2C` + testISBN + `&scope=all&vid=NYU HTTP/1.1
 Host: bobcat.library.nyu.edu`,
 		expectedError: nil,
-		expectedQueryStringValues: url.Values{
-			"isbn": {testISBN},
-		},
-		queryString: "isbn=" + testISBN,
+		expectedISBN:  testISBN,
+		queryString:   "isbn=" + testISBN,
 	},
 	{
 		name: "3 generic query string params and `isbn`",
-		expectedDumpedISBNSearchHTTPRequest: `GET /primo_library/libweb/webservices/rest/primo-explore/v1/pnxs?inst=NYU&limit=50&offset=0&q=isbn%2Cexact%2C` + testISBN + `&scope=all&vid=NYU HTTP/1.1
+		expectedDumpedSearchHTTPRequest: `GET /primo_library/libweb/webservices/rest/primo-explore/v1/pnxs?inst=NYU&limit=50&offset=0&q=isbn%2Cexact%2C` + testISBN + `&scope=all&vid=NYU HTTP/1.1
 Host: bobcat.library.nyu.edu`,
 		expectedError: nil,
-		expectedQueryStringValues: url.Values{
-			"param1": {"1"},
-			"param2": {"2"},
-			"param3": {"3"},
-			"isbn":   {testISBN},
-		},
-		queryString: "param1=1&param2=2&param3=3&isbn=" + testISBN,
+		expectedISBN:  testISBN,
+		queryString:   "param1=1&param2=2&param3=3&isbn=" + testISBN,
 	},
 	{
-		name:                                "Query string without `isbn` param",
-		expectedDumpedISBNSearchHTTPRequest: "",
-		expectedError:                       errors.New("Could not create new Primo request: query string params do not contain required ISBN param"),
-		expectedQueryStringValues: url.Values{
-			"param1": {"1"},
-			"param2": {"2"},
-			"param3": {"3"},
-		},
-		queryString: "param1=1&param2=2&param3=3",
+		name: "Query string with a hyphenated ISBN-10 and qualifier",
+		expectedDumpedSearchHTTPRequest: `GET /primo_library/libweb/webservices/rest/primo-explore/v1/pnxs?inst=NYU&limit=50&offset=0&q=isbn%2Cexact%2C9780198129103&scope=all&vid=NYU HTTP/1.1
+Host: bobcat.library.nyu.edu`,
+		expectedError: nil,
+		expectedISBN:  "0-19-812910-6 (pbk.)",
+		queryString:   "isbn=0-19-812910-6%20(pbk.)",
 	},
 	{
-		name:                                "Empty query string",
-		expectedDumpedISBNSearchHTTPRequest: "",
-		expectedError:                       errors.New("Could not create new Primo request: query string params do not contain required ISBN param"),
-		expectedQueryStringValues:           url.Values{},
-		queryString:                         "",
+		name: "Query string with an invalid ISBN and a title",
+		expectedDumpedSearchHTTPRequest: `GET /primo_library/libweb/webservices/rest/primo-explore/v1/pnxs?inst=NYU&limit=50&offset=0&q=title%2Ccontains%2CHamlet&scope=all&vid=NYU HTTP/1.1
+Host: bobcat.library.nyu.edu`,
+		expectedError: nil,
+		expectedISBN:  "9781111111111",
+		queryString:   "isbn=9781111111111&title=Hamlet",
+	},
+	{
+		name:                            "Query string with only an invalid ISBN",
+		expectedDumpedSearchHTTPRequest: "",
+		expectedError:                   errors.New("Could not create new Primo request: invalid ISBN \"9781111111111\": wrong check digit"),
+		expectedISBN:                    "9781111111111",
+		queryString:                     "isbn=9781111111111",
+	},
+	{
+		name:                            "Query string without anything to search for",
+		expectedDumpedSearchHTTPRequest: "",
+		expectedError:                   errors.New("Could not create new Primo request: query string params do not contain an ISBN, ISSN, OCLC number, LCCN, or title"),
+		expectedISBN:                    "",
+		queryString:                     "param1=1&param2=2&param3=3",
+	},
+	{
+		name:                            "Empty query string",
+		expectedDumpedSearchHTTPRequest: "",
+		expectedError:                   errors.New("Could not create new Primo request: query string params do not contain an ISBN, ISSN, OCLC number, LCCN, or title"),
+		expectedISBN:                    "",
+		queryString:                     "",
 	},
-}
-
-func TestGetISBN(t *testing.T) {
-	genericParams := url.Values{
-		"param1": {"1"},
-		"param2": {"2"},
-		"param3": {"3"},
-	}
-	testCases := []struct {
-		queryStringValues url.Values
-		expectedISBN      string
-	}{
-		{
-			queryStringValues: testutils.MergeURLValues(
-				genericParams,
-				url.Values{"isbn": {testISBN}},
-			),
-			expectedISBN: testISBN,
-		},
-		{
-			queryStringValues: testutils.MergeURLValues(
-				genericParams,
-				url.Values{"rft.isbn": {testISBN}},
-			),
-			expectedISBN: testISBN,
-		},
-		{
-			queryStringValues: testutils.MergeURLValues(
-				genericParams,
-				url.Values{"ISBN": {testISBN}},
-			),
-			expectedISBN: testISBN,
-		},
-		{
-			queryStringValues: testutils.MergeURLValues(
-				genericParams,
-				url.Values{"RFT.ISBN": {testISBN}},
-			),
-			expectedISBN: testISBN,
-		},
-		{
-			queryStringValues: testutils.MergeURLValues(
-				genericParams,
-				url.Values{"iSbN": {testISBN}},
-			),
-			expectedISBN: testISBN,
-		},
-		{
-			queryStringValues: testutils.MergeURLValues(
-				genericParams,
-				url.Values{"rFt.iSbN": {testISBN}},
-			),
-			expectedISBN: testISBN,
-		},
-		{
-			queryStringValues: genericParams,
-			expectedISBN:      "",
-		},
-		{
-			queryStringValues: url.Values{},
-			expectedISBN:      "",
-		},
-	}
-
-	for _, testCase := range testCases {
-		isbn := getISBN(testCase.queryStringValues)
-		if isbn != testCase.expectedISBN {
-			t.Errorf(
-				"getISBN returned incorrect ISBN value for '%v': "+
-					"expected '%s', got '%s'",
-				testCase.queryStringValues,
-				testCase.expectedISBN,
-				isbn,
-			)
-		}
-	}
 }
 
 func TestIsActiveFRBRGroupType(t *testing.T) {
@@ -187,17 +123,17 @@ func TestIsActiveFRBRGroupType(t *testing.T) {
 	}
 }
 
-func TestNewPrimoRequest(t *testing.T) {
+func TestNewRequest(t *testing.T) {
 	for _, testCase := range sharedTestCases {
 		testName := fmt.Sprintf("%s", testCase.queryString)
 		t.Run(testName, func(t *testing.T) {
-			primoRequest, err := NewPrimoRequest(testCase.queryString)
-			if testCase.expectedDumpedISBNSearchHTTPRequest != "" {
-				expected := testutils.NormalizeDumpedHTTPRequest(testCase.expectedDumpedISBNSearchHTTPRequest)
-				got := testutils.NormalizeDumpedHTTPRequest(primoRequest.DumpedISBNSearchHTTPRequest)
+			primoRequest, err := NewClient(DefaultPrimoURL, ClientOptions{}).NewRequest(testCase.queryString)
+			if testCase.expectedDumpedSearchHTTPRequest != "" {
+				expected := testutils.NormalizeDumpedHTTPRequest(testCase.expectedDumpedSearchHTTPRequest)
+				got := testutils.NormalizeDumpedHTTPRequest(primoRequest.DumpedSearchHTTPRequest)
 				if got != expected {
 					t.Errorf(
-						"NewPrimoRequest returned an PrimoRequest with incorrect DumpedISBNSearchHTTPRequest string for '%s': "+
+						"NewRequest returned an PrimoRequest with incorrect DumpedSearchHTTPRequest string for '%s': "+
 							"expected '%s', got '%s'",
 						testCase.name,
 						expected,
@@ -207,27 +143,82 @@ func TestNewPrimoRequest(t *testing.T) {
 			}
 			if testCase.expectedError != nil {
 				if err == nil {
-					t.Errorf("NewPrimoRequest returned no error, expecting '%v'", testCase.expectedError)
+					t.Errorf("NewRequest returned no error, expecting '%v'", testCase.expectedError)
 				} else if err.Error() != testCase.expectedError.Error() {
-					t.Errorf("NewPrimoRequest returned error '%v', expecting '%v'", err, testCase.expectedError)
+					t.Errorf("NewRequest returned error '%v', expecting '%v'", err, testCase.expectedError)
 				}
 			}
 			if err != nil && testCase.expectedError == nil {
-				t.Errorf("NewPrimoRequest returned error '%v', expecting no errors", err)
+				t.Errorf("NewRequest returned error '%v', expecting no errors", err)
 			}
 
-			expectedStringifiedQueryStringValues := testutils.StringifyURLValues(testCase.expectedQueryStringValues)
-			if expectedStringifiedQueryStringValues != "" {
-				got := testutils.StringifyURLValues(primoRequest.QueryStringValues)
-				if got != expectedStringifiedQueryStringValues {
-					t.Errorf(
-						"NewPrimoRequest returned an PrimoRequest with incorrect stringified QueryStringValues for '%s': "+
-							"expected '%s', got '%s'",
-						testCase.name,
-						expectedStringifiedQueryStringValues,
-						got,
-					)
+			if primoRequest.ContextObject != nil &&
+				primoRequest.ContextObject.Referent.ISBN != testCase.expectedISBN {
+				t.Errorf(
+					"NewRequest returned an PrimoRequest with incorrect ContextObject ISBN for '%s': "+
+						"expected '%s', got '%s'",
+					testCase.name,
+					testCase.expectedISBN,
+					primoRequest.ContextObject.Referent.ISBN,
+				)
+			}
+		})
+	}
+}
+
+func TestNewRequestInvalidISBN(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		queryString          string
+		expectedStrategy     string
+		expectedISBNs        []string
+		expectedInvalidISBNs []string
+	}{
+		{"Only an invalid ISBN", "isbn=0-19-812910-7", "", []string{}, []string{"0-19-812910-7"}},
+		{
+			"Invalid ISBN and ISSN",
+			"isbn=0-19-812910-7&issn=0028-792X",
+			SearchStrategyISSN,
+			[]string{},
+			[]string{"0-19-812910-7"},
+		},
+		{
+			"Invalid ISBN, title, and author",
+			"rft.isbn=9780198129104&rft.btitle=The+Merchant+of+Venice&rft.aulast=Shakespeare",
+			SearchStrategyTitleAuthor,
+			[]string{},
+			[]string{"9780198129104"},
+		},
+		{
+			"Invalid and valid ISBNs",
+			"isbn=0-19-812910-7&isbn=9780191732355",
+			SearchStrategyISBN,
+			[]string{"9780191732355"},
+			[]string{"0-19-812910-7"},
+		},
+	}
+
+	client := NewClient(DefaultPrimoURL, ClientOptions{})
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			primoRequest, err := client.NewRequest(testCase.queryString)
+			if !reflect.DeepEqual(primoRequest.ISBNs, testCase.expectedISBNs) {
+				t.Errorf("Expected ISBNs %v, got %v", testCase.expectedISBNs, primoRequest.ISBNs)
+			}
+			if !reflect.DeepEqual(primoRequest.InvalidISBNs, testCase.expectedInvalidISBNs) {
+				t.Errorf("Expected invalid ISBNs %v, got %v", testCase.expectedInvalidISBNs, primoRequest.InvalidISBNs)
+			}
+			if testCase.expectedStrategy == "" {
+				if !errors.Is(err, ErrInvalidISBN) {
+					t.Errorf("Expected NewRequest to return an ErrInvalidISBN error, got %v", err)
 				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRequest returned error: %s", err)
+			}
+			if primoRequest.Strategy != testCase.expectedStrategy {
+				t.Errorf("Expected search strategy '%s', got '%s'", testCase.expectedStrategy, primoRequest.Strategy)
 			}
 		})
 	}
@@ -235,13 +226,13 @@ func TestNewPrimoRequest(t *testing.T) {
 
 func TestNewPrimoHTTPRequest(t *testing.T) {
 	testCases := []struct {
-		isbn                                string
+		query                               string
 		frbrGroupID                         *string
 		expectedDumpedFRBRMemberHTTPRequest string
 		expectedError                       error
 	}{
 		{
-			isbn:        testISBN,
+			query:       "isbn,exact," + testISBN,
 			frbrGroupID: &testFRBRGroupID,
 			expectedDumpedFRBRMemberHTTPRequest: `GET /primo_library/libweb/webservices/rest/primo-explore/v1/pnxs?inst=NYU&limit=50&multiFacets=facet_frbrgroupid%2Cinclude%2C` +
 				testFRBRGroupID +
@@ -252,13 +243,13 @@ Host: bobcat.library.nyu.edu`,
 			expectedError: nil,
 		},
 		{
-			isbn:                                "",
+			query:                               "",
 			frbrGroupID:                         &testFRBRGroupID,
 			expectedDumpedFRBRMemberHTTPRequest: "",
-			expectedError:                       errors.New("query string params do not contain required ISBN param"),
+			expectedError:                       errors.New("query string params do not contain an ISBN, ISSN, OCLC number, LCCN, or title"),
 		},
 		{
-			isbn:        testISBN,
+			query:       "isbn,exact," + testISBN,
 			frbrGroupID: nil,
 			expectedDumpedFRBRMemberHTTPRequest: `GET /primo_library/libweb/webservices/rest/primo-explore/v1/pnxs?inst=NYU&limit=50&offset=0&q=isbn%2Cexact%2C` + testISBN + `&scope=all&vid=NYU HTTP/1.1
 		Host: bobcat.library.nyu.edu`,
@@ -266,9 +257,10 @@ Host: bobcat.library.nyu.edu`,
 		},
 	}
 	for _, testCase := range testCases {
-		testCaseName := fmt.Sprintf("ISBN: %s; FRBR Group ID: %v", testCase.isbn, testCase.frbrGroupID)
+		testCaseName := fmt.Sprintf("Query: %s; FRBR Group ID: %v", testCase.query, testCase.frbrGroupID)
 		t.Run(testCaseName, func(t *testing.T) {
-			frbrMemberRequest, err := newPrimoHTTPRequest(testCase.isbn, testCase.frbrGroupID)
+			primoRequest := PrimoRequest{primoURL: DefaultPrimoURL, searchParams: DefaultSearchParams}
+			frbrMemberRequest, err := primoRequest.newHTTPRequest(testCase.query, testCase.frbrGroupID)
 			if testCase.expectedDumpedFRBRMemberHTTPRequest != "" {
 				gotDumpedFRBRMemberRequest, _ := httputil.DumpRequest(frbrMemberRequest, true)
 				expected := testutils.NormalizeDumpedHTTPRequest(testCase.expectedDumpedFRBRMemberHTTPRequest)
@@ -296,17 +288,17 @@ Host: bobcat.library.nyu.edu`,
 	}
 }
 
-func TestNewPrimoISBNSearchHTTPRequest(t *testing.T) {
+func TestNewPrimoSearchHTTPRequest(t *testing.T) {
 	for _, testCase := range sharedTestCases {
 		testName := fmt.Sprintf("%s", testCase.queryString)
 		t.Run(testName, func(t *testing.T) {
-			primoRequest, err := NewPrimoRequest(testCase.queryString)
-			if testCase.expectedDumpedISBNSearchHTTPRequest != "" {
-				expected := testutils.NormalizeDumpedHTTPRequest(testCase.expectedDumpedISBNSearchHTTPRequest)
-				got := testutils.NormalizeDumpedHTTPRequest(primoRequest.DumpedISBNSearchHTTPRequest)
+			primoRequest, err := NewClient(DefaultPrimoURL, ClientOptions{}).NewRequest(testCase.queryString)
+			if testCase.expectedDumpedSearchHTTPRequest != "" {
+				expected := testutils.NormalizeDumpedHTTPRequest(testCase.expectedDumpedSearchHTTPRequest)
+				got := testutils.NormalizeDumpedHTTPRequest(primoRequest.DumpedSearchHTTPRequest)
 				if got != expected {
 					t.Errorf(
-						"NewPrimoRequest returned an PrimoRequest with incorrect DumpedISBNSearchHTTPRequest string for '%s': "+
+						"NewRequest returned an PrimoRequest with incorrect DumpedSearchHTTPRequest string for '%s': "+
 							"expected '%s', got '%s'",
 						testCase.name,
 						expected,
@@ -316,13 +308,13 @@ func TestNewPrimoISBNSearchHTTPRequest(t *testing.T) {
 			}
 			if testCase.expectedError != nil {
 				if err == nil {
-					t.Errorf("NewPrimoRequest returned no error, expecting '%v'", testCase.expectedError)
+					t.Errorf("NewRequest returned no error, expecting '%v'", testCase.expectedError)
 				} else if err.Error() != testCase.expectedError.Error() {
-					t.Errorf("NewPrimoRequest returned error '%v', expecting '%v'", err, testCase.expectedError)
+					t.Errorf("NewRequest returned error '%v', expecting '%v'", err, testCase.expectedError)
 				}
 			}
 			if err != nil && testCase.expectedError == nil {
-				t.Errorf("NewPrimoRequest returned error '%v', expecting no errors", err)
+				t.Errorf("NewRequest returned error '%v', expecting no errors", err)
 			}
 		})
 	}
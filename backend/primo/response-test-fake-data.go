@@ -46,10 +46,10 @@ var fakeDumpedPrimoISBNSearchHTTPResponse = `HTTP/0.0 000 status code 0
         },
         "search": {
           "isbn": [
-            "1111111111111",
-            "2222222222222",
-            "3333333333333",
-            "4444444444444"
+            "9781111111113",
+            "9782222222224",
+            "9783333333335",
+            "9784444444446"
           ]
         }
       }
@@ -137,10 +137,10 @@ var fakePrimoISBNSearchAPIResponse = APIResponse{
 				},
 				Search: Search{
 					ISBN: []string{
-						"1111111111111",
-						"2222222222222",
-						"3333333333333",
-						"4444444444444",
+						"9781111111113",
+						"9782222222224",
+						"9783333333335",
+						"9784444444446",
 					},
 				},
 			},
@@ -186,10 +186,10 @@ var fakePrimoISBNSearchHTTPResponseBody = `{
         },
         "search": {
           "isbn": [
-            "1111111111111",
-            "2222222222222",
-            "3333333333333",
-            "4444444444444"
+            "9781111111113",
+            "9782222222224",
+            "9783333333335",
+            "9784444444446"
           ]
         }
       }
@@ -1,14 +1,23 @@
 package primo
 
 import (
+	"ariadne/tracing"
+	"ariadne/upstream"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httputil"
 	"sort"
+	"sync"
 )
 
+type Addata struct {
+	LCCN   []string `json:"lccn"`
+	OCLCID []string `json:"oclcid"`
+}
+
 type Delivery struct {
 	Link []Link `json:"link"`
 }
@@ -30,6 +39,7 @@ type Link struct {
 }
 
 type PNX struct {
+	Addata Addata `json:"addata"`
 	Facets Facets `json:"facets"`
 	Search Search `json:"search"`
 }
@@ -41,10 +51,15 @@ type PrimoResponse struct {
 	HTTPResponses                []http.Response
 	APIResponses                 []APIResponse
 	Links                        []Link
+	// The search strategy of the request: one of the SearchStrategy* constants
+	Strategy string
 }
 
 type Search struct {
-	ISBN []string `json:"isbn"`
+	CreatorContrib []string `json:"creatorcontrib"`
+	ISBN           []string `json:"isbn"`
+	ISSN           []string `json:"issn"`
+	Title          []string `json:"title"`
 }
 
 type APIResponse struct {
@@ -65,19 +80,19 @@ func (primoResponse *PrimoResponse) addHTTPResponseData(httpResponse *http.Respo
 
 	dumpedHTTPResponse, err := httputil.DumpResponse(httpResponse, true)
 	if err != nil {
-		return APIResponse{}, fmt.Errorf("Could not dump HTTP response")
+		return APIResponse{}, upstream.NewRequestError(err, "Could not dump HTTP response: %w")
 	}
 
 	primoResponse.DumpedHTTPResponses = append(primoResponse.DumpedHTTPResponses, string(dumpedHTTPResponse))
 
 	body, err := io.ReadAll(httpResponse.Body)
 	if err != nil {
-		return APIResponse{}, fmt.Errorf("Could not read response from Primo server: %v", err)
+		return APIResponse{}, upstream.NewRequestError(err, "Could not read response from Primo server: %w")
 	}
 
 	var apiResponse APIResponse
 	if err = json.Unmarshal(body, &apiResponse); err != nil {
-		return apiResponse, err
+		return apiResponse, &upstream.Error{Kind: upstream.ErrorKindParse, Err: err}
 	}
 
 	primoResponse.APIResponses =
@@ -115,60 +130,105 @@ func (primoResponse *PrimoResponse) dedupeAndSortLinks() {
 	primoResponse.Links = links
 }
 
-func (primoResponse *PrimoResponse) getDocsForFRBRGroup(isbn, frbrGroupID string) ([]Doc, error) {
+func (primoResponse *PrimoResponse) getDocsForFRBRGroup(ctx context.Context, client *http.Client, primoRequest PrimoRequest, frbrGroupID string) ([]Doc, error) {
 	docs := []Doc{}
 
-	httpRequest, err := newPrimoHTTPRequest(isbn, &frbrGroupID)
+	httpRequest, err := primoRequest.newHTTPRequest(primoRequest.query(), &frbrGroupID)
 	if err != nil {
 		return docs, fmt.Errorf("Could not create new FRBR group Primo request: %v", err)
 	}
-
 	// NOTE: This appears to drain httpRequest.Body, but currently these requests
 	// don't have a body, so we should be okay.
 	primoResponse.FRBRMemberHTTPRequests = append(primoResponse.FRBRMemberHTTPRequests, (*httpRequest))
 
 	dumpedHTTPRequest, err := httputil.DumpRequest(httpRequest, true)
 	if err != nil {
-		// TODO: Log this.  PrimoRequest.DumpedISBNSearchHTTPRequest field is for
+		// TODO: Log this.  PrimoRequest.DumpedSearchHTTPRequest field is for
 		// debugging only - it should not block the user request.
 	}
 	primoResponse.DumpedFRBRMemberHTTPRequests =
 		append(primoResponse.DumpedFRBRMemberHTTPRequests, string(dumpedHTTPRequest))
 
-	client := http.Client{}
-	httpResponse, err := client.Do(httpRequest)
+	httpResponse, err := primoRequest.doHTTPRequest(ctx, client, httpRequest, RequestTypeFRBRMemberSearch,
+		"Could not do FRBR group request to Primo server: %w", tracing.Attribute{Key: "primo.frbr_group_id", Value: frbrGroupID})
 	if err != nil {
-		return docs, fmt.Errorf("Could not do FRBR group request to Primo server: %v", err)
+		return docs, err
 	}
 	defer httpResponse.Body.Close()
 
 	apiResponse, err := primoResponse.addHTTPResponseData(httpResponse)
 	if err != nil {
-		return docs, fmt.Errorf("Error adding to Primo response: %v", err)
+		return docs, fmt.Errorf("Error adding to Primo response: %w", err)
 	}
 
 	return apiResponse.Docs, nil
 }
 
-func (primoResponse *PrimoResponse) getLinks(isbn string, isbnSearchResponse APIResponse) error {
-	for _, doc := range isbnSearchResponse.Docs {
-		if isActiveFRBRGroupType(doc) {
+// The FRBR member requests for all active FRBR groups are made concurrently, up
+// to the client's `MaxConcurrentFRBRRequests` at a time.  Each request records
+// its HTTP request and response data in its own PrimoResponse, which are then
+// merged into `primoResponse` in the order of the docs in the search response,
+// so that the result is the same as if the requests had been made serially.
+func (primoResponse *PrimoResponse) getLinks(ctx context.Context, client *http.Client, primoRequest PrimoRequest, searchResponse APIResponse) error {
+	strategy := primoRequest.strategy
+	citation := primoRequest.citation
+
+	type frbrGroupResult struct {
+		docs          []Doc
+		err           error
+		memberRequest *PrimoResponse
+	}
+
+	frbrGroupResults := make([]*frbrGroupResult, len(searchResponse.Docs))
+
+	maxConcurrentFRBRRequests := primoRequest.maxConcurrentFRBRRequests
+	if maxConcurrentFRBRRequests <= 0 {
+		maxConcurrentFRBRRequests = DefaultMaxConcurrentFRBRRequests
+	}
+	semaphore := make(chan struct{}, maxConcurrentFRBRRequests)
+
+	var waitGroup sync.WaitGroup
+	for i, doc := range searchResponse.Docs {
+		if !isActiveFRBRGroupType(doc) {
+			continue
+		}
+
+		result := &frbrGroupResult{memberRequest: &PrimoResponse{}}
+		frbrGroupResults[i] = result
+
+		waitGroup.Add(1)
+		go func(frbrGroupID string) {
+			defer waitGroup.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
 			// This makes another HTTP request to Primo and fetches docs for the
 			// active FRBR group.
-			docsForFRBRGroup, err :=
-				primoResponse.getDocsForFRBRGroup(isbn, doc.PNX.Facets.FRBRGroupID[0])
-			if err != nil {
-				return fmt.Errorf("Error fetching FRBR group links: %v", err)
+			result.docs, result.err = result.memberRequest.getDocsForFRBRGroup(ctx, client, primoRequest, frbrGroupID)
+		}(doc.PNX.Facets.FRBRGroupID[0])
+	}
+	waitGroup.Wait()
+
+	for i, doc := range searchResponse.Docs {
+		result := frbrGroupResults[i]
+		if result == nil {
+			// No FRBR groups involved, just collect the links straight from this
+			// doc, if the search can be trusted to only return the cited work.
+			if !strategy.matchSearchResults || strategy.isMatch(doc, citation) {
+				primoResponse.addLinks(doc)
 			}
-			// Only collect links from docs that match the user-specified ISBN.
-			for _, frbrGroupDoc := range docsForFRBRGroup {
-				if isMatch(frbrGroupDoc, isbn) {
-					primoResponse.addLinks(frbrGroupDoc)
-				}
+			continue
+		}
+
+		primoResponse.merge(result.memberRequest)
+		if result.err != nil {
+			return fmt.Errorf("Error fetching FRBR group links: %w", result.err)
+		}
+
+		// Only collect links from docs that match the citation.
+		for _, frbrGroupDoc := range result.docs {
+			if strategy.isMatch(frbrGroupDoc, citation) {
+				primoResponse.addLinks(frbrGroupDoc)
 			}
-		} else {
-			// No FRBR groups involved, just collect the links straight from this doc.
-			primoResponse.addLinks(doc)
 		}
 	}
 
@@ -177,14 +237,12 @@ func (primoResponse *PrimoResponse) getLinks(isbn string, isbnSearchResponse API
 	return nil
 }
 
-func isMatch(frbrGroupDoc Doc, isbn string) bool {
-	isMatch := false
-	for _, isbnToTest := range frbrGroupDoc.PNX.Search.ISBN {
-		if isbnToTest == isbn {
-			isMatch = true
-			break
-		}
-	}
-
-	return isMatch
+// Appends the HTTP request and response data from `other` to `primoResponse`.
+func (primoResponse *PrimoResponse) merge(other *PrimoResponse) {
+	primoResponse.DumpedFRBRMemberHTTPRequests =
+		append(primoResponse.DumpedFRBRMemberHTTPRequests, other.DumpedFRBRMemberHTTPRequests...)
+	primoResponse.DumpedHTTPResponses = append(primoResponse.DumpedHTTPResponses, other.DumpedHTTPResponses...)
+	primoResponse.FRBRMemberHTTPRequests = append(primoResponse.FRBRMemberHTTPRequests, other.FRBRMemberHTTPRequests...)
+	primoResponse.HTTPResponses = append(primoResponse.HTTPResponses, other.HTTPResponses...)
+	primoResponse.APIResponses = append(primoResponse.APIResponses, other.APIResponses...)
 }
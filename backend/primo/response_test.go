@@ -221,32 +221,38 @@ func TestIsFound(t *testing.T) {
 	}
 }
 
-func TestIsMatch(t *testing.T) {
+func TestIsISBNMatch(t *testing.T) {
 	testCases := []struct {
 		name           string
 		frbrGroupDoc   Doc
-		isbn           string
+		isbns          []string
 		expectedResult bool
 	}{
 		{
 			name:           "ISBN match found",
 			frbrGroupDoc:   fakePrimoISBNSearchAPIResponse.Docs[0],
-			isbn:           "3333333333333",
+			isbns:          []string{"9783333333335"},
 			expectedResult: true,
 		},
 		{
 			name:           "ISBN match not found",
 			frbrGroupDoc:   fakePrimoISBNSearchAPIResponse.Docs[0],
-			isbn:           "5555555555555",
+			isbns:          []string{"9785555555557"},
 			expectedResult: false,
 		},
+		{
+			name:           "ISBN match found for second ISBN",
+			frbrGroupDoc:   fakePrimoISBNSearchAPIResponse.Docs[0],
+			isbns:          []string{"9785555555557", "9784444444446"},
+			expectedResult: true,
+		},
 	}
 
 	for _, testCase := range testCases {
-		got := isMatch(testCase.frbrGroupDoc, testCase.isbn)
+		got := isISBNMatch(testCase.frbrGroupDoc, testCase.isbns)
 		if got != testCase.expectedResult {
 			t.Errorf(
-				"IsMatch returned an incorrect result for test case \"%s\": "+
+				"isISBNMatch returned an incorrect result for test case \"%s\": "+
 					"expected %t, got %t",
 				testCase.name,
 				testCase.expectedResult,
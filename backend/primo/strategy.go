@@ -0,0 +1,285 @@
+package primo
+
+import (
+	"ariadne/openurl"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Primo search strategies, in order of preference.  A Primo request uses the
+// first strategy for which the OpenURL has the needed metadata.
+const (
+	SearchStrategyISBN        = "isbn"
+	SearchStrategyISSN        = "issn"
+	SearchStrategyOCLCNum     = "oclcnum"
+	SearchStrategyLCCN        = "lccn"
+	SearchStrategyTitleAuthor = "title_author"
+)
+
+// What Primo is searched for, and docs are matched against.  The referent has
+// only the first ISBN in the OpenURL, but OpenURLs often have several, e.g. for
+// the print and electronic editions, and any of them might be the one in Primo.
+type citation struct {
+	openurl.Referent
+	// All the valid ISBNs in the OpenURL, in ISBN-13 form.  See `normalizeISBNs`.
+	isbns []string
+	// The invalid ISBNs in the OpenURL, as given
+	invalidISBNs []string
+}
+
+// How to search Primo for a citation, and how to tell whether a doc in the
+// results is the cited work.
+type searchStrategy struct {
+	name        string
+	requestType string
+	// Returns the Primo `q` param for the citation, or "" if the citation doesn't
+	// have the metadata the strategy needs.
+	query func(citation citation) string
+	// Whether `doc` is a record of the cited work.  FRBR member searches return
+	// all the editions in a group, so their docs are always matched.
+	isMatch func(doc Doc, citation citation) bool
+	// Whether the docs of the search itself are matched too, for searches that
+	// are not on an exact identifier.
+	matchSearchResults bool
+}
+
+var searchStrategies = []searchStrategy{
+	{
+		name:        SearchStrategyISBN,
+		requestType: RequestTypeISBNSearch,
+		// Primo indexes both forms of each ISBN, so only the ISBN-13s need to be
+		// searched for.
+		query: func(citation citation) string {
+			queries := []string{}
+			for _, isbn := range citation.isbns {
+				queries = append(queries, makeQuery("isbn", "exact", isbn))
+			}
+
+			return strings.Join(queries, ",OR;")
+		},
+		isMatch: func(doc Doc, citation citation) bool {
+			return isISBNMatch(doc, citation.isbns)
+		},
+	},
+	{
+		name:        SearchStrategyISSN,
+		requestType: RequestTypeISSNSearch,
+		query: func(citation citation) string {
+			return makeQuery("issn", "exact", getISSN(citation.Referent))
+		},
+		isMatch: func(doc Doc, citation citation) bool {
+			return containsNormalized(doc.PNX.Search.ISSN, getISSN(citation.Referent), openurl.NormalizeISSN)
+		},
+	},
+	{
+		name:        SearchStrategyOCLCNum,
+		requestType: RequestTypeOCLCNumSearch,
+		query: func(citation citation) string {
+			return makeQuery("any", "contains", normalizeOCLCNum(citation.OCLCNum))
+		},
+		isMatch: func(doc Doc, citation citation) bool {
+			return containsNormalized(doc.PNX.Addata.OCLCID, normalizeOCLCNum(citation.OCLCNum), normalizeOCLCNum)
+		},
+	},
+	{
+		name:        SearchStrategyLCCN,
+		requestType: RequestTypeLCCNSearch,
+		query: func(citation citation) string {
+			return makeQuery("any", "contains", normalizeLCCN(citation.LCCN))
+		},
+		isMatch: func(doc Doc, citation citation) bool {
+			return containsNormalized(doc.PNX.Addata.LCCN, normalizeLCCN(citation.LCCN), normalizeLCCN)
+		},
+	},
+	{
+		name:        SearchStrategyTitleAuthor,
+		requestType: RequestTypeTitleAuthorSearch,
+		query: func(citation citation) string {
+			query := makeQuery("title", "contains", getTitle(citation.Referent))
+			if query == "" {
+				return ""
+			}
+			if authorLastName := getAuthorLastName(citation.Referent); authorLastName != "" {
+				query += ",AND;" + makeQuery("creator", "contains", authorLastName)
+			}
+
+			return query
+		},
+		isMatch:            isTitleAuthorMatch,
+		matchSearchResults: true,
+	},
+}
+
+var nonAlphanumericRegexp = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+var oclcNumRegexp = regexp.MustCompile(`^[0-9]+$`)
+
+// Invalid ISBNs are left out of the citation's ISBNs.  The error for the first
+// is returned along with the citation, if there were any.
+func newCitation(contextObject *openurl.ContextObject) (citation, error) {
+	isbns, invalidISBNs, err := normalizeISBNs(contextObject.Referent.ISBNs)
+
+	return citation{Referent: contextObject.Referent, isbns: isbns, invalidISBNs: invalidISBNs}, err
+}
+
+// Returns the first strategy that can be used for the citation, or nil if none
+// can.
+func chooseSearchStrategy(citation citation) *searchStrategy {
+	for i := range searchStrategies {
+		if searchStrategies[i].query(citation) != "" {
+			return &searchStrategies[i]
+		}
+	}
+
+	return nil
+}
+
+func containsNormalized(values []string, normalizedValue string, normalize func(string) string) bool {
+	if normalizedValue == "" {
+		return false
+	}
+
+	for _, value := range values {
+		if normalize(value) == normalizedValue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the last name of the first author, which is all that a title search
+// needs to tell editions of different works apart.
+func getAuthorLastName(referent openurl.Referent) string {
+	authors := referent.Authors()
+	if len(authors) == 0 {
+		return ""
+	}
+
+	lastName, _, _ := strings.Cut(authors[0], ",")
+
+	return strings.TrimSpace(lastName)
+}
+
+func getISSN(referent openurl.Referent) string {
+	if referent.ISSN != "" {
+		return openurl.NormalizeISSN(referent.ISSN)
+	}
+
+	return openurl.NormalizeISSN(referent.EISSN)
+}
+
+// Book titles are preferred to journal titles, since books are what Primo is
+// most useful for.
+func getTitle(referent openurl.Referent) string {
+	for _, title := range []string{referent.BTitle, referent.Title, referent.JTitle} {
+		if strings.TrimSpace(title) != "" {
+			return strings.TrimSpace(title)
+		}
+	}
+
+	return ""
+}
+
+// Whether the doc has any of the ISBNs, which must be normalized.  The doc's
+// ISBNs are normalized before comparing, so that its ISBN-10s match too.
+// Invalid ISBNs in the doc are ignored.
+func isISBNMatch(doc Doc, isbns []string) bool {
+	normalize := func(isbnToTest string) string {
+		normalizedISBN, _ := NormalizeISBN(isbnToTest)
+		return normalizedISBN
+	}
+	for _, isbn := range isbns {
+		if containsNormalized(doc.PNX.Search.ISBN, isbn, normalize) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Titles match if one is the other plus a subtitle, e.g. "Hamlet" and "Hamlet:
+// Prince of Denmark", ignoring case and punctuation.  If the citation has an
+// author, one of the doc's creators must have the same last name.
+func isTitleAuthorMatch(doc Doc, citation citation) bool {
+	title := normalizeTitle(getTitle(citation.Referent))
+	if title == "" {
+		return false
+	}
+
+	titleMatches := false
+	for _, docTitle := range doc.PNX.Search.Title {
+		docTitle = normalizeTitle(docTitle)
+		if docTitle != "" && (strings.HasPrefix(docTitle+" ", title+" ") || strings.HasPrefix(title+" ", docTitle+" ")) {
+			titleMatches = true
+			break
+		}
+	}
+	if !titleMatches {
+		return false
+	}
+
+	authorLastName := normalizeTitle(getAuthorLastName(citation.Referent))
+	if authorLastName == "" {
+		return true
+	}
+	for _, creator := range doc.PNX.Search.CreatorContrib {
+		if strings.Contains(" "+normalizeTitle(creator)+" ", " "+authorLastName+" ") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns a single Primo query term, or "" if `value` is empty.  Commas and
+// semicolons separate the parts of Primo queries, so they are replaced.
+func makeQuery(field string, precision string, value string) string {
+	value = strings.Join(strings.Fields(strings.NewReplacer(",", " ", ";", " ").Replace(value)), " ")
+	if value == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s,%s,%s", field, precision, value)
+}
+
+// Normalizes according to https://www.loc.gov/marc/lccn-namespace.html: e.g.
+// "n 79-21164" becomes "n79021164".  Returns "" if `lccn` is empty.
+func normalizeLCCN(lccn string) string {
+	lccn = strings.ToLower(strings.Join(strings.Fields(lccn), ""))
+	lccn, _, _ = strings.Cut(lccn, "/")
+	if prefix, serial, ok := strings.Cut(lccn, "-"); ok {
+		if len(serial) < 6 {
+			serial = strings.Repeat("0", 6-len(serial)) + serial
+		}
+		lccn = prefix + serial
+	}
+
+	return lccn
+}
+
+// OCLC numbers are sent to us and stored in Primo in several forms, e.g.
+// "ocm12345678", "(OCoLC)12345678", and "12345678".  Returns the number
+// without prefix or leading zeros, or "" if it doesn't look like an OCLC
+// number.
+func normalizeOCLCNum(oclcNum string) string {
+	normalized := strings.ToLower(strings.TrimSpace(oclcNum))
+	normalized = strings.TrimPrefix(normalized, "(ocolc)")
+	for _, prefix := range []string{"ocm", "ocn", "on"} {
+		if strings.HasPrefix(normalized, prefix) {
+			normalized = normalized[len(prefix):]
+			break
+		}
+	}
+	normalized = strings.TrimLeft(strings.TrimSpace(normalized), "0")
+	if !oclcNumRegexp.MatchString(normalized) {
+		return ""
+	}
+
+	return normalized
+}
+
+func normalizeTitle(title string) string {
+	return strings.TrimSpace(nonAlphanumericRegexp.ReplaceAllString(strings.ToLower(title), " "))
+}
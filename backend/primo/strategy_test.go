@@ -0,0 +1,175 @@
+package primo
+
+import (
+	"ariadne/openurl"
+	"testing"
+)
+
+func TestChooseSearchStrategy(t *testing.T) {
+	testCases := []struct {
+		name             string
+		queryString      string
+		expectedStrategy string
+		expectedQuery    string
+	}{
+		{"ISBN", "isbn=9780198129103&issn=0028-792X&title=Hamlet", SearchStrategyISBN, "isbn,exact,9780198129103"},
+		{"Hyphenated ISBN-10", "isbn=0-19-812910-6", SearchStrategyISBN, "isbn,exact,9780198129103"},
+		{"ISBN with qualifier", "isbn=9780198129103%20(pbk.)", SearchStrategyISBN, "isbn,exact,9780198129103"},
+		{
+			"Multiple ISBNs",
+			"rft.isbn=9780198129103&isbn=0-19-812910-6&rft.eisbn=9780191732355%3B%209780198129103&rft_id=urn:ISBN:0521532523",
+			SearchStrategyISBN,
+			"isbn,exact,9780198129103,OR;isbn,exact,9780521532525,OR;isbn,exact,9780191732355",
+		},
+		{"Invalid ISBN skipped", "isbn=0198129107,9780191732355", SearchStrategyISBN, "isbn,exact,9780191732355"},
+		{"All ISBNs invalid", "isbn=0198129107&title=Hamlet", SearchStrategyTitleAuthor, "title,contains,Hamlet"},
+		{"ISSN", "issn=0028792x&oclcnum=1760231&title=The%20New%20Yorker", SearchStrategyISSN, "issn,exact,0028-792X"},
+		{"EISSN", "eissn=2163-3827", SearchStrategyISSN, "issn,exact,2163-3827"},
+		{"OCLC number", "oclcnum=ocm01760231&lccn=28005329&title=The%20New%20Yorker", SearchStrategyOCLCNum, "any,contains,1760231"},
+		{"OCLC number identifier", "rft_id=info:oclcnum/1760231", SearchStrategyOCLCNum, "any,contains,1760231"},
+		{"LCCN", "rft_id=info:lccn/n%2079-21164&title=Hamlet", SearchStrategyLCCN, "any,contains,n79021164"},
+		{"Invalid OCLC number", "oclcnum=none&title=Hamlet", SearchStrategyTitleAuthor, "title,contains,Hamlet"},
+		{
+			"Title and author",
+			"genre=book&btitle=The%20Sino-Tibetan%20Languages&aulast=Thurgood&aufirst=Graham",
+			SearchStrategyTitleAuthor,
+			"title,contains,The Sino-Tibetan Languages,AND;creator,contains,Thurgood",
+		},
+		{
+			"Title with commas and semicolons",
+			"title=Hamlet,%20Prince%20of%20Denmark%3B%20a%20tragedy&au=Shakespeare,%20William",
+			SearchStrategyTitleAuthor,
+			"title,contains,Hamlet Prince of Denmark a tragedy,AND;creator,contains,Shakespeare",
+		},
+		{"Nothing to search for", "volume=97&issue=34", "", ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			citation := newTestCitation(t, testCase.queryString)
+			strategy := chooseSearchStrategy(citation)
+			if strategy == nil {
+				if testCase.expectedStrategy != "" {
+					t.Errorf("Expected strategy \"%s\", got none", testCase.expectedStrategy)
+				}
+				return
+			}
+			if strategy.name != testCase.expectedStrategy {
+				t.Errorf("Expected strategy \"%s\", got \"%s\"", testCase.expectedStrategy, strategy.name)
+			}
+			if query := strategy.query(citation); query != testCase.expectedQuery {
+				t.Errorf("Expected query \"%s\", got \"%s\"", testCase.expectedQuery, query)
+			}
+		})
+	}
+}
+
+func TestSearchStrategyIsMatch(t *testing.T) {
+	doc := Doc{
+		PNX: PNX{
+			Addata: Addata{
+				LCCN:   []string{"n  79021164"},
+				OCLCID: []string{"(OCoLC)01760231"},
+			},
+			Search: Search{
+				CreatorContrib: []string{"Shakespeare, William, 1564-1616", "Edwards, Philip"},
+				ISBN:           []string{"0198129106", "719101220"},
+				ISSN:           []string{"0028792X"},
+				Title:          []string{"Hamlet, prince of Denmark"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		queryString string
+		expected    bool
+	}{
+		{"ISBN", "isbn=9780198129103", true},
+		{"ISBN-10 of ISBN-13", "isbn=0198129106", true},
+		{"Hyphenated ISBN", "isbn=978-0-19-812910-3", true},
+		{"Different ISBN", "isbn=9780521532525", false},
+		{"Any of multiple ISBNs", "isbn=9780521532525&eisbn=9780198129103", true},
+		{"ISSN", "issn=0028-792X", true},
+		{"Different ISSN", "issn=0000-0000", false},
+		{"OCLC number", "oclcnum=1760231", true},
+		{"Different OCLC number", "oclcnum=1760232", false},
+		{"LCCN", "lccn=n79-21164", true},
+		{"Different LCCN", "lccn=n79-21165", false},
+		{"Title and author", "title=Hamlet&aulast=Shakespeare", true},
+		{"Title with subtitle", "title=Hamlet%2C%20Prince%20of%20Denmark%3A%20the%20Oxford%20edition", true},
+		{"Title without author", "title=hamlet", true},
+		{"Different author", "title=Hamlet&aulast=Stoppard", false},
+		{"Title prefix that isn't a whole word", "title=Ham&aulast=Shakespeare", false},
+		{"Different title", "title=Macbeth&aulast=Shakespeare", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			citation := newTestCitation(t, testCase.queryString)
+			strategy := chooseSearchStrategy(citation)
+			if actual := strategy.isMatch(doc, citation); actual != testCase.expected {
+				t.Errorf("Expected %s match to be %t, got %t", strategy.name, testCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestNormalizeOCLCNum(t *testing.T) {
+	testCases := []struct {
+		oclcNum  string
+		expected string
+	}{
+		{"1760231", "1760231"},
+		{"ocm01760231", "1760231"},
+		{"ocn123456789", "123456789"},
+		{"on1234567890", "1234567890"},
+		{"(OCoLC)01760231", "1760231"},
+		{" (ocolc)ocm01760231 ", "1760231"},
+		{"abc", ""},
+		{"", ""},
+	}
+
+	for _, testCase := range testCases {
+		if actual := normalizeOCLCNum(testCase.oclcNum); actual != testCase.expected {
+			t.Errorf("normalizeOCLCNum(\"%s\"): expected \"%s\", got \"%s\"", testCase.oclcNum, testCase.expected, actual)
+		}
+	}
+}
+
+// Examples from https://www.loc.gov/marc/lccn-namespace.html
+func TestNormalizeLCCN(t *testing.T) {
+	testCases := []struct {
+		lccn     string
+		expected string
+	}{
+		{"n78-890351", "n78890351"},
+		{"n78-89035", "n78089035"},
+		{"n 78890351 ", "n78890351"},
+		{" 85000002 ", "85000002"},
+		{"85-2 ", "85000002"},
+		{"2001-000002", "2001000002"},
+		{"75-425165//r75", "75425165"},
+		{" 79139101 /AC/r932", "79139101"},
+	}
+
+	for _, testCase := range testCases {
+		if actual := normalizeLCCN(testCase.lccn); actual != testCase.expected {
+			t.Errorf("normalizeLCCN(\"%s\"): expected \"%s\", got \"%s\"", testCase.lccn, testCase.expected, actual)
+		}
+	}
+}
+
+func newTestCitation(t *testing.T, queryString string) citation {
+	t.Helper()
+
+	contextObject, err := openurl.Parse(queryString)
+	if err != nil {
+		t.Fatalf("Could not parse OpenURL: %s", err)
+	}
+
+	// Invalid ISBNs are left out of the citation.
+	citation, _ := newCitation(contextObject)
+
+	return citation
+}
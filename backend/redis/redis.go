@@ -0,0 +1,344 @@
+// Package redis is a minimal client for servers that speak the Redis protocol
+// (RESP).  It only supports the handful of commands needed by the response cache.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultAddress = "localhost:6379"
+const DefaultDialTimeout = 5 * time.Second
+const DefaultMaxIdleConns = 10
+const DefaultReadTimeout = 3 * time.Second
+const DefaultWriteTimeout = 3 * time.Second
+
+// The read and write timeouts apply to commands whose context has no deadline,
+// so that a server which accepts connections but stops replying can't block the
+// caller forever.  Commands whose context has a deadline use it instead.
+type ClientOptions struct {
+	// Defaults to `DefaultDialTimeout`.
+	DialTimeout time.Duration
+	// How long to wait for a reply.  Defaults to `DefaultReadTimeout`.
+	ReadTimeout time.Duration
+	// How long to wait for a command to be sent.  Defaults to
+	// `DefaultWriteTimeout`.
+	WriteTimeout time.Duration
+}
+
+type Client struct {
+	address      string
+	database     int
+	password     string
+	username     string
+	dialTimeout  time.Duration
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	idleConns    []*conn
+	maxIdleConns int
+	mutex        sync.Mutex
+}
+
+// An error reply from the server.
+type ServerError string
+
+func (err ServerError) Error() string {
+	return "Redis server error: " + string(err)
+}
+
+type conn struct {
+	netConn      net.Conn
+	reader       *bufio.Reader
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// `address` is either "host:port" or a URL of the form
+// "redis://[[username]:password@]host:port[/database]".
+func NewClient(address string, options ClientOptions) (*Client, error) {
+	client := &Client{
+		address:      address,
+		dialTimeout:  options.DialTimeout,
+		readTimeout:  options.ReadTimeout,
+		writeTimeout: options.WriteTimeout,
+		maxIdleConns: DefaultMaxIdleConns,
+	}
+	if client.dialTimeout <= 0 {
+		client.dialTimeout = DefaultDialTimeout
+	}
+	if client.readTimeout <= 0 {
+		client.readTimeout = DefaultReadTimeout
+	}
+	if client.writeTimeout <= 0 {
+		client.writeTimeout = DefaultWriteTimeout
+	}
+
+	if !strings.Contains(address, "://") {
+		return client, nil
+	}
+
+	redisURL, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse Redis URL: %v", err)
+	}
+	if redisURL.Scheme != "redis" {
+		return nil, fmt.Errorf("Unsupported Redis URL scheme: %s", redisURL.Scheme)
+	}
+
+	client.address = redisURL.Host
+	if redisURL.User != nil {
+		client.username = redisURL.User.Username()
+		client.password, _ = redisURL.User.Password()
+	}
+
+	database := strings.TrimPrefix(redisURL.Path, "/")
+	if database != "" {
+		client.database, err = strconv.Atoi(database)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Redis database number: %s", database)
+		}
+	}
+
+	return client, nil
+}
+
+// Closes all idle connections.
+func (client *Client) Close() error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	for _, conn := range client.idleConns {
+		conn.netConn.Close()
+	}
+	client.idleConns = nil
+
+	return nil
+}
+
+// Returns false if `key` does not exist.
+func (client *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := client.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if reply == nil {
+		return nil, false, nil
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("Unexpected reply to Redis GET: %v", reply)
+	}
+
+	return value, true, nil
+}
+
+func (client *Client) Ping(ctx context.Context) error {
+	_, err := client.do(ctx, "PING")
+
+	return err
+}
+
+// A `ttl` of 0 means the key never expires.
+func (client *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+
+	_, err := client.do(ctx, args...)
+
+	return err
+}
+
+func (client *Client) do(ctx context.Context, args ...string) (any, error) {
+	conn, err := client.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.do(ctx, args...)
+	if err != nil {
+		// Server errors leave the connection in a usable state, anything else
+		// might not have.
+		var serverError ServerError
+		if errors.As(err, &serverError) {
+			client.putConn(conn)
+		} else {
+			conn.netConn.Close()
+		}
+
+		return nil, err
+	}
+
+	client.putConn(conn)
+
+	return reply, nil
+}
+
+func (client *Client) getConn(ctx context.Context) (*conn, error) {
+	client.mutex.Lock()
+	numIdleConns := len(client.idleConns)
+	if numIdleConns > 0 {
+		conn := client.idleConns[numIdleConns-1]
+		client.idleConns = client.idleConns[:numIdleConns-1]
+		client.mutex.Unlock()
+
+		return conn, nil
+	}
+	client.mutex.Unlock()
+
+	dialer := net.Dialer{Timeout: client.dialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", client.address)
+	if err != nil {
+		return nil, fmt.Errorf("Could not connect to Redis server: %v", err)
+	}
+
+	conn := &conn{
+		netConn:      netConn,
+		reader:       bufio.NewReader(netConn),
+		readTimeout:  client.readTimeout,
+		writeTimeout: client.writeTimeout,
+	}
+
+	if client.password != "" {
+		authArgs := []string{"AUTH", client.password}
+		if client.username != "" {
+			authArgs = []string{"AUTH", client.username, client.password}
+		}
+		_, err = conn.do(ctx, authArgs...)
+		if err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("Could not authenticate with Redis server: %v", err)
+		}
+	}
+
+	if client.database != 0 {
+		_, err = conn.do(ctx, "SELECT", strconv.Itoa(client.database))
+		if err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("Could not select Redis database: %v", err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (client *Client) putConn(conn *conn) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if len(client.idleConns) >= client.maxIdleConns {
+		conn.netConn.Close()
+		return
+	}
+
+	client.idleConns = append(client.idleConns, conn)
+}
+
+func (conn *conn) do(ctx context.Context, args ...string) (any, error) {
+	deadline, hasDeadline := ctx.Deadline()
+	if !hasDeadline {
+		deadline = time.Now().Add(conn.writeTimeout)
+	}
+	err := conn.netConn.SetDeadline(deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commands are sent as arrays of bulk strings.
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	_, err = io.WriteString(conn.netConn, command.String())
+	if err != nil {
+		return nil, fmt.Errorf("Could not write command to Redis server: %v", err)
+	}
+
+	if !hasDeadline {
+		err = conn.netConn.SetReadDeadline(time.Now().Add(conn.readTimeout))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return conn.readReply()
+}
+
+// Returns a string for simple strings, int64 for integers, []byte for bulk
+// strings, []any for arrays, and nil for null bulk strings and arrays.
+func (conn *conn) readReply() (any, error) {
+	line, err := conn.reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("Could not read reply from Redis server: %v", err)
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, fmt.Errorf("Empty reply from Redis server")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, ServerError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid bulk string length in Redis reply: %s", line)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+
+		// Bulk string is followed by CRLF.
+		buffer := make([]byte, length+2)
+		_, err = io.ReadFull(conn.reader, buffer)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read bulk string from Redis server: %v", err)
+		}
+
+		return buffer[:length], nil
+	case '*':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid array length in Redis reply: %s", line)
+		}
+		if length < 0 {
+			return nil, nil
+		}
+
+		elements := make([]any, length)
+		for i := range elements {
+			elements[i], err = conn.readReply()
+			if err != nil {
+				var serverError ServerError
+				if !errors.As(err, &serverError) {
+					return nil, err
+				}
+				elements[i] = err
+			}
+		}
+
+		return elements, nil
+	default:
+		return nil, fmt.Errorf("Unrecognized reply from Redis server: %s", line)
+	}
+}
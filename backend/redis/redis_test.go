@@ -0,0 +1,167 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestClient(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	client, err := NewClient(server.Addr(), ClientOptions{})
+	if err != nil {
+		t.Fatalf("NewClient returned error: %s", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+
+	err = client.Ping(ctx)
+	if err != nil {
+		t.Fatalf("Ping returned error: %s", err)
+	}
+
+	_, ok, err := client.Get(ctx, "missing")
+	if err != nil || ok {
+		t.Errorf("Get of missing key returned ok=%t, error '%v', expecting ok=false and no error", ok, err)
+	}
+
+	// Values are binary-safe.
+	value := []byte("line 1\r\nline 2\x00")
+	err = client.Set(ctx, "key", value, time.Minute)
+	if err != nil {
+		t.Fatalf("Set returned error: %s", err)
+	}
+
+	got, ok, err := client.Get(ctx, "key")
+	if err != nil || !ok || string(got) != string(value) {
+		t.Errorf("Get returned '%q', ok=%t, error '%v', expecting '%q'", got, ok, err, value)
+	}
+
+	server.FastForward(time.Minute)
+	_, ok, _ = client.Get(ctx, "key")
+	if ok {
+		t.Errorf("Get returned expired key")
+	}
+
+	// Server errors are returned, and the connection remains usable.
+	server.Lpush("list", "item")
+	_, _, err = client.Get(ctx, "list")
+	var serverError ServerError
+	if !errors.As(err, &serverError) {
+		t.Errorf("Get of wrong type returned error '%v', expecting a ServerError", err)
+	}
+	err = client.Ping(ctx)
+	if err != nil {
+		t.Errorf("Ping after server error returned error: %s", err)
+	}
+}
+
+func TestClientURL(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+
+	testCases := []struct {
+		name                string
+		address             string
+		expectedErrorNotNil bool
+	}{
+		{"Password and database", "redis://:secret@" + server.Addr() + "/2", false},
+		{"Wrong password", "redis://:wrong@" + server.Addr(), true},
+		{"No password", server.Addr(), true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client, err := NewClient(testCase.address, ClientOptions{})
+			if err != nil {
+				t.Fatalf("NewClient returned error: %s", err)
+			}
+			defer client.Close()
+
+			err = client.Set(context.Background(), "key", []byte("value"), 0)
+			if testCase.expectedErrorNotNil && err == nil {
+				t.Errorf("Set returned no error, expecting an error")
+			}
+			if !testCase.expectedErrorNotNil && err != nil {
+				t.Errorf("Set returned error '%v', expecting no errors", err)
+			}
+		})
+	}
+
+	if !server.DB(2).Exists("key") {
+		t.Errorf("Expected key to be set in database 2")
+	}
+}
+
+func TestNewClientInvalidURL(t *testing.T) {
+	for _, address := range []string{"http://localhost:6379", "redis://localhost:6379/db"} {
+		_, err := NewClient(address, ClientOptions{})
+		if err == nil {
+			t.Errorf("NewClient(\"%s\") returned no error, expecting an error", address)
+		}
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	// Accepts connections, but never replies.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client, err := NewClient(listener.Addr().String(), ClientOptions{ReadTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewClient returned error: %s", err)
+	}
+	defer client.Close()
+
+	testCases := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+	}{
+		{"No context deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		}},
+		{"Context deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 50*time.Millisecond)
+		}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctx, cancel := testCase.ctx()
+			defer cancel()
+
+			result := make(chan error, 1)
+			go func() {
+				result <- client.Ping(ctx)
+			}()
+
+			select {
+			case err := <-result:
+				if err == nil || !strings.Contains(err.Error(), "i/o timeout") {
+					t.Errorf("Ping returned error '%v', expecting a timeout", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Ping did not time out")
+			}
+		})
+	}
+}
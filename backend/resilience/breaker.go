@@ -0,0 +1,164 @@
+package resilience
+
+import (
+	"ariadne/log"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Same key used by the api package for log messages.
+const messageKey = "message"
+
+const DefaultFailureThreshold = 5
+const DefaultCooldown = 30 * time.Second
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	// Requests are allowed through.
+	StateClosed State = iota
+	// Requests are rejected immediately with ErrCircuitOpen.
+	StateOpen
+	// The cooldown has elapsed, and a single trial request is allowed through
+	// to determine whether the breaker should close again.
+	StateHalfOpen
+)
+
+func (state State) String() string {
+	switch state {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker trips after `FailureThreshold` consecutive failures, and then
+// rejects all requests until `Cooldown` has elapsed.  State changes are logged
+// at warn level to `logger`.
+type CircuitBreaker struct {
+	Cooldown         time.Duration
+	FailureThreshold int
+	Name             string
+
+	logger *log.Logger
+
+	mutex               sync.Mutex
+	consecutiveFailures int
+	openedAt            time.Time
+	state               State
+	trialInFlight       bool
+
+	// Can be overridden in tests.
+	now func() time.Time
+}
+
+// `logger` defaults to `log.Default()` if nil.
+func NewCircuitBreaker(name string, logger *log.Logger) *CircuitBreaker {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &CircuitBreaker{
+		Cooldown:         DefaultCooldown,
+		FailureThreshold: DefaultFailureThreshold,
+		Name:             name,
+		logger:           logger,
+		now:              time.Now,
+	}
+}
+
+// Returns ErrCircuitOpen if the request should not be attempted.  Every call
+// that returns nil must be followed by a call to RecordFailure or RecordSuccess
+// (or `release`).
+func (breaker *CircuitBreaker) Allow() error {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	if breaker.state == StateOpen && breaker.now().Sub(breaker.openedAt) >= breaker.Cooldown {
+		breaker.setState(StateHalfOpen)
+	}
+
+	switch breaker.state {
+	case StateOpen:
+		return fmt.Errorf("%s %w", breaker.Name, ErrCircuitOpen)
+	case StateHalfOpen:
+		if breaker.trialInFlight {
+			return fmt.Errorf("%s %w", breaker.Name, ErrCircuitOpen)
+		}
+		breaker.trialInFlight = true
+	}
+
+	return nil
+}
+
+func (breaker *CircuitBreaker) RecordFailure() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.trialInFlight = false
+	breaker.consecutiveFailures++
+
+	if breaker.state == StateHalfOpen ||
+		(breaker.state == StateClosed && breaker.consecutiveFailures >= breaker.FailureThreshold) {
+		breaker.openedAt = breaker.now()
+		breaker.setState(StateOpen)
+	}
+}
+
+func (breaker *CircuitBreaker) RecordSuccess() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.trialInFlight = false
+	breaker.consecutiveFailures = 0
+	breaker.setState(StateClosed)
+}
+
+// Called instead of RecordFailure or RecordSuccess when the outcome of an allowed
+// request says nothing about the health of the upstream service -- for example,
+// when the caller cancelled it.
+func (breaker *CircuitBreaker) release() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.trialInFlight = false
+}
+
+// Closes the breaker and clears the failure count.
+func (breaker *CircuitBreaker) Reset() {
+	breaker.RecordSuccess()
+}
+
+func (breaker *CircuitBreaker) State() State {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	return breaker.state
+}
+
+// Caller must hold the mutex.
+func (breaker *CircuitBreaker) setState(state State) {
+	if breaker.state == state {
+		return
+	}
+
+	breaker.logger.Warn(messageKey, fmt.Sprintf("%s circuit breaker state changed from %s to %s",
+		breaker.Name, breaker.state, state),
+		"circuitBreaker", map[string]any{
+			"name":                breaker.Name,
+			"previousState":       breaker.state.String(),
+			"state":               state.String(),
+			"consecutiveFailures": breaker.consecutiveFailures,
+		})
+
+	breaker.state = state
+}
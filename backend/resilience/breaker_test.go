@@ -0,0 +1,88 @@
+package resilience
+
+import (
+	"ariadne/log"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker("Test", nil)
+	breaker.FailureThreshold = 3
+	breaker.Cooldown = time.Minute
+	breaker.now = func() time.Time { return now }
+
+	assertState := func(expected State) {
+		t.Helper()
+		if breaker.State() != expected {
+			t.Fatalf("Expected breaker state '%s', got '%s'", expected, breaker.State())
+		}
+	}
+	assertAllowed := func(expected bool) {
+		t.Helper()
+		err := breaker.Allow()
+		if expected && err != nil {
+			t.Fatalf("Allow returned error '%v', expecting no error", err)
+		}
+		if !expected && !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Allow returned '%v', expecting ErrCircuitOpen", err)
+		}
+	}
+
+	// A success resets the consecutive failure count.
+	for i := 0; i < 2; i++ {
+		assertAllowed(true)
+		breaker.RecordFailure()
+	}
+	assertAllowed(true)
+	breaker.RecordSuccess()
+	assertState(StateClosed)
+
+	for i := 0; i < 3; i++ {
+		assertAllowed(true)
+		breaker.RecordFailure()
+	}
+	assertState(StateOpen)
+	assertAllowed(false)
+
+	// Only a single trial request is allowed through after the cooldown.
+	now = now.Add(time.Minute)
+	assertAllowed(true)
+	assertState(StateHalfOpen)
+	assertAllowed(false)
+
+	// A failed trial re-opens the breaker for another cooldown.
+	breaker.RecordFailure()
+	assertState(StateOpen)
+	now = now.Add(30 * time.Second)
+	assertAllowed(false)
+
+	// A successful trial closes it.
+	now = now.Add(30 * time.Second)
+	assertAllowed(true)
+	breaker.RecordSuccess()
+	assertState(StateClosed)
+	assertAllowed(true)
+}
+
+func TestCircuitBreakerLogger(t *testing.T) {
+	var logOutput bytes.Buffer
+	breaker := NewCircuitBreaker("Test", log.New(&logOutput, log.LevelDebug))
+	breaker.FailureThreshold = 1
+
+	breaker.RecordFailure()
+	breaker.RecordSuccess()
+
+	for _, expected := range []string{
+		"Test circuit breaker state changed from closed to open",
+		"Test circuit breaker state changed from open to closed",
+	} {
+		if !strings.Contains(logOutput.String(), expected) {
+			t.Errorf("Expected injected logger output to contain \"%s\", got: %s", expected, logOutput.String())
+		}
+	}
+}
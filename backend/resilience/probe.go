@@ -0,0 +1,42 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Probe checks that the server at `url` is reachable by making a single GET
+// request to it.  Any response other than a 5xx counts as reachable, since
+// upstream APIs typically reject a request without params with a 4xx.  If
+// `httpClient` uses a `Transport`, its retries and circuit breaker are bypassed,
+// so that probes neither wait on backoff nor affect requests made on behalf of
+// users.  The probe is cancelled if `ctx` is done before it completes.
+func Probe(ctx context.Context, httpClient *http.Client, url string) error {
+	roundTripper := httpClient.Transport
+	if transport, ok := roundTripper.(*Transport); ok {
+		roundTripper = transport.Base
+	}
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("Could not create probe request: %v", err)
+	}
+
+	response, err := roundTripper.RoundTrip(request)
+	if err != nil {
+		return fmt.Errorf("Could not do probe request: %v", err)
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	if response.StatusCode >= 500 {
+		return fmt.Errorf("Probe request returned HTTP status %s", response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package resilience
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	testCases := []struct {
+		name          string
+		statusCode    int
+		expectedError bool
+	}{
+		{"Success", 200, false},
+		{"4xx is reachable", 400, false},
+		{"5xx is unreachable", 503, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var numRequests int32
+			fakeServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					atomic.AddInt32(&numRequests, 1)
+					w.WriteHeader(testCase.statusCode)
+				}),
+			)
+			defer fakeServer.Close()
+
+			breaker := NewCircuitBreaker("Test", nil)
+			client := &http.Client{
+				Transport: NewTransport(http.DefaultTransport, breaker, testRetryPolicy),
+			}
+
+			err := Probe(context.Background(), client, fakeServer.URL)
+			if (err != nil) != testCase.expectedError {
+				t.Errorf("Probe returned error '%v', expecting error: %t", err, testCase.expectedError)
+			}
+			// Probes must not be retried or recorded by the circuit breaker.
+			if numRequests != 1 {
+				t.Errorf("Expected 1 request, got %d", numRequests)
+			}
+			if breaker.State() != StateClosed {
+				t.Errorf("Expected circuit breaker to be %s, got %s", StateClosed, breaker.State())
+			}
+		})
+	}
+}
+
+func TestProbeUnreachable(t *testing.T) {
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	fakeServer.Close()
+
+	err := Probe(context.Background(), &http.Client{}, fakeServer.URL)
+	if err == nil {
+		t.Errorf("Probe of closed server returned no error")
+	}
+}
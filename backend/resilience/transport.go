@@ -0,0 +1,140 @@
+package resilience
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The global `math/rand` source is not seeded automatically in the Go version
+// we build with.
+var jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+var jitterRandMutex sync.Mutex
+
+// Retries are spread out using "full jitter" exponential backoff: before retry
+// number n, wait a random duration between 0 and min(MaxDelay, BaseDelay * 2^n).
+// See https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+type RetryPolicy struct {
+	BaseDelay   time.Duration
+	MaxAttempts int
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	BaseDelay:   100 * time.Millisecond,
+	MaxAttempts: 3,
+	MaxDelay:    time.Second,
+}
+
+// Transport is an http.RoundTripper that retries idempotent requests which fail
+// with a network error or 5xx response, and which stops sending requests
+// altogether while its circuit breaker is open.
+type Transport struct {
+	Base           http.RoundTripper
+	CircuitBreaker *CircuitBreaker
+	RetryPolicy    RetryPolicy
+}
+
+func NewTransport(base http.RoundTripper, circuitBreaker *CircuitBreaker, retryPolicy RetryPolicy) *Transport {
+	return &Transport{
+		Base:           base,
+		CircuitBreaker: circuitBreaker,
+		RetryPolicy:    retryPolicy,
+	}
+}
+
+func (transport *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	maxAttempts := transport.RetryPolicy.MaxAttempts
+	if !isIdempotent(request) || maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var response *http.Response
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			// Discard the previous failed response so that its connection can be
+			// reused.
+			if response != nil {
+				io.Copy(io.Discard, response.Body)
+				response.Body.Close()
+			}
+
+			timer := time.NewTimer(transport.RetryPolicy.backoff(attempt))
+			select {
+			case <-request.Context().Done():
+				timer.Stop()
+				return nil, request.Context().Err()
+			case <-timer.C:
+			}
+		}
+
+		response, err = transport.roundTripOnce(request)
+		if !isRetryable(response, err) || errors.Is(err, ErrCircuitOpen) ||
+			request.Context().Err() != nil {
+			break
+		}
+	}
+
+	return response, err
+}
+
+func (transport *Transport) roundTripOnce(request *http.Request) (*http.Response, error) {
+	if transport.CircuitBreaker != nil {
+		err := transport.CircuitBreaker.Allow()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	base := transport.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	response, err := base.RoundTrip(request)
+
+	if transport.CircuitBreaker != nil {
+		if request.Context().Err() != nil {
+			transport.CircuitBreaker.release()
+		} else if isRetryable(response, err) {
+			transport.CircuitBreaker.RecordFailure()
+		} else {
+			transport.CircuitBreaker.RecordSuccess()
+		}
+	}
+
+	return response, err
+}
+
+func (retryPolicy RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := retryPolicy.MaxDelay
+	// Guard against overflow for large attempt numbers.
+	if attempt < 32 {
+		exponential := retryPolicy.BaseDelay * (1 << attempt)
+		if exponential > 0 && exponential < ceiling {
+			ceiling = exponential
+		}
+	}
+
+	if ceiling <= 0 {
+		return 0
+	}
+
+	jitterRandMutex.Lock()
+	defer jitterRandMutex.Unlock()
+
+	return time.Duration(jitterRand.Int63n(int64(ceiling)))
+}
+
+func isIdempotent(request *http.Request) bool {
+	return (request.Method == http.MethodGet || request.Method == http.MethodHead || request.Method == "") &&
+		(request.Body == nil || request.Body == http.NoBody)
+}
+
+func isRetryable(response *http.Response, err error) bool {
+	return err != nil || response.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,152 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	BaseDelay:   time.Millisecond,
+	MaxAttempts: 3,
+	MaxDelay:    5 * time.Millisecond,
+}
+
+func TestTransport(t *testing.T) {
+	testCases := []struct {
+		name                string
+		method              string
+		statusCodes         []int
+		expectedNumRequests int32
+		expectedStatusCode  int
+	}{
+		{"Success", http.MethodGet, []int{200}, 1, 200},
+		{"Retries 5xx", http.MethodGet, []int{502, 503, 200}, 3, 200},
+		{"Gives up after max attempts", http.MethodGet, []int{500, 500, 500, 200}, 3, 500},
+		{"Does not retry 4xx", http.MethodGet, []int{404, 200}, 1, 404},
+		{"Does not retry non-idempotent requests", http.MethodPost, []int{500, 200}, 1, 500},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var numRequests int32
+			fakeServer := httptest.NewServer(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					i := atomic.AddInt32(&numRequests, 1) - 1
+					w.WriteHeader(testCase.statusCodes[i])
+				}),
+			)
+			defer fakeServer.Close()
+
+			client := &http.Client{
+				Transport: NewTransport(http.DefaultTransport, NewCircuitBreaker("Test", nil), testRetryPolicy),
+			}
+
+			request, err := http.NewRequest(testCase.method, fakeServer.URL, nil)
+			if err != nil {
+				t.Fatalf("Error creating new HTTP request: %s", err)
+			}
+
+			response, err := client.Do(request)
+			if err != nil {
+				t.Fatalf("Request returned error '%v', expecting no error", err)
+			}
+			response.Body.Close()
+
+			if response.StatusCode != testCase.expectedStatusCode {
+				t.Errorf("Expected status %d, got %d", testCase.expectedStatusCode, response.StatusCode)
+			}
+			if numRequests != testCase.expectedNumRequests {
+				t.Errorf("Expected %d requests, got %d", testCase.expectedNumRequests, numRequests)
+			}
+		})
+	}
+}
+
+func TestTransportCircuitBreaker(t *testing.T) {
+	var numRequests int32
+	fakeServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&numRequests, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}),
+	)
+	defer fakeServer.Close()
+
+	breaker := NewCircuitBreaker("Test", nil)
+	breaker.FailureThreshold = 2
+	client := &http.Client{
+		Transport: NewTransport(http.DefaultTransport, breaker, testRetryPolicy),
+	}
+
+	// The breaker trips on the second attempt, so the third attempt is never made.
+	response, err := client.Get(fakeServer.URL)
+	if err == nil {
+		response.Body.Close()
+		t.Fatal("Request returned no error, expecting ErrCircuitOpen")
+	}
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Request returned error '%v', expecting ErrCircuitOpen", err)
+	}
+	if numRequests != 2 {
+		t.Errorf("Expected 2 requests, got %d", numRequests)
+	}
+	if breaker.State() != StateOpen {
+		t.Errorf("Expected breaker state '%s', got '%s'", StateOpen, breaker.State())
+	}
+}
+
+func TestTransportCancelledRequest(t *testing.T) {
+	fakeServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}),
+	)
+	defer fakeServer.Close()
+
+	breaker := NewCircuitBreaker("Test", nil)
+	breaker.FailureThreshold = 1
+	client := &http.Client{
+		Transport: NewTransport(http.DefaultTransport, breaker, testRetryPolicy),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fakeServer.URL, nil)
+	if err != nil {
+		t.Fatalf("Error creating new HTTP request: %s", err)
+	}
+
+	_, err = client.Do(request)
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("Request returned error '%v', expecting deadline exceeded", err)
+	}
+
+	// The caller giving up says nothing about the health of the server.
+	if breaker.State() != StateClosed {
+		t.Errorf("Expected breaker state '%s', got '%s'", StateClosed, breaker.State())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	retryPolicy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt := 1; attempt < 64; attempt++ {
+		ceiling := retryPolicy.MaxDelay
+		if attempt < 4 {
+			ceiling = retryPolicy.BaseDelay * (1 << attempt)
+		}
+
+		for i := 0; i < 100; i++ {
+			backoff := retryPolicy.backoff(attempt)
+			if backoff < 0 || backoff >= ceiling {
+				t.Fatalf("backoff(%d) returned %s, expecting a value in [0, %s)", attempt, backoff, ceiling)
+			}
+		}
+	}
+}
@@ -1,14 +1,104 @@
 package sfx
 
+import (
+	"ariadne/log"
+	"ariadne/resilience"
+	"ariadne/upstream"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
 // SFX service URL
 const DefaultSFXURL = "http://sfx.library.nyu.edu/sfxlcl41"
 
-var sfxURL = DefaultSFXURL
+// Default timeout for each individual HTTP request made to SFX.
+const DefaultTimeout = 20 * time.Second
+
+const messageKey = "message"
+
+// Called after each HTTP request to SFX completes, e.g. to record metrics.
+// `err` is non-nil if the request failed, returned a non-2xx status, or could not
+// be parsed.
+type RequestObserver func(duration time.Duration, err error)
+
+type ClientOptions struct {
+	// Defaults to an HTTP client returned by `NewHTTPClient(DefaultTimeout, nil)`
+	// which is shared by all clients that don't set one, so that connections to
+	// SFX are pooled.
+	HTTPClient *http.Client
+	// Defaults to `log.Default()`.
+	Logger *log.Logger
+	// Optional
+	RequestObserver RequestObserver
+}
+
+// A single SFX instance.  Institutions that share an SFX server have separate
+// instances, which are distinguished by URL path.
+type Client struct {
+	url             string
+	httpClient      *http.Client
+	logger          *log.Logger
+	requestObserver RequestObserver
+}
+
+var defaultHTTPClient = NewHTTPClient(DefaultTimeout, nil)
+
+func NewClient(url string, options ClientOptions) *Client {
+	client := &Client{
+		url:             url,
+		httpClient:      options.HTTPClient,
+		logger:          options.Logger,
+		requestObserver: options.RequestObserver,
+	}
+	if client.httpClient == nil {
+		client.httpClient = defaultHTTPClient
+	}
+	if client.logger == nil {
+		client.logger = log.Default()
+	}
+
+	return client
+}
+
+// Returns the circuit breaker of the client's HTTP client, or nil if it doesn't
+// have one.
+func (client *Client) CircuitBreaker() *resilience.CircuitBreaker {
+	return upstream.CircuitBreaker(client.httpClient)
+}
+
+func (client *Client) NewRequest(queryString string) (*SFXRequest, error) {
+	return newSFXRequest(client.url, queryString)
+}
+
+// The request is cancelled if `ctx` is done before it completes.
+func (client *Client) Do(ctx context.Context, request *SFXRequest) (*SFXResponse, error) {
+	start := time.Now()
+	sfxResponse, err := request.do(ctx, client.httpClient)
+	if client.requestObserver != nil {
+		client.requestObserver(time.Since(start), err)
+	}
+	if err != nil {
+		client.logger.Debug(messageKey,
+			fmt.Sprintf("SFX request to %s failed after %s: %v", client.url, time.Since(start), err))
+	}
+
+	return sfxResponse, err
+}
+
+// Checks that SFX is reachable, for readiness probes.  See `resilience.Probe`.
+func (client *Client) Ping(ctx context.Context) error {
+	return resilience.Probe(ctx, client.httpClient, client.url)
+}
 
-func Do(request *SFXRequest) (*SFXResponse, error) {
-	return request.do()
+func (client *Client) URL() string {
+	return client.url
 }
 
-func SetSFXURL(dependencyInjectedURL string) {
-	sfxURL = dependencyInjectedURL
+// Returns an HTTP client suitable for use in `ClientOptions`.  SFX clients which
+// share the returned client also share its circuit breaker.  See
+// `upstream.NewHTTPClient`.
+func NewHTTPClient(timeout time.Duration, logger *log.Logger) *http.Client {
+	return upstream.NewHTTPClient("SFX", timeout, logger)
 }
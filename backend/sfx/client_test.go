@@ -0,0 +1,198 @@
+package sfx
+
+import (
+	"ariadne/log"
+	"ariadne/upstream"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+
+	// The fake never responds, so requests can only complete by timing out or
+	// being cancelled.
+	fakeSFXServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}),
+	)
+	defer fakeSFXServer.Close()
+
+	cancelledContext, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name          string
+		ctx           context.Context
+		client        *http.Client
+		expectedError error
+	}{
+		{"Client timeout", context.Background(), NewHTTPClient(50*time.Millisecond, nil), nil},
+		{"Cancelled context", cancelledContext, NewHTTPClient(DefaultTimeout, nil), context.Canceled},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := NewClient(fakeSFXServer.URL, ClientOptions{HTTPClient: testCase.client})
+			request, err := client.NewRequest("isbn=9780198129103")
+			if err != nil {
+				t.Fatalf("NewRequest returned an error: %s", err)
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := client.Do(testCase.ctx, request)
+				done <- err
+			}()
+
+			select {
+			case err = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Do did not return")
+			}
+
+			if err == nil {
+				t.Fatal("Do returned no error, expecting an error")
+			}
+
+			if !strings.HasPrefix(err.Error(), "Could not do request to SFX server") {
+				t.Errorf("Do returned unexpected error: %s", err)
+			}
+
+			if testCase.expectedError != nil && !strings.Contains(err.Error(), testCase.expectedError.Error()) {
+				t.Errorf("Do returned error '%s', expecting it to wrap '%s'",
+					err, testCase.expectedError)
+			}
+		})
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	t.Parallel()
+
+	transportError := errors.New("fake transport")
+	var logOutput bytes.Buffer
+	client := NewClient(DefaultSFXURL, ClientOptions{
+		HTTPClient: &http.Client{Transport: fakeTransport{transportError}},
+		Logger:     log.New(&logOutput, log.LevelDebug),
+	})
+
+	request, err := client.NewRequest("isbn=9780198129103")
+	if err != nil {
+		t.Fatalf("NewRequest returned an error: %s", err)
+	}
+
+	_, err = client.Do(context.Background(), request)
+	if err == nil || !strings.Contains(err.Error(), transportError.Error()) {
+		t.Errorf("Do did not use the injected HTTP client: got error '%v'", err)
+	}
+	if !strings.Contains(logOutput.String(), "SFX request to "+DefaultSFXURL+" failed") {
+		t.Errorf("Do did not log the failure to the injected logger: got '%s'", logOutput.String())
+	}
+	if client.CircuitBreaker() != nil {
+		t.Errorf("CircuitBreaker returned a circuit breaker for an HTTP client without one")
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	sharedHTTPClient := NewHTTPClient(DefaultTimeout, nil)
+	client1 := NewClient(DefaultSFXURL, ClientOptions{HTTPClient: sharedHTTPClient})
+	client2 := NewClient(DefaultSFXURL, ClientOptions{HTTPClient: sharedHTTPClient})
+	client3 := NewClient(DefaultSFXURL, ClientOptions{HTTPClient: NewHTTPClient(DefaultTimeout, nil)})
+
+	if client1.CircuitBreaker() == nil || client1.CircuitBreaker() != client2.CircuitBreaker() {
+		t.Errorf("Expected clients sharing an HTTP client to share its circuit breaker")
+	}
+	if client1.CircuitBreaker() == client3.CircuitBreaker() {
+		t.Errorf("Expected clients with different HTTP clients to have different circuit breakers")
+	}
+}
+
+// Fails every request with `err`.
+type fakeTransport struct {
+	err error
+}
+
+func (transport fakeTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, transport.err
+}
+
+func TestErrorKinds(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name               string
+		handler            http.HandlerFunc
+		client             *http.Client
+		expectedKind       upstream.ErrorKind
+		expectedStatusCode int
+	}{
+		{
+			"Timeout",
+			func(w http.ResponseWriter, r *http.Request) { <-r.Context().Done() },
+			NewHTTPClient(50*time.Millisecond, nil),
+			upstream.ErrorKindTimeout,
+			0,
+		},
+		{
+			"Upstream status",
+			func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Not Found", http.StatusNotFound)
+			},
+			NewHTTPClient(DefaultTimeout, nil),
+			upstream.ErrorKindUpstreamStatus,
+			http.StatusNotFound,
+		},
+		{
+			"Parse",
+			func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<ctx_obj_set>")) },
+			NewHTTPClient(DefaultTimeout, nil),
+			upstream.ErrorKindParse,
+			0,
+		},
+		{
+			"Empty context object",
+			func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<ctx_obj_set></ctx_obj_set>")) },
+			NewHTTPClient(DefaultTimeout, nil),
+			ErrorKindEmptyContextObject,
+			0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fakeSFXServer := httptest.NewServer(testCase.handler)
+			defer fakeSFXServer.Close()
+
+			client := NewClient(fakeSFXServer.URL, ClientOptions{HTTPClient: testCase.client})
+			request, err := client.NewRequest("isbn=9780198129103")
+			if err != nil {
+				t.Fatalf("NewRequest returned an error: %s", err)
+			}
+
+			_, err = client.Do(context.Background(), request)
+
+			var sfxError *upstream.Error
+			if !errors.As(err, &sfxError) {
+				t.Fatalf("Do returned error '%v', expecting an *upstream.Error", err)
+			}
+			if sfxError.Kind != testCase.expectedKind {
+				t.Errorf("Do returned error kind '%s', expecting '%s'",
+					sfxError.Kind, testCase.expectedKind)
+			}
+			if sfxError.StatusCode != testCase.expectedStatusCode {
+				t.Errorf("Do returned error status code %d, expecting %d",
+					sfxError.StatusCode, testCase.expectedStatusCode)
+			}
+		})
+	}
+}
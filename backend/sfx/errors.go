@@ -0,0 +1,7 @@
+package sfx
+
+import "ariadne/upstream"
+
+// The SFX response did not contain a context object.  All failures to get a
+// usable response from SFX are returned as `*upstream.Error`s.
+const ErrorKindEmptyContextObject upstream.ErrorKind = "empty_context_object"
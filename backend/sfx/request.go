@@ -1,27 +1,48 @@
 package sfx
 
 import (
+	"ariadne/openurl"
+	"ariadne/tracing"
+	"ariadne/upstream"
+	"context"
 	_ "embed"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 )
 
 type SFXRequest struct {
+	ContextObject     *openurl.ContextObject
 	DumpedHTTPRequest string
 	HTTPRequest       http.Request
 }
 
-func (c SFXRequest) do() (*SFXResponse, error) {
-	client := http.Client{}
-	response, err := client.Do(&c.HTTPRequest)
+// The request is made in its own trace span, which is propagated to SFX.
+func (c SFXRequest) do(ctx context.Context, client *http.Client) (sfxResponse *SFXResponse, err error) {
+	ctx, span := tracing.StartSpan(ctx, "SFX request", tracing.SpanKindClient)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	span.SetAttribute("server.address", c.HTTPRequest.URL.Host)
+
+	// Cloned so that the traceparent header isn't added to the request that's
+	// kept for logging.
+	httpRequest := c.HTTPRequest.Clone(ctx)
+	tracing.Inject(ctx, httpRequest.Header)
+
+	response, err := client.Do(httpRequest)
 	if err != nil {
-		return &SFXResponse{}, fmt.Errorf("Could not do request to SFX server: %v", err)
+		return &SFXResponse{}, upstream.NewRequestError(err, "Could not do request to SFX server: %w")
 	}
 	defer response.Body.Close()
 
-	sfxResponse, err := newSFXResponse(response)
+	span.SetAttribute("http.response.status_code", response.StatusCode)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return &SFXResponse{}, upstream.NewStatusError("SFX", response.StatusCode, response.Status)
+	}
+
+	sfxResponse, err = newSFXResponse(response)
 	if err != nil {
 		return sfxResponse, err
 	}
@@ -29,15 +50,17 @@ func (c SFXRequest) do() (*SFXResponse, error) {
 	return sfxResponse, nil
 }
 
-func NewSFXRequest(queryString string) (*SFXRequest, error) {
+func newSFXRequest(sfxURL string, queryString string) (*SFXRequest, error) {
 	sfxRequest := &SFXRequest{}
 
-	queryStringValues, err := url.ParseQuery(queryString)
+	contextObject, err := openurl.Parse(queryString)
 	if err != nil {
 		return sfxRequest, err
 	}
 
-	httpRequest, err := newSFXHTTPRequest(queryStringValues)
+	sfxRequest.ContextObject = contextObject
+
+	httpRequest, err := newSFXHTTPRequest(sfxURL, contextObject)
 	if err != nil {
 		return sfxRequest, fmt.Errorf("Could not create new SFX request: %v", err)
 	}
@@ -57,41 +80,29 @@ func NewSFXRequest(queryString string) (*SFXRequest, error) {
 	return sfxRequest, nil
 }
 
-// Transforms `sid` to `rfr_id` since the former seems to trigger SFX errors when
-// its value contains certain unicode encodings, while the latter doesn't.
-// Example of such a request:
-//
-//	http://sfx.library.nyu.edu/sfxlcl41?genre=article&isbn=&issn=19447485&title=Community%20Development&volume=49&issue=5&date=20181020&atitle=Can%20community%20task%20groups%20learn%20from%20the%20principles%20of%20group%20therapy?&aulast=Zanbar,%20L.&spage=574&sid=EBSCO:Scopus\\u00ae&pid=Zanbar,%20L.edselc.2-52.0-8505573399120181020Scopus\\u00ae
-func filterOpenURLParams(queryStringValues url.Values) url.Values {
-	// If no `sid`, we do nothing
-	sid := queryStringValues.Get("sid")
-	if sid == "" {
-		return queryStringValues
-	}
-
-	// Replace `sid` with `rfr_id`
-	queryStringValues.Del("sid")
-	queryStringValues.Add("rfr_id", sid)
-
-	return queryStringValues
-}
-
-func noOpenURLTimeParams(params url.Values) bool {
-	return !(params.Get("date") != "" || params.Get("rft.date") != "" || params.Get("year") != "" || params.Get("rft.year") != "")
+func noOpenURLTimeParams(contextObject *openurl.ContextObject) bool {
+	return contextObject.Referent.Date == ""
 }
 
-func noOpenURLIdentifiers(params url.Values) bool {
-	return !(params.Get("doi") != "" || params.Get("rft.doi") != "" || params.Get("pmid") != "" || params.Get("rft.pmid") != "")
+func noOpenURLIdentifiers(contextObject *openurl.ContextObject) bool {
+	return contextObject.Referent.DOI == "" && contextObject.Referent.PMID == ""
 }
 
-func newSFXHTTPRequest(queryStringValues url.Values) (*http.Request, error) {
-	params := filterOpenURLParams(queryStringValues)
+// The context object is sent to SFX in its canonical OpenURL 1.0 KEV form.
+// Note that this transforms `sid` to `rfr_id`, which is required since the former
+// seems to trigger SFX errors when its value contains certain unicode encodings,
+// while the latter doesn't.
+// Example of such a request:
+//
+//	http://sfx.library.nyu.edu/sfxlcl41?genre=article&isbn=&issn=19447485&title=Community%20Development&volume=49&issue=5&date=20181020&atitle=Can%20community%20task%20groups%20learn%20from%20the%20principles%20of%20group%20therapy?&aulast=Zanbar,%20L.&spage=574&sid=EBSCO:Scopus\\u00ae&pid=Zanbar,%20L.edselc.2-52.0-8505573399120181020Scopus\\u00ae
+func newSFXHTTPRequest(sfxURL string, contextObject *openurl.ContextObject) (*http.Request, error) {
+	params := contextObject.KEV()
 
 	// Add SFX query params
 	params.Add("url_ctx_fmt", "info:ofi/fmt:xml:xsd:ctx")
 	params.Add("sfx.response_type", "multi_obj_xml")
 	// Do we always need these parameters? Umlaut adds them only in certain conditions: https://github.com/team-umlaut/umlaut/blob/b954895e0aa0a7cd0a9ec6bb716c1886c813601e/app/service_adaptors/sfx.rb#L145-L153
-	if noOpenURLTimeParams(params) && noOpenURLIdentifiers(params) {
+	if noOpenURLTimeParams(contextObject) && noOpenURLIdentifiers(contextObject) {
 		params.Add("sfx.show_availability", "1")
 		params.Add("sfx.ignore_date_threshold", "1")
 	}
@@ -3,12 +3,10 @@ package sfx
 import (
 	"ariadne/testutils"
 	"fmt"
-	"net/url"
-	"reflect"
 	"testing"
 )
 
-func TestNewSFXRequest(t *testing.T) {
+func TestNewRequest(t *testing.T) {
 	var tests = []struct {
 		name                      string
 		expectedDumpedHTTPRequest string
@@ -17,31 +15,42 @@ func TestNewSFXRequest(t *testing.T) {
 	}{
 		{
 			// This request as-is was causing SFX to return a "XSS violation occured [sic]."
-			// error.  Ariadne currently remediates by replacing `sid` with `rfr_id` (set to `sid` value).
+			// error.  Ariadne currently remediates by replacing `sid` with `rfr_id` (set to `sid` value),
+			// which the canonical OpenURL 1.0 KEV serialization does.
 			// We do not know exactly how/why this appears to eliminate the error,
 			// but somehow it does.
 			// NOTE: This is the can-community-task-groups-learn-from-the-principles-of-group-therapy
 			// test case from backend/testutils/testdata/test-cases.json.
 			name: "Trouble-causing `sid`",
-			expectedDumpedHTTPRequest: `GET /sfxlcl41?atitle=Can+community+task+groups+learn+from+the+principles+of+group+therapy%3F&aulast=Zanbar%2C+L.&date=20181020&genre=article&isbn=&issn=19447485&issue=5&pid=Zanbar%2C+L.edselc.2-52.0-8505573399120181020Scopus%5C%C2%AE&rfr_id=EBSCO%3AScopus%5C%C2%AE&sfx.doi_url=http%3A%2F%2Fdx.doi.org&sfx.response_type=multi_obj_xml&spage=574&title=Community+Development&url_ctx_fmt=info%3Aofi%2Ffmt%3Axml%3Axsd%3Actx&volume=49 HTTP/1.1
+			expectedDumpedHTTPRequest: `GET /sfxlcl41?ctx_ver=Z39.88-2004&pid=Zanbar%2C+L.edselc.2-52.0-8505573399120181020Scopus%5C%C2%AE&rfr_id=EBSCO%3AScopus%5C%C2%AE&rft.atitle=Can+community+task+groups+learn+from+the+principles+of+group+therapy%3F&rft.aulast=Zanbar%2C+L.&rft.date=20181020&rft.genre=article&rft.issn=1944-7485&rft.issue=5&rft.spage=574&rft.title=Community+Development&rft.volume=49&rft_val_fmt=info%3Aofi%2Ffmt%3Akev%3Amtx%3Ajournal&sfx.doi_url=http%3A%2F%2Fdx.doi.org&sfx.response_type=multi_obj_xml&url_ctx_fmt=info%3Aofi%2Ffmt%3Axml%3Axsd%3Actx&url_ver=Z39.88-2004 HTTP/1.1
 Host: sfx.library.nyu.edu`,
 			expectedError: nil,
 			queryString:   "genre=article&isbn=&issn=19447485&title=Community%20Development&volume=49&issue=5&date=20181020&atitle=Can%20community%20task%20groups%20learn%20from%20the%20principles%20of%20group%20therapy?&aulast=Zanbar,%20L.&spage=574&sid=EBSCO:Scopus\\®&pid=Zanbar,%20L.edselc.2-52.0-8505573399120181020Scopus\\®",
 		},
 		{
-			// This is the `history-today` test case.
+			// This is the `history-today` test case.  Empty params are dropped
+			// from the canonical OpenURL 1.0 KEV serialization.
 			name: "`date` query param value is empty",
-			expectedDumpedHTTPRequest: `GET /sfxlcl41?atitle=&aulast=&date=&genre=article&isbn=&issn=00182753&issue=&pid=Academic+Search+Complete+--+Publications&rfr_id=EBSCO%3AAcademic+Search+Complete+--+Publications&sfx.doi_url=http%3A%2F%2Fdx.doi.org&sfx.ignore_date_threshold=1&sfx.response_type=multi_obj_xml&sfx.show_availability=1&spage=&title=History+Today&url_ctx_fmt=info%3Aofi%2Ffmt%3Axml%3Axsd%3Actx&volume= HTTP/1.1
+			expectedDumpedHTTPRequest: `GET /sfxlcl41?ctx_ver=Z39.88-2004&pid=Academic+Search+Complete+--+Publications&rfr_id=EBSCO%3AAcademic+Search+Complete+--+Publications&rft.genre=article&rft.issn=0018-2753&rft.title=History+Today&rft_val_fmt=info%3Aofi%2Ffmt%3Akev%3Amtx%3Ajournal&sfx.doi_url=http%3A%2F%2Fdx.doi.org&sfx.ignore_date_threshold=1&sfx.response_type=multi_obj_xml&sfx.show_availability=1&url_ctx_fmt=info%3Aofi%2Ffmt%3Axml%3Axsd%3Actx&url_ver=Z39.88-2004 HTTP/1.1
 Host: sfx.library.nyu.edu`,
 			expectedError: nil,
 			queryString:   "genre=article&isbn=&issn=00182753&title=History%20Today&volume=&issue=&date=&atitle=&aulast=&spage=&sid=EBSCO:Academic%20Search%20Complete%20--%20Publications&pid=Academic%20Search%20Complete%20--%20Publications",
 		},
+		{
+			// All the values of repeated fields and identifiers are sent, not just
+			// the first.
+			name: "Two `rft_id`s and two ISSNs",
+			expectedDumpedHTTPRequest: `GET /sfxlcl41?ctx_ver=Z39.88-2004&rft.genre=article&rft.issn=0028-792X&rft.issn=2163-3827&rft_id=info%3Adoi%2F10.1037%2Fa0021867&rft_id=info%3Apmid%2F18509570&rft_id=info%3Apmid%2F18509571&rft_val_fmt=info%3Aofi%2Ffmt%3Akev%3Amtx%3Ajournal&sfx.doi_url=http%3A%2F%2Fdx.doi.org&sfx.response_type=multi_obj_xml&url_ctx_fmt=info%3Aofi%2Ffmt%3Axml%3Axsd%3Actx&url_ver=Z39.88-2004 HTTP/1.1
+Host: sfx.library.nyu.edu`,
+			expectedError: nil,
+			queryString:   "rft.genre=article&rft.issn=0028-792X&rft.issn=2163-3827&rft_id=info:pmid/18509570&rft_id=info:pmid/18509571&rft_id=info:doi/10.1037/a0021867",
+		},
 		// These unit tests were originally written when Ariadne was making POST
 		// requests to the SFX API, with complicated query string params validation
 		// and massaging and a somewhat brittle XML request body.  There were plenty
 		// of error conditions to test for.
 		// It is extremely difficult and perhaps impossible to cause the current
-		// `NewSFXRequest` code to return an error.  Should we need to write unit
+		// `Client.NewRequest` code to return an error.  Should we need to write unit
 		// tests to check for errors, we would use test cases with this structure:
 		//
 		// {
@@ -52,16 +61,18 @@ Host: sfx.library.nyu.edu`,
 		// },
 	}
 
+	client := NewClient(DefaultSFXURL, ClientOptions{})
+
 	for _, testCase := range tests {
 		testName := fmt.Sprintf("%s", testCase.queryString)
 		t.Run(testName, func(t *testing.T) {
-			sfxRequest, err := NewSFXRequest(testCase.queryString)
+			sfxRequest, err := client.NewRequest(testCase.queryString)
 			if testCase.expectedDumpedHTTPRequest != "" {
 				expected := testutils.NormalizeDumpedHTTPRequest(testCase.expectedDumpedHTTPRequest)
 				got := testutils.NormalizeDumpedHTTPRequest(sfxRequest.DumpedHTTPRequest)
 				if got != expected {
 					t.Errorf(
-						"NewSFXRequest returned an SFXRequest with incorrect DumpedHTTPRequest string for '%s': "+
+						"NewRequest returned an SFXRequest with incorrect DumpedHTTPRequest string for '%s': "+
 							"expected '%s', got '%s'",
 						testCase.name,
 						expected,
@@ -71,33 +82,13 @@ Host: sfx.library.nyu.edu`,
 			}
 			if testCase.expectedError != nil {
 				if err == nil {
-					t.Errorf("NewSFXRequest returned no error, expecting '%v'", testCase.expectedError)
+					t.Errorf("NewRequest returned no error, expecting '%v'", testCase.expectedError)
 				} else if err.Error() != testCase.expectedError.Error() {
-					t.Errorf("NewSFXRequest returned error '%v', expecting '%v'", err, testCase.expectedError)
+					t.Errorf("NewRequest returned error '%v', expecting '%v'", err, testCase.expectedError)
 				}
 			}
 			if err != nil && testCase.expectedError == nil {
-				t.Errorf("NewSFXRequest returned error '%v', expecting no errors", err)
-			}
-		})
-	}
-}
-
-func TestFilterOpenURLParams(t *testing.T) {
-	var testCases = []struct {
-		testName    string
-		queryString url.Values
-		expected    url.Values
-	}{
-		{"query string contains sid", map[string][]string{"sid": {"unicode+garbage+EBSCO:Scopus\\u00ae"}}, map[string][]string{"rfr_id": {"unicode+garbage+EBSCO:Scopus\\u00ae"}}},
-		{"query string doesn't contain sid", map[string][]string{"id": {"unicode+garbage+EBSCO:Scopus\\u00ae"}}, map[string][]string{"id": {"unicode+garbage+EBSCO:Scopus\\u00ae"}}},
-	}
-
-	for _, testCase := range testCases {
-		t.Run(testCase.testName, func(t *testing.T) {
-			actual := filterOpenURLParams(testCase.queryString)
-			if !reflect.DeepEqual(actual, testCase.expected) {
-				t.Errorf("filterOpenURLParams returned '%v', expecting '%v'", actual, testCase.expected)
+				t.Errorf("NewRequest returned error '%v', expecting no errors", err)
 			}
 		})
 	}
@@ -1,12 +1,14 @@
 package sfx
 
 import (
+	"ariadne/upstream"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"strings"
 )
 
@@ -26,7 +28,7 @@ type XMLResponseBody struct {
 }
 
 type ContextObject struct {
-	// SFXContextObjectAttrs   string
+	SFXContextObjectAttrs   string                  `xml:"ctx_obj_attributes" json:"ctx_obj_attributes,omitempty"`
 	SFXContextObjectTargets *[]ContextObjectTargets `xml:"ctx_obj_targets" json:"ctx_obj_targets"`
 }
 
@@ -71,6 +73,7 @@ type Coverage struct {
 
 type Embargo struct {
 	Availability string `xml:"availability" json:"availability,omitempty"`
+	Year         string `xml:"year" json:"year,omitempty"`
 	Month        string `xml:"month" json:"month,omitempty"`
 	Days         string `xml:"days" json:"days,omitempty"`
 }
@@ -96,10 +99,45 @@ type ThresholdText struct {
 	CoverageStatement []string `xml:"coverage_statement" json:"coverage_statement,omitempty"`
 }
 
+// The <ctx_obj_attributes> element contains an escaped Perl data structure
+// dump of the context object as SFX understands it, which includes data that
+// SFX has added from its knowledgebase (normalized ISSNs, article titles from
+// PMIDs, etc.).  Example:
+//
+//	<perldata>
+//	 <hash>
+//	  <item key="rft.issn">0028-792X</item>
+//	  <item key="@rft.aulast">
+//	   <array>
+//	    <item key="0">Ross</item>
+//	   </array>
+//	  </item>
+//	 </hash>
+//	</perldata>
+type perldata struct {
+	Hash perldataHash `xml:"hash"`
+}
+
+type perldataArray struct {
+	Items []perldataItem `xml:"item"`
+}
+
+type perldataHash struct {
+	Items []perldataItem `xml:"item"`
+}
+
+type perldataItem struct {
+	Key   string         `xml:"key,attr"`
+	Value string         `xml:",chardata"`
+	Array *perldataArray `xml:"array"`
+	Hash  *perldataHash  `xml:"hash"`
+}
+
 const AskALibrarianLink = "http://library.nyu.edu/ask/"
 const ILLLink = "ill.library.nyu.edu"
 
-func (sfxResponse *SFXResponse) RemoveTarget(targetURL string) {
+// Removes all targets with the given targetURL, and returns the number removed.
+func (sfxResponse *SFXResponse) RemoveTarget(targetURL string) int {
 	currentTargets := (*(*sfxResponse.XMLResponseBody.ContextObject)[0].SFXContextObjectTargets)[0].Targets
 	var newTargets []Target
 	for _, target := range *currentTargets {
@@ -108,6 +146,8 @@ func (sfxResponse *SFXResponse) RemoveTarget(targetURL string) {
 		}
 	}
 	(*(*sfxResponse.XMLResponseBody.ContextObject)[0].SFXContextObjectTargets)[0].Targets = &newTargets
+
+	return len(*currentTargets) - len(newTargets)
 }
 
 // returns Target matching given targetURL
@@ -121,6 +161,48 @@ func (sfxResponse *SFXResponse) GetTarget(targetURL string) *Target {
 	return nil
 }
 
+// Returns the top-level context object attributes from the <perldata> hash in
+// <ctx_obj_attributes>.  SFX prefixes the keys of array values with "@" -- that
+// prefix is removed, so for example both "rft.aulast" and "@rft.aulast" end up
+// under "rft.aulast".  Nested hashes (e.g. "_stash") and empty values are skipped.
+func (sfxResponse *SFXResponse) ContextObjectAttributes() (url.Values, error) {
+	attributes := url.Values{}
+
+	contextObjects := sfxResponse.XMLResponseBody.ContextObject
+	if contextObjects == nil || len(*contextObjects) == 0 {
+		return attributes, nil
+	}
+
+	contextObjectAttrs := strings.TrimSpace((*contextObjects)[0].SFXContextObjectAttrs)
+	if contextObjectAttrs == "" {
+		return attributes, nil
+	}
+
+	var perldata perldata
+	if err := xml.Unmarshal([]byte(contextObjectAttrs), &perldata); err != nil {
+		return attributes, fmt.Errorf("Could not parse SFX context object attributes: %v", err)
+	}
+
+	for _, item := range perldata.Hash.Items {
+		if item.Hash != nil {
+			continue
+		}
+
+		key := strings.TrimPrefix(item.Key, "@")
+		if item.Array != nil {
+			for _, arrayItem := range item.Array.Items {
+				if arrayItem.Value != "" {
+					attributes.Add(key, arrayItem.Value)
+				}
+			}
+		} else if item.Value != "" {
+			attributes.Add(key, item.Value)
+		}
+	}
+
+	return attributes, nil
+}
+
 func (sfxResponse *SFXResponse) IsFound() bool {
 	targets := (*(*sfxResponse.XMLResponseBody.ContextObject)[0].SFXContextObjectTargets)[0].Targets
 
@@ -154,24 +236,27 @@ func newSFXResponse(httpResponse *http.Response) (*SFXResponse, error) {
 
 	dumpedHTTPResponse, err := httputil.DumpResponse(httpResponse, true)
 	if err != nil {
-		return sfxResponse, fmt.Errorf("Could not dump HTTP response: %v", err)
+		return sfxResponse, upstream.NewRequestError(err, "Could not dump HTTP response: %w")
 	}
 	sfxResponse.DumpedHTTPResponse = string(dumpedHTTPResponse)
 
 	body, err := io.ReadAll(httpResponse.Body)
 	if err != nil {
-		return sfxResponse, fmt.Errorf("Could not read response from SFX server: %v", err)
+		return sfxResponse, upstream.NewRequestError(err, "Could not read response from SFX server: %w")
 	}
 
 	sfxResponse.XML = string(body)
 
 	var xmlResponseBody XMLResponseBody
 	if err = xml.Unmarshal(body, &xmlResponseBody); err != nil {
-		return sfxResponse, err
+		return sfxResponse, &upstream.Error{Kind: upstream.ErrorKindParse, Err: err}
 	}
 
 	if xmlResponseBody.ContextObject == nil {
-		return sfxResponse, fmt.Errorf("Could not identify context object in response XML: %s", sfxResponse.XML)
+		return sfxResponse, &upstream.Error{
+			Kind: ErrorKindEmptyContextObject,
+			Err:  fmt.Errorf("Could not identify context object in response XML: %s", sfxResponse.XML),
+		}
 	}
 
 	sfxResponse.XMLResponseBody = xmlResponseBody
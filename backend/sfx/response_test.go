@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
@@ -23,6 +25,34 @@ const dummyGoodXMLResponse = `
 	</ctx_obj>
 </ctx_obj_set>`
 
+const dummyXMLResponseWithContextObjectAttributes = `
+<ctx_obj_set>
+	<ctx_obj>
+		<ctx_obj_attributes>&lt;perldata&gt;
+ &lt;hash&gt;
+  &lt;item key="rft.issn"&gt;0028-792X&lt;/item&gt;
+  &lt;item key="rft.volume"&gt;&lt;/item&gt;
+  &lt;item key="@rft.aulast"&gt;
+   &lt;array&gt;
+    &lt;item key="0"&gt;Ross&lt;/item&gt;
+    &lt;item key="1"&gt;&lt;/item&gt;
+   &lt;/array&gt;
+  &lt;/item&gt;
+  &lt;item key="_stash"&gt;
+   &lt;hash&gt;
+    &lt;item key="rft.issn"&gt;9999-9999&lt;/item&gt;
+   &lt;/hash&gt;
+  &lt;/item&gt;
+ &lt;/hash&gt;
+&lt;/perldata&gt;</ctx_obj_attributes>
+		<ctx_obj_targets>
+			<target>
+				<target_url>http://answers.library.newschool.edu/</target_url>
+			</target>
+		</ctx_obj_targets>
+	</ctx_obj>
+</ctx_obj_set>`
+
 const dummyBadXMLResponse = `
 <ctx_obj_set`
 
@@ -85,6 +115,44 @@ func TestNewSFXResponse(t *testing.T) {
 	}
 }
 
+func TestContextObjectAttributes(t *testing.T) {
+	testCases := []struct {
+		name          string
+		httpResponse  *http.Response
+		expected      url.Values
+		expectedError error
+	}{
+		{
+			"SFX response with context object attributes",
+			makeFakeHTTPResponse(dummyXMLResponseWithContextObjectAttributes),
+			url.Values{
+				"rft.issn":   {"0028-792X"},
+				"rft.aulast": {"Ross"},
+			},
+			nil,
+		},
+		{
+			"SFX response without context object attributes",
+			makeFakeHTTPResponse(dummyGoodXMLResponse),
+			url.Values{},
+			nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			sfxResponse := makeFakeSFXResponse(testCase.httpResponse)
+			got, err := sfxResponse.ContextObjectAttributes()
+			if err != nil && testCase.expectedError == nil {
+				t.Errorf("ContextObjectAttributes returned error '%v', expecting no errors", err)
+			}
+			if !reflect.DeepEqual(got, testCase.expected) {
+				t.Errorf("ContextObjectAttributes returned '%v', expecting '%v'", got, testCase.expected)
+			}
+		})
+	}
+}
+
 func TestRemoveTarget(t *testing.T) {
 	targetURLsToRemove := []string{
 		"http://library.nyu.edu/ask/",
@@ -3,7 +3,9 @@ package testutils
 import (
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -15,6 +17,9 @@ import (
 //go:embed testdata/test-cases.json
 var TestCasesJSON []byte
 
+// A Primo search response with no docs.
+const PrimoFakeResponseNoResults = `{"docs": []}`
+
 type TestCase struct {
 	// Identifier used for fixture and golden file basename.
 	Key string
@@ -65,8 +70,15 @@ func GetLogOutputGoldenValue(testCase TestCase, level string) (string, error) {
 	return GetTestdataFileContents(LogOutputGoldenFile(testCase, level))
 }
 
-func GetPrimoFakeResponseISBNSearch(testCase TestCase) (string, error) {
-	return GetTestdataFileContents(primoFakeResponseFileISBNSearch(testCase))
+// Primo is searched for every test case, but only some test cases have Primo
+// fixtures.  The others get a response with no docs.
+func GetPrimoFakeResponseSearch(testCase TestCase) (string, error) {
+	fakeResponse, err := GetTestdataFileContents(primoFakeResponseFileSearch(testCase))
+	if errors.Is(err, fs.ErrNotExist) {
+		return PrimoFakeResponseNoResults, nil
+	}
+
+	return fakeResponse, err
 }
 
 func GetPrimoFakeResponseFRBRMemberSearch(testCase TestCase) (string, error) {
@@ -127,7 +139,7 @@ func primoFakeResponseFileFRBRMemberSearch(testCase TestCase) string {
 	return testutilsPath + "/testdata/fixtures/primo-fake-responses/frbr-member-search-data/" + testCase.Key + ".json"
 }
 
-func primoFakeResponseFileISBNSearch(testCase TestCase) string {
+func primoFakeResponseFileSearch(testCase TestCase) string {
 	return testutilsPath + "/testdata/fixtures/primo-fake-responses/" + testCase.Key + ".json"
 }
 
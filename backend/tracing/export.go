@@ -0,0 +1,284 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default endpoint of a local OpenTelemetry collector's OTLP/HTTP receiver.
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+// How often the OTLP exporter sends buffered spans.
+const DefaultOTLPExportInterval = 5 * time.Second
+
+// Buffered spans beyond this are dropped rather than using unbounded memory
+// while the collector is unreachable.
+const maxBufferedSpans = 2048
+
+// Writes each span as a line of OTLP JSON, i.e. an ExportTraceServiceRequest
+// containing the single span.  Intended for stdout and tests.
+type WriterExporter struct {
+	mutex       sync.Mutex
+	serviceName string
+	w           io.Writer
+}
+
+// Sends spans in batches to an OTLP/HTTP endpoint using the JSON encoding.
+// Export errors are reported to `onError`, if set.
+type OTLPExporter struct {
+	done        chan struct{}
+	endpoint    string
+	httpClient  *http.Client
+	mutex       sync.Mutex
+	onError     func(err error)
+	serviceName string
+	spans       []SpanData
+	stopped     chan struct{}
+}
+
+type OTLPExporterOptions struct {
+	// Defaults to `DefaultOTLPEndpoint`.
+	Endpoint string
+	// Defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+	// Defaults to `DefaultOTLPExportInterval`.
+	Interval time.Duration
+	// Called with errors sending spans.  Optional.
+	OnError func(err error)
+}
+
+func NewWriterExporter(w io.Writer, serviceName string) *WriterExporter {
+	return &WriterExporter{serviceName: serviceName, w: w}
+}
+
+func (exporter *WriterExporter) ExportSpan(span SpanData) {
+	requestJSON, err := json.Marshal(newOTLPRequest(exporter.serviceName, []SpanData{span}))
+	if err != nil {
+		return
+	}
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	exporter.w.Write(append(requestJSON, '\n'))
+}
+
+func (exporter *WriterExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Starts a goroutine that sends buffered spans every `options.Interval`, until
+// `Shutdown` is called.
+func NewOTLPExporter(serviceName string, options OTLPExporterOptions) *OTLPExporter {
+	exporter := &OTLPExporter{
+		done:        make(chan struct{}),
+		endpoint:    options.Endpoint,
+		httpClient:  options.HTTPClient,
+		onError:     options.OnError,
+		serviceName: serviceName,
+		stopped:     make(chan struct{}),
+	}
+	if exporter.endpoint == "" {
+		exporter.endpoint = DefaultOTLPEndpoint
+	}
+	if exporter.httpClient == nil {
+		exporter.httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	interval := options.Interval
+	if interval == 0 {
+		interval = DefaultOTLPExportInterval
+	}
+
+	go func() {
+		defer close(exporter.stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-exporter.done:
+				return
+			case <-ticker.C:
+				exporter.flush(context.Background())
+			}
+		}
+	}()
+
+	return exporter
+}
+
+func (exporter *OTLPExporter) ExportSpan(span SpanData) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	if len(exporter.spans) < maxBufferedSpans {
+		exporter.spans = append(exporter.spans, span)
+	}
+}
+
+// Stops the background goroutine and sends any buffered spans.
+func (exporter *OTLPExporter) Shutdown(ctx context.Context) error {
+	close(exporter.done)
+	<-exporter.stopped
+
+	return exporter.flush(ctx)
+}
+
+func (exporter *OTLPExporter) flush(ctx context.Context) error {
+	exporter.mutex.Lock()
+	spans := exporter.spans
+	exporter.spans = nil
+	exporter.mutex.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	err := exporter.send(ctx, spans)
+	if err != nil && exporter.onError != nil {
+		exporter.onError(err)
+	}
+
+	return err
+}
+
+func (exporter *OTLPExporter) send(ctx context.Context, spans []SpanData) error {
+	requestJSON, err := json.Marshal(newOTLPRequest(exporter.serviceName, spans))
+	if err != nil {
+		return fmt.Errorf("Could not marshal spans: %v", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, exporter.endpoint, bytes.NewReader(requestJSON))
+	if err != nil {
+		return fmt.Errorf("Could not create OTLP request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := exporter.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("Could not export %d spans to %s: %v", len(spans), exporter.endpoint, err)
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Could not export %d spans to %s: HTTP status %s", len(spans), exporter.endpoint, response.Status)
+	}
+
+	return nil
+}
+
+// The OTLP JSON encoding of ExportTraceServiceRequest.  See
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/docs/specification.md#json-protobuf-encoding
+// IDs are hex rather than base64, and 64-bit integers are strings.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	StringValue *string `json:"stringValue,omitempty"`
+}
+
+func newOTLPRequest(serviceName string, spans []SpanData) otlpRequest {
+	otlpSpans := []otlpSpan{}
+	for _, span := range spans {
+		otlpSpan := otlpSpan{
+			TraceID:           span.SpanContext.TraceID.String(),
+			SpanID:            span.SpanContext.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Status:            otlpStatus{span.StatusCode, span.StatusMessage},
+		}
+		if span.ParentSpanID.IsValid() {
+			otlpSpan.ParentSpanID = span.ParentSpanID.String()
+		}
+		for _, attribute := range span.Attributes {
+			otlpSpan.Attributes = append(otlpSpan.Attributes, newOTLPAttribute(attribute.Key, attribute.Value))
+		}
+		otlpSpans = append(otlpSpans, otlpSpan)
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{newOTLPAttribute("service.name", serviceName)},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "ariadne/tracing"},
+				Spans: otlpSpans,
+			}},
+		}},
+	}
+}
+
+func newOTLPAttribute(key string, value any) otlpAttribute {
+	var anyValue otlpAnyValue
+	switch typedValue := value.(type) {
+	case bool:
+		anyValue.BoolValue = &typedValue
+	case int:
+		intValue := strconv.Itoa(typedValue)
+		anyValue.IntValue = &intValue
+	case int64:
+		intValue := strconv.FormatInt(typedValue, 10)
+		anyValue.IntValue = &intValue
+	case string:
+		anyValue.StringValue = &typedValue
+	default:
+		stringValue := fmt.Sprint(typedValue)
+		anyValue.StringValue = &stringValue
+	}
+
+	return otlpAttribute{Key: key, Value: anyValue}
+}
@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Header used to propagate trace context.  See https://www.w3.org/TR/trace-context/
+const TraceparentHeader = "traceparent"
+
+const traceparentVersion = "00"
+const sampledFlag = 0x01
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (traceID TraceID) String() string {
+	return hex.EncodeToString(traceID[:])
+}
+
+func (traceID TraceID) IsValid() bool {
+	return traceID != TraceID{}
+}
+
+func (spanID SpanID) String() string {
+	return hex.EncodeToString(spanID[:])
+}
+
+func (spanID SpanID) IsValid() bool {
+	return spanID != SpanID{}
+}
+
+// Identifies a span, and whether its trace is being recorded.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (spanContext SpanContext) IsValid() bool {
+	return spanContext.TraceID.IsValid() && spanContext.SpanID.IsValid()
+}
+
+// Returns the value of the `traceparent` header for the span.
+func (spanContext SpanContext) Traceparent() string {
+	flags := 0
+	if spanContext.Sampled {
+		flags = sampledFlag
+	}
+
+	return fmt.Sprintf("%s-%s-%s-%02x", traceparentVersion, spanContext.TraceID, spanContext.SpanID, flags)
+}
+
+// Parses a `traceparent` header value.  Versions other than 00 are parsed as
+// 00, as the spec requires, as long as the first four fields are well-formed.
+func ParseTraceparent(traceparent string) (SpanContext, error) {
+	fields := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(fields) < 4 || (fields[0] == traceparentVersion && len(fields) != 4) {
+		return SpanContext{}, fmt.Errorf("Invalid traceparent \"%s\": wrong number of fields", traceparent)
+	}
+	if len(fields[0]) != 2 || fields[0] == "ff" {
+		return SpanContext{}, fmt.Errorf("Invalid traceparent \"%s\": unsupported version", traceparent)
+	}
+
+	var spanContext SpanContext
+	if !decodeHexField(fields[1], spanContext.TraceID[:]) || !spanContext.TraceID.IsValid() {
+		return SpanContext{}, fmt.Errorf("Invalid traceparent \"%s\": invalid trace ID", traceparent)
+	}
+	if !decodeHexField(fields[2], spanContext.SpanID[:]) || !spanContext.SpanID.IsValid() {
+		return SpanContext{}, fmt.Errorf("Invalid traceparent \"%s\": invalid parent ID", traceparent)
+	}
+	var flags [1]byte
+	if !decodeHexField(fields[3], flags[:]) {
+		return SpanContext{}, fmt.Errorf("Invalid traceparent \"%s\": invalid flags", traceparent)
+	}
+	spanContext.Sampled = flags[0]&sampledFlag != 0
+
+	return spanContext, nil
+}
+
+// Only lowercase hex is valid in `traceparent`.
+func decodeHexField(field string, destination []byte) bool {
+	if len(field) != hex.EncodedLen(len(destination)) || strings.ToLower(field) != field {
+		return false
+	}
+	_, err := hex.Decode(destination, []byte(field))
+
+	return err == nil
+}
+
+// Returns the span context in the `traceparent` header, if it's present and valid.
+func Extract(header http.Header) (SpanContext, bool) {
+	spanContext, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+
+	return spanContext, true
+}
+
+// Sets the `traceparent` header to the span context of the span in `ctx`, if
+// there is one, so that the upstream's spans are part of the same trace.
+func Inject(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	header.Set(TraceparentHeader, span.SpanContext().Traceparent())
+}
@@ -0,0 +1,223 @@
+// Package tracing records trace spans compatible with OpenTelemetry, and
+// exports them with OTLP.  It implements only what we need: spans carried in a
+// `context.Context`, W3C trace context propagation, and OTLP/HTTP JSON export.
+//
+// Code that makes upstream requests calls `StartSpan`, which creates a child of
+// the span in the context, if there is one, and does nothing otherwise -- so
+// packages like sfx and primo don't need to know whether tracing is enabled.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type SpanKind int
+
+// Values from the OTLP protobuf definition.
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+type StatusCode int
+
+// Values from the OTLP protobuf definition.
+const (
+	StatusCodeUnset StatusCode = 0
+	StatusCodeOK    StatusCode = 1
+	StatusCodeError StatusCode = 2
+)
+
+// Creates root spans, and exports finished spans.
+type Tracer struct {
+	exporter Exporter
+}
+
+// Receives finished spans.  Implementations must be safe for concurrent use.
+type Exporter interface {
+	ExportSpan(span SpanData)
+	// Exports any buffered spans.
+	Shutdown(ctx context.Context) error
+}
+
+// A span in progress.  All methods are safe to call on a nil *Span, which is
+// what `StartSpan` returns when tracing is disabled.
+type Span struct {
+	mutex  sync.Mutex
+	data   SpanData
+	ended  bool
+	tracer *Tracer
+}
+
+type Attribute struct {
+	Key string
+	// string, bool, int, or int64
+	Value any
+}
+
+// A finished span, as exported.
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    []Attribute
+	StatusCode    StatusCode
+	StatusMessage string
+}
+
+type spanContextKey struct{}
+
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Starts a span which is a child of `parent` if it's valid, e.g. a span
+// context extracted from an incoming request, or else the root of a new trace.
+// A nil *Tracer starts no spans.
+func (tracer *Tracer) StartSpan(ctx context.Context, name string, kind SpanKind, parent SpanContext) (context.Context, *Span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	spanContext := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		spanContext.TraceID = parent.TraceID
+		spanContext.Sampled = parent.Sampled
+	} else {
+		spanContext.TraceID = newTraceID()
+		spanContext.Sampled = true
+	}
+
+	span := &Span{
+		data: SpanData{
+			Name:         name,
+			Kind:         kind,
+			SpanContext:  spanContext,
+			ParentSpanID: parent.SpanID,
+			StartTime:    time.Now(),
+		},
+		tracer: tracer,
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// Exports any buffered spans.
+func (tracer *Tracer) Shutdown(ctx context.Context) error {
+	if tracer == nil {
+		return nil
+	}
+
+	return tracer.exporter.Shutdown(ctx)
+}
+
+// Starts a child of the span in `ctx`.  If there isn't one, no span is started,
+// and the returned nil *Span can be used as if it were.
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	return parent.tracer.StartSpan(ctx, name, kind, parent.SpanContext())
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+
+	return span
+}
+
+func (span *Span) SpanContext() SpanContext {
+	if span == nil {
+		return SpanContext{}
+	}
+
+	return span.data.SpanContext
+}
+
+// `value` should be a string, bool, int, or int64.  Attributes set after the
+// span ends are ignored.
+func (span *Span) SetAttribute(key string, value any) {
+	if span == nil {
+		return
+	}
+
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	if !span.ended {
+		span.data.Attributes = append(span.data.Attributes, Attribute{key, value})
+	}
+}
+
+// Sets the span's status to error, unless `err` is nil.
+func (span *Span) RecordError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+
+	if !span.ended {
+		span.data.StatusCode = StatusCodeError
+		span.data.StatusMessage = err.Error()
+	}
+}
+
+// Ends the span and exports it, if its trace is sampled.  Calls after the first
+// are ignored.
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+
+	span.mutex.Lock()
+	if span.ended {
+		span.mutex.Unlock()
+		return
+	}
+	span.ended = true
+	span.data.EndTime = time.Now()
+	data := span.data
+	span.mutex.Unlock()
+
+	if data.SpanContext.Sampled {
+		span.tracer.exporter.ExportSpan(data)
+	}
+}
+
+func newTraceID() TraceID {
+	var traceID TraceID
+	for !traceID.IsValid() {
+		readRandom(traceID[:])
+	}
+
+	return traceID
+}
+
+func newSpanID() SpanID {
+	var spanID SpanID
+	for !spanID.IsValid() {
+		readRandom(spanID[:])
+	}
+
+	return spanID
+}
+
+func readRandom(destination []byte) {
+	_, err := rand.Read(destination)
+	if err != nil {
+		// crypto/rand doesn't fail on the platforms we run on.
+		panic(fmt.Sprintf("tracing: could not generate ID: %v", err))
+	}
+}
@@ -0,0 +1,222 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	testCases := []struct {
+		name            string
+		traceparent     string
+		expectedSampled bool
+		expectedError   bool
+	}{
+		{"Sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, false},
+		{"Not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false, false},
+		{"Future version with extra field", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, false},
+		{"Empty", "", false, true},
+		{"Version ff", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, true},
+		{"Version 00 with extra field", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, true},
+		{"Uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, true},
+		{"All-zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, true},
+		{"All-zero parent ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, true},
+		{"Short parent ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-01", false, true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			spanContext, err := ParseTraceparent(testCase.traceparent)
+			if testCase.expectedError {
+				if err == nil {
+					t.Errorf("ParseTraceparent returned %+v, expecting error", spanContext)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTraceparent returned error: %s", err)
+			}
+
+			if spanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+				spanContext.SpanID.String() != "00f067aa0ba902b7" ||
+				spanContext.Sampled != testCase.expectedSampled {
+				t.Errorf("Unexpected span context %s", spanContext.Traceparent())
+			}
+		})
+	}
+}
+
+func TestSpans(t *testing.T) {
+	var buffer bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&buffer, "test"))
+
+	parent, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatalf("ParseTraceparent returned error: %s", err)
+	}
+
+	ctx, serverSpan := tracer.StartSpan(context.Background(), "server", SpanKindServer, parent)
+	childCtx, clientSpan := StartSpan(ctx, "client", SpanKindClient)
+	clientSpan.SetAttribute("http.response.status_code", 502)
+	clientSpan.RecordError(errors.New("Bad gateway"))
+
+	header := http.Header{}
+	Inject(childCtx, header)
+	if header.Get(TraceparentHeader) != clientSpan.SpanContext().Traceparent() {
+		t.Errorf("Expected injected traceparent %s, got %s",
+			clientSpan.SpanContext().Traceparent(), header.Get(TraceparentHeader))
+	}
+
+	clientSpan.End()
+	serverSpan.End()
+	// Ignored
+	serverSpan.End()
+
+	spans := decodeSpans(t, &buffer)
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	clientSpanJSON, serverSpanJSON := spans[0], spans[1]
+	if serverSpanJSON.TraceID != parent.TraceID.String() || serverSpanJSON.ParentSpanID != parent.SpanID.String() {
+		t.Errorf("Expected server span to continue trace %s, got %+v", parent.Traceparent(), serverSpanJSON)
+	}
+	if clientSpanJSON.TraceID != parent.TraceID.String() || clientSpanJSON.ParentSpanID != serverSpanJSON.SpanID {
+		t.Errorf("Expected client span to be a child of the server span, got %+v", clientSpanJSON)
+	}
+	if clientSpanJSON.Kind != SpanKindClient || clientSpanJSON.Status.Code != StatusCodeError ||
+		clientSpanJSON.Status.Message != "Bad gateway" {
+		t.Errorf("Unexpected client span kind or status: %+v", clientSpanJSON)
+	}
+	if len(clientSpanJSON.Attributes) != 1 || *clientSpanJSON.Attributes[0].Value.IntValue != "502" {
+		t.Errorf("Unexpected client span attributes: %+v", clientSpanJSON.Attributes)
+	}
+}
+
+func TestNoSpans(t *testing.T) {
+	var tracer *Tracer
+	ctx, span := tracer.StartSpan(context.Background(), "server", SpanKindServer, SpanContext{})
+	if span != nil {
+		t.Errorf("Nil tracer started span %+v", span)
+	}
+
+	ctx, span = StartSpan(ctx, "client", SpanKindClient)
+	if span != nil {
+		t.Errorf("StartSpan with no parent started span %+v", span)
+	}
+	span.SetAttribute("key", "value")
+	span.RecordError(errors.New("Error"))
+	span.End()
+
+	header := http.Header{}
+	Inject(ctx, header)
+	if header.Get(TraceparentHeader) != "" {
+		t.Errorf("Expected no traceparent, got %s", header.Get(TraceparentHeader))
+	}
+}
+
+func TestNotSampled(t *testing.T) {
+	var buffer bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&buffer, "test"))
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, serverSpan := tracer.StartSpan(context.Background(), "server", SpanKindServer, parent)
+
+	// Not exported, but still propagated.
+	header := http.Header{}
+	Inject(ctx, header)
+	serverSpan.End()
+
+	if buffer.Len() != 0 {
+		t.Errorf("Expected no exported spans, got %s", buffer.String())
+	}
+	if header.Get(TraceparentHeader) != serverSpan.SpanContext().Traceparent() {
+		t.Errorf("Expected injected traceparent %s, got %s",
+			serverSpan.SpanContext().Traceparent(), header.Get(TraceparentHeader))
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var numSpans int32
+	var contentType atomic.Value
+	fakeCollector := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contentType.Store(r.Header.Get("Content-Type"))
+			body, _ := io.ReadAll(r.Body)
+			var request otlpRequest
+			err := json.Unmarshal(body, &request)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			atomic.AddInt32(&numSpans, int32(len(request.ResourceSpans[0].ScopeSpans[0].Spans)))
+		}),
+	)
+	defer fakeCollector.Close()
+
+	exporter := NewOTLPExporter("test", OTLPExporterOptions{Endpoint: fakeCollector.URL, Interval: time.Hour})
+	tracer := NewTracer(exporter)
+	for i := 0; i < 3; i++ {
+		_, span := tracer.StartSpan(context.Background(), "server", SpanKindServer, SpanContext{})
+		span.End()
+	}
+
+	err := tracer.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("Shutdown returned error: %s", err)
+	}
+	if numSpans != 3 || contentType.Load() != "application/json" {
+		t.Errorf("Expected 3 spans sent as application/json, got %d sent as %v", numSpans, contentType.Load())
+	}
+}
+
+func TestOTLPExporterError(t *testing.T) {
+	fakeCollector := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}),
+	)
+	defer fakeCollector.Close()
+
+	var onErrorCalled bool
+	exporter := NewOTLPExporter("test", OTLPExporterOptions{
+		Endpoint: fakeCollector.URL,
+		Interval: time.Hour,
+		OnError:  func(err error) { onErrorCalled = true },
+	})
+	_, span := NewTracer(exporter).StartSpan(context.Background(), "server", SpanKindServer, SpanContext{})
+	span.End()
+
+	err := exporter.Shutdown(context.Background())
+	if err == nil || !onErrorCalled {
+		t.Errorf("Expected Shutdown to return and report an error, got '%v'", err)
+	}
+}
+
+func decodeSpans(t *testing.T, reader io.Reader) []otlpSpan {
+	t.Helper()
+
+	spans := []otlpSpan{}
+	decoder := json.NewDecoder(reader)
+	for decoder.More() {
+		var request otlpRequest
+		err := decoder.Decode(&request)
+		if err != nil {
+			t.Fatalf("Could not decode exported span: %s", err)
+		}
+		resourceAttribute := request.ResourceSpans[0].Resource.Attributes[0]
+		if resourceAttribute.Key != "service.name" || *resourceAttribute.Value.StringValue != "test" {
+			t.Errorf("Unexpected resource attribute %+v", resourceAttribute)
+		}
+		spans = append(spans, request.ResourceSpans[0].ScopeSpans[0].Spans...)
+	}
+
+	return spans
+}
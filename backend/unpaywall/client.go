@@ -0,0 +1,124 @@
+package unpaywall
+
+import (
+	"ariadne/log"
+	"ariadne/resilience"
+	"ariadne/tracing"
+	"ariadne/upstream"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Unpaywall REST API.  Open-access locations for a DOI are at <url>/<DOI>.
+const DefaultUnpaywallURL = "https://api.unpaywall.org/v2"
+
+// Default timeout for each individual HTTP request made to Unpaywall.
+const DefaultTimeout = 5 * time.Second
+
+const messageKey = "message"
+
+// Called after each HTTP request to Unpaywall completes, e.g. to record metrics.
+// `err` is non-nil if the request failed, returned a non-2xx status, or could not
+// be parsed.
+type RequestObserver func(duration time.Duration, err error)
+
+type ClientOptions struct {
+	// Defaults to an HTTP client returned by `NewHTTPClient(DefaultTimeout, nil)`
+	// which is shared by all clients that don't set one.
+	HTTPClient *http.Client
+	// Defaults to `log.Default()`.
+	Logger *log.Logger
+	// Optional
+	RequestObserver RequestObserver
+}
+
+// Looks up open-access copies of articles in the Unpaywall API, or any service
+// with the same API, like a local fake in tests.
+type Client struct {
+	url             string
+	email           string
+	httpClient      *http.Client
+	logger          *log.Logger
+	requestObserver RequestObserver
+}
+
+var defaultHTTPClient = NewHTTPClient(DefaultTimeout, nil)
+
+// `email` is required by Unpaywall, which uses it to contact heavy users.
+func NewClient(url string, email string, options ClientOptions) *Client {
+	client := &Client{
+		url:             strings.TrimRight(url, "/"),
+		email:           email,
+		httpClient:      options.HTTPClient,
+		logger:          options.Logger,
+		requestObserver: options.RequestObserver,
+	}
+	if client.httpClient == nil {
+		client.httpClient = defaultHTTPClient
+	}
+	if client.logger == nil {
+		client.logger = log.Default()
+	}
+
+	return client
+}
+
+// Returns the circuit breaker of the client's HTTP client, or nil if it doesn't
+// have one.
+func (client *Client) CircuitBreaker() *resilience.CircuitBreaker {
+	return upstream.CircuitBreaker(client.httpClient)
+}
+
+// Returns the open-access status and locations for `doi`.  Returns an error for
+// which `upstream.IsNotFound` is true if Unpaywall doesn't know the DOI.  The
+// request is made in its own trace span, which is propagated to Unpaywall, and
+// is cancelled if `ctx` is done before it completes.
+func (client *Client) Lookup(ctx context.Context, doi string) (*Work, error) {
+	start := time.Now()
+	work, err := client.lookup(ctx, doi)
+	if client.requestObserver != nil {
+		client.requestObserver(time.Since(start), err)
+	}
+	if err != nil {
+		client.logger.Debug(messageKey,
+			fmt.Sprintf("Unpaywall request for DOI %s failed after %s: %v", doi, time.Since(start), err))
+	}
+
+	return work, err
+}
+
+func (client *Client) URL() string {
+	return client.url
+}
+
+func (client *Client) lookup(ctx context.Context, doi string) (work *Work, err error) {
+	ctx, span := tracing.StartSpan(ctx, "Unpaywall lookup", tracing.SpanKindClient)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	work = &Work{}
+	err = upstream.GetJSON(ctx, client.httpClient, "Unpaywall", client.queryURL(doi), work)
+	if err != nil {
+		return nil, err
+	}
+
+	return work, nil
+}
+
+// DOIs can contain characters like "#" and ";", so the DOI is escaped as a
+// single path segment.
+func (client *Client) queryURL(doi string) string {
+	return client.url + "/" + url.PathEscape(doi) + "?" + url.Values{"email": {client.email}}.Encode()
+}
+
+// Returns an HTTP client suitable for use in `ClientOptions`.  See
+// `upstream.NewHTTPClient`.
+func NewHTTPClient(timeout time.Duration, logger *log.Logger) *http.Client {
+	return upstream.NewHTTPClient("Unpaywall", timeout, logger)
+}
@@ -0,0 +1,86 @@
+package unpaywall
+
+import (
+	"ariadne/upstream"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const testWorkJSON = `{
+  "doi": "10.1038/nature12373",
+  "is_oa": true,
+  "oa_locations": [
+    {
+      "host_type": "publisher",
+      "license": "cc-by",
+      "repository_institution": null,
+      "url": "https://www.nature.com/articles/nature12373.pdf",
+      "version": "publishedVersion"
+    },
+    {
+      "host_type": "repository",
+      "license": null,
+      "repository_institution": "Europe PMC",
+      "url": "https://europepmc.org/articles/pmc4221854?pdf=render",
+      "version": "acceptedVersion"
+    }
+  ]
+}`
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	requestedURIs := make(chan string, 3)
+	fakeUnpaywallServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestedURIs <- r.URL.RequestURI()
+			switch r.URL.Path {
+			case "/v2/10.1038/nature12373":
+				fmt.Fprint(w, testWorkJSON)
+			case "/v2/10.1000/invalid-json":
+				fmt.Fprint(w, "<html>")
+			default:
+				http.Error(w, `{"error": true, "message": "not found"}`, http.StatusNotFound)
+			}
+		}),
+	)
+	defer fakeUnpaywallServer.Close()
+
+	client := NewClient(fakeUnpaywallServer.URL+"/v2/", "lib-appdev@nyu.edu", ClientOptions{})
+
+	work, err := client.Lookup(context.Background(), "10.1038/nature12373")
+	if err != nil {
+		t.Fatalf("Lookup returned error: %s", err)
+	}
+	expected := &Work{
+		DOI:  "10.1038/nature12373",
+		IsOA: true,
+		OALocations: []Location{
+			{HostTypePublisher, "cc-by", "", "https://www.nature.com/articles/nature12373.pdf", VersionPublished},
+			{HostTypeRepository, "", "Europe PMC", "https://europepmc.org/articles/pmc4221854?pdf=render", VersionAccepted},
+		},
+	}
+	if !reflect.DeepEqual(work, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, work)
+	}
+	expectedURI := "/v2/10.1038%2Fnature12373?email=lib-appdev%40nyu.edu"
+	if requestedURI := <-requestedURIs; requestedURI != expectedURI {
+		t.Errorf("Expected request for %s, got %s", expectedURI, requestedURI)
+	}
+
+	_, err = client.Lookup(context.Background(), "10.1000/unknown")
+	if !upstream.IsNotFound(err) {
+		t.Errorf("Expected not found error for unknown DOI, got %v", err)
+	}
+
+	_, err = client.Lookup(context.Background(), "10.1000/invalid-json")
+	var unpaywallError *upstream.Error
+	if !errors.As(err, &unpaywallError) || unpaywallError.Kind != upstream.ErrorKindParse {
+		t.Errorf("Expected parse error, got %v", err)
+	}
+}
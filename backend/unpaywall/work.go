@@ -0,0 +1,63 @@
+package unpaywall
+
+// Values of `Location.HostType`
+const (
+	HostTypePublisher  = "publisher"
+	HostTypeRepository = "repository"
+)
+
+// Values of `Location.Version`, from least to most authoritative
+const (
+	VersionSubmitted = "submittedVersion"
+	VersionAccepted  = "acceptedVersion"
+	VersionPublished = "publishedVersion"
+)
+
+// The open-access status of a single DOI.  Only the fields used for links are
+// mapped.  See https://unpaywall.org/data-format.  Example:
+//
+//	https://api.unpaywall.org/v2/10.1038/nature12373?email=...
+type Work struct {
+	DOI  string `json:"doi"`
+	IsOA bool   `json:"is_oa"`
+	// Best first, as ranked by Unpaywall: publisher copies before repository
+	// copies, and published versions before accepted and submitted versions.
+	OALocations []Location `json:"oa_locations"`
+}
+
+// A place where a free, legal copy of the work can be read.
+type Location struct {
+	// "publisher" or "repository"
+	HostType string `json:"host_type"`
+	// E.g. "cc-by", "cc-by-nc", or "publisher-specific-oa".  Empty if unknown.
+	License string `json:"license"`
+	// Name of the repository, for repository copies.  Often empty.
+	RepositoryInstitution string `json:"repository_institution"`
+	// The PDF URL if there is one, otherwise the landing page URL
+	URL string `json:"url"`
+	// "publishedVersion", "acceptedVersion", or "submittedVersion"
+	Version string `json:"version"`
+}
+
+// Returns the open-access locations, best first, without locations that have no
+// URL or the same URL as a better location.
+func (work *Work) Locations() []Location {
+	locations := []Location{}
+	if !work.IsOA {
+		return locations
+	}
+
+	seenURLs := map[string]struct{}{}
+	for _, location := range work.OALocations {
+		if location.URL == "" {
+			continue
+		}
+		if _, ok := seenURLs[location.URL]; ok {
+			continue
+		}
+		seenURLs[location.URL] = struct{}{}
+		locations = append(locations, location)
+	}
+
+	return locations
+}
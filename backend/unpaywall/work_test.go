@@ -0,0 +1,42 @@
+package unpaywall
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLocations(t *testing.T) {
+	publisherLocation := Location{HostType: HostTypePublisher, URL: "https://example.com/article.pdf", Version: VersionPublished}
+	repositoryLocation := Location{HostType: HostTypeRepository, URL: "https://arxiv.org/abs/1234.5678", Version: VersionSubmitted}
+
+	testCases := []struct {
+		name     string
+		work     Work
+		expected []Location
+	}{
+		{
+			"Deduped by URL",
+			Work{IsOA: true, OALocations: []Location{
+				publisherLocation,
+				{HostType: HostTypeRepository, URL: publisherLocation.URL, Version: VersionAccepted},
+				repositoryLocation,
+			}},
+			[]Location{publisherLocation, repositoryLocation},
+		},
+		{
+			"No URL",
+			Work{IsOA: true, OALocations: []Location{{HostType: HostTypeRepository}, repositoryLocation}},
+			[]Location{repositoryLocation},
+		},
+		{"Not open access", Work{IsOA: false, OALocations: []Location{publisherLocation}}, []Location{}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			actual := testCase.work.Locations()
+			if !reflect.DeepEqual(actual, testCase.expected) {
+				t.Errorf("Expected %+v, got %+v", testCase.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package upstream
+
+import (
+	"ariadne/log"
+	"ariadne/resilience"
+	"ariadne/tracing"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Returns an HTTP client for the upstream named `name`.  Failed requests are
+// retried with backoff, and are short-circuited while the returned client's
+// circuit breaker is open.  Upstream clients which share the returned client
+// also share its circuit breaker.  Note that `timeout` covers all attempts.  The
+// circuit breaker logs its state changes to `logger`, which defaults to
+// `log.Default()` if nil.
+func NewHTTPClient(name string, timeout time.Duration, logger *log.Logger) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: resilience.NewTransport(
+			http.DefaultTransport, resilience.NewCircuitBreaker(name, logger), resilience.DefaultRetryPolicy),
+	}
+}
+
+// Returns the circuit breaker of `httpClient`, or nil if it doesn't have one.
+func CircuitBreaker(httpClient *http.Client) *resilience.CircuitBreaker {
+	transport, ok := httpClient.Transport.(*resilience.Transport)
+	if !ok {
+		return nil
+	}
+
+	return transport.CircuitBreaker
+}
+
+// Gets `url` from the upstream named `name` and decodes the JSON response body
+// into `result`.  A 404 response is an ErrorKindNotFound error, since the JSON
+// APIs that we use respond 404 for identifiers that they don't know.  The
+// request is propagated with and recorded on the trace span in `ctx`, if any,
+// and is cancelled if `ctx` is done before it completes.
+func GetJSON(ctx context.Context, httpClient *http.Client, name string, url string, result any) error {
+	httpRequest, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("Could not initialize request to %s server: %v", name, err)
+	}
+	httpRequest.Header.Set("Accept", "application/json")
+
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttribute("server.address", httpRequest.URL.Host)
+	tracing.Inject(ctx, httpRequest.Header)
+
+	httpResponse, err := httpClient.Do(httpRequest)
+	if err != nil {
+		return NewRequestError(err, "Could not do request to "+name+" server: %w")
+	}
+	defer httpResponse.Body.Close()
+
+	span.SetAttribute("http.response.status_code", httpResponse.StatusCode)
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		statusError := NewStatusError(name, httpResponse.StatusCode, httpResponse.Status)
+		if httpResponse.StatusCode == http.StatusNotFound {
+			statusError.Kind = ErrorKindNotFound
+		}
+		return statusError
+	}
+
+	body, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return NewRequestError(err, "Could not read response from "+name+" server: %w")
+	}
+
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		return &Error{Kind: ErrorKindParse, Err: fmt.Errorf("Could not parse %s response: %v", name, err)}
+	}
+
+	return nil
+}
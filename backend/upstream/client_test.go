@@ -0,0 +1,98 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetJSON(t *testing.T) {
+	t.Parallel()
+
+	fakeServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/found":
+				if r.Header.Get("Accept") != "application/json" {
+					http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+					return
+				}
+				fmt.Fprint(w, `{"doi": "10.1000/1"}`)
+			case "/invalid-json":
+				fmt.Fprint(w, "<html>")
+			case "/error":
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			default:
+				http.Error(w, "Resource not found.", http.StatusNotFound)
+			}
+		}),
+	)
+	defer fakeServer.Close()
+
+	testCases := []struct {
+		name               string
+		path               string
+		expectedKind       ErrorKind
+		expectedStatusCode int
+	}{
+		{"Found", "/found", "", 0},
+		{"Not found", "/unknown", ErrorKindNotFound, http.StatusNotFound},
+		{"Upstream status", "/error", ErrorKindUpstreamStatus, http.StatusInternalServerError},
+		{"Invalid JSON", "/invalid-json", ErrorKindParse, 0},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var result struct {
+				DOI string `json:"doi"`
+			}
+			err := GetJSON(context.Background(), http.DefaultClient, "Test", fakeServer.URL+testCase.path, &result)
+			if testCase.expectedKind == "" {
+				if err != nil {
+					t.Fatalf("GetJSON returned error: %s", err)
+				}
+				if result.DOI != "10.1000/1" {
+					t.Errorf("Expected DOI \"10.1000/1\", got \"%s\"", result.DOI)
+				}
+				return
+			}
+
+			var upstreamError *Error
+			if !errors.As(err, &upstreamError) {
+				t.Fatalf("GetJSON returned error '%v', expecting an *Error", err)
+			}
+			if upstreamError.Kind != testCase.expectedKind {
+				t.Errorf("Expected error kind '%s', got '%s'", testCase.expectedKind, upstreamError.Kind)
+			}
+			if upstreamError.StatusCode != testCase.expectedStatusCode {
+				t.Errorf("Expected status code %d, got %d", testCase.expectedStatusCode, upstreamError.StatusCode)
+			}
+			if IsNotFound(err) != (testCase.expectedKind == ErrorKindNotFound) {
+				t.Errorf("IsNotFound returned %t for error kind '%s'", IsNotFound(err), upstreamError.Kind)
+			}
+		})
+	}
+}
+
+func TestGetJSONTimeout(t *testing.T) {
+	t.Parallel()
+
+	// The fake never responds, so requests can only complete by timing out.
+	fakeServer := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}),
+	)
+	defer fakeServer.Close()
+
+	var result any
+	err := GetJSON(context.Background(), NewHTTPClient("Test", 50*time.Millisecond, nil), "Test", fakeServer.URL, &result)
+	var upstreamError *Error
+	if !errors.As(err, &upstreamError) || upstreamError.Kind != ErrorKindTimeout {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+// Package upstream has what the clients for the services Ariadne depends on --
+// SFX, Primo, Crossref, and Unpaywall -- have in common: classifying their
+// errors, building their resilient HTTP clients, and making JSON GET requests.
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+type ErrorKind string
+
+const (
+	// The request could not be made: connection refused, reset, DNS failure,
+	// etc., or the circuit breaker is open.
+	ErrorKindNetwork ErrorKind = "network"
+	// The request did not complete before the deadline or client timeout.
+	ErrorKindTimeout ErrorKind = "timeout"
+	// The upstream responded with a non-2xx HTTP status.  For JSON GET requests,
+	// a status other than 404.
+	ErrorKindUpstreamStatus ErrorKind = "upstream_status"
+	// The upstream response body could not be parsed.
+	ErrorKindParse ErrorKind = "parse"
+	// The upstream doesn't know the identifier that was looked up.  Only
+	// returned for JSON GET requests, whose APIs respond 404 in that case.
+	ErrorKindNotFound ErrorKind = "not_found"
+)
+
+// Error is returned for all failures to get a usable response from an upstream.
+type Error struct {
+	Kind ErrorKind
+	// Only set for ErrorKindUpstreamStatus and ErrorKindNotFound
+	StatusCode int
+	Err        error
+}
+
+func (err *Error) Error() string {
+	return err.Err.Error()
+}
+
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// Returns whether `err` means that the upstream doesn't know the identifier that
+// was looked up.
+func IsNotFound(err error) bool {
+	var upstreamError *Error
+
+	return errors.As(err, &upstreamError) && upstreamError.Kind == ErrorKindNotFound
+}
+
+// Classifies an error returned by `http.Client.Do` or by reading a response
+// body.  `format` must have a single %w verb for `err`.
+func NewRequestError(err error, format string) *Error {
+	kind := ErrorKindNetwork
+	var netError net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netError) && netError.Timeout()) {
+		kind = ErrorKindTimeout
+	}
+
+	return &Error{Kind: kind, Err: fmt.Errorf(format, err)}
+}
+
+// `name` is the upstream's name, e.g. "SFX", and `status` is the response's
+// `Status`, e.g. "500 Internal Server Error".
+func NewStatusError(name string, statusCode int, status string) *Error {
+	return &Error{
+		Kind:       ErrorKindUpstreamStatus,
+		StatusCode: statusCode,
+		Err:        fmt.Errorf("%s server responded with HTTP status %s", name, status),
+	}
+}
@@ -1,5 +1,10 @@
 import { useState } from 'react';
 
+// Error responses from the backend have a request ID, which support staff can
+// use to find the log entries for the request.
+const requestIdText = (responseBody) =>
+  responseBody && responseBody.request_id ? ` (request ID: ${responseBody.request_id})` : '';
+
 export default (apiFunc) => {
   const [resource, setResource] = useState(null);
   const [found, setFound] = useState(null);
@@ -20,10 +25,11 @@ export default (apiFunc) => {
           setResource(arrOfLinks);
           setFound(responseBody.found);
         } else {
-          setError(`The backend API returned errors: ${responseBody.errors.map((error) => `"${error}"`).join(', ')}`);
+          setError(`The backend API returned errors: ${responseBody.errors.map((error) => `"${error.message}"`).join(', ')}${requestIdText(responseBody)}`);
         }
       } else {
-        setError(`The backend API returned an HTTP error response: ${response.status} (${response.statusText})`);
+        const responseBody = await response.json().catch(() => null);
+        setError(`The backend API returned an HTTP error response: ${response.status} (${response.statusText})${requestIdText(responseBody)}`);
       }
     } catch (error) {
       setError(`Error fetching data from the Ariadne API: ${error}`);